@@ -0,0 +1,18 @@
+// Command paystub runs the local stand-in of a payment provider for development.
+// Set PAYMENT_PROVIDER=stub and PAYMENT_STUB_URL to its address to use it.
+package main
+
+import (
+	"delivery/pkg/payment"
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8091", "address to listen on")
+	flag.Parse()
+
+	log.Printf("payment stub listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, payment.NewStubServer()))
+}
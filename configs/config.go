@@ -2,9 +2,14 @@ package configs
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	// the time zone database is built in, so Asia/Tashkent loads on hosts without tzdata
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -56,6 +61,72 @@ type Configuration struct {
 	BucketName  string
 	Credentials string
 
+	// working hours, statements and dates sent by clients are in TimeZone
+	TimeZone *time.Location
+	// delivery slots close SlotLeadTime before they start
+	SlotLeadTime time.Duration
+	// percent of items total charged when an order is cancelled late
+	CancelFeePercent int64
+	// orders not accepted by the seller within OrderAcceptTimeout are rejected
+	OrderAcceptTimeout time.Duration
+	// couriers are looked for within DispatchRadiusKm of the xozmak
+	DispatchRadiusKm float64
+	// a courier has CourierOfferTimeout to accept an offered order
+	CourierOfferTimeout time.Duration
+	// max number of active orders a courier can carry
+	CourierMaxLoad int
+	// at most BatchMaxOrders orders are offered to one courier at once. They are picked up
+	// within BatchPickupRadiusKm, go the same way within BatchMaxBearingDeg and are ready
+	// within BatchReadyWindow of each other.
+	BatchMaxOrders      int
+	BatchPickupRadiusKm float64
+	BatchMaxBearingDeg  float64
+	BatchReadyWindow    time.Duration
+	// average courier speed by vehicle type used for ETA, e.g. "foot:5,car:30"
+	VehicleSpeedsKmh map[string]float64
+	// roads are longer than the straight line between two points by RouteDetourFactor
+	RouteDetourFactor float64
+	// a courier earns CourierBasePay for every delivery and CourierPayPerKm for the route
+	CourierBasePay  int64
+	CourierPayPerKm int64
+	// a courier holding more than CourierCashLimit of collected cash gets no cash orders
+	// until it is handed over, 0 turns the limit off
+	CourierCashLimit int64
+	// PaymentProvider takes card payments: fake keeps invoices in memory, stub calls
+	// the stand-in server at PaymentStubURL
+	PaymentProvider string
+	PaymentStubURL  string
+	// the customer is sent to PaymentReturnURL after paying
+	PaymentReturnURL string
+	// orders not paid within PaymentTimeout are cancelled
+	PaymentTimeout time.Duration
+	// PushProvider sends push notifications: fake keeps them in memory, fcm sends them
+	// through Firebase with the service account key in FCMCredentialsFile
+	PushProvider       string
+	FCMCredentialsFile string
+	// MediaProvider keeps uploaded files: disk stores them under MediaDir, fake in memory.
+	// Uploads larger than MaxFileSizeInMBs are refused.
+	MediaProvider string
+	MediaDir      string
+	// the commission taken from an order when no commission rule matches it
+	DefaultCommissionPercent float64
+	// customers can tip the courier within TipWindow after the order is delivered
+	TipWindow time.Duration
+	// the responses of requests with an Idempotency-Key header are replayed for IdempotencyKeyTTL
+	IdempotencyKeyTTL time.Duration
+	// customers get CashbackPercent of the items total to their wallet when an order is delivered
+	CashbackPercent int64
+	// Payme is enabled when PaymeMerchantID is set, Payme calls the merchant
+	// endpoint with the PaymeKey of the cashbox
+	PaymeMerchantID  string
+	PaymeKey         string
+	PaymeCheckoutURL string
+	// Click is enabled when ClickServiceID is set, the callbacks are signed with ClickSecretKey
+	ClickServiceID   string
+	ClickMerchantID  string
+	ClickSecretKey   string
+	ClickCheckoutURL string
+
 	// context timeout in seconds
 
 	JWTSecretKey string
@@ -79,6 +150,35 @@ func load() *Configuration {
 	// v.SetDefault("MIDDLEWARE_ROLES_PATH", "db/models.csv")
 	// v.SetDefault("CREDENTIALS", "db/credentials.json")
 
+	v.SetDefault("TIME_ZONE", "Asia/Tashkent")
+	v.SetDefault("SLOT_LEAD_TIME", "1h")
+	v.SetDefault("CANCEL_FEE_PERCENT", 20)
+	v.SetDefault("ORDER_ACCEPT_TIMEOUT", "10m")
+	v.SetDefault("DISPATCH_RADIUS_KM", 5)
+	v.SetDefault("COURIER_OFFER_TIMEOUT", "30s")
+	v.SetDefault("COURIER_MAX_LOAD", 3)
+	v.SetDefault("BATCH_MAX_ORDERS", 3)
+	v.SetDefault("BATCH_PICKUP_RADIUS_KM", 1)
+	v.SetDefault("BATCH_MAX_BEARING_DEG", 45)
+	v.SetDefault("BATCH_READY_WINDOW", "10m")
+	v.SetDefault("VEHICLE_SPEEDS_KMH", "foot:5,bicycle:14,scooter:25,car:30")
+	v.SetDefault("ROUTE_DETOUR_FACTOR", 1.3)
+	v.SetDefault("COURIER_BASE_PAY", 8000)
+	v.SetDefault("COURIER_PAY_PER_KM", 1500)
+	v.SetDefault("COURIER_CASH_LIMIT", 1000000)
+	v.SetDefault("DEFAULT_COMMISSION_PERCENT", 10)
+	v.SetDefault("PAYMENT_PROVIDER", "stub")
+	v.SetDefault("PAYMENT_STUB_URL", "http://localhost:8091")
+	v.SetDefault("PAYMENT_TIMEOUT", "15m")
+	v.SetDefault("PUSH_PROVIDER", "fake")
+	v.SetDefault("MEDIA_PROVIDER", "disk")
+	v.SetDefault("MEDIA_DIR", "media")
+	v.SetDefault("MAX_FILE_SIZE_MB", 5)
+	v.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	v.SetDefault("TIP_WINDOW", "24h")
+	v.SetDefault("PAYME_CHECKOUT_URL", "https://checkout.paycom.uz")
+	v.SetDefault("CLICK_CHECKOUT_URL", "https://my.click.uz/services/pay")
+
 	// v.SetDefault("MEDIA_SERVICE_URL", "https://media.lavina.tech/media")
 	// v.SetDefault("MEDIA_SERVICE_SECRET", "some secret key")
 	// v.SetDefault("MEDIA_SERVICE_KEY", "key")
@@ -101,10 +201,52 @@ func load() *Configuration {
 	config.JWTSecretKey = v.GetString("JWT_SECRET_KEY")
 	config.Credentials = v.GetString("CREDENTIALS")
 
+	config.TimeZone, err = time.LoadLocation(v.GetString("TIME_ZONE"))
+	if err != nil {
+		log.Fatal("error loading TIME_ZONE: ", err)
+	}
+	config.SlotLeadTime = v.GetDuration("SLOT_LEAD_TIME")
+	config.CancelFeePercent = v.GetInt64("CANCEL_FEE_PERCENT")
+	config.OrderAcceptTimeout = v.GetDuration("ORDER_ACCEPT_TIMEOUT")
+	config.DispatchRadiusKm = v.GetFloat64("DISPATCH_RADIUS_KM")
+	config.CourierOfferTimeout = v.GetDuration("COURIER_OFFER_TIMEOUT")
+	config.CourierMaxLoad = v.GetInt("COURIER_MAX_LOAD")
+	config.BatchMaxOrders = v.GetInt("BATCH_MAX_ORDERS")
+	config.BatchPickupRadiusKm = v.GetFloat64("BATCH_PICKUP_RADIUS_KM")
+	config.BatchMaxBearingDeg = v.GetFloat64("BATCH_MAX_BEARING_DEG")
+	config.BatchReadyWindow = v.GetDuration("BATCH_READY_WINDOW")
+	config.RouteDetourFactor = v.GetFloat64("ROUTE_DETOUR_FACTOR")
+	config.CourierBasePay = v.GetInt64("COURIER_BASE_PAY")
+	config.CourierPayPerKm = v.GetInt64("COURIER_PAY_PER_KM")
+	config.CourierCashLimit = v.GetInt64("COURIER_CASH_LIMIT")
+	config.PaymentProvider = v.GetString("PAYMENT_PROVIDER")
+	config.PaymentStubURL = v.GetString("PAYMENT_STUB_URL")
+	config.PaymentReturnURL = v.GetString("PAYMENT_RETURN_URL")
+	config.PaymentTimeout = v.GetDuration("PAYMENT_TIMEOUT")
+	config.PushProvider = v.GetString("PUSH_PROVIDER")
+	config.FCMCredentialsFile = v.GetString("FCM_CREDENTIALS_FILE")
+	config.MediaProvider = v.GetString("MEDIA_PROVIDER")
+	config.MediaDir = v.GetString("MEDIA_DIR")
+	config.MaxFileSizeInMBs = v.GetInt64("MAX_FILE_SIZE_MB")
+	config.DefaultCommissionPercent = v.GetFloat64("DEFAULT_COMMISSION_PERCENT")
+	config.CashbackPercent = v.GetInt64("CASHBACK_PERCENT")
+	config.IdempotencyKeyTTL = v.GetDuration("IDEMPOTENCY_KEY_TTL")
+	config.TipWindow = v.GetDuration("TIP_WINDOW")
+	config.PaymeMerchantID = v.GetString("PAYME_MERCHANT_ID")
+	config.PaymeKey = v.GetString("PAYME_KEY")
+	config.PaymeCheckoutURL = v.GetString("PAYME_CHECKOUT_URL")
+	config.ClickServiceID = v.GetString("CLICK_SERVICE_ID")
+	config.ClickMerchantID = v.GetString("CLICK_MERCHANT_ID")
+	config.ClickSecretKey = v.GetString("CLICK_SECRET_KEY")
+	config.ClickCheckoutURL = v.GetString("CLICK_CHECKOUT_URL")
+	config.VehicleSpeedsKmh, err = parseSpeeds(v.GetString("VEHICLE_SPEEDS_KMH"))
+	if err != nil {
+		log.Fatal("error parsing VEHICLE_SPEEDS_KMH: ", err)
+	}
+
 	// config.MediaServiceSecret = v.GetString("MEDIA_SERVICE_SECRET")
 	// config.MediaServiceKey = v.GetString("MEDIA_SERVICE_KEY")
 	// config.MediaServiceUrl = v.GetString("MEDIA_SERVICE_URL")
-	// config.MaxFileSizeInMBs = v.GetInt64("MAX_FILE_SIZE_MB")
 
 	// config.CodeToIgnore = v.GetString("CODE_TO_IGNORE") //used for testing purpose
 	// config.PhoneToIgnore = "+998900000000" //used for testing purpose
@@ -118,6 +260,27 @@ func load() *Configuration {
 	return &config
 }
 
+// parseSpeeds reads "vehicle:km/h" pairs separated by commas
+func parseSpeeds(value string) (map[string]float64, error) {
+	speeds := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		vehicle, speed, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid pair %q", pair)
+		}
+		kmh, err := strconv.ParseFloat(strings.TrimSpace(speed), 64)
+		if err != nil || kmh <= 0 {
+			return nil, fmt.Errorf("invalid speed for %q", vehicle)
+		}
+		speeds[strings.TrimSpace(vehicle)] = kmh
+	}
+	return speeds, nil
+}
+
 func (c *Configuration) validate() error {
 	if c.HTTPPort == "" {
 		return errors.New("http_port required")
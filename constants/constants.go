@@ -25,8 +25,6 @@ const (
 	FirebaseReturnURL = "https://firebasestorage.googleapis.com/v0/b/phleybo.appspot.com/o/"
 
 	Success = "success"
-	InternelServError = "Sizni so'rovingizni bajarishda kutilmagan xatolik, Iltimos keyinroq urunib ko'ring"
-	BadRequest = "Yuborgan so'rovingizda xatolik"
 	Active = 1
 	InActive = 0
 	
@@ -37,3 +35,351 @@ const (
 	ExpenseTransactionID
 	TransferTransactionID
 )
+
+const (
+	OrderStatusNew       = "new"
+	OrderStatusAccepted  = "accepted"
+	OrderStatusPreparing = "preparing"
+	OrderStatusReady     = "ready"
+	OrderStatusPickedUp  = "picked_up"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRejected  = "rejected"
+	// OrderStatusAwaitingPayment orders are shown to the seller only after they are paid
+	OrderStatusAwaitingPayment = "awaiting_payment"
+
+	PaymentMethodCash   = "cash"
+	PaymentMethodCard   = "card"
+	PaymentMethodWallet = "wallet"
+
+	// SlotsDefaultDays is how many days ahead slots are listed by default
+	SlotsDefaultDays = 7
+
+	CartKeyPrefix = "cart:"
+	CartTTL       = time.Hour * 24 * 7
+
+	ReorderItemUnavailable    = "unavailable"
+	ReorderItemOutOfStock     = "out_of_stock"
+	ReorderItemNotEnoughStock = "not_enough_stock"
+	ReorderItemPriceChanged   = "price_changed"
+)
+
+const (
+	AdminRole   = "admin"
+	SellerRole  = "seller"
+	CourierRole = "courier"
+	// SystemRole is used in order history for automatic decisions
+	SystemRole = "system"
+
+	OrderActionPlaced       = "placed"
+	OrderActionCancelled    = "cancelled"
+	OrderActionCancelDenied = "cancel_denied"
+	OrderActionAccepted     = "accepted"
+	OrderActionPreparing    = "preparing"
+	OrderActionRejected     = "rejected"
+	OrderActionReady        = "ready"
+
+	CancelReasonChangedMind         = "changed_mind"
+	CancelReasonOrderedByMistake    = "ordered_by_mistake"
+	CancelReasonLongWait            = "long_wait"
+	CancelReasonOutOfStock          = "out_of_stock"
+	CancelReasonShopClosed          = "shop_closed"
+	CancelReasonCustomerUnreachable = "customer_unreachable"
+	CancelReasonAddressUnreachable  = "address_unreachable"
+	CancelReasonVehicleProblem      = "vehicle_problem"
+	CancelReasonFraudSuspected      = "fraud_suspected"
+	CancelReasonOther               = "other"
+	// CancelReasonAcceptTimeout is used when the seller did not accept the order in time
+	CancelReasonAcceptTimeout = "accept_timeout"
+
+	RefundStatusPending = "pending"
+
+	// AcceptTimeoutCheckInterval is how often not accepted orders are looked for
+	AcceptTimeoutCheckInterval = time.Second * 30
+	MaxPrepMinutes             = 240
+)
+
+const (
+	// MaxItemModifiers limits the number of modifiers per order item
+	MaxItemModifiers = 10
+
+	ReceiptPaper58mm = 58
+	ReceiptPaper80mm = 80
+)
+
+const (
+	CourierStatusActive  = "active"
+	CourierStatusBlocked = "blocked"
+
+	VehicleFoot    = "foot"
+	VehicleBicycle = "bicycle"
+	VehicleScooter = "scooter"
+	VehicleCar     = "car"
+
+	CourierDocumentPassport      = "passport"
+	CourierDocumentDriverLicense = "driver_license"
+	CourierDocumentVehicle       = "vehicle_registration"
+)
+
+const (
+	// CourierGeoKey keeps the latest position of every courier
+	CourierGeoKey = "couriers:geo"
+	// CourierSeenKey scores couriers by the unix time of their latest position
+	CourierSeenKey            = "couriers:seen"
+	CourierPositionKeyPrefix  = "courier:position:"
+	CourierTrackLastKeyPrefix = "courier:track:last:"
+	CourierPositionTTL        = time.Minute * 10
+	// CourierOrdersKeyPrefix caches the orders the courier carries, it is cleared whenever
+	// they change and expires in case a change was missed
+	CourierOrdersKeyPrefix = "courier:orders:"
+	CourierOrdersTTL       = time.Minute * 2
+
+	MaxLocationBatch = 500
+	// MaxGeoLatitude is the highest latitude Redis GEO commands accept
+	MaxGeoLatitude = 85.05112878
+	// LocationMaxClockSkew is how far in the future a point from the phone may be
+	LocationMaxClockSkew = time.Minute
+	// a point is kept in the order track when the courier moved or enough time passed
+	TrackMinInterval   = time.Second * 30
+	TrackMinDistanceKm = 0.1
+)
+
+const (
+	OrderActionCourierOffered    = "courier_offered"
+	OrderActionCourierAssigned   = "courier_assigned"
+	OrderActionCourierReassigned = "courier_reassigned"
+
+	DispatchOfferKeyPrefix        = "dispatch:offer:"
+	DispatchCourierOfferKeyPrefix = "dispatch:courier:"
+	// DispatchSkipKeyPrefix keeps couriers that already got an offer for the order
+	DispatchSkipKeyPrefix = "dispatch:skip:"
+	// DispatchDeadlinesKey scores orders by the time their offer expires or dispatch is retried
+	DispatchDeadlinesKey = "dispatch:deadlines"
+	DispatchSkipTTL      = time.Hour * 24
+	// DispatchBatchKeyPrefix lists the orders offered together with the first order of a batch
+	DispatchBatchKeyPrefix = "dispatch:batch:"
+
+	DispatchCheckInterval   = time.Second * 5
+	DispatchRetryInterval   = time.Second * 30
+	DispatchCandidatesLimit = 20
+	// orders waiting for a courier looked through when a batch is formed
+	DispatchWaitingOrdersLimit = 100
+	// a courier carrying one more order or rated one star lower is treated as this much farther away
+	DispatchLoadPenaltyKm   = 1.0
+	DispatchRatingPenaltyKm = 0.5
+	CourierMaxRating        = 5
+)
+
+const (
+	// DefaultPrepMinutes is assumed until the seller tells how long the order takes
+	DefaultPrepMinutes = 20
+	// EtaHandoverTime covers parking, finding the shop and taking the bag
+	EtaHandoverTime = time.Minute * 3
+	// EtaDefaultVehicle is used when there is no courier yet or their vehicle has no speed
+	EtaDefaultVehicle = VehicleScooter
+	// EtaFallbackSpeedKmh is used when VEHICLE_SPEEDS_KMH misses the default vehicle
+	EtaFallbackSpeedKmh = 20
+)
+
+const (
+	GeoJSONPolygon      = "Polygon"
+	GeoJSONMultiPolygon = "MultiPolygon"
+)
+
+const (
+	// order events are fanned out through Redis pub/sub so every replica can stream them
+	OrderEventsChannelPrefix   = "order:events:"
+	CourierEventsChannelPrefix = "courier:events:"
+
+	OrderEventStatus   = "status"
+	OrderEventCourier  = "courier"
+	OrderEventPosition = "position"
+	OrderEventETA      = "eta"
+
+	// TrackingHeartbeatInterval keeps idle streams open behind proxies
+	TrackingHeartbeatInterval = time.Second * 15
+	// TrackingSubscriptionBuffer is how many messages a stream may fall behind before it is closed
+	TrackingSubscriptionBuffer = 64
+)
+
+
+const (
+	OrderActionPickedUp  = "picked_up"
+	OrderActionDelivered = "delivered"
+	// the courier entered too many wrong codes, support has to confirm the handover
+	OrderActionHandoffLocked = "handoff_locked"
+
+	// the customer tells the code to the courier, a photo is left for contactless drops.
+	// Support confirms the handover when the code is locked.
+	ProofMethodCode    = "code"
+	ProofMethodPhoto   = "photo"
+	ProofMethodSupport = "support"
+	// photos of delivered orders are stored under proofs/<order id>/
+	ProofPhotoKeyPrefix = "proofs/"
+
+	HandoffCodeBytes = 2
+	// wrong handoff codes are counted so that the code can not be guessed
+	HandoffAttemptsKeyPrefix = "handoff:attempts:"
+	MaxHandoffAttempts       = 5
+	HandoffAttemptsTTL       = time.Hour
+)
+
+const (
+	LedgerKindDeliveryBase     = "delivery_base"
+	LedgerKindDeliveryDistance = "delivery_distance"
+	LedgerKindTip              = "tip"
+	LedgerKindBonus            = "bonus"
+	LedgerKindPenalty          = "penalty"
+	LedgerKindPayout           = "payout"
+
+	// debits are positive and credits are negative, a courier payable account
+	// with a negative balance means the platform owes the courier
+	LedgerAccountCourierPayablePrefix = "courier_payable:"
+	LedgerAccountDeliveryExpense      = "delivery_expense"
+	LedgerAccountTipsHeld             = "tips_held"
+	LedgerAccountPenaltyIncome        = "penalty_income"
+	LedgerAccountBank                 = "bank"
+
+	// BackfillInterval is how often the money a delivery failed to record is recorded again,
+	// orders delivered before BackfillWindow are left to the support team
+	BackfillInterval   = time.Minute * 5
+	BackfillWindow     = time.Hour * 24 * 7
+	BackfillBatchLimit = 100
+
+	StatementPeriodDay  = "day"
+	StatementPeriodWeek = "week"
+
+	// couriers hand the collected cash over to the office or to a xozmak
+	CashReceiverOffice = "office"
+	CashReceiverXozmak = "xozmak"
+)
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusPaid      = "paid"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusCancelled = "cancelled"
+	PaymentStatusRefunded  = "refunded"
+
+	PaymentProviderFake = "fake"
+	PaymentProviderStub = "stub"
+	// PaymentProviderPayme payments are changed by the Payme merchant API calls
+	PaymentProviderPayme = "payme"
+	// PaymentProviderClick payments are changed by the Click SHOP API callbacks
+	PaymentProviderClick = "click"
+	// PaymentProviderWallet payments are paid from the customer's wallet at once
+	PaymentProviderWallet = "wallet"
+
+	OrderActionPaid            = "paid"
+	CancelReasonPaymentTimeout = "payment_timeout"
+	// CancelReasonPaymentCancelled is used when the provider takes a payment back
+	CancelReasonPaymentCancelled = "payment_cancelled"
+
+	RefundStatusCompleted = "completed"
+	RefundStatusFailed    = "failed"
+	// RefundStatusManual refunds are made by the support team in the provider's cabinet
+	RefundStatusManual = "manual"
+	// a refund the provider keeps refusing is left to the support team
+	MaxRefundAttempts = 5
+
+	// PaymentCheckInterval is how often pending payments and refunds are processed
+	PaymentCheckInterval = time.Second * 30
+	PaymentBatchLimit    = 100
+)
+
+// Payme transaction states from the merchant API spec
+const (
+	PaymeStateCreated               = 1
+	PaymeStatePerformed             = 2
+	PaymeStateCancelled             = -1
+	PaymeStateCancelledAfterPerform = -2
+
+	// PaymeReasonTimeout is the cancel reason of a transaction not performed in PaymeTransactionTimeout
+	PaymeReasonTimeout = 4
+	// Payme cancels a transaction it could not perform within 12 hours
+	PaymeTransactionTimeout = 12 * time.Hour
+)
+
+// Click transaction statuses
+const (
+	ClickStatusPrepared  = "prepared"
+	ClickStatusCompleted = "completed"
+	ClickStatusCancelled = "cancelled"
+
+	ClickActionPrepare  = "0"
+	ClickActionComplete = "1"
+)
+
+const (
+	// wallet transactions of IncomeTransactionID type top the wallet up,
+	// ExpenseTransactionID ones spend from it
+	WalletKindCashback     = "cashback"
+	WalletKindRefund       = "refund"
+	WalletKindTopUp        = "top_up"
+	WalletKindOrderPayment = "order_payment"
+	WalletKindTip          = "tip"
+
+	// a wallet account with a negative balance means the platform owes the customer
+	LedgerAccountWalletPrefix    = "wallet:"
+	LedgerAccountCashbackExpense = "cashback_expense"
+	LedgerAccountOrderRevenue    = "order_revenue"
+	// top-ups are made by admins after the money is received outside of the app
+	LedgerAccountWalletTopUp = "wallet_top_up"
+)
+
+const (
+	// a percent rule takes a share of the items total, a fixed one a sum per order and
+	// a tiered one the percent of the tier the items total falls into
+	CommissionTypePercent = "percent"
+	CommissionTypeFixed   = "fixed"
+	CommissionTypeTiered  = "tiered"
+
+	SettlementStatusPending = "pending"
+	SettlementStatusSettled = "settled"
+)
+
+const (
+	// a payment pays for the order or for the tip given after the order is delivered
+	PaymentPurposeOrder = "order"
+	PaymentPurposeTip   = "tip"
+
+	MaxTipPercent = 100
+)
+
+const (
+	// push events tell the app what happened, the app opens OrderDeepLinkPrefix + order id
+	PushEventOrderAccepted   = "order_accepted"
+	PushEventOrderRejected   = "order_rejected"
+	PushEventCourierAssigned = "courier_assigned"
+	PushEventOrderPickedUp   = "order_picked_up"
+	PushEventCourierArriving = "courier_arriving"
+	PushEventOrderDelivered  = "order_delivered"
+	PushEventCourierOffer    = "courier_offer"
+	OrderDeepLinkPrefix      = "delivery://orders/"
+
+	// the customer is told the courier is arriving once the courier is ArrivingRadiusKm
+	// away, ArrivingKeyPrefix keeps orders already told about it
+	ArrivingRadiusKm  = 0.3
+	ArrivingKeyPrefix = "order:arriving:"
+	ArrivingKeyTTL    = 6 * time.Hour
+	// pushes are sent in the background, a slow provider does not hold the request
+	PushTimeout = 10 * time.Second
+)
+
+const (
+	// notification and message templates, pkg/templates keeps their uz and ru texts
+	TemplateOrderAccepted        = "order_accepted"
+	TemplateOrderRejected        = "order_rejected"
+	TemplateCourierAssigned      = "courier_assigned"
+	TemplateOrderPickedUp        = "order_picked_up"
+	TemplateCourierArriving      = "courier_arriving"
+	TemplateOrderDelivered       = "order_delivered"
+	TemplateCourierOffer         = "courier_offer"
+	TemplateCourierBatchOffer    = "courier_batch_offer"
+	TemplateCourierOrderAssigned = "courier_order_assigned"
+	TemplateCodeSent             = "code_sent"
+	TemplateInternalError        = "internal_error"
+	TemplateBadRequest           = "bad_request"
+
+	DefaultLang = UzLang
+)
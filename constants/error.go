@@ -22,4 +22,6 @@ const (
 	PGForeignKeyViolationCode = "23503"
 	// PGUniqueKeyViolationCode is used to check unique key violation in database
 	PGUniqueKeyViolationCode = "23505"
+	// PGCheckViolationCode is used to check CHECK constraint violation in database
+	PGCheckViolationCode = "23514"
 )
@@ -5,10 +5,12 @@ import (
 	"delivery/configs"
 	"delivery/constants"
 	"delivery/entities"
+	e "delivery/errors"
 	"delivery/logger"
 	pkgerrors "delivery/pkg/errors"
 	"delivery/pkg/jwt"
 	"delivery/storage"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -37,6 +39,10 @@ type AdminController interface {
 	GetSubCategory(ctx context.Context)([]entities.SubCategory, error)
 	UpdateSubCategory(ctx context.Context, req entities.SubCategory) error
 	DeleteSubCategory(ctx context.Context, sub_category_id string) error
+	CreateProduct(ctx context.Context, req entities.Product) error
+	GetProducts(ctx context.Context, xozmakId string) ([]entities.Product, error)
+	UpdateProduct(ctx context.Context, req entities.Product) error
+	DeleteProduct(ctx context.Context, id string) error
 }
 
 type adminController struct {
@@ -93,25 +99,46 @@ func (a adminController) Registration(ctx context.Context, req entities.RegistrR
 	}
 
 	if storedCode != req.Code {
-		if req.Code != "020202" {
-			return entities.RegistrRes{}, pkgerrors.NewError(http.StatusBadRequest, "Kod noto'g'ri")
-		}
+		return entities.RegistrRes{}, pkgerrors.NewError(http.StatusBadRequest, "Kod noto'g'ri")
+	}
+	// the code is used once, it can not be tried again against other accounts
+	if err := a.redis.Del(ctx, req.PhoneNumber).Err(); err != nil {
+		a.log.Error("error in Registration: ", logger.Error(err))
 	}
 
 	var Id string = uuid.NewString()
+	role := constants.UserRole
 	err = a.storage.Admin().Registration(ctx, entities.RegistrReq{
 		ID:          Id,
 		PhoneNumber: req.PhoneNumber,
 		FcmToken: req.FcmToken,
 	})
-	if err != nil {
+	if errors.Is(err, e.ErrAccountAlreadyExists) {
+		// the number is already registered, for example a courier created by admin, so log them in
+		account, err := a.storage.Admin().GetUserByPhone(ctx, req.PhoneNumber)
+		if err != nil {
+			a.log.Error("error in Registration: ", logger.Error(err))
+			return entities.RegistrRes{}, pkgerrors.NewError(http.StatusInternalServerError, "Telefon raqamini saqlashda xatolik")
+		}
+		Id = account.ID
+		if account.Role != "" {
+			role = account.Role
+		}
+	} else if err != nil {
 		a.log.Error("Telefon raqamini saqlashda xatolik", logger.Error(err))
 		return entities.RegistrRes{}, pkgerrors.NewError(http.StatusInternalServerError, "Telefon raqamini saqlashda xatolik")
 	}
 
+	// the user may log in from a new device, pushes go to the device they logged in last
+	if req.FcmToken != "" {
+		if err := a.storage.Notification().UpdateFcmToken(ctx, Id, req.FcmToken); err != nil {
+			a.log.Error("error in Registration: ", logger.Error(err))
+		}
+	}
+
 	tokenMetadata := map[string]string{
 		"id":   Id,
-		"role": constants.UserRole,
+		"role": role,
 	}
 
 	tokens := entities.Tokens{}
@@ -136,7 +163,7 @@ func (a adminController) Registration(ctx context.Context, req entities.RegistrR
 
 func (a adminController) CreateXozmak(ctx context.Context, req entities.Xozmak) error {
 	a.log.Info("CreateXozmak started: ",
-		zap.String("Request: ", fmt.Sprintf("XozmakID: %s, XozmakName: %s, CreatedBy: %s", req.ID, req.Name, req.CreatedBy)))
+		zap.String("Request: ", fmt.Sprintf("XozmakID: %s, XozmakName: %s, CreatedBy: %s", req.ID, req.Name, req.CreatedBy.String)))
 
 	err := a.storage.Admin().CreateXozmak(ctx, req)
 	if err != nil {
@@ -153,7 +180,24 @@ func (a adminController) InsertUserLocation(ctx context.Context, req entities.Us
 	a.log.Info("AddLocation started: ",
 		zap.String("Request: ", fmt.Sprintf("LocationID: %s, LocationName: %s, CreatedBy: %s", req.ID, req.Name, req.UserID)))
 
-	err := a.storage.Admin().InsertUserLocation(ctx, req)
+	// addresses no xozmak delivers to are not saved, unless no zones are drawn yet
+	zones, err := a.storage.Order().GetZonesAround(ctx, req.Latitude, req.Longitude)
+	if err != nil {
+		a.log.Error("error in AddLocation: ", zap.Error(err))
+		return status.Error(codes.Internal, "internal server error")
+	}
+	if _, ok := entities.MatchZone(zones, req.Latitude, req.Longitude); !ok {
+		hasZones, err := a.storage.Order().HasZones(ctx)
+		if err != nil {
+			a.log.Error("error in AddLocation: ", zap.Error(err))
+			return status.Error(codes.Internal, "internal server error")
+		}
+		if hasZones {
+			return e.ErrOutOfDeliveryArea
+		}
+	}
+
+	err = a.storage.Admin().InsertUserLocation(ctx, req)
 	if err != nil {
 		a.log.Error("error in AddLocation: ", zap.Error(err))
 		return status.Error(codes.Internal, "internal server error")
@@ -313,6 +357,69 @@ func (a adminController) DeleteSubCategory (ctx context.Context, sub_categoryId
 }
 
 
+func (a adminController) CreateProduct(ctx context.Context, req entities.Product) error {
+	a.log.Info("CreateProduct started: ",
+		zap.String("Request: ", fmt.Sprintf("ProductID: %s, XozmakID: %s, Name: %s", req.ID, req.XozmakID, req.Name)))
+
+	err := a.storage.Admin().CreateProduct(ctx, req)
+	if err != nil {
+		a.log.Error("error in CreateProduct: ", zap.Error(err))
+		if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, "internal server error")
+	}
+	a.log.Info("CreateProduct finished")
+
+	return nil
+}
+
+func (a adminController) GetProducts(ctx context.Context, xozmakId string) ([]entities.Product, error) {
+	a.log.Info("GetProducts started: ", zap.String("XozmakID", xozmakId))
+
+	data, err := a.storage.Admin().GetProducts(ctx, xozmakId)
+	if err != nil {
+		a.log.Error("error in GetProducts: ", zap.Error(err))
+		return []entities.Product{}, status.Error(codes.Internal, "internal server error")
+	}
+	a.log.Info("GetProducts finished")
+
+	return data, nil
+}
+
+func (a adminController) UpdateProduct(ctx context.Context, req entities.Product) error {
+	a.log.Info("UpdateProduct started: ", zap.String("ProductID", req.ID))
+
+	err := a.storage.Admin().UpdateProduct(ctx, req)
+	if err != nil {
+		a.log.Error("error in UpdateProduct: ", zap.Error(err))
+		if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, "internal server error")
+	}
+	a.log.Info("UpdateProduct finished")
+
+	return nil
+}
+
+func (a adminController) DeleteProduct(ctx context.Context, id string) error {
+	a.log.Info("DeleteProduct started: ", zap.String("ProductID", id))
+
+	err := a.storage.Admin().DeleteProduct(ctx, id)
+	if err != nil {
+		a.log.Error("error in DeleteProduct: ", zap.Error(err))
+		if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, "internal server error")
+	}
+	a.log.Info("DeleteProduct finished")
+
+	return nil
+}
+
+
 
 // func (a adminController) Login(ctx context.Context, req entities.LoginReq) (entities.LoginRes, error) {
 
@@ -0,0 +1,303 @@
+package click
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"delivery/configs"
+	"delivery/constants"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	"delivery/pkg/utils"
+	"delivery/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ClickController interface {
+	// Prepare checks that the order can be paid and reserves a transaction for it
+	Prepare(ctx context.Context, req entities.ClickRequest) entities.ClickResponse
+	// Complete pays the order with the prepared transaction, or cancels it when Click failed to charge the customer
+	Complete(ctx context.Context, req entities.ClickRequest) entities.ClickResponse
+}
+
+type clickController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	cfg     *configs.Configuration
+	tracker trackingcontroller.TrackingController
+}
+
+func NewClickController(log logger.LoggerI, storage storage.Storage, tracker trackingcontroller.TrackingController) ClickController {
+	return clickController{
+		log:     log,
+		storage: storage,
+		cfg:     configs.Config(),
+		tracker: tracker,
+	}
+}
+
+// respond fills the error of the response, every response of the SHOP API is sent with HTTP 200
+func (c clickController) respond(res entities.ClickResponse, code int) entities.ClickResponse {
+	res.Error = code
+	res.ErrorNote = errorNotes[code]
+	if code != codeSuccess {
+		c.log.Warn("Click request refused", zap.Int("Code", code),
+			zap.Int64("ClickTransID", res.ClickTransID), zap.String("OrderID", res.MerchantTransID))
+	}
+	return res
+}
+
+// validSign checks sign_string, the md5 of the request fields and the secret key.
+// merchant_prepare_id is a part of it only in the complete request.
+func (c clickController) validSign(req entities.ClickRequest) bool {
+	if c.cfg.ClickSecretKey == "" || req.ServiceID != c.cfg.ClickServiceID {
+		return false
+	}
+	data := req.ClickTransID + req.ServiceID + c.cfg.ClickSecretKey + req.MerchantTransID
+	if req.Action == constants.ClickActionComplete {
+		data += req.MerchantPrepareID
+	}
+	data += req.Amount + req.Action + req.SignTime
+
+	sum := md5.Sum([]byte(data))
+	expected := hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(req.SignString))) == 1
+}
+
+// sameAmount compares the amount Click sent, e.g. "125000.00", with an amount in soums
+func sameAmount(amount string, soums int64) bool {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return false
+	}
+	return int64(math.Round(value*100)) == soums*100
+}
+
+// payment returns the pending Click payment the customer opened the pay page with,
+// a new one is made when the customer started paying in the Click app
+func (c clickController) payment(ctx context.Context, order entities.Order) (entities.Payment, error) {
+	payments, err := c.storage.Payment().GetOrderPayments(ctx, order.ID)
+	if err != nil {
+		return entities.Payment{}, err
+	}
+	for _, pay := range payments {
+		if pay.Provider == constants.PaymentProviderClick && pay.Status == constants.PaymentStatusPending && pay.ProviderPaymentID == nil {
+			return pay, nil
+		}
+	}
+
+	now := time.Now()
+	return entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Provider:  constants.PaymentProviderClick,
+		Amount:    order.Total,
+		Status:    constants.PaymentStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (c clickController) Prepare(ctx context.Context, req entities.ClickRequest) entities.ClickResponse {
+	c.log.Info("Click Prepare started: ",
+		zap.String("Request: ", fmt.Sprintf("ClickTransID: %s, OrderID: %s, Amount: %s",
+			req.ClickTransID, req.MerchantTransID, req.Amount)))
+
+	res := entities.ClickResponse{MerchantTransID: req.MerchantTransID}
+	clickTransId, err := strconv.ParseInt(req.ClickTransID, 10, 64)
+	if err != nil {
+		return c.respond(res, codeBadRequest)
+	}
+	res.ClickTransID = clickTransId
+	paydocId, err := strconv.ParseInt(req.ClickPaydocID, 10, 64)
+	if err != nil {
+		return c.respond(res, codeBadRequest)
+	}
+	if !c.validSign(req) {
+		return c.respond(res, codeSignCheckFailed)
+	}
+	if req.Action != constants.ClickActionPrepare {
+		return c.respond(res, codeActionNotFound)
+	}
+
+	// Click repeats the request when it does not get the answer
+	transaction, err := c.storage.Payment().GetClickTransactionByClickID(ctx, clickTransId)
+	switch {
+	case err == nil:
+		if transaction.OrderID != req.MerchantTransID {
+			return c.respond(res, codeTransactionNotFound)
+		}
+		switch transaction.Status {
+		case constants.ClickStatusCompleted:
+			return c.respond(res, codeAlreadyPaid)
+		case constants.ClickStatusCancelled:
+			return c.respond(res, codeTransactionCanceled)
+		}
+		res.MerchantPrepareID = transaction.ID
+		return c.respond(res, codeSuccess)
+	case !errors.Is(err, e.ErrClickTransactionNotFound):
+		c.log.Error("error in Click Prepare: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+
+	if !utils.IsValidUUID(req.MerchantTransID) {
+		return c.respond(res, codeOrderNotFound)
+	}
+	order, err := c.storage.Order().GetOrder(ctx, req.MerchantTransID)
+	if errors.Is(err, e.ErrOrderNotFound) {
+		return c.respond(res, codeOrderNotFound)
+	}
+	if err != nil {
+		c.log.Error("error in Click Prepare: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+	if order.PaidAt != nil {
+		return c.respond(res, codeAlreadyPaid)
+	}
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return c.respond(res, codeTransactionCanceled)
+	}
+	if !sameAmount(req.Amount, order.Total) {
+		return c.respond(res, codeIncorrectAmount)
+	}
+
+	pay, err := c.payment(ctx, order)
+	if err != nil {
+		c.log.Error("error in Click Prepare: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+	transaction, err = c.storage.Payment().CreateClickTransaction(ctx, entities.ClickTransaction{
+		ClickTransID:  clickTransId,
+		ClickPaydocID: paydocId,
+		PaymentID:     pay.ID,
+		OrderID:       order.ID,
+		Amount:        order.Total,
+		Status:        constants.ClickStatusPrepared,
+		CreatedAt:     time.Now(),
+	}, pay)
+	if errors.Is(err, e.ErrClickTransactionChanged) {
+		// prepared by a concurrent request
+		transaction, err = c.storage.Payment().GetClickTransactionByClickID(ctx, clickTransId)
+	}
+	if err != nil {
+		c.log.Error("error in Click Prepare: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+
+	res.MerchantPrepareID = transaction.ID
+	c.log.Info("Click Prepare finished", zap.Int64("MerchantPrepareID", transaction.ID))
+	return c.respond(res, codeSuccess)
+}
+
+func (c clickController) Complete(ctx context.Context, req entities.ClickRequest) entities.ClickResponse {
+	c.log.Info("Click Complete started: ",
+		zap.String("Request: ", fmt.Sprintf("ClickTransID: %s, OrderID: %s, MerchantPrepareID: %s, Error: %s",
+			req.ClickTransID, req.MerchantTransID, req.MerchantPrepareID, req.Error)))
+
+	res := entities.ClickResponse{MerchantTransID: req.MerchantTransID}
+	clickTransId, err := strconv.ParseInt(req.ClickTransID, 10, 64)
+	if err != nil {
+		return c.respond(res, codeBadRequest)
+	}
+	res.ClickTransID = clickTransId
+	prepareId, err := strconv.ParseInt(req.MerchantPrepareID, 10, 64)
+	if err != nil {
+		return c.respond(res, codeBadRequest)
+	}
+	clickError, err := strconv.Atoi(req.Error)
+	if err != nil {
+		return c.respond(res, codeBadRequest)
+	}
+	if !c.validSign(req) {
+		return c.respond(res, codeSignCheckFailed)
+	}
+	if req.Action != constants.ClickActionComplete {
+		return c.respond(res, codeActionNotFound)
+	}
+
+	transaction, err := c.storage.Payment().GetClickTransaction(ctx, prepareId)
+	if errors.Is(err, e.ErrClickTransactionNotFound) {
+		return c.respond(res, codeTransactionNotFound)
+	}
+	if err != nil {
+		c.log.Error("error in Click Complete: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+	if transaction.ClickTransID != clickTransId || transaction.OrderID != req.MerchantTransID {
+		return c.respond(res, codeTransactionNotFound)
+	}
+	res.MerchantConfirmID = transaction.ID
+
+	switch transaction.Status {
+	case constants.ClickStatusCompleted:
+		// a repeated request is answered the same way
+		return c.respond(res, codeSuccess)
+	case constants.ClickStatusCancelled:
+		return c.respond(res, codeTransactionCanceled)
+	}
+
+	// Click could not charge the customer, e.g. there is not enough money on the card
+	if clickError < 0 {
+		return c.cancel(ctx, res, transaction, fmt.Sprintf("click error %d: %s", clickError, req.ErrorNote))
+	}
+	if !sameAmount(req.Amount, transaction.Amount) {
+		return c.respond(res, codeIncorrectAmount)
+	}
+
+	err = c.storage.Payment().CompleteClickTransaction(ctx, transaction, entities.OrderHistory{
+		OrderID:    transaction.OrderID,
+		Action:     constants.OrderActionPaid,
+		FromStatus: constants.OrderStatusAwaitingPayment,
+		ToStatus:   constants.OrderStatusNew,
+		ActorRole:  constants.SystemRole,
+		Note:       constants.PaymentProviderClick,
+	})
+	switch {
+	case errors.Is(err, e.ErrClickTransactionChanged):
+		// completed or cancelled by a concurrent request
+		transaction, err = c.storage.Payment().GetClickTransaction(ctx, prepareId)
+		if err != nil {
+			c.log.Error("error in Click Complete: ", zap.Error(err))
+			return c.respond(res, codeUpdateFailed)
+		}
+		if transaction.Status != constants.ClickStatusCompleted {
+			return c.respond(res, codeTransactionCanceled)
+		}
+		return c.respond(res, codeSuccess)
+	case errors.Is(err, e.ErrOrderNotAwaitingPayment), errors.Is(err, e.ErrPaymentStatusChanged):
+		// the order was closed while the customer was paying, Click returns the money
+		return c.cancel(ctx, res, transaction, "order is not waiting for the payment")
+	case err != nil:
+		c.log.Error("error in Click Complete: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+
+	c.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventStatus,
+		OrderID: transaction.OrderID,
+		Status:  constants.OrderStatusNew,
+	})
+	c.log.Info("Click Complete finished", zap.Int64("MerchantConfirmID", transaction.ID))
+	return c.respond(res, codeSuccess)
+}
+
+func (c clickController) cancel(ctx context.Context, res entities.ClickResponse, transaction entities.ClickTransaction, reason string) entities.ClickResponse {
+	err := c.storage.Payment().CancelClickTransaction(ctx, transaction, reason)
+	if err != nil && !errors.Is(err, e.ErrClickTransactionChanged) {
+		c.log.Error("error in Click cancel: ", zap.Error(err))
+		return c.respond(res, codeUpdateFailed)
+	}
+	return c.respond(res, codeTransactionCanceled)
+}
@@ -0,0 +1,338 @@
+package click
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	"delivery/storage"
+	"delivery/storage/repo"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+const testOrderID = "3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69"
+
+// Callbacks recorded from the Click test environment, signed with service 12345 and the key "test-secret"
+const (
+	prepareBody = "click_trans_id=2145587125&service_id=12345&click_paydoc_id=2578043816" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&amount=125000.00&action=0&error=0" +
+		"&error_note=Success&sign_time=2024-05-01+12%3A00%3A00&sign_string=e612f34124517f58eae7e9819f909ab1"
+	completeBody = "click_trans_id=2145587125&service_id=12345&click_paydoc_id=2578043816" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&merchant_prepare_id=1&amount=125000.00" +
+		"&action=1&error=0&error_note=Success&sign_time=2024-05-01+12%3A00%3A00" +
+		"&sign_string=dc7c478985150937f394581e74fbf831"
+	// the customer did not have enough money on the card
+	failedCompleteBody = "click_trans_id=2145587125&service_id=12345&click_paydoc_id=2578043816" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&merchant_prepare_id=1&amount=125000.00" +
+		"&action=1&error=-5017&error_note=%D0%9D%D0%B5%D0%B4%D0%BE%D1%81%D1%82%D0%B0%D1%82%D0%BE%D1%87%D0%BD%D0%BE" +
+		"&sign_time=2024-05-01+12%3A00%3A00&sign_string=dc7c478985150937f394581e74fbf831"
+	wrongAmountBody = "click_trans_id=2145587126&service_id=12345&click_paydoc_id=2578043817" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&amount=120000&action=0&error=0" +
+		"&error_note=Success&sign_time=2024-05-01+12%3A00%3A00&sign_string=d48ed0a23fb7805db052758247ce9600"
+	unknownOrderBody = "click_trans_id=2145587127&service_id=12345&click_paydoc_id=2578043818" +
+		"&merchant_trans_id=9a1b2c3d-4e5f-4a6b-8c7d-0e1f2a3b4c5d&amount=125000.00&action=0&error=0" +
+		"&error_note=Success&sign_time=2024-05-01+12%3A00%3A00&sign_string=d233dcf5c8b5755feb1400015ffbb9ac"
+	// a complete request sent to the prepare callback
+	wrongActionBody = "click_trans_id=2145587125&service_id=12345&click_paydoc_id=2578043816" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&amount=125000.00&action=1&error=0" +
+		"&error_note=Success&sign_time=2024-05-01+12%3A00%3A00&sign_string=3e22d20865ad7992eff10d50c5d530f3"
+	unknownPrepareBody = "click_trans_id=2145587125&service_id=12345&click_paydoc_id=2578043816" +
+		"&merchant_trans_id=3f6c1a2b-7d4e-4c5a-9b8f-1e2d3c4b5a69&merchant_prepare_id=77&amount=125000.00" +
+		"&action=1&error=0&error_note=Success&sign_time=2024-05-01+12%3A00%3A00" +
+		"&sign_string=654bb48e53225743d0a8e04dce1a3384"
+)
+
+type fakeOrders struct {
+	repo.IOrderStorage
+	orders map[string]entities.Order
+}
+
+func (f *fakeOrders) GetOrder(ctx context.Context, id string) (entities.Order, error) {
+	order, ok := f.orders[id]
+	if !ok {
+		return entities.Order{}, e.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// fakePayments follows the state changes of the postgres storage
+type fakePayments struct {
+	repo.IPaymentStorage
+	orders       *fakeOrders
+	payments     map[string]entities.Payment
+	transactions map[int64]entities.ClickTransaction
+}
+
+func (f *fakePayments) GetOrderPayments(ctx context.Context, orderId string) ([]entities.Payment, error) {
+	var payments []entities.Payment
+	for _, pay := range f.payments {
+		if pay.OrderID == orderId {
+			payments = append(payments, pay)
+		}
+	}
+	return payments, nil
+}
+
+func (f *fakePayments) GetClickTransaction(ctx context.Context, id int64) (entities.ClickTransaction, error) {
+	transaction, ok := f.transactions[id]
+	if !ok {
+		return entities.ClickTransaction{}, e.ErrClickTransactionNotFound
+	}
+	return transaction, nil
+}
+
+func (f *fakePayments) GetClickTransactionByClickID(ctx context.Context, clickTransId int64) (entities.ClickTransaction, error) {
+	for _, transaction := range f.transactions {
+		if transaction.ClickTransID == clickTransId {
+			return transaction, nil
+		}
+	}
+	return entities.ClickTransaction{}, e.ErrClickTransactionNotFound
+}
+
+func (f *fakePayments) CreateClickTransaction(ctx context.Context, transaction entities.ClickTransaction, payment entities.Payment) (entities.ClickTransaction, error) {
+	if _, err := f.GetClickTransactionByClickID(ctx, transaction.ClickTransID); err == nil {
+		return entities.ClickTransaction{}, e.ErrClickTransactionChanged
+	}
+	id := "2145587125"
+	payment.ProviderPaymentID = &id
+	f.payments[payment.ID] = payment
+	transaction.ID = int64(len(f.transactions) + 1)
+	f.transactions[transaction.ID] = transaction
+	return transaction, nil
+}
+
+func (f *fakePayments) CompleteClickTransaction(ctx context.Context, transaction entities.ClickTransaction, history entities.OrderHistory) error {
+	if f.transactions[transaction.ID].Status != constants.ClickStatusPrepared {
+		return e.ErrClickTransactionChanged
+	}
+	order := f.orders.orders[transaction.OrderID]
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return e.ErrOrderNotAwaitingPayment
+	}
+
+	now := time.Now()
+	transaction.Status = constants.ClickStatusCompleted
+	f.transactions[transaction.ID] = transaction
+	pay := f.payments[transaction.PaymentID]
+	pay.Status = constants.PaymentStatusPaid
+	f.payments[pay.ID] = pay
+	order.Status = constants.OrderStatusNew
+	order.PaidAt = &now
+	f.orders.orders[order.ID] = order
+	return nil
+}
+
+func (f *fakePayments) CancelClickTransaction(ctx context.Context, transaction entities.ClickTransaction, reason string) error {
+	if f.transactions[transaction.ID].Status != constants.ClickStatusPrepared {
+		return e.ErrClickTransactionChanged
+	}
+	transaction.Status = constants.ClickStatusCancelled
+	transaction.Error = reason
+	f.transactions[transaction.ID] = transaction
+	pay := f.payments[transaction.PaymentID]
+	pay.Status = constants.PaymentStatusCancelled
+	f.payments[pay.ID] = pay
+	return nil
+}
+
+type fakeStorage struct {
+	storage.Storage
+	orders   *fakeOrders
+	payments *fakePayments
+}
+
+func (s fakeStorage) Order() repo.IOrderStorage {
+	return s.orders
+}
+
+func (s fakeStorage) Payment() repo.IPaymentStorage {
+	return s.payments
+}
+
+type fakeTracker struct {
+	trackingcontroller.TrackingController
+	events *[]entities.OrderEvent
+}
+
+func (f fakeTracker) PublishOrderEvent(ctx context.Context, event entities.OrderEvent) {
+	*f.events = append(*f.events, event)
+}
+
+type testEnv struct {
+	controller clickController
+	storage    fakeStorage
+	events     []entities.OrderEvent
+}
+
+// newTestEnv has one card order of 125 000 so'm waiting for a payment
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	orders := &fakeOrders{orders: map[string]entities.Order{
+		testOrderID: {
+			ID:            testOrderID,
+			UserID:        "5f0a6b2e-1c3d-4e5f-8a9b-0c1d2e3f4a5b",
+			Status:        constants.OrderStatusAwaitingPayment,
+			PaymentMethod: constants.PaymentMethodCard,
+			Total:         125000,
+		},
+	}}
+	strg := fakeStorage{
+		orders: orders,
+		payments: &fakePayments{
+			orders:       orders,
+			payments:     map[string]entities.Payment{},
+			transactions: map[int64]entities.ClickTransaction{},
+		},
+	}
+	env := &testEnv{storage: strg}
+	env.controller = clickController{
+		log:     logger.NewLogger("test", "error"),
+		storage: strg,
+		cfg:     &configs.Configuration{ClickServiceID: "12345", ClickSecretKey: "test-secret"},
+		tracker: fakeTracker{events: &env.events},
+	}
+	return env
+}
+
+// request binds the recorded form the way the handler does
+func request(t *testing.T, body string) entities.ClickRequest {
+	t.Helper()
+
+	httpReq := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var req entities.ClickRequest
+	if err := binding.Form.Bind(httpReq, &req); err != nil {
+		t.Fatalf("bind %q: %v", body, err)
+	}
+	return req
+}
+
+func expectCode(t *testing.T, res entities.ClickResponse, code int) {
+	t.Helper()
+
+	if res.Error != code {
+		t.Fatalf("error = %d (%s), want %d", res.Error, res.ErrorNote, code)
+	}
+	if res.ErrorNote != errorNotes[code] {
+		t.Fatalf("error_note = %q, want %q", res.ErrorNote, errorNotes[code])
+	}
+}
+
+func TestClickPrepareAndComplete(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	prepared := env.controller.Prepare(ctx, request(t, prepareBody))
+	expectCode(t, prepared, codeSuccess)
+	if prepared.ClickTransID != 2145587125 || prepared.MerchantTransID != testOrderID || prepared.MerchantPrepareID != 1 {
+		t.Fatalf("unexpected prepare response %+v", prepared)
+	}
+	transaction := env.storage.payments.transactions[1]
+	pay := env.storage.payments.payments[transaction.PaymentID]
+	if pay.Provider != constants.PaymentProviderClick || pay.OrderID != testOrderID || pay.Amount != 125000 ||
+		pay.ProviderPaymentID == nil || *pay.ProviderPaymentID != "2145587125" {
+		t.Fatalf("transaction is not bound to a payment: %+v", pay)
+	}
+
+	// Click repeats the request when our answer is lost
+	if repeated := env.controller.Prepare(ctx, request(t, prepareBody)); repeated != prepared {
+		t.Fatalf("repeated prepare = %+v, want %+v", repeated, prepared)
+	}
+	if len(env.storage.payments.transactions) != 1 || len(env.storage.payments.payments) != 1 {
+		t.Fatalf("repeated prepare made another transaction")
+	}
+
+	completed := env.controller.Complete(ctx, request(t, completeBody))
+	expectCode(t, completed, codeSuccess)
+	if completed.MerchantConfirmID != 1 || completed.ClickTransID != 2145587125 {
+		t.Fatalf("unexpected complete response %+v", completed)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusNew || order.PaidAt == nil {
+		t.Fatalf("order is not passed to the seller: %+v", order)
+	}
+	if pay := env.storage.payments.payments[transaction.PaymentID]; pay.Status != constants.PaymentStatusPaid {
+		t.Fatalf("payment status = %q", pay.Status)
+	}
+	if len(env.events) != 1 || env.events[0].Status != constants.OrderStatusNew {
+		t.Fatalf("order status is not published: %+v", env.events)
+	}
+
+	if repeated := env.controller.Complete(ctx, request(t, completeBody)); repeated != completed {
+		t.Fatalf("repeated complete = %+v, want %+v", repeated, completed)
+	}
+	if len(env.events) != 1 {
+		t.Fatalf("repeated complete published the status again")
+	}
+	expectCode(t, env.controller.Prepare(ctx, request(t, prepareBody)), codeAlreadyPaid)
+}
+
+func TestClickRequestErrors(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	forged := strings.Replace(prepareBody, "amount=125000.00", "amount=1000.00", 1)
+	expectCode(t, env.controller.Prepare(ctx, request(t, forged)), codeSignCheckFailed)
+	otherService := strings.Replace(prepareBody, "service_id=12345", "service_id=54321", 1)
+	expectCode(t, env.controller.Prepare(ctx, request(t, otherService)), codeSignCheckFailed)
+	expectCode(t, env.controller.Prepare(ctx, request(t, "service_id=12345&action=0")), codeBadRequest)
+	expectCode(t, env.controller.Prepare(ctx, request(t, wrongActionBody)), codeActionNotFound)
+	expectCode(t, env.controller.Prepare(ctx, request(t, wrongAmountBody)), codeIncorrectAmount)
+	expectCode(t, env.controller.Prepare(ctx, request(t, unknownOrderBody)), codeOrderNotFound)
+	if len(env.storage.payments.transactions) != 0 {
+		t.Fatalf("refused requests made transactions: %+v", env.storage.payments.transactions)
+	}
+
+	expectCode(t, env.controller.Complete(ctx, request(t, completeBody)), codeTransactionNotFound)
+	expectCode(t, env.controller.Prepare(ctx, request(t, prepareBody)), codeSuccess)
+	expectCode(t, env.controller.Complete(ctx, request(t, unknownPrepareBody)), codeTransactionNotFound)
+}
+
+func TestClickFailedComplete(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	expectCode(t, env.controller.Prepare(ctx, request(t, prepareBody)), codeSuccess)
+	expectCode(t, env.controller.Complete(ctx, request(t, failedCompleteBody)), codeTransactionCanceled)
+
+	transaction := env.storage.payments.transactions[1]
+	if transaction.Status != constants.ClickStatusCancelled || !strings.Contains(transaction.Error, "-5017") {
+		t.Fatalf("transaction is not cancelled: %+v", transaction)
+	}
+	if pay := env.storage.payments.payments[transaction.PaymentID]; pay.Status != constants.PaymentStatusCancelled {
+		t.Fatalf("payment status = %q", pay.Status)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusAwaitingPayment {
+		t.Fatalf("order status = %q, the customer can still pay it", order.Status)
+	}
+
+	expectCode(t, env.controller.Complete(ctx, request(t, completeBody)), codeTransactionCanceled)
+	expectCode(t, env.controller.Prepare(ctx, request(t, prepareBody)), codeTransactionCanceled)
+}
+
+func TestClickCompleteOfClosedOrder(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	expectCode(t, env.controller.Prepare(ctx, request(t, prepareBody)), codeSuccess)
+
+	order := env.storage.orders.orders[testOrderID]
+	order.Status = constants.OrderStatusCancelled
+	env.storage.orders.orders[testOrderID] = order
+	expectCode(t, env.controller.Complete(ctx, request(t, completeBody)), codeTransactionCanceled)
+	if transaction := env.storage.payments.transactions[1]; transaction.Status != constants.ClickStatusCancelled {
+		t.Fatalf("transaction of a closed order is not cancelled: %+v", transaction)
+	}
+	if len(env.events) != 0 {
+		t.Fatalf("status of a closed order is published: %+v", env.events)
+	}
+}
@@ -0,0 +1,28 @@
+package click
+
+// Error codes of the Click SHOP API with the notes Click expects
+const (
+	codeSuccess             = 0
+	codeSignCheckFailed     = -1
+	codeIncorrectAmount     = -2
+	codeActionNotFound      = -3
+	codeAlreadyPaid         = -4
+	codeOrderNotFound       = -5
+	codeTransactionNotFound = -6
+	codeUpdateFailed        = -7
+	codeBadRequest          = -8
+	codeTransactionCanceled = -9
+)
+
+var errorNotes = map[int]string{
+	codeSuccess:             "Success",
+	codeSignCheckFailed:     "SIGN CHECK FAILED!",
+	codeIncorrectAmount:     "Incorrect parameter amount",
+	codeActionNotFound:      "Action not found",
+	codeAlreadyPaid:         "Already paid",
+	codeOrderNotFound:       "User does not exist",
+	codeTransactionNotFound: "Transaction does not exist",
+	codeUpdateFailed:        "Failed to update user",
+	codeBadRequest:          "Error in request from click",
+	codeTransactionCanceled: "Transaction cancelled",
+}
@@ -0,0 +1,204 @@
+package courier
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	notificationcontroller "delivery/controllers/notification"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/storage"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type CourierController interface {
+	CreateCourier(ctx context.Context, req entities.CourierReq) (entities.Courier, error)
+	GetCouriers(ctx context.Context, status string, limit, page int) (entities.CourierList, error)
+	GetCourier(ctx context.Context, id string) (entities.Courier, error)
+	UpdateCourier(ctx context.Context, req entities.CourierReq) error
+	DeleteCourier(ctx context.Context, id string) error
+	BlockCourier(ctx context.Context, id, reason string) error
+	UnblockCourier(ctx context.Context, id string) error
+	GoOnline(ctx context.Context, courierId string) (entities.CourierShift, error)
+	GoOffline(ctx context.Context, courierId string) (entities.CourierShift, error)
+	GetCourierShifts(ctx context.Context, courierId string, limit, page int) ([]entities.CourierShift, error)
+	IngestLocation(ctx context.Context, courierId string, points []entities.LocationPoint) (entities.LocationIngestRes, error)
+}
+
+type courierController struct {
+	log      logger.LoggerI
+	storage  storage.Storage
+	cfg      *configs.Configuration
+	redis    *redis.Client
+	tracker  trackingcontroller.TrackingController
+	notifier notificationcontroller.NotificationController
+}
+
+func NewCourierController(log logger.LoggerI, storage storage.Storage, redis *redis.Client, tracker trackingcontroller.TrackingController, notifier notificationcontroller.NotificationController) CourierController {
+	return courierController{
+		log:      log,
+		storage:  storage,
+		cfg:      configs.Config(),
+		redis:    redis,
+		tracker:  tracker,
+		notifier: notifier,
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (c courierController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	c.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func (c courierController) CreateCourier(ctx context.Context, req entities.CourierReq) (entities.Courier, error) {
+	c.log.Info("CreateCourier started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, PhoneNumber: %s, VehicleType: %s", req.ID, req.PhoneNumber, req.VehicleType)))
+
+	err := c.storage.Courier().CreateCourier(ctx, req)
+	if err != nil {
+		return entities.Courier{}, c.internalError("CreateCourier", err)
+	}
+
+	courier, err := c.storage.Courier().GetCourier(ctx, req.ID)
+	if err != nil {
+		return entities.Courier{}, c.internalError("CreateCourier", err)
+	}
+
+	c.log.Info("CreateCourier finished")
+	return courier, nil
+}
+
+func (c courierController) GetCouriers(ctx context.Context, status string, limit, page int) (entities.CourierList, error) {
+	c.log.Info("GetCouriers started: ", zap.String("Status", status))
+
+	couriers, count, err := c.storage.Courier().GetCouriers(ctx, status, limit, (page-1)*limit)
+	if err != nil {
+		return entities.CourierList{}, c.internalError("GetCouriers", err)
+	}
+
+	c.log.Info("GetCouriers finished")
+	return entities.CourierList{Couriers: couriers, Count: count}, nil
+}
+
+func (c courierController) GetCourier(ctx context.Context, id string) (entities.Courier, error) {
+	c.log.Info("GetCourier started: ", zap.String("CourierID", id))
+
+	courier, err := c.storage.Courier().GetCourier(ctx, id)
+	if err != nil {
+		return entities.Courier{}, c.internalError("GetCourier", err)
+	}
+
+	c.log.Info("GetCourier finished")
+	return courier, nil
+}
+
+func (c courierController) UpdateCourier(ctx context.Context, req entities.CourierReq) error {
+	c.log.Info("UpdateCourier started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, VehicleType: %s", req.ID, req.VehicleType)))
+
+	err := c.storage.Courier().UpdateCourier(ctx, req)
+	if err != nil {
+		return c.internalError("UpdateCourier", err)
+	}
+
+	c.log.Info("UpdateCourier finished")
+	return nil
+}
+
+func (c courierController) DeleteCourier(ctx context.Context, id string) error {
+	c.log.Info("DeleteCourier started: ", zap.String("CourierID", id))
+
+	err := c.storage.Courier().DeleteCourier(ctx, id)
+	if err != nil {
+		return c.internalError("DeleteCourier", err)
+	}
+	c.forgetPosition(ctx, id)
+
+	c.log.Info("DeleteCourier finished")
+	return nil
+}
+
+// BlockCourier stops the courier from taking orders and ends the current shift
+func (c courierController) BlockCourier(ctx context.Context, id, reason string) error {
+	c.log.Info("BlockCourier started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, Reason: %s", id, reason)))
+
+	err := c.storage.Courier().SetCourierStatus(ctx, id, constants.CourierStatusBlocked, reason)
+	if err != nil {
+		return c.internalError("BlockCourier", err)
+	}
+	c.forgetPosition(ctx, id)
+
+	c.log.Info("BlockCourier finished")
+	return nil
+}
+
+func (c courierController) UnblockCourier(ctx context.Context, id string) error {
+	c.log.Info("UnblockCourier started: ", zap.String("CourierID", id))
+
+	err := c.storage.Courier().SetCourierStatus(ctx, id, constants.CourierStatusActive, "")
+	if err != nil {
+		return c.internalError("UnblockCourier", err)
+	}
+
+	c.log.Info("UnblockCourier finished")
+	return nil
+}
+
+// GoOnline starts a shift, the courier is available for orders until GoOffline
+func (c courierController) GoOnline(ctx context.Context, courierId string) (entities.CourierShift, error) {
+	c.log.Info("GoOnline started: ", zap.String("CourierID", courierId))
+
+	courier, err := c.storage.Courier().GetCourier(ctx, courierId)
+	if err != nil {
+		return entities.CourierShift{}, c.internalError("GoOnline", err)
+	}
+	if courier.Status == constants.CourierStatusBlocked {
+		return entities.CourierShift{}, e.ErrCourierBlocked
+	}
+
+	shift, err := c.storage.Courier().StartShift(ctx, courierId)
+	if err != nil {
+		return entities.CourierShift{}, c.internalError("GoOnline", err)
+	}
+
+	c.log.Info("GoOnline finished")
+	return shift, nil
+}
+
+func (c courierController) GoOffline(ctx context.Context, courierId string) (entities.CourierShift, error) {
+	c.log.Info("GoOffline started: ", zap.String("CourierID", courierId))
+
+	shift, err := c.storage.Courier().EndShift(ctx, courierId)
+	if err != nil {
+		return entities.CourierShift{}, c.internalError("GoOffline", err)
+	}
+	c.forgetPosition(ctx, courierId)
+
+	c.log.Info("GoOffline finished")
+	return shift, nil
+}
+
+func (c courierController) GetCourierShifts(ctx context.Context, courierId string, limit, page int) ([]entities.CourierShift, error) {
+	c.log.Info("GetCourierShifts started: ", zap.String("CourierID", courierId))
+
+	shifts, err := c.storage.Courier().GetCourierShifts(ctx, courierId, limit, (page-1)*limit)
+	if err != nil {
+		return nil, c.internalError("GetCourierShifts", err)
+	}
+
+	c.log.Info("GetCourierShifts finished")
+	return shifts, nil
+}
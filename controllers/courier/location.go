@@ -0,0 +1,239 @@
+package courier
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// courierActiveStatuses are order statuses in which the courier position is tracked
+var courierActiveStatuses = []string{
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+	constants.OrderStatusPickedUp,
+}
+
+// IngestLocation stores the latest position of the courier in Redis GEO and
+// appends a downsampled track to the courier's active orders.
+// It is called every few seconds by every courier, so it does not log successful calls.
+func (c courierController) IngestLocation(ctx context.Context, courierId string, points []entities.LocationPoint) (entities.LocationIngestRes, error) {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].RecordedAt.Before(points[j].RecordedAt)
+	})
+
+	latest := points[len(points)-1]
+	updated, err := c.updatePosition(ctx, courierId, latest)
+	if err != nil {
+		return entities.LocationIngestRes{}, c.internalError("IngestLocation", err)
+	}
+	if updated {
+		c.tracker.PublishPosition(ctx, courierId, latest)
+		c.notifyArriving(ctx, courierId, latest)
+	}
+
+	tracked, err := c.trackPoints(ctx, courierId, points)
+	if err != nil {
+		return entities.LocationIngestRes{}, c.internalError("IngestLocation", err)
+	}
+
+	return entities.LocationIngestRes{Accepted: len(points), Tracked: tracked}, nil
+}
+
+// updatePosition moves the courier on the map unless a newer position is already known,
+// batches buffered offline may arrive after fresher single points
+func (c courierController) updatePosition(ctx context.Context, courierId string, point entities.LocationPoint) (bool, error) {
+	seen, err := c.redis.ZScore(ctx, constants.CourierSeenKey, courierId).Result()
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("failed to get courier last seen time: %w", err)
+	}
+	if err == nil && int64(seen) >= point.RecordedAt.Unix() {
+		return false, nil
+	}
+
+	position, err := json.Marshal(point)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal courier position: %w", err)
+	}
+
+	pipe := c.redis.TxPipeline()
+	pipe.GeoAdd(ctx, constants.CourierGeoKey, &redis.GeoLocation{
+		Name:      courierId,
+		Longitude: point.Long,
+		Latitude:  point.Lat,
+	})
+	pipe.ZAdd(ctx, constants.CourierSeenKey, &redis.Z{Score: float64(point.RecordedAt.Unix()), Member: courierId})
+	pipe.Set(ctx, constants.CourierPositionKeyPrefix+courierId, position, constants.CourierPositionTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to save courier position: %w", err)
+	}
+	return true, nil
+}
+
+// notifyArriving tells the customers the courier is about to reach them, once per order
+func (c courierController) notifyArriving(ctx context.Context, courierId string, point entities.LocationPoint) {
+	orders, err := c.activeOrders(ctx, courierId)
+	if err != nil {
+		c.log.Error("error in notifyArriving: ", zap.Error(err))
+		return
+	}
+	for _, order := range orders {
+		if order.Status != constants.OrderStatusPickedUp {
+			continue
+		}
+		dropoff := order.AddressLocation
+		if utils.DistanceKm(point.Lat, point.Long, dropoff.Lat, dropoff.Long) > constants.ArrivingRadiusKm {
+			continue
+		}
+		first, err := c.redis.SetNX(ctx, constants.ArrivingKeyPrefix+order.ID, courierId, constants.ArrivingKeyTTL).Result()
+		if err != nil {
+			c.log.Error("error in notifyArriving: ", zap.Error(err))
+			continue
+		}
+		if !first {
+			continue
+		}
+		err = c.notifier.Notify(ctx, entities.Notification{
+			UserID:   order.UserID,
+			OrderID:  &order.ID,
+			Event:    constants.PushEventCourierArriving,
+			Template: constants.TemplateCourierArriving,
+			Vars: map[string]string{
+				"order_number": strconv.FormatInt(order.Number, 10),
+				"handoff_code": order.HandoffCode,
+			},
+		})
+		if err != nil {
+			c.log.Error("error in notifyArriving: ", zap.Error(err))
+		}
+	}
+}
+
+// trackPoints keeps a point when the courier moved far enough or enough time passed
+// since the last kept one and saves it for every active order of the courier
+func (c courierController) trackPoints(ctx context.Context, courierId string, points []entities.LocationPoint) (int, error) {
+	key := constants.CourierTrackLastKeyPrefix + courierId
+
+	var last *entities.LocationPoint
+	data, err := c.redis.Get(ctx, key).Bytes()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to get last tracked point: %w", err)
+	}
+	if err == nil {
+		var point entities.LocationPoint
+		if err := json.Unmarshal(data, &point); err == nil {
+			last = &point
+		}
+	}
+
+	var kept []entities.LocationPoint
+	for _, point := range points {
+		if last != nil {
+			if !point.RecordedAt.After(last.RecordedAt) {
+				continue
+			}
+			moved := utils.DistanceKm(last.Lat, last.Long, point.Lat, point.Long) >= constants.TrackMinDistanceKm
+			if !moved && point.RecordedAt.Sub(last.RecordedAt) < constants.TrackMinInterval {
+				continue
+			}
+		}
+		kept = append(kept, point)
+		last = &kept[len(kept)-1]
+	}
+	if len(kept) == 0 {
+		return 0, nil
+	}
+
+	orders, err := c.activeOrders(ctx, courierId)
+	if err != nil {
+		return 0, err
+	}
+
+	track := make([]entities.OrderTrackPoint, 0, len(kept)*len(orders))
+	for _, order := range orders {
+		for _, point := range kept {
+			track = append(track, entities.OrderTrackPoint{
+				OrderID:    order.ID,
+				CourierID:  courierId,
+				Latitude:   point.Lat,
+				Longitude:  point.Long,
+				Accuracy:   point.Accuracy,
+				Speed:      point.Speed,
+				Heading:    point.Heading,
+				RecordedAt: point.RecordedAt,
+			})
+		}
+	}
+	if err := c.storage.Courier().InsertTrackPoints(ctx, track); err != nil {
+		return 0, err
+	}
+
+	data, err = json.Marshal(last)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal last tracked point: %w", err)
+	}
+	if err := c.redis.Set(ctx, key, data, constants.CourierPositionTTL).Err(); err != nil {
+		return 0, fmt.Errorf("failed to save last tracked point: %w", err)
+	}
+	return len(track), nil
+}
+
+// activeOrders returns the orders the courier carries from the cache, they are loaded from
+// the database when the cache was cleared by a change of the courier's orders or expired
+func (c courierController) activeOrders(ctx context.Context, courierId string) ([]entities.CourierActiveOrder, error) {
+	key := constants.CourierOrdersKeyPrefix + courierId
+	data, err := c.redis.Get(ctx, key).Bytes()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to get courier orders: %w", err)
+	}
+	var active []entities.CourierActiveOrder
+	if err == nil {
+		if err := json.Unmarshal(data, &active); err == nil {
+			return active, nil
+		}
+	}
+
+	orders, err := c.storage.Courier().GetCourierOrders(ctx, courierId, courierActiveStatuses)
+	if err != nil {
+		return nil, err
+	}
+	active = make([]entities.CourierActiveOrder, 0, len(orders))
+	for _, order := range orders {
+		active = append(active, entities.CourierActiveOrder{
+			ID:              order.ID,
+			Number:          order.Number,
+			UserID:          order.UserID,
+			Status:          order.Status,
+			AddressLocation: order.AddressLocation,
+			HandoffCode:     order.HandoffCode,
+		})
+	}
+	// the next push loads the orders again when they could not be cached
+	data, err = json.Marshal(active)
+	if err == nil {
+		err = c.redis.Set(ctx, key, data, constants.CourierOrdersTTL).Err()
+	}
+	if err != nil {
+		c.log.Error("error in activeOrders: ", zap.Error(err))
+	}
+	return active, nil
+}
+
+// forgetPosition removes the courier from the map so that they are not offered orders
+func (c courierController) forgetPosition(ctx context.Context, courierId string) {
+	pipe := c.redis.TxPipeline()
+	pipe.ZRem(ctx, constants.CourierGeoKey, courierId)
+	pipe.ZRem(ctx, constants.CourierSeenKey, courierId)
+	pipe.Del(ctx, constants.CourierPositionKeyPrefix+courierId, constants.CourierTrackLastKeyPrefix+courierId)
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.log.Error("error in forgetPosition: ", zap.Error(err))
+	}
+}
@@ -0,0 +1,97 @@
+package courier
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/logger"
+	"delivery/storage"
+	"delivery/storage/repo"
+	"os"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// testRedis connects to the Redis at TEST_REDIS_ADDR or localhost and empties DB 15 for the test
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis is not available at %s: %v", addr, err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatalf("flush redis: %v", err)
+	}
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+	})
+	return client
+}
+
+// courierOrdersStorage serves the courier's orders and counts how often they are queried
+type courierOrdersStorage struct {
+	storage.Storage
+	repo.ICourierStorage
+	orders  []entities.Order
+	queries int
+}
+
+func (s *courierOrdersStorage) Courier() repo.ICourierStorage {
+	return s
+}
+
+func (s *courierOrdersStorage) GetCourierOrders(ctx context.Context, courierId string, statuses []string) ([]entities.Order, error) {
+	s.queries++
+	return s.orders, nil
+}
+
+func TestActiveOrdersAreCachedUntilCleared(t *testing.T) {
+	client := testRedis(t)
+	ctx := context.Background()
+	strg := &courierOrdersStorage{orders: []entities.Order{
+		{ID: "order-1", Number: 7, UserID: "user-1", Status: constants.OrderStatusPickedUp,
+			AddressLocation: entities.Location{Lat: 41.3, Long: 69.2}, HandoffCode: "4821"},
+	}}
+	c := courierController{log: logger.NewLogger("test", "error"), storage: strg, redis: client}
+
+	for i := 0; i < 3; i++ {
+		orders, err := c.activeOrders(ctx, "courier-1")
+		if err != nil {
+			t.Fatalf("activeOrders: %v", err)
+		}
+		if len(orders) != 1 || orders[0].ID != "order-1" || orders[0].HandoffCode != "4821" || orders[0].Number != 7 {
+			t.Fatalf("unexpected orders %+v", orders)
+		}
+	}
+	if strg.queries != 1 {
+		t.Fatalf("orders queried %d times, want 1", strg.queries)
+	}
+	if ttl := client.TTL(ctx, constants.CourierOrdersKeyPrefix+"courier-1").Val(); ttl <= 0 || ttl > constants.CourierOrdersTTL {
+		t.Fatalf("cache ttl is %v", ttl)
+	}
+
+	// the order is delivered and the cache is cleared
+	strg.orders = nil
+	client.Del(ctx, constants.CourierOrdersKeyPrefix+"courier-1")
+	for i := 0; i < 2; i++ {
+		orders, err := c.activeOrders(ctx, "courier-1")
+		if err != nil {
+			t.Fatalf("activeOrders: %v", err)
+		}
+		if len(orders) != 0 {
+			t.Fatalf("unexpected orders %+v", orders)
+		}
+	}
+	// a courier without orders is cached too
+	if strg.queries != 2 {
+		t.Fatalf("orders queried %d times, want 2", strg.queries)
+	}
+}
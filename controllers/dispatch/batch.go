@@ -0,0 +1,99 @@
+package dispatch
+
+import (
+	"delivery/entities"
+	"delivery/pkg/utils"
+	"sort"
+	"time"
+)
+
+// batchRules tell which orders one courier can carry together
+type batchRules struct {
+	MaxOrders      int
+	PickupRadiusKm float64
+	MaxBearingDeg  float64
+	ReadyWindow    time.Duration
+}
+
+// batchOrders picks waiting orders that can go with lead: picked up near the lead xozmak,
+// in the same delivery slot, ready at about the same time and going the same way or to
+// a customer next to the lead one. The result starts with lead, the closest matches follow.
+func batchOrders(lead entities.WaitingOrder, waiting []entities.WaitingOrder, rules batchRules, now time.Time) []entities.WaitingOrder {
+	batch := []entities.WaitingOrder{lead}
+	if rules.MaxOrders <= 1 {
+		return batch
+	}
+
+	leadReadyAt := lead.ExpectedReadyAt(now)
+	leadBearing := utils.BearingDeg(lead.Pickup.Lat, lead.Pickup.Long, lead.AddressLocation.Lat, lead.AddressLocation.Long)
+
+	type match struct {
+		order entities.WaitingOrder
+		// detourKm is how far apart the pickups and the drop-offs are
+		detourKm float64
+	}
+	var matches []match
+	for _, order := range waiting {
+		if order.ID == lead.ID || !sameSlot(lead.SlotID, order.SlotID) {
+			continue
+		}
+		pickupKm := utils.DistanceKm(lead.Pickup.Lat, lead.Pickup.Long, order.Pickup.Lat, order.Pickup.Long)
+		if pickupKm > rules.PickupRadiusKm {
+			continue
+		}
+		readyDiff := order.ExpectedReadyAt(now).Sub(leadReadyAt)
+		if readyDiff > rules.ReadyWindow || readyDiff < -rules.ReadyWindow {
+			continue
+		}
+		dropoffKm := utils.DistanceKm(lead.AddressLocation.Lat, lead.AddressLocation.Long, order.AddressLocation.Lat, order.AddressLocation.Long)
+		if dropoffKm > rules.PickupRadiusKm {
+			bearing := utils.BearingDeg(lead.Pickup.Lat, lead.Pickup.Long, order.AddressLocation.Lat, order.AddressLocation.Long)
+			if utils.AngleDiffDeg(leadBearing, bearing) > rules.MaxBearingDeg {
+				continue
+			}
+		}
+		matches = append(matches, match{order: order, detourKm: pickupKm + dropoffKm})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].detourKm < matches[j].detourKm
+	})
+	for _, m := range matches {
+		if len(batch) == rules.MaxOrders {
+			break
+		}
+		batch = append(batch, m.order)
+	}
+	return batch
+}
+
+// deliveryRoute orders the batch the way the courier goes. The xozmaks are close to each
+// other so the route starts at the first one and the nearest customer is always visited next.
+func deliveryRoute(batch []entities.Order, start entities.Location) []entities.Order {
+	left := append([]entities.Order(nil), batch...)
+	route := make([]entities.Order, 0, len(batch))
+	here := start
+	for len(left) > 0 {
+		next := 0
+		for i := range left {
+			if distanceTo(here, left[i]) < distanceTo(here, left[next]) {
+				next = i
+			}
+		}
+		route = append(route, left[next])
+		here = left[next].AddressLocation
+		left = append(left[:next], left[next+1:]...)
+	}
+	return route
+}
+
+func distanceTo(from entities.Location, order entities.Order) float64 {
+	return utils.DistanceKm(from.Lat, from.Long, order.AddressLocation.Lat, order.AddressLocation.Long)
+}
+
+func sameSlot(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
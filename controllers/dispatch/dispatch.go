@@ -0,0 +1,627 @@
+package dispatch
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	notificationcontroller "delivery/controllers/notification"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/utils"
+	"delivery/storage"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dispatchStatuses are order statuses in which a courier is looked for
+var dispatchStatuses = []string{
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+}
+
+// assignStatuses also allow admins to hand over an order that is already on the way
+var assignStatuses = []string{
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+	constants.OrderStatusPickedUp,
+}
+
+type DispatchController interface {
+	Dispatch(ctx context.Context, orderId string) error
+	GetCourierOffer(ctx context.Context, courierId string) (*entities.CourierOffer, error)
+	AcceptOffer(ctx context.Context, courierId, orderId string) (entities.Order, error)
+	DeclineOffer(ctx context.Context, courierId, orderId string) error
+	AssignCourier(ctx context.Context, req entities.AssignCourierReq) error
+	RunDispatchWorker(ctx context.Context)
+}
+
+type dispatchController struct {
+	log      logger.LoggerI
+	storage  storage.Storage
+	cfg      *configs.Configuration
+	offers   offerStore
+	notifier notificationcontroller.NotificationController
+	tracker  trackingcontroller.TrackingController
+}
+
+func NewDispatchController(log logger.LoggerI, storage storage.Storage, redis *redis.Client, notifier notificationcontroller.NotificationController, tracker trackingcontroller.TrackingController) DispatchController {
+	return dispatchController{
+		log:      log,
+		storage:  storage,
+		cfg:      configs.Config(),
+		offers:   offerStore{redis: redis},
+		notifier: notifier,
+		tracker:  tracker,
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (d dispatchController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	d.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// Dispatch offers the order to the best courier near the xozmak. When nobody is
+// available the attempt is repeated by the dispatch worker.
+func (d dispatchController) Dispatch(ctx context.Context, orderId string) error {
+	d.log.Info("Dispatch started: ", zap.String("OrderID", orderId))
+
+	order, err := d.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return d.internalError("Dispatch", err)
+	}
+	if order.CourierID != nil || !utils.InEnums(order.Status, dispatchStatuses) {
+		d.log.Info("Dispatch finished, order does not need a courier")
+		return nil
+	}
+	active, err := d.offers.active(ctx, orderId)
+	if err != nil {
+		return d.internalError("Dispatch", err)
+	}
+	if active {
+		d.log.Info("Dispatch finished, order is already offered")
+		return nil
+	}
+
+	candidates, err := d.candidates(ctx, order)
+	if err != nil {
+		return d.internalError("Dispatch", err)
+	}
+
+	var batch []entities.Order
+	if len(candidates) > 0 {
+		batch, err = d.batch(ctx, order)
+		if err != nil {
+			return d.internalError("Dispatch", err)
+		}
+	}
+
+	for _, candidate := range candidates {
+		orders := offerable(batch, candidate, d.cfg.CourierCashLimit)
+		if len(orders) == 0 || orders[0].ID != order.ID {
+			// the courier holds too much cash to take the cash order
+			continue
+		}
+		// a busy courier gets only as many orders as they can still carry
+		if free := d.cfg.CourierMaxLoad - candidate.Load; len(orders) > free {
+			orders = orders[:free]
+		}
+		orderIds := make([]string, 0, len(orders))
+		for _, o := range orders {
+			orderIds = append(orderIds, o.ID)
+		}
+
+		created, err := d.offers.createBatch(ctx, orderIds, candidate.CourierID, d.cfg.CourierOfferTimeout)
+		if err != nil {
+			return d.internalError("Dispatch", err)
+		}
+		if !created {
+			// the courier is thinking about another order
+			continue
+		}
+
+		d.recordOffer(ctx, orders, candidate)
+		d.log.Info("Dispatch finished: ",
+			zap.String("Offer: ", fmt.Sprintf("CourierID: %s, Orders: %d", candidate.CourierID, len(orders))))
+		return nil
+	}
+
+	if err := d.offers.retry(ctx, order.ID, time.Now().Add(constants.DispatchRetryInterval)); err != nil {
+		return d.internalError("Dispatch", err)
+	}
+	d.log.Info("Dispatch finished, no courier available")
+	return nil
+}
+
+// candidates returns couriers that can take the order, the best first
+func (d dispatchController) candidates(ctx context.Context, order entities.Order) ([]entities.DispatchCandidate, error) {
+	xozmak, err := d.storage.Order().GetXozmakByID(ctx, order.XozmakID)
+	if err != nil {
+		return nil, err
+	}
+
+	nearby, err := d.offers.nearby(ctx, order.ID, xozmak.Location, d.cfg.DispatchRadiusKm,
+		time.Now().Add(-constants.CourierPositionTTL))
+	if err != nil || len(nearby) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(nearby))
+	for _, candidate := range nearby {
+		ids = append(ids, candidate.CourierID)
+	}
+	profiles, err := d.storage.Courier().GetDispatchCandidates(ctx, ids, assignStatuses)
+	if err != nil {
+		return nil, err
+	}
+	return rankCandidates(nearby, profiles, d.cfg.CourierMaxLoad), nil
+}
+
+// batch returns the order followed by other waiting orders the same courier can carry,
+// only the order itself when batching is off or nothing goes with it
+func (d dispatchController) batch(ctx context.Context, order entities.Order) ([]entities.Order, error) {
+	batch := []entities.Order{order}
+	if d.cfg.BatchMaxOrders <= 1 {
+		return batch, nil
+	}
+
+	waiting, err := d.storage.Order().GetWaitingOrders(ctx, dispatchStatuses, constants.DispatchWaitingOrdersLimit)
+	if err != nil {
+		return nil, err
+	}
+	orderIds := make([]string, 0, len(waiting))
+	for _, w := range waiting {
+		orderIds = append(orderIds, w.ID)
+	}
+	offered, err := d.offers.offered(ctx, orderIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var lead *entities.WaitingOrder
+	free := make([]entities.WaitingOrder, 0, len(waiting))
+	for i := range waiting {
+		if waiting[i].ID == order.ID {
+			lead = &waiting[i]
+		} else if !offered[waiting[i].ID] {
+			free = append(free, waiting[i])
+		}
+	}
+	if lead == nil {
+		return batch, nil
+	}
+
+	rules := batchRules{
+		MaxOrders:      d.cfg.BatchMaxOrders,
+		PickupRadiusKm: d.cfg.BatchPickupRadiusKm,
+		MaxBearingDeg:  d.cfg.BatchMaxBearingDeg,
+		ReadyWindow:    d.cfg.BatchReadyWindow,
+	}
+	batch = make([]entities.Order, 0, rules.MaxOrders)
+	for _, w := range batchOrders(*lead, free, rules, time.Now()) {
+		batch = append(batch, w.Order)
+	}
+	return batch, nil
+}
+
+// offerable drops cash orders from the batch when the courier has to hand the collected cash over first
+func offerable(batch []entities.Order, candidate entities.DispatchCandidate, cashLimit int64) []entities.Order {
+	if !entities.CashOrdersBlocked(candidate.CashBalance, cashLimit) {
+		return batch
+	}
+	orders := make([]entities.Order, 0, len(batch))
+	for _, order := range batch {
+		if order.PaymentMethod != constants.PaymentMethodCash {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+func (d dispatchController) recordOffer(ctx context.Context, orders []entities.Order, candidate entities.DispatchCandidate) {
+	numbers := make([]string, 0, len(orders))
+	for _, order := range orders {
+		numbers = append(numbers, fmt.Sprintf("#%d", order.Number))
+
+		note := fmt.Sprintf("courier_id=%s distance_km=%.2f load=%d rating=%.2f",
+			candidate.CourierID, candidate.DistanceKm, candidate.Load, candidate.Rating)
+		if len(orders) > 1 {
+			note += fmt.Sprintf(" batch_size=%d", len(orders))
+		}
+		err := d.storage.Order().InsertOrderHistory(ctx, entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionCourierOffered,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			ActorRole:  constants.SystemRole,
+			Note:       note,
+		})
+		if err != nil {
+			d.log.Error("error in recordOffer: ", zap.Error(err))
+		}
+	}
+
+	template := constants.TemplateCourierOffer
+	vars := map[string]string{
+		"order_number":  strconv.FormatInt(orders[0].Number, 10),
+		"offer_seconds": strconv.Itoa(int(d.cfg.CourierOfferTimeout.Seconds())),
+	}
+	if len(orders) > 1 {
+		template = constants.TemplateCourierBatchOffer
+		vars["order_count"] = strconv.Itoa(len(orders))
+		vars["order_numbers"] = strings.Join(numbers, ", ")
+	}
+	err := d.notifier.Notify(ctx, entities.Notification{
+		UserID:   candidate.CourierID,
+		OrderID:  &orders[0].ID,
+		Event:    constants.PushEventCourierOffer,
+		Template: template,
+		Vars:     vars,
+	})
+	if err != nil {
+		d.log.Error("error in recordOffer: ", zap.Error(err))
+	}
+}
+
+func (d dispatchController) GetCourierOffer(ctx context.Context, courierId string) (*entities.CourierOffer, error) {
+	d.log.Info("GetCourierOffer started: ", zap.String("CourierID", courierId))
+
+	orderId, expiresAt, err := d.offers.courierOffer(ctx, courierId)
+	if err != nil {
+		return nil, d.internalError("GetCourierOffer", err)
+	}
+	if orderId == "" {
+		d.log.Info("GetCourierOffer finished, no offer")
+		return nil, nil
+	}
+
+	order, err := d.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, d.internalError("GetCourierOffer", err)
+	}
+
+	batch, err := d.offers.batch(ctx, orderId)
+	if err != nil {
+		return nil, d.internalError("GetCourierOffer", err)
+	}
+	for _, id := range batch {
+		other, err := d.storage.Order().GetOrder(ctx, id)
+		if err != nil {
+			return nil, d.internalError("GetCourierOffer", err)
+		}
+		order.Batch = append(order.Batch, other)
+	}
+
+	d.log.Info("GetCourierOffer finished")
+	return &entities.CourierOffer{Order: order, ExpiresAt: expiresAt}, nil
+}
+
+func (d dispatchController) AcceptOffer(ctx context.Context, courierId, orderId string) (entities.Order, error) {
+	d.log.Info("AcceptOffer started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s", orderId, courierId)))
+
+	taken, err := d.offers.takeBatch(ctx, orderId, courierId)
+	if err != nil {
+		return entities.Order{}, d.internalError("AcceptOffer", err)
+	}
+	if len(taken) == 0 {
+		return entities.Order{}, e.ErrOfferNotFound
+	}
+
+	order, err := d.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		d.requeue(ctx, courierId, taken)
+		return entities.Order{}, d.internalError("AcceptOffer", err)
+	}
+	if len(taken) > 1 {
+		return d.acceptBatch(ctx, courierId, order, taken[1:])
+	}
+
+	err = d.storage.Order().AssignCourier(ctx, entities.CourierAssignment{
+		OrderID:   order.ID,
+		CourierID: courierId,
+		Statuses:  dispatchStatuses,
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionCourierAssigned,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			ActorRole:  constants.CourierRole,
+			ActorID:    &courierId,
+		},
+	})
+	if err != nil {
+		d.requeue(ctx, courierId, taken)
+		return entities.Order{}, d.internalError("AcceptOffer", err)
+	}
+	order.CourierID = &courierId
+	d.forgetCourierOrders(ctx, courierId)
+	d.publishCourier(ctx, order.ID, courierId)
+	d.notifyCourierAssigned(ctx, order)
+
+	d.log.Info("AcceptOffer finished")
+	return order, nil
+}
+
+// acceptBatch gives the courier the lead order with the rest of the offered batch. The orders
+// are numbered in the order the courier delivers them, the lead one is returned with the rest in Batch.
+func (d dispatchController) acceptBatch(ctx context.Context, courierId string, lead entities.Order, otherIds []string) (entities.Order, error) {
+	taken := append([]string{lead.ID}, otherIds...)
+	orders := []entities.Order{lead}
+	for _, id := range otherIds {
+		order, err := d.storage.Order().GetOrder(ctx, id)
+		if err != nil {
+			d.requeue(ctx, courierId, taken)
+			return entities.Order{}, d.internalError("acceptBatch", err)
+		}
+		orders = append(orders, order)
+	}
+	xozmak, err := d.storage.Order().GetXozmakByID(ctx, lead.XozmakID)
+	if err != nil {
+		d.requeue(ctx, courierId, taken)
+		return entities.Order{}, d.internalError("acceptBatch", err)
+	}
+	route := deliveryRoute(orders, xozmak.Location)
+
+	req := entities.BatchAssignment{BatchID: uuid.NewString(), CourierID: courierId}
+	for _, order := range route {
+		req.Orders = append(req.Orders, entities.CourierAssignment{
+			OrderID:   order.ID,
+			CourierID: courierId,
+			Statuses:  dispatchStatuses,
+			History: entities.OrderHistory{
+				OrderID:    order.ID,
+				Action:     constants.OrderActionCourierAssigned,
+				FromStatus: order.Status,
+				ToStatus:   order.Status,
+				ActorRole:  constants.CourierRole,
+				ActorID:    &courierId,
+				Note:       "batch_id=" + req.BatchID,
+			},
+		})
+	}
+	assigned, err := d.storage.Order().AssignBatch(ctx, req)
+	if err != nil {
+		d.requeue(ctx, courierId, taken)
+		return entities.Order{}, d.internalError("acceptBatch", err)
+	}
+	d.forgetCourierOrders(ctx, courierId)
+
+	seqs := make(map[string]int, len(assigned))
+	for i, id := range assigned {
+		seqs[id] = i + 1
+	}
+	var accepted []entities.Order
+	for _, order := range route {
+		seq, ok := seqs[order.ID]
+		if !ok {
+			continue
+		}
+		order.CourierID = &courierId
+		order.BatchID = &req.BatchID
+		order.BatchSeq = &seq
+		d.publishCourier(ctx, order.ID, courierId)
+		d.notifyCourierAssigned(ctx, order)
+		// the lead order goes first unless it was cancelled while the courier was answering
+		if order.ID == lead.ID {
+			accepted = append([]entities.Order{order}, accepted...)
+		} else {
+			accepted = append(accepted, order)
+		}
+	}
+	result := accepted[0]
+	result.Batch = accepted[1:]
+
+	d.log.Info("AcceptOffer finished: ", zap.String("BatchID", req.BatchID))
+	return result, nil
+}
+
+// requeue gives the taken orders back to dispatch when the courier could not get them, their
+// offers are gone already so nothing else would look for a courier. An order that can not be
+// dispatched right now is left to the worker.
+func (d dispatchController) requeue(ctx context.Context, courierId string, orderIds []string) {
+	if err := d.offers.release(ctx, courierId, orderIds); err != nil {
+		d.log.Error("error in requeue: ", zap.Error(err))
+	}
+	for _, orderId := range orderIds {
+		if err := d.Dispatch(ctx, orderId); err != nil {
+			d.log.Warn("could not dispatch order", zap.String("OrderID", orderId), zap.Error(err))
+			if err := d.offers.retry(ctx, orderId, time.Now().Add(constants.DispatchRetryInterval)); err != nil {
+				d.log.Error("error in requeue: ", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DeclineOffer passes the order to the next courier
+func (d dispatchController) DeclineOffer(ctx context.Context, courierId, orderId string) error {
+	d.log.Info("DeclineOffer started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s", orderId, courierId)))
+
+	taken, err := d.offers.takeBatch(ctx, orderId, courierId)
+	if err != nil {
+		return d.internalError("DeclineOffer", err)
+	}
+	if len(taken) == 0 {
+		return e.ErrOfferNotFound
+	}
+
+	// orders of a declined batch look for couriers again one by one
+	for _, id := range taken {
+		if err := d.Dispatch(ctx, id); err != nil {
+			d.log.Error("error in DeclineOffer: ", zap.Error(err))
+		}
+	}
+
+	d.log.Info("DeclineOffer finished")
+	return nil
+}
+
+// AssignCourier lets an admin pick the courier by hand or replace the current one
+func (d dispatchController) AssignCourier(ctx context.Context, req entities.AssignCourierReq) error {
+	d.log.Info("AssignCourier started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s, AdminID: %s, Force: %t", req.OrderID, req.CourierID, req.Actor.ID, req.Force)))
+
+	order, err := d.storage.Order().GetOrder(ctx, req.OrderID)
+	if err != nil {
+		return d.internalError("AssignCourier", err)
+	}
+	if !utils.InEnums(order.Status, assignStatuses) {
+		return e.ErrCourierAssignNotAllowed
+	}
+	if order.CourierID != nil && *order.CourierID == req.CourierID {
+		return nil
+	}
+
+	courier, err := d.storage.Courier().GetCourier(ctx, req.CourierID)
+	if err != nil {
+		return d.internalError("AssignCourier", err)
+	}
+	if courier.Status == constants.CourierStatusBlocked {
+		return e.ErrCourierBlocked
+	}
+
+	// the courier must be one dispatch could have offered the order to, unless the admin forces it
+	profiles, err := d.storage.Courier().GetDispatchCandidates(ctx, []string{courier.ID}, assignStatuses)
+	if err != nil {
+		return d.internalError("AssignCourier", err)
+	}
+	var candidate *entities.DispatchCandidate
+	if len(profiles) > 0 {
+		candidate = &profiles[0]
+	}
+	broken := brokenRules(order, candidate, d.cfg.CourierMaxLoad, d.cfg.CourierCashLimit)
+	if len(broken) > 0 && !req.Force {
+		return ruleErrors[broken[0]]
+	}
+
+	if _, err := d.offers.cancel(ctx, order.ID); err != nil {
+		return d.internalError("AssignCourier", err)
+	}
+
+	action := constants.OrderActionCourierAssigned
+	var notes []string
+	if order.CourierID != nil {
+		action = constants.OrderActionCourierReassigned
+		notes = append(notes, "previous_courier_id="+*order.CourierID)
+	}
+	if len(broken) > 0 {
+		notes = append(notes, "forced="+strings.Join(broken, ","))
+	}
+	err = d.storage.Order().AssignCourier(ctx, entities.CourierAssignment{
+		OrderID:       order.ID,
+		CourierID:     courier.ID,
+		FromCourierID: order.CourierID,
+		Statuses:      assignStatuses,
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     action,
+			FromStatus: order.Status,
+			ToStatus:   order.Status,
+			ActorRole:  req.Actor.Role,
+			ActorID:    &req.Actor.ID,
+			Note:       strings.Join(notes, " "),
+		},
+	})
+	if err != nil {
+		return d.internalError("AssignCourier", err)
+	}
+	d.forgetCourierOrders(ctx, courier.ID)
+	if order.CourierID != nil {
+		d.forgetCourierOrders(ctx, *order.CourierID)
+	}
+	d.publishCourier(ctx, order.ID, courier.ID)
+	d.notifyCourierAssigned(ctx, order)
+
+	err = d.notifier.Notify(ctx, entities.Notification{
+		UserID:   courier.ID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventCourierAssigned,
+		Template: constants.TemplateCourierOrderAssigned,
+		Vars:     map[string]string{"order_number": strconv.FormatInt(order.Number, 10)},
+	})
+	if err != nil {
+		d.log.Error("error in AssignCourier: ", zap.Error(err))
+	}
+
+	d.log.Info("AssignCourier finished")
+	return nil
+}
+
+// forgetCourierOrders clears the cached orders of the courier, their next location push loads them again
+func (d dispatchController) forgetCourierOrders(ctx context.Context, courierId string) {
+	if err := d.offers.clearCourierOrders(ctx, courierId); err != nil {
+		d.log.Error("error in forgetCourierOrders: ", zap.Error(err))
+	}
+}
+
+// publishCourier lets customers following the order know who is bringing it
+func (d dispatchController) publishCourier(ctx context.Context, orderId, courierId string) {
+	d.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:      constants.OrderEventCourier,
+		OrderID:   orderId,
+		CourierID: &courierId,
+	})
+}
+
+// notifyCourierAssigned tells the customer a courier is bringing the order
+func (d dispatchController) notifyCourierAssigned(ctx context.Context, order entities.Order) {
+	err := d.notifier.Notify(ctx, entities.Notification{
+		UserID:   order.UserID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventCourierAssigned,
+		Template: constants.TemplateCourierAssigned,
+		Vars:     map[string]string{"order_number": strconv.FormatInt(order.Number, 10)},
+	})
+	if err != nil {
+		d.log.Error("error in notifyCourierAssigned: ", zap.Error(err))
+	}
+}
+
+// RunDispatchWorker passes expired offers to the next courier and retries orders
+// nobody could take until ctx is done
+func (d dispatchController) RunDispatchWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.DispatchCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatchDue(ctx)
+		}
+	}
+}
+
+func (d dispatchController) dispatchDue(ctx context.Context) {
+	orderIds, err := d.offers.due(ctx, time.Now(), 100)
+	if err != nil {
+		d.log.Error("error in dispatchDue: ", zap.Error(err))
+	}
+
+	for _, orderId := range orderIds {
+		if err := d.Dispatch(ctx, orderId); err != nil {
+			d.log.Warn("could not dispatch order", zap.String("OrderID", orderId), zap.Error(err))
+			if err := d.offers.retry(ctx, orderId, time.Now().Add(constants.DispatchRetryInterval)); err != nil {
+				d.log.Error("error in dispatchDue: ", zap.Error(err))
+			}
+		}
+	}
+}
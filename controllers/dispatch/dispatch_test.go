@@ -0,0 +1,428 @@
+package dispatch
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// testRedis connects to the local Redis from TEST_REDIS_ADDR (localhost:6379 by default)
+// and uses a separate database that is flushed before every test
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis is not available at %s: %v", addr, err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatalf("flush redis: %v", err)
+	}
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+	})
+	return client
+}
+
+// putCourier places the courier on the map as if they sent a position at seenAt
+func putCourier(t *testing.T, client *redis.Client, id string, lat, long float64, seenAt time.Time) {
+	t.Helper()
+
+	ctx := context.Background()
+	err := client.GeoAdd(ctx, constants.CourierGeoKey, &redis.GeoLocation{Name: id, Latitude: lat, Longitude: long}).Err()
+	if err != nil {
+		t.Fatalf("geoadd: %v", err)
+	}
+	err = client.ZAdd(ctx, constants.CourierSeenKey, &redis.Z{Score: float64(seenAt.Unix()), Member: id}).Err()
+	if err != nil {
+		t.Fatalf("zadd: %v", err)
+	}
+}
+
+// xozmak in the centre of Tashkent
+var xozmakLocation = entities.Location{Lat: 41.311081, Long: 69.240562}
+
+func TestNearbyOrdersByDistanceAndDropsStaleAndFar(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+	now := time.Now()
+
+	putCourier(t, client, "near", 41.312, 69.241, now)
+	putCourier(t, client, "middle", 41.320, 69.250, now)
+	putCourier(t, client, "stale", 41.3111, 69.2406, now.Add(-time.Hour))
+	putCourier(t, client, "far", 41.550, 69.900, now)
+
+	candidates, err := store.nearby(ctx, "order-1", xozmakLocation, 5, now.Add(-constants.CourierPositionTTL))
+	if err != nil {
+		t.Fatalf("nearby: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %+v", candidates)
+	}
+	if candidates[0].CourierID != "near" || candidates[1].CourierID != "middle" {
+		t.Fatalf("unexpected order %+v", candidates)
+	}
+	if candidates[0].DistanceKm <= 0 || candidates[0].DistanceKm >= candidates[1].DistanceKm {
+		t.Fatalf("unexpected distances %+v", candidates)
+	}
+}
+
+func TestOfferIsNotRepeatedAndCourierHoldsOneOffer(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+	now := time.Now()
+
+	putCourier(t, client, "courier-1", 41.312, 69.241, now)
+	putCourier(t, client, "courier-2", 41.320, 69.250, now)
+
+	created, err := store.create(ctx, "order-1", "courier-1", time.Minute)
+	if err != nil || !created {
+		t.Fatalf("create: %v %v", created, err)
+	}
+
+	// the courier is busy with the first offer
+	created, err = store.create(ctx, "order-2", "courier-1", time.Minute)
+	if err != nil || created {
+		t.Fatalf("second offer to a busy courier: %v %v", created, err)
+	}
+
+	orderId, expiresAt, err := store.courierOffer(ctx, "courier-1")
+	if err != nil || orderId != "order-1" || !expiresAt.After(now) {
+		t.Fatalf("courierOffer: %s %s %v", orderId, expiresAt, err)
+	}
+
+	// after declining the order is not offered to the same courier again
+	taken, err := store.take(ctx, "order-1", "courier-1")
+	if err != nil || !taken {
+		t.Fatalf("take: %v %v", taken, err)
+	}
+	candidates, err := store.nearby(ctx, "order-1", xozmakLocation, 5, now.Add(-constants.CourierPositionTTL))
+	if err != nil {
+		t.Fatalf("nearby: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].CourierID != "courier-2" {
+		t.Fatalf("expected only courier-2, got %+v", candidates)
+	}
+
+	// the courier is free for other orders
+	created, err = store.create(ctx, "order-2", "courier-1", time.Minute)
+	if err != nil || !created {
+		t.Fatalf("offer to a free courier: %v %v", created, err)
+	}
+}
+
+func TestOfferCanBeTakenOnlyByItsCourier(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+
+	if _, err := store.create(ctx, "order-1", "courier-1", time.Minute); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	taken, err := store.take(ctx, "order-1", "courier-2")
+	if err != nil || taken {
+		t.Fatalf("taken by another courier: %v %v", taken, err)
+	}
+	taken, err = store.take(ctx, "order-1", "courier-1")
+	if err != nil || !taken {
+		t.Fatalf("take: %v %v", taken, err)
+	}
+	taken, err = store.take(ctx, "order-1", "courier-1")
+	if err != nil || taken {
+		t.Fatalf("taken twice: %v %v", taken, err)
+	}
+
+	active, err := store.active(ctx, "order-1")
+	if err != nil || active {
+		t.Fatalf("offer is still active: %v %v", active, err)
+	}
+}
+
+func TestReleaseFreesCourierOnlyForTheirOrders(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+
+	if _, err := store.create(ctx, "order-1", "courier-1", time.Minute); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.release(ctx, "courier-1", []string{"order-2"}); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if orderId, _, err := store.courierOffer(ctx, "courier-1"); err != nil || orderId != "order-1" {
+		t.Fatalf("offer of another order released: %q %v", orderId, err)
+	}
+	if err := store.release(ctx, "courier-1", []string{"order-1"}); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if orderId, _, err := store.courierOffer(ctx, "courier-1"); err != nil || orderId != "" {
+		t.Fatalf("courier is still busy: %q %v", orderId, err)
+	}
+}
+
+func TestCancelReturnsOfferedCourier(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+
+	if _, err := store.create(ctx, "order-1", "courier-1", time.Minute); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	courierId, err := store.cancel(ctx, "order-1")
+	if err != nil || courierId != "courier-1" {
+		t.Fatalf("cancel: %s %v", courierId, err)
+	}
+	orderId, _, err := store.courierOffer(ctx, "courier-1")
+	if err != nil || orderId != "" {
+		t.Fatalf("courier still has the offer: %s %v", orderId, err)
+	}
+
+	courierId, err = store.cancel(ctx, "order-1")
+	if err != nil || courierId != "" {
+		t.Fatalf("cancel without offer: %s %v", courierId, err)
+	}
+}
+
+func TestExpiredOffersAndRetriesAreClaimedOnce(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.create(ctx, "offered", "courier-1", time.Minute); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.retry(ctx, "retry-now", now.Add(-time.Second)); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if err := store.retry(ctx, "retry-later", now.Add(time.Hour)); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+
+	due, err := store.due(ctx, now, 100)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 1 || due[0] != "retry-now" {
+		t.Fatalf("expected only retry-now, got %v", due)
+	}
+
+	// the offer is due once it expires
+	due, err = store.due(ctx, now.Add(2*time.Minute), 100)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 1 || due[0] != "offered" {
+		t.Fatalf("expected only offered, got %v", due)
+	}
+
+	due, err = store.due(ctx, now.Add(2*time.Minute), 100)
+	if err != nil || len(due) != 0 {
+		t.Fatalf("claimed twice: %v %v", due, err)
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	nearby := []entities.DispatchCandidate{
+		{CourierID: "busy", DistanceKm: 0.3},
+		{CourierID: "free", DistanceKm: 0.8},
+		{CourierID: "low-rated", DistanceKm: 0.5},
+		{CourierID: "full", DistanceKm: 0.1},
+		{CourierID: "offline", DistanceKm: 0.2},
+	}
+	profiles := []entities.DispatchCandidate{
+		{CourierID: "busy", Rating: 5, Load: 2},
+		{CourierID: "free", Rating: 5, Load: 0},
+		{CourierID: "low-rated", Rating: 3, Load: 0},
+		{CourierID: "full", Rating: 5, Load: 3},
+	}
+
+	ranked := rankCandidates(nearby, profiles, 3)
+
+	expected := []string{"free", "low-rated", "busy"}
+	if len(ranked) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, ranked)
+	}
+	for i, id := range expected {
+		if ranked[i].CourierID != id {
+			t.Fatalf("expected %v, got %+v", expected, ranked)
+		}
+	}
+}
+
+func TestOfferableDropsCashOrdersOverTheLimit(t *testing.T) {
+	batch := []entities.Order{
+		{ID: "cash", PaymentMethod: constants.PaymentMethodCash},
+		{ID: "card", PaymentMethod: constants.PaymentMethodCard},
+	}
+
+	if orders := offerable(batch, entities.DispatchCandidate{CashBalance: 500000}, 1000000); len(orders) != 2 {
+		t.Fatalf("expected the whole batch under the limit, got %+v", orders)
+	}
+	if orders := offerable(batch, entities.DispatchCandidate{CashBalance: 5000000}, 0); len(orders) != 2 {
+		t.Fatalf("expected the whole batch without a limit, got %+v", orders)
+	}
+	orders := offerable(batch, entities.DispatchCandidate{CashBalance: 1500000}, 1000000)
+	if len(orders) != 1 || orders[0].ID != "card" {
+		t.Fatalf("expected only the card order over the limit, got %+v", orders)
+	}
+}
+
+func TestBrokenRulesOfManualAssignment(t *testing.T) {
+	cash := entities.Order{ID: "cash", PaymentMethod: constants.PaymentMethodCash}
+	card := entities.Order{ID: "card", PaymentMethod: constants.PaymentMethodCard}
+
+	cases := []struct {
+		name      string
+		order     entities.Order
+		candidate *entities.DispatchCandidate
+		want      []string
+	}{
+		{"free courier", cash, &entities.DispatchCandidate{Load: 1, CashBalance: 500000}, nil},
+		{"offline courier", card, nil, []string{ruleOnShift}},
+		{"fully loaded courier", card, &entities.DispatchCandidate{Load: 3}, []string{ruleMaxLoad}},
+		{"cash order over the limit", cash, &entities.DispatchCandidate{CashBalance: 1500000}, []string{ruleCashLimit}},
+		{"card order over the limit", card, &entities.DispatchCandidate{CashBalance: 1500000}, nil},
+		{"loaded and over the limit", cash, &entities.DispatchCandidate{Load: 3, CashBalance: 1500000}, []string{ruleMaxLoad, ruleCashLimit}},
+	}
+	for _, c := range cases {
+		broken := brokenRules(c.order, c.candidate, 3, 1000000)
+		if fmt.Sprint(broken) != fmt.Sprint(c.want) {
+			t.Fatalf("%s: broken rules %v, want %v", c.name, broken, c.want)
+		}
+		for _, rule := range broken {
+			if ruleErrors[rule] == nil {
+				t.Fatalf("%s: rule %s has no error", c.name, rule)
+			}
+		}
+	}
+}
+
+func waitingOrder(id string, pickup, address entities.Location, readyAt time.Time) entities.WaitingOrder {
+	return entities.WaitingOrder{
+		Order: entities.Order{
+			ID:              id,
+			Status:          constants.OrderStatusReady,
+			ReadyAt:         &readyAt,
+			AddressLocation: address,
+		},
+		Pickup: pickup,
+	}
+}
+
+func TestBatchOrdersGroupsCompatibleOrders(t *testing.T) {
+	now := time.Now()
+	rules := batchRules{MaxOrders: 3, PickupRadiusKm: 1, MaxBearingDeg: 45, ReadyWindow: 10 * time.Minute}
+	nextDoor := entities.Location{Lat: 41.3115, Long: 69.2410}
+	slot := "slot-1"
+
+	// the lead goes north
+	lead := waitingOrder("lead", xozmakLocation, entities.Location{Lat: 41.340, Long: 69.241}, now)
+	sameWay := waitingOrder("same-way", nextDoor, entities.Location{Lat: 41.335, Long: 69.245}, now.Add(5*time.Minute))
+	nearCustomer := waitingOrder("near-customer", xozmakLocation, entities.Location{Lat: 41.3405, Long: 69.2415}, now)
+	oppositeWay := waitingOrder("opposite-way", xozmakLocation, entities.Location{Lat: 41.280, Long: 69.240}, now)
+	farPickup := waitingOrder("far-pickup", entities.Location{Lat: 41.350, Long: 69.300}, entities.Location{Lat: 41.360, Long: 69.241}, now)
+	late := waitingOrder("late", xozmakLocation, entities.Location{Lat: 41.338, Long: 69.242}, now.Add(30*time.Minute))
+	otherSlot := waitingOrder("other-slot", xozmakLocation, entities.Location{Lat: 41.339, Long: 69.241}, now)
+	otherSlot.SlotID = &slot
+
+	batch := batchOrders(lead, []entities.WaitingOrder{oppositeWay, farPickup, late, otherSlot, sameWay, lead, nearCustomer}, rules, now)
+
+	expected := []string{"lead", "near-customer", "same-way"}
+	if len(batch) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, batch)
+	}
+	for i, id := range expected {
+		if batch[i].ID != id {
+			t.Fatalf("expected %v, got %+v", expected, batch)
+		}
+	}
+
+	rules.MaxOrders = 2
+	if batch := batchOrders(lead, []entities.WaitingOrder{sameWay, nearCustomer}, rules, now); len(batch) != 2 || batch[1].ID != "near-customer" {
+		t.Fatalf("capacity is not respected: %+v", batch)
+	}
+	rules.MaxOrders = 1
+	if batch := batchOrders(lead, []entities.WaitingOrder{sameWay, nearCustomer}, rules, now); len(batch) != 1 {
+		t.Fatalf("batching is off but got %+v", batch)
+	}
+}
+
+func TestDeliveryRouteVisitsNearestCustomerFirst(t *testing.T) {
+	orders := []entities.Order{
+		{ID: "far", AddressLocation: entities.Location{Lat: 41.360, Long: 69.241}},
+		{ID: "near", AddressLocation: entities.Location{Lat: 41.320, Long: 69.241}},
+		{ID: "middle", AddressLocation: entities.Location{Lat: 41.340, Long: 69.241}},
+	}
+
+	route := deliveryRoute(orders, xozmakLocation)
+
+	expected := []string{"near", "middle", "far"}
+	for i, id := range expected {
+		if route[i].ID != id {
+			t.Fatalf("expected %v, got %+v", expected, route)
+		}
+	}
+	if orders[0].ID != "far" {
+		t.Fatalf("the batch was changed: %+v", orders)
+	}
+}
+
+func TestBatchOfferIsTakenAsAWhole(t *testing.T) {
+	client := testRedis(t)
+	store := offerStore{redis: client}
+	ctx := context.Background()
+
+	created, err := store.createBatch(ctx, []string{"order-1", "order-2", "order-3"}, "courier-1", time.Minute)
+	if err != nil || !created {
+		t.Fatalf("createBatch: %v %v", created, err)
+	}
+	batch, err := store.batch(ctx, "order-1")
+	if err != nil || len(batch) != 2 || batch[0] != "order-2" || batch[1] != "order-3" {
+		t.Fatalf("batch: %v %v", batch, err)
+	}
+
+	// none of the orders can be offered to somebody else meanwhile
+	created, err = store.create(ctx, "order-3", "courier-2", time.Minute)
+	if err != nil || created {
+		t.Fatalf("order of a batch offered twice: %v %v", created, err)
+	}
+	offered, err := store.offered(ctx, []string{"order-2", "order-4"})
+	if err != nil || !offered["order-2"] || offered["order-4"] {
+		t.Fatalf("offered: %v %v", offered, err)
+	}
+
+	// an order dropped from the offer is not taken with the batch
+	courierId, err := store.cancel(ctx, "order-2")
+	if err != nil || courierId != "courier-1" {
+		t.Fatalf("cancel: %s %v", courierId, err)
+	}
+
+	taken, err := store.takeBatch(ctx, "order-1", "courier-1")
+	if err != nil || len(taken) != 2 || taken[0] != "order-1" || taken[1] != "order-3" {
+		t.Fatalf("takeBatch: %v %v", taken, err)
+	}
+	offered, err = store.offered(ctx, []string{"order-1", "order-2", "order-3"})
+	if err != nil || offered["order-1"] || offered["order-2"] || offered["order-3"] {
+		t.Fatalf("orders are still offered: %v %v", offered, err)
+	}
+	orderId, _, err := store.courierOffer(ctx, "courier-1")
+	if err != nil || orderId != "" {
+		t.Fatalf("courier still has the offer: %s %v", orderId, err)
+	}
+}
@@ -0,0 +1,326 @@
+package dispatch
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// createOfferScript offers the orders only if the courier is not busy with another offer and
+// none of the orders is offered to somebody else. The first order leads the offer, the rest
+// are listed under the batch key. The courier is remembered so that an order is never offered
+// to them twice.
+var createOfferScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+for i = 4, #KEYS, 2 do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[5], 'PX', ARGV[2])
+for i = 4, #KEYS, 2 do
+	local orderId = ARGV[5 + (i - 4) / 2]
+	redis.call('SET', KEYS[i], ARGV[1], 'PX', ARGV[2])
+	redis.call('ZADD', KEYS[2], ARGV[3], orderId)
+	redis.call('SADD', KEYS[i + 1], ARGV[1])
+	redis.call('PEXPIRE', KEYS[i + 1], ARGV[4])
+	if i > 4 then
+		redis.call('RPUSH', KEYS[3], orderId)
+	end
+end
+if #KEYS > 5 then
+	redis.call('PEXPIRE', KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// takeOfferScript removes the offer if it still belongs to the courier. When the order leads
+// a batch the other orders still offered to the courier are removed too, all removed orders
+// are returned.
+var takeOfferScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return {}
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[3], ARGV[2])
+local taken = {ARGV[2]}
+if redis.call('GET', KEYS[2]) == ARGV[2] then
+	redis.call('DEL', KEYS[2])
+	for _, orderId in ipairs(redis.call('LRANGE', KEYS[4], 0, -1)) do
+		local key = ARGV[3] .. orderId
+		if redis.call('GET', key) == ARGV[1] then
+			redis.call('DEL', key)
+			redis.call('ZREM', KEYS[3], orderId)
+			table.insert(taken, orderId)
+		end
+	end
+	redis.call('DEL', KEYS[4])
+end
+return taken
+`)
+
+// releaseOfferScript frees the courier for new offers if they are still held by one of the orders
+var releaseOfferScript = redis.NewScript(`
+local orderId = redis.call('GET', KEYS[1])
+if not orderId then
+	return 0
+end
+for _, id in ipairs(ARGV) do
+	if id == orderId then
+		return redis.call('DEL', KEYS[1])
+	end
+end
+return 0
+`)
+
+// offerStore keeps courier offers in Redis, every offer expires on its own
+type offerStore struct {
+	redis *redis.Client
+}
+
+func offerKey(orderId string) string {
+	return constants.DispatchOfferKeyPrefix + orderId
+}
+
+func courierOfferKey(courierId string) string {
+	return constants.DispatchCourierOfferKeyPrefix + courierId
+}
+
+func skipKey(orderId string) string {
+	return constants.DispatchSkipKeyPrefix + orderId
+}
+
+func batchKey(orderId string) string {
+	return constants.DispatchBatchKeyPrefix + orderId
+}
+
+// nearby finds couriers around the location ordered by distance, couriers whose position
+// is older than seenAfter or who already got an offer for the order are left out
+func (s offerStore) nearby(ctx context.Context, orderId string, location entities.Location, radiusKm float64, seenAfter time.Time) ([]entities.DispatchCandidate, error) {
+	found, err := s.redis.GeoSearchLocation(ctx, constants.CourierGeoKey, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  location.Long,
+			Latitude:   location.Lat,
+			Radius:     radiusKm,
+			RadiusUnit: "km",
+			Sort:       "ASC",
+			Count:      constants.DispatchCandidatesLimit,
+		},
+		WithDist: true,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search couriers: %w", err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	members := make([]string, 0, len(found))
+	for _, location := range found {
+		members = append(members, location.Name)
+	}
+	seen, err := s.redis.ZMScore(ctx, constants.CourierSeenKey, members...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get couriers last seen time: %w", err)
+	}
+	skipped, err := s.redis.SMembersMap(ctx, skipKey(orderId)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get skipped couriers: %w", err)
+	}
+
+	candidates := make([]entities.DispatchCandidate, 0, len(found))
+	for i, location := range found {
+		if _, ok := skipped[location.Name]; ok {
+			continue
+		}
+		// a missing score is returned as 0 and is stale as well
+		if seen[i] < float64(seenAfter.Unix()) {
+			continue
+		}
+		candidates = append(candidates, entities.DispatchCandidate{
+			CourierID:  location.Name,
+			DistanceKm: location.Dist,
+		})
+	}
+	return candidates, nil
+}
+
+// create offers the order to the courier for ttl, false means the courier is busy with another offer
+func (s offerStore) create(ctx context.Context, orderId, courierId string, ttl time.Duration) (bool, error) {
+	return s.createBatch(ctx, []string{orderId}, courierId, ttl)
+}
+
+// createBatch offers the orders to the courier at once, the first order leads the offer.
+// False means the courier or one of the orders is busy with another offer.
+func (s offerStore) createBatch(ctx context.Context, orderIds []string, courierId string, ttl time.Duration) (bool, error) {
+	// the deadline is a bit later than the offer keys expire, so the worker never sees a live offer
+	deadline := time.Now().Add(ttl + time.Second)
+
+	keys := []string{courierOfferKey(courierId), constants.DispatchDeadlinesKey, batchKey(orderIds[0])}
+	args := []interface{}{courierId, ttl.Milliseconds(), deadline.Unix(), constants.DispatchSkipTTL.Milliseconds()}
+	for _, orderId := range orderIds {
+		keys = append(keys, offerKey(orderId), skipKey(orderId))
+		args = append(args, orderId)
+	}
+
+	created, err := createOfferScript.Run(ctx, s.redis, keys, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to create offer: %w", err)
+	}
+	return created == 1, nil
+}
+
+// take removes the offer when the courier answers it, false means it expired or was never theirs
+func (s offerStore) take(ctx context.Context, orderId, courierId string) (bool, error) {
+	taken, err := s.takeBatch(ctx, orderId, courierId)
+	return len(taken) > 0, err
+}
+
+// takeBatch removes the offer together with the rest of its batch and returns the removed
+// orders, the answered one first. Nothing is returned when the offer is not the courier's.
+func (s offerStore) takeBatch(ctx context.Context, orderId, courierId string) ([]string, error) {
+	taken, err := takeOfferScript.Run(ctx, s.redis,
+		[]string{offerKey(orderId), courierOfferKey(courierId), constants.DispatchDeadlinesKey, batchKey(orderId)},
+		courierId, orderId, constants.DispatchOfferKeyPrefix,
+	).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take offer: %w", err)
+	}
+	return taken, nil
+}
+
+// release frees the courier for new offers when their offer led by one of the orders is still
+// held, it is used when the courier took the offer but could not get the orders
+func (s offerStore) release(ctx context.Context, courierId string, orderIds []string) error {
+	args := make([]interface{}, 0, len(orderIds))
+	for _, orderId := range orderIds {
+		args = append(args, orderId)
+	}
+	if err := releaseOfferScript.Run(ctx, s.redis, []string{courierOfferKey(courierId)}, args...).Err(); err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to release courier offer: %w", err)
+	}
+	return nil
+}
+
+// batch returns the orders offered together with the leading order
+func (s offerStore) batch(ctx context.Context, orderId string) ([]string, error) {
+	orderIds, err := s.redis.LRange(ctx, batchKey(orderId), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offer batch: %w", err)
+	}
+	return orderIds, nil
+}
+
+// cancel drops the pending offer of the order, if any, and returns the courier it was offered to.
+// Other orders of its batch are dispatched again right away.
+func (s offerStore) cancel(ctx context.Context, orderId string) (string, error) {
+	courierId, err := s.redis.Get(ctx, offerKey(orderId)).Result()
+	if err == redis.Nil {
+		s.redis.ZRem(ctx, constants.DispatchDeadlinesKey, orderId)
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get offer: %w", err)
+	}
+	taken, err := s.takeBatch(ctx, orderId, courierId)
+	if err != nil {
+		return "", err
+	}
+	for _, other := range taken {
+		if other == orderId {
+			continue
+		}
+		if err := s.retry(ctx, other, time.Now()); err != nil {
+			return "", err
+		}
+	}
+	return courierId, nil
+}
+
+// active tells whether the order is currently offered to a courier
+func (s offerStore) active(ctx context.Context, orderId string) (bool, error) {
+	n, err := s.redis.Exists(ctx, offerKey(orderId)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check offer: %w", err)
+	}
+	return n == 1, nil
+}
+
+// offered returns which of the orders are currently offered to a courier
+func (s offerStore) offered(ctx context.Context, orderIds []string) (map[string]bool, error) {
+	pipe := s.redis.Pipeline()
+	cmds := make([]*redis.IntCmd, len(orderIds))
+	for i, orderId := range orderIds {
+		cmds[i] = pipe.Exists(ctx, offerKey(orderId))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to check offers: %w", err)
+	}
+
+	offered := make(map[string]bool, len(orderIds))
+	for i, orderId := range orderIds {
+		offered[orderId] = cmds[i].Val() == 1
+	}
+	return offered, nil
+}
+
+// courierOffer returns the order offered to the courier and when the offer expires
+func (s offerStore) courierOffer(ctx context.Context, courierId string) (string, time.Time, error) {
+	key := courierOfferKey(courierId)
+	orderId, err := s.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get courier offer: %w", err)
+	}
+	ttl, err := s.redis.PTTL(ctx, key).Result()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get courier offer ttl: %w", err)
+	}
+	return orderId, time.Now().Add(ttl), nil
+}
+
+// retry schedules another dispatch attempt for the order
+func (s offerStore) retry(ctx context.Context, orderId string, at time.Time) error {
+	err := s.redis.ZAdd(ctx, constants.DispatchDeadlinesKey, &redis.Z{Score: float64(at.Unix()), Member: orderId}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to schedule dispatch retry: %w", err)
+	}
+	return nil
+}
+
+// due claims orders whose offer expired or retry time came, an order is claimed by one worker only
+func (s offerStore) due(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	orderIds, err := s.redis.ZRangeByScore(ctx, constants.DispatchDeadlinesKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprintf("%d", now.Unix()),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due dispatches: %w", err)
+	}
+
+	claimed := make([]string, 0, len(orderIds))
+	for _, orderId := range orderIds {
+		removed, err := s.redis.ZRem(ctx, constants.DispatchDeadlinesKey, orderId).Result()
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim due dispatch: %w", err)
+		}
+		if removed == 1 {
+			claimed = append(claimed, orderId)
+		}
+	}
+	return claimed, nil
+}
+
+// clearCourierOrders drops the cached orders of the courier after dispatch changed them
+func (s offerStore) clearCourierOrders(ctx context.Context, courierId string) error {
+	return s.redis.Del(ctx, constants.CourierOrdersKeyPrefix+courierId).Err()
+}
@@ -0,0 +1,68 @@
+package dispatch
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"sort"
+)
+
+// dispatch rules by the name written to the order history when an admin breaks them
+const (
+	ruleOnShift   = "on_shift"
+	ruleMaxLoad   = "max_load"
+	ruleCashLimit = "cash_limit"
+)
+
+var ruleErrors = map[string]error{
+	ruleOnShift:   e.ErrCourierOffline,
+	ruleMaxLoad:   e.ErrCourierFullyLoaded,
+	ruleCashLimit: e.ErrCourierOverCashLimit,
+}
+
+// rankCandidates joins couriers found on the map with their profiles, drops those
+// who can not take one more order and sorts the rest from the best to the worst.
+// Load and rating are turned into extra kilometres so that a free, well rated courier
+// a bit farther away wins over a busy one next door.
+func rankCandidates(nearby, profiles []entities.DispatchCandidate, maxLoad int) []entities.DispatchCandidate {
+	byID := make(map[string]entities.DispatchCandidate, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.CourierID] = profile
+	}
+
+	ranked := make([]entities.DispatchCandidate, 0, len(nearby))
+	for _, candidate := range nearby {
+		profile, ok := byID[candidate.CourierID]
+		if !ok || profile.Load >= maxLoad {
+			continue
+		}
+		candidate.Rating = profile.Rating
+		candidate.Load = profile.Load
+		candidate.CashBalance = profile.CashBalance
+		candidate.Score = candidate.DistanceKm +
+			float64(candidate.Load)*constants.DispatchLoadPenaltyKm +
+			(constants.CourierMaxRating-candidate.Rating)*constants.DispatchRatingPenaltyKm
+		ranked = append(ranked, candidate)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score < ranked[j].Score
+	})
+	return ranked
+}
+
+// brokenRules lists the rules that keep the courier from being offered the order,
+// candidate is nil when the courier is not online on an active profile
+func brokenRules(order entities.Order, candidate *entities.DispatchCandidate, maxLoad int, cashLimit int64) []string {
+	if candidate == nil {
+		return []string{ruleOnShift}
+	}
+	var broken []string
+	if candidate.Load >= maxLoad {
+		broken = append(broken, ruleMaxLoad)
+	}
+	if len(offerable([]entities.Order{order}, *candidate, cashLimit)) == 0 {
+		broken = append(broken, ruleCashLimit)
+	}
+	return broken
+}
@@ -0,0 +1,134 @@
+package earnings
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RecordCashCollection adds the total of a delivered cash order to the cash the courier holds
+func (c earningsController) RecordCashCollection(ctx context.Context, order entities.Order) error {
+	if order.CourierID == nil || order.PaymentMethod != constants.PaymentMethodCash || order.Total <= 0 {
+		return nil
+	}
+	collectedAt := time.Now()
+	if order.DeliveredAt != nil {
+		collectedAt = *order.DeliveredAt
+	}
+	err := c.storage.Ledger().RecordCashCollection(ctx, entities.CashCollection{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		CourierID: *order.CourierID,
+		Amount:    order.Total,
+		CreatedAt: collectedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record cash collection: %w", err)
+	}
+	return nil
+}
+
+// backfillCashCollections adds the cash of delivered orders that was not added to the courier's
+// balance, so that dispatch sees what the courier really holds
+func (c earningsController) backfillCashCollections(ctx context.Context) {
+	orderIds, err := c.storage.Ledger().GetUncollectedCashOrderIDs(ctx, time.Now().Add(-constants.BackfillWindow), constants.BackfillBatchLimit)
+	if err != nil {
+		c.log.Error("error in backfillCashCollections: ", zap.Error(err))
+		return
+	}
+	for _, orderId := range orderIds {
+		order, err := c.storage.Order().GetOrder(ctx, orderId)
+		if err == nil {
+			err = c.RecordCashCollection(ctx, order)
+		}
+		if err != nil {
+			c.log.Error("error in backfillCashCollections: ", zap.String("OrderID", orderId), zap.Error(err))
+		}
+	}
+}
+
+// RecordCashHandover is called by whoever took the cash from the courier: an admin
+// takes it for the office and a seller for their xozmak
+func (c earningsController) RecordCashHandover(ctx context.Context, req entities.CashHandoverReq) (entities.CashHandover, error) {
+	c.log.Info("RecordCashHandover started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, Amount: %d, ActorID: %s", req.CourierID, req.Amount, req.Actor.ID)))
+
+	if _, err := c.storage.Courier().GetCourier(ctx, req.CourierID); err != nil {
+		return entities.CashHandover{}, c.internalError("RecordCashHandover", err)
+	}
+
+	handover := entities.CashHandover{
+		ID:         uuid.NewString(),
+		CourierID:  req.CourierID,
+		Amount:     req.Amount,
+		Receiver:   constants.CashReceiverOffice,
+		ReceivedBy: req.Actor.ID,
+		Note:       req.Note,
+		CreatedAt:  time.Now(),
+	}
+	if req.Actor.Role == constants.SellerRole {
+		xozmakId, err := c.storage.Order().GetUserXozmakID(ctx, req.Actor.ID)
+		if err != nil {
+			if errors.Is(err, e.ErrXozmakNotFound) {
+				return entities.CashHandover{}, e.ErrNotSeller
+			}
+			return entities.CashHandover{}, c.internalError("RecordCashHandover", err)
+		}
+		handover.Receiver = constants.CashReceiverXozmak
+		handover.XozmakID = &xozmakId
+	}
+
+	if err := c.storage.Ledger().CreateCashHandover(ctx, handover); err != nil {
+		return entities.CashHandover{}, c.internalError("RecordCashHandover", err)
+	}
+
+	c.log.Info("RecordCashHandover finished", zap.String("HandoverID", handover.ID))
+	return handover, nil
+}
+
+func (c earningsController) GetCashStatement(ctx context.Context, courierId, period string, day time.Time) (entities.CourierCashStatement, error) {
+	c.log.Info("GetCashStatement started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, Period: %s, Day: %s", courierId, period, day.Format(time.DateOnly))))
+
+	courier, err := c.storage.Courier().GetCourier(ctx, courierId)
+	if err != nil {
+		return entities.CourierCashStatement{}, c.internalError("GetCashStatement", err)
+	}
+	from, to := statementRange(period, day)
+	collections, err := c.storage.Ledger().GetCashCollections(ctx, courierId, from, to)
+	if err != nil {
+		return entities.CourierCashStatement{}, c.internalError("GetCashStatement", err)
+	}
+	handovers, err := c.storage.Ledger().GetCashHandovers(ctx, courierId, from, to)
+	if err != nil {
+		return entities.CourierCashStatement{}, c.internalError("GetCashStatement", err)
+	}
+
+	statement := entities.CourierCashStatement{
+		CourierID:         courierId,
+		Period:            period,
+		From:              from,
+		To:                to,
+		Collections:       collections,
+		Handovers:         handovers,
+		Balance:           courier.CashBalance,
+		Limit:             c.cfg.CourierCashLimit,
+		CashOrdersBlocked: entities.CashOrdersBlocked(courier.CashBalance, c.cfg.CourierCashLimit),
+	}
+	for _, collection := range collections {
+		statement.Collected += collection.Amount
+	}
+	for _, handover := range handovers {
+		statement.HandedOver += handover.Amount
+	}
+
+	c.log.Info("GetCashStatement finished")
+	return statement, nil
+}
@@ -0,0 +1,316 @@
+package earnings
+
+import (
+	"bytes"
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/utils"
+	"delivery/storage"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type EarningsController interface {
+	RecordDelivery(ctx context.Context, order entities.Order) error
+	RunBackfillWorker(ctx context.Context)
+	AddAdjustment(ctx context.Context, req entities.EarningAdjustmentReq) (entities.LedgerTransaction, error)
+	GetStatement(ctx context.Context, courierId, period string, day time.Time) (entities.CourierStatement, error)
+	CreatePayoutBatch(ctx context.Context, req entities.PayoutBatchReq) (entities.PayoutBatch, error)
+	GetPayoutBatches(ctx context.Context, limit, page int) ([]entities.PayoutBatch, error)
+	GetPayoutBatch(ctx context.Context, id string) (entities.PayoutBatch, error)
+	ExportPayoutBatch(ctx context.Context, id string) ([]byte, error)
+	RecordCashCollection(ctx context.Context, order entities.Order) error
+	RecordCashHandover(ctx context.Context, req entities.CashHandoverReq) (entities.CashHandover, error)
+	GetCashStatement(ctx context.Context, courierId, period string, day time.Time) (entities.CourierCashStatement, error)
+}
+
+type earningsController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	cfg     *configs.Configuration
+}
+
+func NewEarningsController(log logger.LoggerI, storage storage.Storage) EarningsController {
+	return earningsController{
+		log:     log,
+		storage: storage,
+		cfg:     configs.Config(),
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (c earningsController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	c.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// earning credits the courier and debits the account the money comes from
+func earning(kind, courierId string, orderId *string, amount int64, from, note string) entities.LedgerTransaction {
+	return entities.CourierEarning(uuid.NewString(), kind, courierId, orderId, amount, from, note)
+}
+
+// RecordDelivery posts the base and distance pay and the tip of a delivered order. It is safe to
+// call twice, an order is paid only once.
+func (c earningsController) RecordDelivery(ctx context.Context, order entities.Order) error {
+	c.log.Info("RecordDelivery started: ", zap.String("OrderID", order.ID))
+
+	if order.CourierID == nil {
+		return nil
+	}
+
+	xozmak, err := c.storage.Order().GetXozmakByID(ctx, order.XozmakID)
+	if err != nil {
+		return c.internalError("RecordDelivery", err)
+	}
+	km := utils.DistanceKm(xozmak.Location.Lat, xozmak.Location.Long, order.AddressLocation.Lat, order.AddressLocation.Long) *
+		c.cfg.RouteDetourFactor
+	km = math.Round(km*10) / 10
+
+	transactions := []entities.LedgerTransaction{
+		earning(constants.LedgerKindDeliveryBase, *order.CourierID, &order.ID, c.cfg.CourierBasePay,
+			constants.LedgerAccountDeliveryExpense, fmt.Sprintf("order #%d", order.Number)),
+	}
+	if distancePay := int64(math.Round(km * float64(c.cfg.CourierPayPerKm))); distancePay > 0 {
+		transactions = append(transactions, earning(constants.LedgerKindDeliveryDistance, *order.CourierID, &order.ID, distancePay,
+			constants.LedgerAccountDeliveryExpense, fmt.Sprintf("order #%d, %.1f km", order.Number, km)))
+	}
+	// the tip chosen at checkout was paid with the order
+	if order.Tip > 0 {
+		transactions = append(transactions, earning(constants.LedgerKindTip, *order.CourierID, &order.ID, order.Tip,
+			constants.LedgerAccountTipsHeld, fmt.Sprintf("order #%d tip", order.Number)))
+	}
+	// the earnings belong to the period of the delivery even when they are recorded later
+	if order.DeliveredAt != nil {
+		for i := range transactions {
+			transactions[i].CreatedAt = *order.DeliveredAt
+		}
+	}
+
+	err = c.storage.Ledger().PostTransactions(ctx, transactions)
+	if err != nil && !errors.Is(err, e.ErrEarningAlreadyPosted) {
+		return c.internalError("RecordDelivery", err)
+	}
+
+	c.log.Info("RecordDelivery finished")
+	return nil
+}
+
+// RunBackfillWorker records the courier money that failed to be recorded on delivery until ctx is done
+func (c earningsController) RunBackfillWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.BackfillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.backfillDeliveries(ctx)
+			c.backfillCashCollections(ctx)
+		}
+	}
+}
+
+// backfillDeliveries pays the couriers of delivered orders that have no delivery earning,
+// an order that fails again waits for the next run
+func (c earningsController) backfillDeliveries(ctx context.Context) {
+	orderIds, err := c.storage.Ledger().GetOrderIDsWithoutEarning(ctx, time.Now().Add(-constants.BackfillWindow), constants.BackfillBatchLimit)
+	if err != nil {
+		c.log.Error("error in backfillDeliveries: ", zap.Error(err))
+		return
+	}
+	for _, orderId := range orderIds {
+		order, err := c.storage.Order().GetOrder(ctx, orderId)
+		if err == nil {
+			err = c.RecordDelivery(ctx, order)
+		}
+		if err != nil {
+			c.log.Error("error in backfillDeliveries: ", zap.String("OrderID", orderId), zap.Error(err))
+		}
+	}
+}
+
+// AddAdjustment gives the courier a bonus or takes a penalty from their earnings
+func (c earningsController) AddAdjustment(ctx context.Context, req entities.EarningAdjustmentReq) (entities.LedgerTransaction, error) {
+	c.log.Info("AddAdjustment started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, Kind: %s, Amount: %d, AdminID: %s", req.CourierID, req.Kind, req.Amount, req.Actor.ID)))
+
+	if _, err := c.storage.Courier().GetCourier(ctx, req.CourierID); err != nil {
+		return entities.LedgerTransaction{}, c.internalError("AddAdjustment", err)
+	}
+
+	var transaction entities.LedgerTransaction
+	if req.Kind == constants.LedgerKindBonus {
+		transaction = earning(req.Kind, req.CourierID, req.OrderID, req.Amount, constants.LedgerAccountDeliveryExpense, req.Note)
+	} else {
+		// a penalty is an earning in the opposite direction
+		transaction = earning(req.Kind, req.CourierID, req.OrderID, -req.Amount, constants.LedgerAccountPenaltyIncome, req.Note)
+	}
+	transaction.CreatedBy = &req.Actor.ID
+
+	if err := c.storage.Ledger().PostTransactions(ctx, []entities.LedgerTransaction{transaction}); err != nil {
+		return entities.LedgerTransaction{}, c.internalError("AddAdjustment", err)
+	}
+
+	c.log.Info("AddAdjustment finished")
+	return transaction, nil
+}
+
+// statementRange returns the day or the Monday to Sunday week that contains day
+func statementRange(period string, day time.Time) (time.Time, time.Time) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	if period == constants.StatementPeriodWeek {
+		// time.Sunday is 0, weeks start on Monday
+		from = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+		return from, from.AddDate(0, 0, 7)
+	}
+	return from, from.AddDate(0, 0, 1)
+}
+
+func (c earningsController) GetStatement(ctx context.Context, courierId, period string, day time.Time) (entities.CourierStatement, error) {
+	c.log.Info("GetStatement started: ",
+		zap.String("Request: ", fmt.Sprintf("CourierID: %s, Period: %s, Day: %s", courierId, period, day.Format(time.DateOnly))))
+
+	from, to := statementRange(period, day)
+	transactions, err := c.storage.Ledger().GetCourierTransactions(ctx, courierId, from, to)
+	if err != nil {
+		return entities.CourierStatement{}, c.internalError("GetStatement", err)
+	}
+	balance, err := c.storage.Ledger().GetCourierBalance(ctx, courierId)
+	if err != nil {
+		return entities.CourierStatement{}, c.internalError("GetStatement", err)
+	}
+
+	statement := entities.CourierStatement{
+		CourierID:    courierId,
+		Period:       period,
+		From:         from,
+		To:           to,
+		Transactions: transactions,
+		Balance:      balance,
+	}
+	for date := from; date.Before(to); date = date.AddDate(0, 0, 1) {
+		statement.Days = append(statement.Days, entities.StatementDay{Date: date.Format(time.DateOnly)})
+	}
+	for _, transaction := range transactions {
+		if transaction.Kind == constants.LedgerKindPayout {
+			continue
+		}
+		index := int(transaction.CreatedAt.In(from.Location()).Sub(from).Hours() / 24)
+		if index >= 0 && index < len(statement.Days) {
+			statement.Days[index].Add(transaction)
+		}
+		statement.Totals.Add(transaction)
+		if transaction.PayoutID != nil {
+			statement.Paid += transaction.Amount
+		} else {
+			statement.Unpaid += transaction.Amount
+		}
+	}
+
+	c.log.Info("GetStatement finished")
+	return statement, nil
+}
+
+// CreatePayoutBatch marks all unpaid earnings before the period end as paid
+func (c earningsController) CreatePayoutBatch(ctx context.Context, req entities.PayoutBatchReq) (entities.PayoutBatch, error) {
+	c.log.Info("CreatePayoutBatch started: ", zap.String("AdminID", req.Actor.ID))
+
+	now := time.Now()
+	batch := entities.PayoutBatch{
+		ID:        uuid.NewString(),
+		PeriodEnd: now,
+		CreatedAt: now,
+	}
+	if req.Actor.ID != "" {
+		batch.CreatedBy = &req.Actor.ID
+	}
+	if req.PeriodEnd != nil && req.PeriodEnd.Before(now) {
+		batch.PeriodEnd = *req.PeriodEnd
+	}
+
+	batch, err := c.storage.Ledger().CreatePayoutBatch(ctx, batch)
+	if err != nil {
+		return entities.PayoutBatch{}, c.internalError("CreatePayoutBatch", err)
+	}
+
+	c.log.Info("CreatePayoutBatch finished: ",
+		zap.String("Batch: ", fmt.Sprintf("ID: %s, Payouts: %d, Total: %d", batch.ID, len(batch.Payouts), batch.Total)))
+	return batch, nil
+}
+
+func (c earningsController) GetPayoutBatches(ctx context.Context, limit, page int) ([]entities.PayoutBatch, error) {
+	c.log.Info("GetPayoutBatches started")
+
+	data, err := c.storage.Ledger().GetPayoutBatches(ctx, limit, (page-1)*limit)
+	if err != nil {
+		return nil, c.internalError("GetPayoutBatches", err)
+	}
+
+	c.log.Info("GetPayoutBatches finished")
+	return data, nil
+}
+
+func (c earningsController) GetPayoutBatch(ctx context.Context, id string) (entities.PayoutBatch, error) {
+	c.log.Info("GetPayoutBatch started: ", zap.String("BatchID", id))
+
+	data, err := c.storage.Ledger().GetPayoutBatch(ctx, id)
+	if err != nil {
+		return entities.PayoutBatch{}, c.internalError("GetPayoutBatch", err)
+	}
+
+	c.log.Info("GetPayoutBatch finished")
+	return data, nil
+}
+
+// ExportPayoutBatch renders the batch as a CSV file for the bank
+func (c earningsController) ExportPayoutBatch(ctx context.Context, id string) ([]byte, error) {
+	c.log.Info("ExportPayoutBatch started: ", zap.String("BatchID", id))
+
+	batch, err := c.storage.Ledger().GetPayoutBatch(ctx, id)
+	if err != nil {
+		return nil, c.internalError("ExportPayoutBatch", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{{"payout_id", "courier_id", "full_name", "phone_number", "account", "amount", "purpose"}}
+	for _, payout := range batch.Payouts {
+		account := ""
+		if payout.PayoutAccount != nil {
+			account = *payout.PayoutAccount
+		}
+		rows = append(rows, []string{
+			payout.ID,
+			payout.CourierID,
+			payout.Firstname + " " + payout.Surname,
+			payout.PhoneNumber,
+			account,
+			strconv.FormatInt(payout.Amount, 10),
+			fmt.Sprintf("Kuryer daromadi %s gacha", batch.PeriodEnd.Format(time.DateOnly)),
+		})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, c.internalError("ExportPayoutBatch", err)
+	}
+
+	c.log.Info("ExportPayoutBatch finished")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package notification
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/push"
+	"delivery/pkg/templates"
+	"delivery/storage"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type NotificationController interface {
+	Notify(ctx context.Context, req entities.Notification) error
+	GetUserNotifications(ctx context.Context, userId string, limit, page int) ([]entities.Notification, error)
+	Text(ctx context.Context, key, language string, vars map[string]string) templates.Text
+	GetTemplates(ctx context.Context) ([]entities.NotificationTemplate, error)
+	UpdateTemplate(ctx context.Context, req entities.NotificationTemplate) (entities.NotificationTemplate, error)
+	ResetTemplate(ctx context.Context, key, language string) (entities.NotificationTemplate, error)
+	PreviewTemplate(ctx context.Context, req entities.TemplatePreviewReq) (templates.Text, error)
+}
+
+type notificationController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	push    push.PushSender
+}
+
+func NewNotificationController(log logger.LoggerI, storage storage.Storage, push push.PushSender) NotificationController {
+	return notificationController{
+		log:     log,
+		storage: storage,
+		push:    push,
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (n notificationController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	n.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// Notify saves the notification to the user's inbox and pushes it to the user's device,
+// a notification with a template is written in the user's preferred language
+func (n notificationController) Notify(ctx context.Context, req entities.Notification) error {
+	n.log.Info("Notify started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, Template: %s, Title: %s", req.UserID, req.Template, req.Title)))
+
+	if req.ID == "" {
+		req.ID = uuid.NewString()
+	}
+	if req.Template != "" {
+		language, err := n.storage.Notification().GetUserLanguage(ctx, req.UserID)
+		if err != nil {
+			n.log.Error("error in Notify: ", zap.Error(err))
+		}
+		text := n.Text(ctx, req.Template, templates.Language(language), req.Vars)
+		req.Title, req.Body = text.Title, text.Body
+	}
+	err := n.storage.Notification().CreateNotification(ctx, req)
+	if err != nil {
+		n.log.Error("error in Notify: ", zap.Error(err))
+		return status.Error(codes.Internal, "internal server error")
+	}
+	// the inbox keeps the notification when the push fails, the push does not fail the caller
+	go n.sendPush(req)
+
+	n.log.Info("Notify finished")
+	return nil
+}
+
+func (n notificationController) GetUserNotifications(ctx context.Context, userId string, limit, page int) ([]entities.Notification, error) {
+	n.log.Info("GetUserNotifications started: ", zap.String("UserID", userId))
+
+	data, err := n.storage.Notification().GetUserNotifications(ctx, userId, limit, (page-1)*limit)
+	if err != nil {
+		n.log.Error("error in GetUserNotifications: ", zap.Error(err))
+		return []entities.Notification{}, status.Error(codes.Internal, "internal server error")
+	}
+
+	n.log.Info("GetUserNotifications finished")
+	return data, nil
+}
+
+// sendPush delivers the notification to the device the user logged in last,
+// the token is forgotten when the app is no longer installed there
+func (n notificationController) sendPush(req entities.Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.PushTimeout)
+	defer cancel()
+
+	token, err := n.storage.Notification().GetFcmToken(ctx, req.UserID)
+	if err != nil {
+		n.log.Error("error in sendPush: ", zap.Error(err))
+		return
+	}
+	if token == "" {
+		return
+	}
+
+	err = n.push.Send(ctx, push.Message{
+		Token: token,
+		Title: req.Title,
+		Body:  req.Body,
+		Data:  pushData(req),
+	})
+	if errors.Is(err, push.ErrInvalidToken) {
+		n.log.Info("removing invalid device token", zap.String("UserID", req.UserID))
+		if err := n.storage.Notification().ClearFcmToken(ctx, req.UserID, token); err != nil {
+			n.log.Error("error in sendPush: ", zap.Error(err))
+		}
+		return
+	}
+	if err != nil {
+		n.log.Error("error in sendPush: ", zap.Error(err))
+	}
+}
+
+// pushData is the payload the app deep-links from
+func pushData(req entities.Notification) map[string]string {
+	data := map[string]string{"notification_id": req.ID}
+	if req.Event != "" {
+		data["event"] = req.Event
+	}
+	if req.OrderID != nil {
+		data["order_id"] = *req.OrderID
+		data["link"] = constants.OrderDeepLinkPrefix + *req.OrderID
+	}
+	return data
+}
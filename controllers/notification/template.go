@@ -0,0 +1,170 @@
+package notification
+
+import (
+	"context"
+	"delivery/entities"
+	e "delivery/errors"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/templates"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Text renders the template in the language, the text edited by admins wins over the built-in one.
+// It does not fail: when the edited text can not be rendered the built-in one is used.
+func (n notificationController) Text(ctx context.Context, key, language string, vars map[string]string) templates.Text {
+	def, ok := templates.Lookup(key)
+	if !ok {
+		n.log.Error("error in Text: unknown template", zap.String("Key", key))
+		return templates.Text{}
+	}
+
+	edited, err := n.storage.Notification().GetTemplate(ctx, key, language)
+	if err == nil {
+		text, err := templates.Render(templates.Text{Title: edited.Title, Body: edited.Body}, vars)
+		if err == nil {
+			return text
+		}
+		n.log.Error("error in Text: ", zap.String("Key", key), zap.Error(err))
+	} else if !errors.Is(err, e.ErrTemplateNotFound) {
+		n.log.Error("error in Text: ", zap.Error(err))
+	}
+
+	text, err := templates.Render(def.Text(language), vars)
+	if err != nil {
+		n.log.Error("error in Text: ", zap.String("Key", key), zap.Error(err))
+		return def.Text(language)
+	}
+	return text
+}
+
+// GetTemplates lists every template in every language with the text currently in use
+func (n notificationController) GetTemplates(ctx context.Context) ([]entities.NotificationTemplate, error) {
+	n.log.Info("GetTemplates started")
+
+	edited, err := n.storage.Notification().GetTemplates(ctx)
+	if err != nil {
+		return nil, n.internalError("GetTemplates", err)
+	}
+	editedByKey := make(map[string]entities.NotificationTemplate, len(edited))
+	for _, template := range edited {
+		editedByKey[template.Key+":"+template.Language] = template
+	}
+
+	var data []entities.NotificationTemplate
+	for _, def := range templates.All() {
+		for _, language := range templates.Languages {
+			template, ok := editedByKey[def.Key+":"+language]
+			if ok {
+				template.Edited = true
+			} else {
+				template = builtInTemplate(def, language)
+			}
+			template.Variables = def.Variables
+			data = append(data, template)
+		}
+	}
+
+	n.log.Info("GetTemplates finished")
+	return data, nil
+}
+
+// UpdateTemplate saves the text edited by the admin, it may use only the variables of the template
+func (n notificationController) UpdateTemplate(ctx context.Context, req entities.NotificationTemplate) (entities.NotificationTemplate, error) {
+	n.log.Info("UpdateTemplate started: ",
+		zap.String("Request: ", fmt.Sprintf("Key: %s, Language: %s", req.Key, req.Language)))
+
+	def, ok := templates.Lookup(req.Key)
+	if !ok {
+		return entities.NotificationTemplate{}, e.ErrTemplateNotFound
+	}
+	if err := def.Validate(templates.Text{Title: req.Title, Body: req.Body}); err != nil {
+		return entities.NotificationTemplate{}, pkgerrors.NewError(http.StatusBadRequest, "invalid template: "+err.Error())
+	}
+
+	now := time.Now()
+	req.UpdatedAt = &now
+	if err := n.storage.Notification().SaveTemplate(ctx, req); err != nil {
+		return entities.NotificationTemplate{}, n.internalError("UpdateTemplate", err)
+	}
+	req.Variables = def.Variables
+	req.Edited = true
+
+	n.log.Info("UpdateTemplate finished")
+	return req, nil
+}
+
+// ResetTemplate drops the edited text so that the built-in one is used again
+func (n notificationController) ResetTemplate(ctx context.Context, key, language string) (entities.NotificationTemplate, error) {
+	n.log.Info("ResetTemplate started: ",
+		zap.String("Request: ", fmt.Sprintf("Key: %s, Language: %s", key, language)))
+
+	def, ok := templates.Lookup(key)
+	if !ok {
+		return entities.NotificationTemplate{}, e.ErrTemplateNotFound
+	}
+	if err := n.storage.Notification().DeleteTemplate(ctx, key, language); err != nil {
+		return entities.NotificationTemplate{}, n.internalError("ResetTemplate", err)
+	}
+	template := builtInTemplate(def, language)
+	template.Variables = def.Variables
+
+	n.log.Info("ResetTemplate finished")
+	return template, nil
+}
+
+// PreviewTemplate renders a text before the admin saves it, or the text in use when
+// none is given. Variables not given in the request get sample values.
+func (n notificationController) PreviewTemplate(ctx context.Context, req entities.TemplatePreviewReq) (templates.Text, error) {
+	n.log.Info("PreviewTemplate started: ",
+		zap.String("Request: ", fmt.Sprintf("Key: %s, Language: %s", req.Key, req.Language)))
+
+	def, ok := templates.Lookup(req.Key)
+	if !ok {
+		return templates.Text{}, e.ErrTemplateNotFound
+	}
+
+	text := templates.Text{Title: req.Title, Body: req.Body}
+	if text.Body == "" {
+		edited, err := n.storage.Notification().GetTemplate(ctx, req.Key, req.Language)
+		switch {
+		case err == nil:
+			text = templates.Text{Title: edited.Title, Body: edited.Body}
+		case errors.Is(err, e.ErrTemplateNotFound):
+			text = def.Text(req.Language)
+		default:
+			return templates.Text{}, n.internalError("PreviewTemplate", err)
+		}
+	}
+	if err := def.Validate(text); err != nil {
+		return templates.Text{}, pkgerrors.NewError(http.StatusBadRequest, "invalid template: "+err.Error())
+	}
+
+	vars := def.Sample()
+	for name, value := range req.Vars {
+		if _, ok := vars[name]; ok {
+			vars[name] = value
+		}
+	}
+	data, err := templates.Render(text, vars)
+	if err != nil {
+		return templates.Text{}, pkgerrors.NewError(http.StatusBadRequest, "invalid template: "+err.Error())
+	}
+
+	n.log.Info("PreviewTemplate finished")
+	return data, nil
+}
+
+func builtInTemplate(def templates.Definition, language string) entities.NotificationTemplate {
+	text := def.Text(language)
+	return entities.NotificationTemplate{
+		Key:      def.Key,
+		Language: language,
+		Title:    text.Title,
+		Body:     text.Body,
+	}
+}
@@ -0,0 +1,242 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/pkg/utils"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// cancelRule tells whether the customer is charged the cancellation fee
+type cancelRule struct {
+	feeAlways bool
+	// feeReasons charge the fee only when the cancellation is the customer's fault
+	feeReasons []string
+}
+
+// cancelPolicy lists, per actor role, the order statuses an order can be cancelled from.
+// A status that is missing for a role means the role can not cancel the order in it.
+var cancelPolicy = map[string]map[string]cancelRule{
+	constants.UserRole: {
+		constants.OrderStatusAwaitingPayment: {},
+		constants.OrderStatusNew:             {},
+		constants.OrderStatusAccepted:        {},
+		constants.OrderStatusPreparing:       {feeAlways: true},
+		constants.OrderStatusReady:           {feeAlways: true},
+	},
+	constants.SellerRole: {
+		constants.OrderStatusNew:       {},
+		constants.OrderStatusAccepted:  {},
+		constants.OrderStatusPreparing: {},
+		constants.OrderStatusReady:     {},
+	},
+	constants.CourierRole: {
+		constants.OrderStatusPickedUp: {
+			feeReasons: []string{constants.CancelReasonCustomerUnreachable, constants.CancelReasonAddressUnreachable},
+		},
+	},
+	constants.AdminRole: {
+		constants.OrderStatusAwaitingPayment: {},
+		constants.OrderStatusNew:             {},
+		constants.OrderStatusAccepted:        {},
+		constants.OrderStatusPreparing:       {},
+		constants.OrderStatusReady:           {},
+		constants.OrderStatusPickedUp:        {},
+	},
+	constants.SystemRole: {
+		constants.OrderStatusAwaitingPayment: {},
+		constants.OrderStatusNew:             {},
+	},
+}
+
+// cancelReasons lists reason codes every role may use
+var cancelReasons = map[string][]string{
+	constants.UserRole: {
+		constants.CancelReasonChangedMind,
+		constants.CancelReasonOrderedByMistake,
+		constants.CancelReasonLongWait,
+		constants.CancelReasonOther,
+	},
+	constants.SellerRole: {
+		constants.CancelReasonOutOfStock,
+		constants.CancelReasonShopClosed,
+		constants.CancelReasonFraudSuspected,
+		constants.CancelReasonOther,
+	},
+	constants.CourierRole: {
+		constants.CancelReasonCustomerUnreachable,
+		constants.CancelReasonAddressUnreachable,
+		constants.CancelReasonVehicleProblem,
+		constants.CancelReasonOther,
+	},
+	constants.AdminRole: {
+		constants.CancelReasonChangedMind,
+		constants.CancelReasonOrderedByMistake,
+		constants.CancelReasonLongWait,
+		constants.CancelReasonOutOfStock,
+		constants.CancelReasonShopClosed,
+		constants.CancelReasonCustomerUnreachable,
+		constants.CancelReasonAddressUnreachable,
+		constants.CancelReasonVehicleProblem,
+		constants.CancelReasonFraudSuspected,
+		constants.CancelReasonOther,
+	},
+}
+
+// cancellationFee decides if the actor can cancel the order and returns the fee the customer pays
+func cancellationFee(order entities.Order, role, reasonCode string, feePercent int64) (int64, error) {
+	rule, ok := cancelPolicy[role][order.Status]
+	if !ok {
+		return 0, e.ErrOrderCancelNotAllowed
+	}
+	if !rule.feeAlways && !utils.InEnums(reasonCode, rule.feeReasons) {
+		return 0, nil
+	}
+
+	fee := order.ItemsTotal * feePercent / 100
+	if fee > order.Total {
+		fee = order.Total
+	}
+	return fee, nil
+}
+
+// authorizeActor checks that the actor is related to the order
+func (o orderController) authorizeActor(ctx context.Context, order entities.Order, actor entities.Actor) error {
+	switch actor.Role {
+	case constants.AdminRole, constants.SystemRole:
+		return nil
+	case constants.UserRole:
+		if order.UserID == actor.ID {
+			return nil
+		}
+	case constants.SellerRole:
+		xozmakId, err := o.storage.Order().GetUserXozmakID(ctx, actor.ID)
+		if err == nil && xozmakId == order.XozmakID {
+			return nil
+		}
+	case constants.CourierRole:
+		if order.CourierID != nil && *order.CourierID == actor.ID {
+			return nil
+		}
+	}
+	return e.ErrOrderForbidden
+}
+
+func (o orderController) CancelOrder(ctx context.Context, req entities.CancelOrderReq) (entities.CancelOrderRes, error) {
+	o.log.Info("CancelOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, ActorID: %s, ActorRole: %s, ReasonCode: %s",
+			req.OrderID, req.Actor.ID, req.Actor.Role, req.ReasonCode)))
+
+	if req.Actor.Role != constants.SystemRole && !utils.InEnums(req.ReasonCode, cancelReasons[req.Actor.Role]) {
+		return entities.CancelOrderRes{}, e.ErrInvalidReasonCode
+	}
+
+	order, err := o.storage.Order().GetOrder(ctx, req.OrderID)
+	if err != nil {
+		return entities.CancelOrderRes{}, o.internalError("CancelOrder", err)
+	}
+	if err := o.authorizeActor(ctx, order, req.Actor); err != nil {
+		return entities.CancelOrderRes{}, err
+	}
+
+	history := entities.OrderHistory{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ActorRole:  req.Actor.Role,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+	}
+	if req.Actor.ID != "" {
+		history.ActorID = &req.Actor.ID
+	}
+
+	fee, err := cancellationFee(order, req.Actor.Role, req.ReasonCode, o.cfg.CancelFeePercent)
+	if err != nil {
+		history.Action = constants.OrderActionCancelDenied
+		history.ToStatus = order.Status
+		if err := o.storage.Order().InsertOrderHistory(ctx, history); err != nil {
+			o.log.Error("error in CancelOrder: ", zap.Error(err))
+		}
+		return entities.CancelOrderRes{}, err
+	}
+
+	history.Action = constants.OrderActionCancelled
+	history.Fee = fee
+
+	res, err := o.closeOrder(ctx, order, constants.OrderStatusCancelled, history)
+	if err != nil {
+		return entities.CancelOrderRes{}, err
+	}
+
+	o.log.Info("CancelOrder finished")
+	return res, nil
+}
+
+// closeOrder moves the order to a final status, releases its reservations and refunds a prepaid order
+func (o orderController) closeOrder(ctx context.Context, order entities.Order, toStatus string, history entities.OrderHistory) (entities.CancelOrderRes, error) {
+	history.ToStatus = toStatus
+	cancellation := entities.OrderCancellation{
+		Order:    order,
+		ToStatus: toStatus,
+		History:  history,
+	}
+
+	// cash is collected on delivery, so only paid prepaid orders get money back
+	refundAmount := order.Total - history.Fee
+	if order.PaymentMethod != constants.PaymentMethodCash && order.PaidAt != nil && refundAmount > 0 {
+		cancellation.Refund = &entities.Refund{
+			ID:            uuid.NewString(),
+			OrderID:       order.ID,
+			Amount:        refundAmount,
+			PaymentMethod: order.PaymentMethod,
+			Status:        constants.RefundStatusPending,
+		}
+	} else {
+		refundAmount = 0
+	}
+
+	err := o.storage.Order().CancelOrder(ctx, cancellation)
+	if err != nil {
+		return entities.CancelOrderRes{}, o.internalError("closeOrder", err)
+	}
+	o.publishStatus(ctx, order.ID, toStatus)
+	o.forgetCourierOrders(ctx, order.CourierID)
+
+	if order.Status == constants.OrderStatusAwaitingPayment {
+		if err := o.payments.CancelPendingPayments(ctx, order.ID); err != nil {
+			o.log.Error("error in closeOrder: ", zap.Error(err))
+		}
+	}
+
+	return entities.CancelOrderRes{
+		OrderID:      order.ID,
+		Status:       toStatus,
+		Fee:          history.Fee,
+		RefundAmount: refundAmount,
+	}, nil
+}
+
+func (o orderController) GetOrderTimeline(ctx context.Context, actor entities.Actor, orderId string) ([]entities.OrderHistory, error) {
+	o.log.Info("GetOrderTimeline started: ", zap.String("OrderID", orderId))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("GetOrderTimeline", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	data, err := o.storage.Order().GetOrderHistory(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("GetOrderTimeline", err)
+	}
+
+	o.log.Info("GetOrderTimeline finished")
+	return data, nil
+}
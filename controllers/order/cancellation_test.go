@@ -0,0 +1,109 @@
+package order
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"testing"
+)
+
+var orderStatuses = []string{
+	constants.OrderStatusAwaitingPayment,
+	constants.OrderStatusNew,
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+	constants.OrderStatusPickedUp,
+	constants.OrderStatusDelivered,
+	constants.OrderStatusCancelled,
+	constants.OrderStatusRejected,
+}
+
+func TestCancelPolicyMatrix(t *testing.T) {
+	// the statuses each role can cancel from, every other status is refused
+	allowed := map[string][]string{
+		constants.UserRole: {
+			constants.OrderStatusAwaitingPayment,
+			constants.OrderStatusNew,
+			constants.OrderStatusAccepted,
+			constants.OrderStatusPreparing,
+			constants.OrderStatusReady,
+		},
+		constants.SellerRole: {
+			constants.OrderStatusNew,
+			constants.OrderStatusAccepted,
+			constants.OrderStatusPreparing,
+			constants.OrderStatusReady,
+		},
+		constants.CourierRole: {
+			constants.OrderStatusPickedUp,
+		},
+		constants.AdminRole: {
+			constants.OrderStatusAwaitingPayment,
+			constants.OrderStatusNew,
+			constants.OrderStatusAccepted,
+			constants.OrderStatusPreparing,
+			constants.OrderStatusReady,
+			constants.OrderStatusPickedUp,
+		},
+		constants.SystemRole: {
+			constants.OrderStatusAwaitingPayment,
+			constants.OrderStatusNew,
+		},
+		"guest": nil,
+	}
+
+	for role, statuses := range allowed {
+		for _, status := range orderStatuses {
+			want := false
+			for _, s := range statuses {
+				want = want || s == status
+			}
+			order := entities.Order{Status: status, ItemsTotal: 100000, Total: 110000}
+			_, err := cancellationFee(order, role, constants.CancelReasonOther, 10)
+			if want && err != nil {
+				t.Fatalf("%s can not cancel a %s order: %v", role, status, err)
+			}
+			if !want && !errors.Is(err, e.ErrOrderCancelNotAllowed) {
+				t.Fatalf("%s cancelling a %s order: got %v, want ErrOrderCancelNotAllowed", role, status, err)
+			}
+		}
+	}
+}
+
+func TestCancellationFee(t *testing.T) {
+	cases := []struct {
+		name       string
+		role       string
+		status     string
+		reason     string
+		itemsTotal int64
+		total      int64
+		percent    int64
+		want       int64
+	}{
+		{"customer before preparing", constants.UserRole, constants.OrderStatusAccepted, constants.CancelReasonChangedMind, 100000, 110000, 10, 0},
+		{"customer while preparing", constants.UserRole, constants.OrderStatusPreparing, constants.CancelReasonChangedMind, 100000, 110000, 10, 10000},
+		{"customer when ready", constants.UserRole, constants.OrderStatusReady, constants.CancelReasonLongWait, 100000, 110000, 10, 10000},
+		{"fee is taken from the items only", constants.UserRole, constants.OrderStatusReady, constants.CancelReasonOther, 55555, 70555, 20, 11111},
+		{"fee never exceeds the total", constants.UserRole, constants.OrderStatusReady, constants.CancelReasonOther, 100000, 30000, 50, 30000},
+		{"no fee when the percent is zero", constants.UserRole, constants.OrderStatusReady, constants.CancelReasonOther, 100000, 110000, 0, 0},
+		{"seller never charges", constants.SellerRole, constants.OrderStatusReady, constants.CancelReasonOutOfStock, 100000, 110000, 10, 0},
+		{"courier with customer unreachable", constants.CourierRole, constants.OrderStatusPickedUp, constants.CancelReasonCustomerUnreachable, 100000, 110000, 10, 10000},
+		{"courier with address unreachable", constants.CourierRole, constants.OrderStatusPickedUp, constants.CancelReasonAddressUnreachable, 100000, 110000, 10, 10000},
+		{"courier with a vehicle problem", constants.CourierRole, constants.OrderStatusPickedUp, constants.CancelReasonVehicleProblem, 100000, 110000, 10, 0},
+		{"admin after pickup", constants.AdminRole, constants.OrderStatusPickedUp, constants.CancelReasonCustomerUnreachable, 100000, 110000, 10, 0},
+		{"system before payment", constants.SystemRole, constants.OrderStatusAwaitingPayment, constants.CancelReasonPaymentTimeout, 100000, 110000, 10, 0},
+	}
+	for _, c := range cases {
+		order := entities.Order{Status: c.status, ItemsTotal: c.itemsTotal, Total: c.total}
+		fee, err := cancellationFee(order, c.role, c.reason, c.percent)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if fee != c.want {
+			t.Fatalf("%s: fee is %d, want %d", c.name, fee, c.want)
+		}
+	}
+}
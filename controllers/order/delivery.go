@@ -0,0 +1,384 @@
+package order
+
+import (
+	"context"
+	"crypto/subtle"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/pkg/media"
+	"delivery/pkg/receipt"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// courierOrder loads an order the courier is assigned to
+func (o orderController) courierOrder(ctx context.Context, actor entities.Actor, orderId string) (entities.Order, error) {
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.Order{}, o.internalError("courierOrder", err)
+	}
+	if actor.Role != constants.CourierRole || order.CourierID == nil || *order.CourierID != actor.ID {
+		return entities.Order{}, e.ErrOrderForbidden
+	}
+	return order, nil
+}
+
+// PickUpOrder is called by the courier when they take the bag at the xozmak
+func (o orderController) PickUpOrder(ctx context.Context, actor entities.Actor, orderId string) error {
+	o.log.Info("PickUpOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s", orderId, actor.ID)))
+
+	order, err := o.courierOrder(ctx, actor, orderId)
+	if err != nil {
+		return err
+	}
+	if order.Status != constants.OrderStatusReady {
+		return e.ErrOrderStatusChanged
+	}
+
+	err = o.storage.Order().UpdateOrderStatus(ctx, entities.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   constants.OrderStatusPickedUp,
+		Fields:     map[string]interface{}{"picked_up_at": time.Now()},
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionPickedUp,
+			FromStatus: order.Status,
+			ToStatus:   constants.OrderStatusPickedUp,
+			ActorRole:  actor.Role,
+			ActorID:    &actor.ID,
+		},
+	})
+	if err != nil {
+		return o.internalError("PickUpOrder", err)
+	}
+	o.publishStatus(ctx, order.ID, constants.OrderStatusPickedUp)
+	o.forgetCourierOrders(ctx, order.CourierID)
+
+	err = o.notifier.Notify(ctx, entities.Notification{
+		UserID:   order.UserID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventOrderPickedUp,
+		Template: constants.TemplateOrderPickedUp,
+		Vars: map[string]string{
+			"order_number": strconv.FormatInt(order.Number, 10),
+			"handoff_code": order.HandoffCode,
+		},
+	})
+	if err != nil {
+		o.log.Error("error in PickUpOrder: ", zap.Error(err))
+	}
+
+	o.log.Info("PickUpOrder finished")
+	return nil
+}
+
+// proofPhotoTypes are the accepted photo formats with the extension they are stored with
+var proofPhotoTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+func proofPhotoPrefix(orderId string) string {
+	return constants.ProofPhotoKeyPrefix + orderId + "/"
+}
+
+// UploadProofPhoto stores the photo of the drop taken by the courier, the returned key
+// is sent with the delivery. The format is told by the content, not by the file name.
+func (o orderController) UploadProofPhoto(ctx context.Context, actor entities.Actor, orderId string, data []byte) (entities.ProofPhoto, error) {
+	o.log.Info("UploadProofPhoto started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s, Size: %d", orderId, actor.ID, len(data))))
+
+	order, err := o.courierOrder(ctx, actor, orderId)
+	if err != nil {
+		return entities.ProofPhoto{}, err
+	}
+	if order.Status != constants.OrderStatusPickedUp {
+		return entities.ProofPhoto{}, e.ErrOrderStatusChanged
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := proofPhotoTypes[contentType]
+	if !ok {
+		return entities.ProofPhoto{}, e.ErrUnsupportedPhoto
+	}
+	key := proofPhotoPrefix(order.ID) + uuid.NewString() + ext
+	if err := o.media.Put(ctx, key, media.Object{Data: data, ContentType: contentType}); err != nil {
+		return entities.ProofPhoto{}, o.internalError("UploadProofPhoto", err)
+	}
+
+	o.log.Info("UploadProofPhoto finished", zap.String("Key", key))
+	return entities.ProofPhoto{Key: key}, nil
+}
+
+// DeliverOrder completes the order. The courier enters the code the customer tells them,
+// only a contactless order may be left at the door with a photo instead. After too many
+// wrong codes the order goes to support, who confirm the handover with the customer.
+// The proof is kept with the place and time.
+func (o orderController) DeliverOrder(ctx context.Context, req entities.DeliverOrderReq) error {
+	o.log.Info("DeliverOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, CourierID: %s, WithCode: %t, WithPhoto: %t",
+			req.OrderID, req.Actor.ID, req.Code != "", req.PhotoKey != "")))
+
+	if req.Code == "" && req.PhotoKey == "" {
+		return e.ErrProofRequired
+	}
+
+	order, err := o.courierOrder(ctx, req.Actor, req.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != constants.OrderStatusPickedUp {
+		return e.ErrOrderStatusChanged
+	}
+
+	proof := entities.DeliveryProof{
+		OrderID:   order.ID,
+		CourierID: req.Actor.ID,
+		Method:    constants.ProofMethodPhoto,
+		Latitude:  req.Lat,
+		Longitude: req.Long,
+		Accuracy:  req.Accuracy,
+		CreatedAt: time.Now(),
+	}
+	if req.PhotoKey != "" {
+		// only a photo uploaded for this order is accepted
+		if !strings.HasPrefix(req.PhotoKey, proofPhotoPrefix(order.ID)) {
+			return e.ErrProofPhotoNotFound
+		}
+		exists, err := o.media.Exists(ctx, req.PhotoKey)
+		if err != nil {
+			return o.internalError("DeliverOrder", err)
+		}
+		if !exists {
+			return e.ErrProofPhotoNotFound
+		}
+		proof.PhotoKey = &req.PhotoKey
+	}
+	if req.Code != "" {
+		if err := o.checkHandoffCode(ctx, order, req.Actor, req.Code); err != nil {
+			return err
+		}
+		proof.Method = constants.ProofMethodCode
+	} else if !order.Contactless {
+		return e.ErrHandoffCodeRequired
+	}
+
+	if err := o.completeDelivery(ctx, order, proof, req.Actor, "proof="+proof.Method); err != nil {
+		return err
+	}
+
+	o.log.Info("DeliverOrder finished")
+	return nil
+}
+
+// GetHandoffLockedOrders lists orders on the way whose handoff code got locked, support
+// calls their customers and confirms the handover
+func (o orderController) GetHandoffLockedOrders(ctx context.Context, limit, page int) (entities.OrderList, error) {
+	o.log.Info("GetHandoffLockedOrders started")
+
+	orders, count, err := o.storage.Order().GetOrdersWithAction(ctx, constants.OrderStatusPickedUp,
+		constants.OrderActionHandoffLocked, limit, (page-1)*limit)
+	if err != nil {
+		return entities.OrderList{}, o.internalError("GetHandoffLockedOrders", err)
+	}
+
+	o.log.Info("GetHandoffLockedOrders finished")
+	return entities.OrderList{Orders: orders, Count: count}, nil
+}
+
+// ConfirmDelivery completes the order on behalf of the courier once support confirmed the
+// handover with the customer, it is used when the handoff code got locked
+func (o orderController) ConfirmDelivery(ctx context.Context, req entities.ConfirmDeliveryReq) error {
+	o.log.Info("ConfirmDelivery started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, AdminID: %s", req.OrderID, req.Actor.ID)))
+
+	order, err := o.storage.Order().GetOrder(ctx, req.OrderID)
+	if err != nil {
+		return o.internalError("ConfirmDelivery", err)
+	}
+	if order.Status != constants.OrderStatusPickedUp || order.CourierID == nil {
+		return e.ErrOrderStatusChanged
+	}
+
+	proof := entities.DeliveryProof{
+		OrderID:   order.ID,
+		CourierID: *order.CourierID,
+		Method:    constants.ProofMethodSupport,
+		CreatedAt: time.Now(),
+	}
+	if err := o.completeDelivery(ctx, order, proof, req.Actor, "proof="+proof.Method+"; "+req.Note); err != nil {
+		return err
+	}
+
+	o.log.Info("ConfirmDelivery finished")
+	return nil
+}
+
+// forgetCourierOrders clears the cached orders of the courier, their next location push loads them again
+func (o orderController) forgetCourierOrders(ctx context.Context, courierId *string) {
+	if courierId == nil {
+		return
+	}
+	if err := o.redis.Del(ctx, constants.CourierOrdersKeyPrefix+*courierId).Err(); err != nil {
+		o.log.Error("error in forgetCourierOrders: ", zap.Error(err))
+	}
+}
+
+// completeDelivery marks the order delivered with the proof, pays the courier, takes the
+// commission and tells the customer
+func (o orderController) completeDelivery(ctx context.Context, order entities.Order, proof entities.DeliveryProof, actor entities.Actor, note string) error {
+	err := o.storage.Order().UpdateOrderStatus(ctx, entities.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   constants.OrderStatusDelivered,
+		Fields:     map[string]interface{}{"delivered_at": proof.CreatedAt},
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionDelivered,
+			FromStatus: order.Status,
+			ToStatus:   constants.OrderStatusDelivered,
+			ActorRole:  actor.Role,
+			ActorID:    &actor.ID,
+			Note:       note,
+		},
+		Proof: &proof,
+	})
+	if err != nil {
+		return o.internalError("completeDelivery", err)
+	}
+	o.publishStatus(ctx, order.ID, constants.OrderStatusDelivered)
+	o.redis.Del(ctx, constants.HandoffAttemptsKeyPrefix+order.ID)
+	o.forgetCourierOrders(ctx, order.CourierID)
+
+	// what fails to be recorded here is recorded again by the backfill workers and before settlements
+	if err := o.earnings.RecordDelivery(ctx, order); err != nil {
+		o.log.Error("error in completeDelivery: ", zap.Error(err))
+	}
+	if err := o.earnings.RecordCashCollection(ctx, order); err != nil {
+		o.log.Error("error in completeDelivery: ", zap.Error(err))
+	}
+	if err := o.wallet.AddCashback(ctx, order); err != nil {
+		o.log.Error("error in completeDelivery: ", zap.Error(err))
+	}
+	if err := o.settlement.RecordCommission(ctx, order); err != nil {
+		o.log.Error("error in completeDelivery: ", zap.Error(err))
+	}
+
+	err = o.notifier.Notify(ctx, entities.Notification{
+		UserID:   order.UserID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventOrderDelivered,
+		Template: constants.TemplateOrderDelivered,
+		Vars: map[string]string{
+			"order_number": strconv.FormatInt(order.Number, 10),
+			"amount":       receipt.FormatNumber(order.Total),
+		},
+	})
+	if err != nil {
+		o.log.Error("error in completeDelivery: ", zap.Error(err))
+	}
+	return nil
+}
+
+// checkHandoffCode compares the code and counts wrong attempts, so that
+// a courier can not try all 10000 codes. The last allowed attempt sends the order to support.
+func (o orderController) checkHandoffCode(ctx context.Context, order entities.Order, actor entities.Actor, code string) error {
+	key := constants.HandoffAttemptsKeyPrefix + order.ID
+
+	locked, err := o.handoffCodeLocked(ctx, order)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return e.ErrHandoffCodeLocked
+	}
+
+	if order.HandoffCode != "" && subtle.ConstantTimeCompare([]byte(order.HandoffCode), []byte(code)) == 1 {
+		return nil
+	}
+
+	pipe := o.redis.TxPipeline()
+	attempts := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, constants.HandoffAttemptsTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return o.internalError("checkHandoffCode", err)
+	}
+	if attempts.Val() < constants.MaxHandoffAttempts {
+		return e.ErrInvalidHandoffCode
+	}
+
+	err = o.storage.Order().InsertOrderHistory(ctx, entities.OrderHistory{
+		OrderID:    order.ID,
+		Action:     constants.OrderActionHandoffLocked,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		ActorRole:  actor.Role,
+		ActorID:    &actor.ID,
+		Note:       fmt.Sprintf("attempts=%d", attempts.Val()),
+	})
+	if err != nil {
+		return o.internalError("checkHandoffCode", err)
+	}
+	o.log.Warn("handoff code locked, the order is sent to support", zap.String("OrderID", order.ID))
+	return e.ErrHandoffCodeLocked
+}
+
+// handoffCodeLocked reports whether the courier entered too many wrong codes for the order
+func (o orderController) handoffCodeLocked(ctx context.Context, order entities.Order) (bool, error) {
+	attempts, err := o.redis.Get(ctx, constants.HandoffAttemptsKeyPrefix+order.ID).Int()
+	if err != nil && err != redis.Nil {
+		return false, o.internalError("handoffCodeLocked", err)
+	}
+	return attempts >= constants.MaxHandoffAttempts, nil
+}
+
+func (o orderController) GetDeliveryProof(ctx context.Context, actor entities.Actor, orderId string) (entities.DeliveryProof, error) {
+	o.log.Info("GetDeliveryProof started: ", zap.String("OrderID", orderId))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.DeliveryProof{}, o.internalError("GetDeliveryProof", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return entities.DeliveryProof{}, err
+	}
+
+	data, err := o.storage.Order().GetDeliveryProof(ctx, orderId)
+	if err != nil {
+		return entities.DeliveryProof{}, o.internalError("GetDeliveryProof", err)
+	}
+
+	o.log.Info("GetDeliveryProof finished")
+	return data, nil
+}
+
+// GetDeliveryProofPhoto returns the photo of the drop to the people who can see the proof
+func (o orderController) GetDeliveryProofPhoto(ctx context.Context, actor entities.Actor, orderId string) (media.Object, error) {
+	proof, err := o.GetDeliveryProof(ctx, actor, orderId)
+	if err != nil {
+		return media.Object{}, err
+	}
+	if proof.PhotoKey == nil {
+		return media.Object{}, e.ErrProofNotFound
+	}
+	object, err := o.media.Get(ctx, *proof.PhotoKey)
+	if errors.Is(err, media.ErrNotFound) {
+		return media.Object{}, e.ErrProofNotFound
+	}
+	if err != nil {
+		return media.Object{}, o.internalError("GetDeliveryProofPhoto", err)
+	}
+	return object, nil
+}
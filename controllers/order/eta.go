@@ -0,0 +1,257 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// etaStatuses are order statuses in which the ETA is shown
+var etaStatuses = []string{
+	constants.OrderStatusNew,
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+	constants.OrderStatusPickedUp,
+}
+
+// etaInput is everything the estimate depends on
+type etaInput struct {
+	Order  entities.Order
+	Xozmak entities.Location
+	// Vehicle of the assigned courier, empty when there is none
+	Vehicle string
+	// Position is the latest known courier position, nil when unknown
+	Position *entities.LocationPoint
+	// Batch are the unfinished orders of the order's batch in delivery order, the order
+	// itself included, and Pickups are the locations of their xozmaks
+	Batch   []entities.Order
+	Pickups map[string]entities.Location
+	Now     time.Time
+}
+
+// travelTime is how long the vehicle needs to cover the straight line distance
+func (o orderController) travelTime(vehicle string, km float64) time.Duration {
+	speed, ok := o.cfg.VehicleSpeedsKmh[vehicle]
+	if !ok {
+		speed = o.cfg.VehicleSpeedsKmh[constants.EtaDefaultVehicle]
+	}
+	if speed <= 0 {
+		speed = constants.EtaFallbackSpeedKmh
+	}
+	hours := km * o.cfg.RouteDetourFactor / speed
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// estimateETA predicts when the order is ready, picked up and delivered.
+// Before pickup the courier goes to the xozmak and waits for the order if it is not
+// ready yet, after pickup only the way from the courier to the customer is left.
+func (o orderController) estimateETA(in etaInput) entities.OrderETA {
+	order := in.Order
+	now := in.Now
+
+	readyAt := order.ExpectedReadyAt(now)
+	eta := entities.OrderETA{ReadyAt: readyAt, CalculatedAt: now}
+	address := order.AddressLocation
+
+	if len(in.Batch) > 1 {
+		o.estimateBatchRoute(in, &eta)
+	} else if order.Status == constants.OrderStatusPickedUp {
+		from := in.Xozmak
+		if in.Position != nil {
+			from = entities.Location{Lat: in.Position.Lat, Long: in.Position.Long}
+		}
+		km := utils.DistanceKm(from.Lat, from.Long, address.Lat, address.Long)
+		if in.Position != nil {
+			eta.CourierDistanceKm = &km
+		}
+		eta.DeliveryAt = now.Add(o.travelTime(in.Vehicle, km))
+	} else {
+		pickupAt := readyAt
+		if in.Position != nil {
+			distance := utils.DistanceKm(in.Position.Lat, in.Position.Long, in.Xozmak.Lat, in.Xozmak.Long)
+			eta.CourierDistanceKm = &distance
+			if arrival := now.Add(o.travelTime(in.Vehicle, distance)); arrival.After(pickupAt) {
+				pickupAt = arrival
+			}
+		}
+		km := utils.DistanceKm(in.Xozmak.Lat, in.Xozmak.Long, address.Lat, address.Long)
+		eta.PickupAt = &pickupAt
+		eta.DeliveryAt = pickupAt.Add(constants.EtaHandoverTime + o.travelTime(in.Vehicle, km))
+	}
+
+	minutes := int(math.Ceil(eta.DeliveryAt.Sub(now).Minutes()))
+	if minutes < 1 {
+		minutes = 1
+	}
+	eta.DeliveryInMinutes = minutes
+	return eta
+}
+
+// estimateBatchRoute follows a courier carrying a batch: the orders not picked up yet are
+// collected first, then the customers get them in the batch order. Without a position the
+// courier is assumed to be at the first xozmak they still have to visit.
+func (o orderController) estimateBatchRoute(in etaInput, eta *entities.OrderETA) {
+	at := in.Now
+	here := in.Xozmak
+	for _, order := range in.Batch {
+		if order.Status != constants.OrderStatusPickedUp {
+			here = in.Pickups[order.XozmakID]
+			break
+		}
+	}
+	firstLeg := in.Position != nil
+	if firstLeg {
+		here = entities.Location{Lat: in.Position.Lat, Long: in.Position.Long}
+	}
+
+	goTo := func(stop entities.Location) {
+		km := utils.DistanceKm(here.Lat, here.Long, stop.Lat, stop.Long)
+		if firstLeg {
+			eta.CourierDistanceKm = &km
+			firstLeg = false
+		}
+		at = at.Add(o.travelTime(in.Vehicle, km))
+		here = stop
+	}
+
+	for _, order := range in.Batch {
+		if order.ID == in.Order.ID {
+			order = in.Order
+		}
+		if order.Status == constants.OrderStatusPickedUp {
+			continue
+		}
+		goTo(in.Pickups[order.XozmakID])
+		if readyAt := order.ExpectedReadyAt(in.Now); readyAt.After(at) {
+			at = readyAt
+		}
+		if order.ID == in.Order.ID {
+			pickupAt := at
+			eta.PickupAt = &pickupAt
+		}
+		at = at.Add(constants.EtaHandoverTime)
+	}
+	for _, order := range in.Batch {
+		goTo(order.AddressLocation)
+		if order.ID == in.Order.ID {
+			break
+		}
+		at = at.Add(constants.EtaHandoverTime)
+	}
+	eta.DeliveryAt = at
+}
+
+// orderETA estimates the order with the latest courier position, so the ETA follows the
+// courier as they move. Nil is returned for finished orders.
+func (o orderController) orderETA(ctx context.Context, order entities.Order) (*entities.OrderETA, error) {
+	if !utils.InEnums(order.Status, etaStatuses) {
+		return nil, nil
+	}
+
+	in, err := o.etaInput(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	eta := o.estimateETA(in)
+	return &eta, nil
+}
+
+// etaInput loads the xozmak and the assigned courier with their latest position
+func (o orderController) etaInput(ctx context.Context, order entities.Order) (etaInput, error) {
+	xozmak, err := o.storage.Order().GetXozmakByID(ctx, order.XozmakID)
+	if err != nil {
+		return etaInput{}, err
+	}
+	in := etaInput{Order: order, Xozmak: xozmak.Location, Now: time.Now()}
+	if order.BatchID != nil {
+		if err := o.loadBatch(ctx, &in); err != nil {
+			return etaInput{}, err
+		}
+	}
+
+	if order.CourierID != nil {
+		courier, err := o.storage.Courier().GetCourier(ctx, *order.CourierID)
+		if err != nil {
+			return etaInput{}, err
+		}
+		in.Vehicle = courier.VehicleType
+
+		position, err := o.courierPosition(ctx, courier.ID)
+		if err != nil {
+			return etaInput{}, err
+		}
+		in.Position = position
+	}
+	return in, nil
+}
+
+// loadBatch adds the unfinished orders of the batch and their xozmaks to the input
+func (o orderController) loadBatch(ctx context.Context, in *etaInput) error {
+	orders, err := o.storage.Order().GetBatchOrders(ctx, *in.Order.BatchID)
+	if err != nil {
+		return err
+	}
+
+	in.Pickups = map[string]entities.Location{in.Order.XozmakID: in.Xozmak}
+	for _, order := range orders {
+		if !utils.InEnums(order.Status, etaStatuses) {
+			continue
+		}
+		if _, ok := in.Pickups[order.XozmakID]; !ok {
+			xozmak, err := o.storage.Order().GetXozmakByID(ctx, order.XozmakID)
+			if err != nil {
+				return err
+			}
+			in.Pickups[order.XozmakID] = xozmak.Location
+		}
+		in.Batch = append(in.Batch, order)
+	}
+	return nil
+}
+
+// courierPosition returns the latest position the courier sent, nil when it expired
+func (o orderController) courierPosition(ctx context.Context, courierId string) (*entities.LocationPoint, error) {
+	data, err := o.redis.Get(ctx, constants.CourierPositionKeyPrefix+courierId).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier position: %w", err)
+	}
+	var point entities.LocationPoint
+	if err := json.Unmarshal(data, &point); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal courier position: %w", err)
+	}
+	return &point, nil
+}
+
+// GetOrderETA returns the current ETA of the order
+func (o orderController) GetOrderETA(ctx context.Context, actor entities.Actor, orderId string) (*entities.OrderETA, error) {
+	o.log.Info("GetOrderETA started: ", zap.String("OrderID", orderId))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("GetOrderETA", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	eta, err := o.orderETA(ctx, order)
+	if err != nil {
+		return nil, o.internalError("GetOrderETA", err)
+	}
+
+	o.log.Info("GetOrderETA finished")
+	return eta, nil
+}
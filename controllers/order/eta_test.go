@@ -0,0 +1,181 @@
+package order
+
+import (
+	"delivery/configs"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/pkg/utils"
+	"math"
+	"testing"
+	"time"
+)
+
+func testETAController() orderController {
+	return orderController{cfg: &configs.Configuration{
+		VehicleSpeedsKmh: map[string]float64{
+			constants.VehicleScooter: 30,
+			constants.VehicleBicycle: 15,
+		},
+		RouteDetourFactor: 1.5,
+	}}
+}
+
+func TestEstimateETA(t *testing.T) {
+	o := testETAController()
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	minutes := func(n int) time.Duration { return time.Duration(n) * time.Minute }
+	ptr := func(d time.Duration) *time.Duration { return &d }
+	intPtr := func(n int) *int { return &n }
+	at := func(d time.Duration) *time.Time { moment := now.Add(d); return &moment }
+
+	xozmak := entities.Location{Lat: 41.0, Long: 69.0}
+	address := entities.Location{Lat: 41.09, Long: 69.0}
+	far := &entities.LocationPoint{Lat: 40.82, Long: 69.0}
+	near := &entities.LocationPoint{Lat: 41.001, Long: 69.0}
+	onTheWay := &entities.LocationPoint{Lat: 41.05, Long: 69.0}
+
+	// travel is how long the vehicle going at speed needs for the road between the points
+	travel := func(from, to entities.Location, speed float64) time.Duration {
+		km := utils.DistanceKm(from.Lat, from.Long, to.Lat, to.Long)
+		return time.Duration(km * 1.5 / speed * float64(time.Hour))
+	}
+	point := func(p *entities.LocationPoint) entities.Location { return entities.Location{Lat: p.Lat, Long: p.Long} }
+	shop := travel(xozmak, address, 30)
+
+	cases := []struct {
+		name     string
+		order    entities.Order
+		vehicle  string
+		position *entities.LocationPoint
+		ready    time.Duration
+		// pickup is nil when the order is already picked up
+		pickup   *time.Duration
+		delivery time.Duration
+		// distance is the courier position to the next stop, nil without a position
+		distance *entities.Location
+	}{
+		{
+			name:     "new order waits for the default prep time",
+			order:    entities.Order{Status: constants.OrderStatusNew},
+			ready:    minutes(constants.DefaultPrepMinutes),
+			pickup:   ptr(minutes(constants.DefaultPrepMinutes)),
+			delivery: minutes(constants.DefaultPrepMinutes) + constants.EtaHandoverTime + shop,
+		},
+		{
+			name:     "prep time counts from the acceptance",
+			order:    entities.Order{Status: constants.OrderStatusPreparing, PrepMinutes: intPtr(25), AcceptedAt: at(-minutes(10))},
+			ready:    minutes(15),
+			pickup:   ptr(minutes(15)),
+			delivery: minutes(15) + constants.EtaHandoverTime + shop,
+		},
+		{
+			name:     "late order is expected to be ready now",
+			order:    entities.Order{Status: constants.OrderStatusPreparing, PrepMinutes: intPtr(10), AcceptedAt: at(-minutes(30))},
+			ready:    0,
+			pickup:   ptr(0),
+			delivery: constants.EtaHandoverTime + shop,
+		},
+		{
+			name:     "ready order is picked up as soon as it is ready",
+			order:    entities.Order{Status: constants.OrderStatusReady, ReadyAt: at(-minutes(5))},
+			ready:    -minutes(5),
+			pickup:   ptr(-minutes(5)),
+			delivery: -minutes(5) + constants.EtaHandoverTime + shop,
+		},
+		{
+			name:     "courier close to the xozmak waits for the order",
+			order:    entities.Order{Status: constants.OrderStatusPreparing, PrepMinutes: intPtr(15), AcceptedAt: &now},
+			vehicle:  constants.VehicleScooter,
+			position: near,
+			ready:    minutes(15),
+			pickup:   ptr(minutes(15)),
+			delivery: minutes(15) + constants.EtaHandoverTime + shop,
+			distance: &xozmak,
+		},
+		{
+			name:     "order waits for a courier far from the xozmak",
+			order:    entities.Order{Status: constants.OrderStatusPreparing, PrepMinutes: intPtr(15), AcceptedAt: &now},
+			vehicle:  constants.VehicleBicycle,
+			position: far,
+			ready:    minutes(15),
+			pickup:   ptr(travel(point(far), xozmak, 15)),
+			delivery: travel(point(far), xozmak, 15) + constants.EtaHandoverTime + travel(xozmak, address, 15),
+			distance: &xozmak,
+		},
+		{
+			name:     "courier with an unknown vehicle goes at the default speed",
+			order:    entities.Order{Status: constants.OrderStatusReady, ReadyAt: &now},
+			vehicle:  "rocket",
+			position: far,
+			ready:    0,
+			pickup:   ptr(travel(point(far), xozmak, 30)),
+			delivery: travel(point(far), xozmak, 30) + constants.EtaHandoverTime + shop,
+			distance: &xozmak,
+		},
+		{
+			name:     "picked up order goes from the courier to the customer",
+			order:    entities.Order{Status: constants.OrderStatusPickedUp, ReadyAt: at(-minutes(8))},
+			vehicle:  constants.VehicleScooter,
+			position: onTheWay,
+			ready:    -minutes(8),
+			delivery: travel(point(onTheWay), address, 30),
+			distance: &address,
+		},
+		{
+			name:     "picked up order without a position is counted from the xozmak",
+			order:    entities.Order{Status: constants.OrderStatusPickedUp, ReadyAt: at(-minutes(8))},
+			vehicle:  constants.VehicleScooter,
+			ready:    -minutes(8),
+			delivery: shop,
+		},
+	}
+
+	for _, c := range cases {
+		c.order.AddressLocation = address
+		eta := o.estimateETA(etaInput{Order: c.order, Xozmak: xozmak, Vehicle: c.vehicle, Position: c.position, Now: now})
+
+		if !eta.ReadyAt.Equal(now.Add(c.ready)) {
+			t.Fatalf("%s: ready at %v, want %v", c.name, eta.ReadyAt.Sub(now), c.ready)
+		}
+		switch {
+		case c.pickup == nil && eta.PickupAt != nil:
+			t.Fatalf("%s: pickup at %v, want none", c.name, eta.PickupAt.Sub(now))
+		case c.pickup != nil && (eta.PickupAt == nil || !eta.PickupAt.Equal(now.Add(*c.pickup))):
+			t.Fatalf("%s: pickup at %v, want %v", c.name, eta.PickupAt, *c.pickup)
+		}
+		if !eta.DeliveryAt.Equal(now.Add(c.delivery)) {
+			t.Fatalf("%s: delivery at %v, want %v", c.name, eta.DeliveryAt.Sub(now), c.delivery)
+		}
+		if want := int(math.Ceil(c.delivery.Minutes())); eta.DeliveryInMinutes != want {
+			t.Fatalf("%s: delivery in %d minutes, want %d", c.name, eta.DeliveryInMinutes, want)
+		}
+
+		switch {
+		case c.distance == nil && eta.CourierDistanceKm != nil:
+			t.Fatalf("%s: courier distance %v, want none", c.name, *eta.CourierDistanceKm)
+		case c.distance != nil:
+			want := utils.DistanceKm(c.position.Lat, c.position.Long, c.distance.Lat, c.distance.Long)
+			if eta.CourierDistanceKm == nil || *eta.CourierDistanceKm != want {
+				t.Fatalf("%s: courier distance %v, want %v", c.name, eta.CourierDistanceKm, want)
+			}
+		}
+	}
+}
+
+func TestEstimateETAAtLeastOneMinute(t *testing.T) {
+	o := testETAController()
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	address := entities.Location{Lat: 41.09, Long: 69.0}
+
+	// the courier is already at the door
+	eta := o.estimateETA(etaInput{
+		Order:    entities.Order{Status: constants.OrderStatusPickedUp, ReadyAt: &now, AddressLocation: address},
+		Xozmak:   entities.Location{Lat: 41.0, Long: 69.0},
+		Vehicle:  constants.VehicleScooter,
+		Position: &entities.LocationPoint{Lat: address.Lat, Long: address.Long},
+		Now:      now,
+	})
+	if eta.DeliveryInMinutes != 1 || !eta.DeliveryAt.Equal(now) {
+		t.Fatalf("delivery in %d minutes at %v, want 1 minute at now", eta.DeliveryInMinutes, eta.DeliveryAt)
+	}
+}
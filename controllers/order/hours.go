@@ -0,0 +1,226 @@
+package order
+
+import (
+	"context"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// scheduleLookahead is how many days of exceptions are loaded for the open status,
+// the day before now is loaded as well for openings that last past midnight
+const scheduleLookahead = 16
+
+// xozmakSchedules loads the hours and exceptions around at of every xozmak
+func (o orderController) xozmakSchedules(ctx context.Context, xozmaks []entities.Xozmak, at time.Time) (map[string]entities.XozmakSchedule, error) {
+	ids := make([]string, 0, len(xozmaks))
+	for _, xozmak := range xozmaks {
+		ids = append(ids, xozmak.ID)
+	}
+	schedules := make(map[string]entities.XozmakSchedule, len(xozmaks))
+	if len(ids) == 0 {
+		return schedules, nil
+	}
+
+	hours, err := o.storage.Order().GetWorkingHours(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	from := at.AddDate(0, 0, -1).Format(time.DateOnly)
+	to := at.AddDate(0, 0, scheduleLookahead).Format(time.DateOnly)
+	exceptions, err := o.storage.Order().GetHoursExceptions(ctx, ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, xozmak := range xozmaks {
+		schedule := entities.XozmakSchedule{
+			Hours:          []entities.WorkingHours{},
+			Exceptions:     []entities.HoursException{},
+			Paused:         xozmak.OrdersPaused,
+			AllowPreorders: xozmak.AllowPreorders,
+		}
+		for _, h := range hours {
+			if h.XozmakID == xozmak.ID {
+				schedule.Hours = append(schedule.Hours, h)
+			}
+		}
+		for _, exception := range exceptions {
+			if exception.XozmakID == nil || *exception.XozmakID == xozmak.ID {
+				schedule.Exceptions = append(schedule.Exceptions, exception)
+			}
+		}
+		schedules[xozmak.ID] = schedule
+	}
+	return schedules, nil
+}
+
+func (o orderController) xozmakSchedule(ctx context.Context, xozmak entities.Xozmak, at time.Time) (entities.XozmakSchedule, error) {
+	schedules, err := o.xozmakSchedules(ctx, []entities.Xozmak{xozmak}, at)
+	if err != nil {
+		return entities.XozmakSchedule{}, err
+	}
+	return schedules[xozmak.ID], nil
+}
+
+// now returns the current time in the time zone the working hours are set in
+func (o orderController) now() time.Time {
+	return time.Now().In(o.cfg.TimeZone)
+}
+
+// checkOrderingHours tells whether the xozmak takes an order now. While it is closed only
+// pre-orders for a slot are taken, and the slot must start while the xozmak works.
+func (o orderController) checkOrderingHours(ctx context.Context, xozmak entities.Xozmak, slotId string) error {
+	now := o.now()
+	schedule, err := o.xozmakSchedule(ctx, xozmak, now)
+	if err != nil {
+		return err
+	}
+	status := schedule.StatusAt(now)
+	if status.Paused {
+		return e.ErrXozmakPaused
+	}
+	if slotId == "" {
+		if !status.IsOpen {
+			return e.ErrXozmakClosed
+		}
+		return nil
+	}
+
+	slot, err := o.storage.Order().GetSlot(ctx, slotId)
+	if err != nil {
+		return err
+	}
+	if slot.XozmakID != xozmak.ID {
+		return e.ErrSlotNotFound
+	}
+	if !status.IsOpen && !status.AcceptsPreorders {
+		return e.ErrXozmakClosed
+	}
+	worksAt, err := o.worksAt(ctx, xozmak, schedule, now, slot.StartsAt)
+	if err != nil {
+		return err
+	}
+	if !worksAt {
+		return e.ErrSlotOutsideHours
+	}
+	return nil
+}
+
+// worksAt tells whether the xozmak works at t using the schedule loaded at now,
+// the exceptions are loaded again when t is further than they reach
+func (o orderController) worksAt(ctx context.Context, xozmak entities.Xozmak, schedule entities.XozmakSchedule, now, t time.Time) (bool, error) {
+	t = t.In(o.cfg.TimeZone)
+	if t.After(now.AddDate(0, 0, scheduleLookahead-1)) || t.Before(now) {
+		var err error
+		if schedule, err = o.xozmakSchedule(ctx, xozmak, t); err != nil {
+			return false, err
+		}
+	}
+	return schedule.OpenAt(t), nil
+}
+
+func (o orderController) SetWorkingHours(ctx context.Context, req entities.WorkingHoursReq) error {
+	o.log.Info("SetWorkingHours started: ",
+		zap.String("Request: ", fmt.Sprintf("XozmakID: %s, Hours: %d", req.XozmakID, len(req.Hours))))
+
+	if _, err := o.storage.Order().GetXozmakByID(ctx, req.XozmakID); err != nil {
+		return o.internalError("SetWorkingHours", err)
+	}
+	err := o.storage.Order().ReplaceWorkingHours(ctx, req.XozmakID, req.Hours)
+	if err != nil {
+		return o.internalError("SetWorkingHours", err)
+	}
+
+	o.log.Info("SetWorkingHours finished")
+	return nil
+}
+
+// GetXozmakSchedule returns the hours, the upcoming exceptions and the open status of the xozmak
+func (o orderController) GetXozmakSchedule(ctx context.Context, xozmakId string) (entities.XozmakSchedule, error) {
+	o.log.Info("GetXozmakSchedule started: ", zap.String("XozmakID", xozmakId))
+
+	xozmak, err := o.storage.Order().GetXozmakByID(ctx, xozmakId)
+	if err != nil {
+		return entities.XozmakSchedule{}, o.internalError("GetXozmakSchedule", err)
+	}
+	now := o.now()
+	schedule, err := o.xozmakSchedule(ctx, xozmak, now)
+	if err != nil {
+		return entities.XozmakSchedule{}, o.internalError("GetXozmakSchedule", err)
+	}
+	schedule.Status = schedule.StatusAt(now)
+
+	o.log.Info("GetXozmakSchedule finished")
+	return schedule, nil
+}
+
+// CreateHoursException adds a day off or special hours, a holiday of all xozmaks when XozmakID is nil
+func (o orderController) CreateHoursException(ctx context.Context, req entities.HoursException) (entities.HoursException, error) {
+	o.log.Info("CreateHoursException started: ",
+		zap.String("Request: ", fmt.Sprintf("XozmakID: %v, Date: %s, Closed: %t", req.XozmakID, req.Date, req.Closed)))
+
+	if req.XozmakID != nil {
+		if _, err := o.storage.Order().GetXozmakByID(ctx, *req.XozmakID); err != nil {
+			return entities.HoursException{}, o.internalError("CreateHoursException", err)
+		}
+	}
+	req.ID = uuid.NewString()
+	req.CreatedAt = time.Now()
+	if err := o.storage.Order().CreateHoursException(ctx, req); err != nil {
+		return entities.HoursException{}, o.internalError("CreateHoursException", err)
+	}
+
+	o.log.Info("CreateHoursException finished")
+	return req, nil
+}
+
+func (o orderController) DeleteHoursException(ctx context.Context, id string) error {
+	o.log.Info("DeleteHoursException started: ", zap.String("ExceptionID", id))
+
+	err := o.storage.Order().DeleteHoursException(ctx, id)
+	if err != nil {
+		return o.internalError("DeleteHoursException", err)
+	}
+
+	o.log.Info("DeleteHoursException finished")
+	return nil
+}
+
+func (o orderController) SetXozmakOrdering(ctx context.Context, req entities.XozmakOrderingReq) error {
+	o.log.Info("SetXozmakOrdering started: ", zap.String("XozmakID", req.XozmakID))
+
+	err := o.storage.Order().UpdateXozmakOrdering(ctx, req)
+	if err != nil {
+		return o.internalError("SetXozmakOrdering", err)
+	}
+
+	o.log.Info("SetXozmakOrdering finished")
+	return nil
+}
+
+// SetSellerOrdersPaused stops or resumes taking orders of the seller's xozmak
+func (o orderController) SetSellerOrdersPaused(ctx context.Context, actor entities.Actor, paused bool) error {
+	o.log.Info("SetSellerOrdersPaused started: ",
+		zap.String("Request: ", fmt.Sprintf("SellerID: %s, Paused: %t", actor.ID, paused)))
+
+	xozmakId, err := o.storage.Order().GetUserXozmakID(ctx, actor.ID)
+	if err != nil {
+		if errors.Is(err, e.ErrXozmakNotFound) {
+			return e.ErrNotSeller
+		}
+		return o.internalError("SetSellerOrdersPaused", err)
+	}
+	err = o.storage.Order().UpdateXozmakOrdering(ctx, entities.XozmakOrderingReq{XozmakID: xozmakId, OrdersPaused: &paused})
+	if err != nil {
+		return o.internalError("SetSellerOrdersPaused", err)
+	}
+
+	o.log.Info("SetSellerOrdersPaused finished")
+	return nil
+}
@@ -0,0 +1,534 @@
+package order
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	dispatchcontroller "delivery/controllers/dispatch"
+	earningscontroller "delivery/controllers/earnings"
+	notificationcontroller "delivery/controllers/notification"
+	paymentcontroller "delivery/controllers/payment"
+	settlementcontroller "delivery/controllers/settlement"
+	trackingcontroller "delivery/controllers/tracking"
+	walletcontroller "delivery/controllers/wallet"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/media"
+	"delivery/pkg/security"
+	"delivery/pkg/utils"
+	"delivery/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type OrderController interface {
+	CreateSlot(ctx context.Context, req entities.DeliverySlot) error
+	GetXozmakSlots(ctx context.Context, xozmakId string) ([]entities.DeliverySlot, error)
+	UpdateSlot(ctx context.Context, req entities.DeliverySlot) error
+	DeleteSlot(ctx context.Context, id string) error
+	GetAvailableSlots(ctx context.Context, req entities.SlotsReq) ([]entities.SlotAvailability, error)
+	PlaceOrder(ctx context.Context, req entities.PlaceOrderReq) (entities.Order, error)
+	GetOrderHistory(ctx context.Context, userId string, limit, page int) (entities.OrderList, error)
+	GetUserOrder(ctx context.Context, userId, orderId string) (entities.Order, error)
+	Reorder(ctx context.Context, userId, orderId string) (entities.ReorderRes, error)
+	GetCart(ctx context.Context, userId string) (entities.Cart, error)
+	CancelOrder(ctx context.Context, req entities.CancelOrderReq) (entities.CancelOrderRes, error)
+	GetOrderTimeline(ctx context.Context, actor entities.Actor, orderId string) ([]entities.OrderHistory, error)
+	GetSellerOrders(ctx context.Context, actor entities.Actor, statuses []string, limit, page int) (entities.OrderList, error)
+	AcceptOrder(ctx context.Context, req entities.AcceptOrderReq) (entities.Order, error)
+	StartPreparing(ctx context.Context, actor entities.Actor, orderId string) error
+	MarkOrderReady(ctx context.Context, actor entities.Actor, orderId string) error
+	RejectOrder(ctx context.Context, req entities.CancelOrderReq) (entities.CancelOrderRes, error)
+	RunAcceptTimeoutWorker(ctx context.Context)
+	RenderCustomerReceipt(ctx context.Context, actor entities.Actor, orderId string) ([]byte, error)
+	RenderPrinterSlip(ctx context.Context, actor entities.Actor, orderId string, paperWidth int) ([]byte, error)
+	GetOrderTrack(ctx context.Context, actor entities.Actor, orderId string) ([]entities.OrderTrackPoint, error)
+	GetOrderETA(ctx context.Context, actor entities.Actor, orderId string) (*entities.OrderETA, error)
+	PickUpOrder(ctx context.Context, actor entities.Actor, orderId string) error
+	UploadProofPhoto(ctx context.Context, actor entities.Actor, orderId string, data []byte) (entities.ProofPhoto, error)
+	DeliverOrder(ctx context.Context, req entities.DeliverOrderReq) error
+	GetHandoffLockedOrders(ctx context.Context, limit, page int) (entities.OrderList, error)
+	ConfirmDelivery(ctx context.Context, req entities.ConfirmDeliveryReq) error
+	TipOrder(ctx context.Context, req entities.TipOrderReq) (entities.Payment, error)
+	GetDeliveryProof(ctx context.Context, actor entities.Actor, orderId string) (entities.DeliveryProof, error)
+	GetDeliveryProofPhoto(ctx context.Context, actor entities.Actor, orderId string) (media.Object, error)
+	CreateZone(ctx context.Context, req entities.DeliveryZone) error
+	GetXozmakZones(ctx context.Context, xozmakId string) ([]entities.DeliveryZone, error)
+	UpdateZone(ctx context.Context, req entities.DeliveryZone) error
+	DeleteZone(ctx context.Context, id string) error
+	GetDeliveringXozmaks(ctx context.Context, userId, locationId string) ([]entities.DeliveringXozmak, error)
+	StreamOrder(ctx context.Context, actor entities.Actor, orderId string) (<-chan entities.OrderEvent, error)
+	SetWorkingHours(ctx context.Context, req entities.WorkingHoursReq) error
+	GetXozmakSchedule(ctx context.Context, xozmakId string) (entities.XozmakSchedule, error)
+	CreateHoursException(ctx context.Context, req entities.HoursException) (entities.HoursException, error)
+	DeleteHoursException(ctx context.Context, id string) error
+	SetXozmakOrdering(ctx context.Context, req entities.XozmakOrderingReq) error
+	SetSellerOrdersPaused(ctx context.Context, actor entities.Actor, paused bool) error
+}
+
+type orderController struct {
+	log        logger.LoggerI
+	storage    storage.Storage
+	cfg        *configs.Configuration
+	redis      *redis.Client
+	notifier   notificationcontroller.NotificationController
+	dispatcher dispatchcontroller.DispatchController
+	tracker    trackingcontroller.TrackingController
+	earnings   earningscontroller.EarningsController
+	payments   paymentcontroller.PaymentController
+	wallet     walletcontroller.WalletController
+	settlement settlementcontroller.SettlementController
+	media      media.MediaStore
+}
+
+func NewOrderController(log logger.LoggerI, storage storage.Storage, redis *redis.Client, notifier notificationcontroller.NotificationController, dispatcher dispatchcontroller.DispatchController, tracker trackingcontroller.TrackingController, earnings earningscontroller.EarningsController, payments paymentcontroller.PaymentController, wallet walletcontroller.WalletController, settlement settlementcontroller.SettlementController, mediaStore media.MediaStore) OrderController {
+	return orderController{
+		log:        log,
+		storage:    storage,
+		cfg:        configs.Config(),
+		redis:      redis,
+		notifier:   notifier,
+		dispatcher: dispatcher,
+		tracker:    tracker,
+		earnings:   earnings,
+		payments:   payments,
+		wallet:     wallet,
+		settlement: settlement,
+		media:      mediaStore,
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (o orderController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	o.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func (o orderController) CreateSlot(ctx context.Context, req entities.DeliverySlot) error {
+	o.log.Info("CreateSlot started: ",
+		zap.String("Request: ", fmt.Sprintf("XozmakID: %s, StartsAt: %s, Capacity: %d", req.XozmakID, req.StartsAt, req.Capacity)))
+
+	err := o.storage.Order().CreateSlot(ctx, req)
+	if err != nil {
+		return o.internalError("CreateSlot", err)
+	}
+
+	o.log.Info("CreateSlot finished")
+	return nil
+}
+
+func (o orderController) GetXozmakSlots(ctx context.Context, xozmakId string) ([]entities.DeliverySlot, error) {
+	o.log.Info("GetXozmakSlots started: ", zap.String("XozmakID", xozmakId))
+
+	now := time.Now()
+	data, err := o.storage.Order().GetSlots(ctx, xozmakId, now, now.AddDate(0, 0, constants.SlotsDefaultDays))
+	if err != nil {
+		return []entities.DeliverySlot{}, o.internalError("GetXozmakSlots", err)
+	}
+
+	o.log.Info("GetXozmakSlots finished")
+	return data, nil
+}
+
+func (o orderController) UpdateSlot(ctx context.Context, req entities.DeliverySlot) error {
+	o.log.Info("UpdateSlot started: ", zap.String("SlotID", req.ID))
+
+	err := o.storage.Order().UpdateSlot(ctx, req)
+	if err != nil {
+		return o.internalError("UpdateSlot", err)
+	}
+
+	o.log.Info("UpdateSlot finished")
+	return nil
+}
+
+func (o orderController) DeleteSlot(ctx context.Context, id string) error {
+	o.log.Info("DeleteSlot started: ", zap.String("SlotID", id))
+
+	err := o.storage.Order().DeleteSlot(ctx, id)
+	if err != nil {
+		return o.internalError("DeleteSlot", err)
+	}
+
+	o.log.Info("DeleteSlot finished")
+	return nil
+}
+
+func (o orderController) GetAvailableSlots(ctx context.Context, req entities.SlotsReq) ([]entities.SlotAvailability, error) {
+	o.log.Info("GetAvailableSlots started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, XozmakID: %s, LocationID: %s", req.UserID, req.XozmakID, req.LocationID)))
+
+	xozmak, err := o.storage.Order().GetXozmakByID(ctx, req.XozmakID)
+	if err != nil {
+		return nil, o.internalError("GetAvailableSlots", err)
+	}
+
+	location, err := o.storage.Order().GetUserLocationByID(ctx, req.UserID, req.LocationID)
+	if err != nil {
+		return nil, o.internalError("GetAvailableSlots", err)
+	}
+
+	if _, err := o.deliveryZone(ctx, xozmak.ID, location); err != nil {
+		return nil, o.internalError("GetAvailableSlots", err)
+	}
+
+	slots, err := o.storage.Order().GetSlots(ctx, xozmak.ID, req.From, req.To)
+	if err != nil {
+		return nil, o.internalError("GetAvailableSlots", err)
+	}
+
+	now := o.now()
+	schedule, err := o.xozmakSchedule(ctx, xozmak, now)
+	if err != nil {
+		return nil, o.internalError("GetAvailableSlots", err)
+	}
+	status := schedule.StatusAt(now)
+	ordering := status.IsOpen || status.AcceptsPreorders
+
+	res := make([]entities.SlotAvailability, 0, len(slots))
+	for _, slot := range slots {
+		closesAt := slot.StartsAt.Add(-o.cfg.SlotLeadTime)
+		available := slot.Capacity - slot.Reserved
+		worksAt := false
+		if ordering {
+			worksAt, err = o.worksAt(ctx, xozmak, schedule, now, slot.StartsAt)
+			if err != nil {
+				return nil, o.internalError("GetAvailableSlots", err)
+			}
+		}
+		res = append(res, entities.SlotAvailability{
+			ID:        slot.ID,
+			XozmakID:  slot.XozmakID,
+			StartsAt:  slot.StartsAt,
+			EndsAt:    slot.EndsAt,
+			Available: available,
+			ClosesAt:  closesAt,
+			IsOpen:    available > 0 && now.Before(closesAt) && worksAt,
+		})
+	}
+
+	o.log.Info("GetAvailableSlots finished")
+	return res, nil
+}
+
+func (o orderController) PlaceOrder(ctx context.Context, req entities.PlaceOrderReq) (entities.Order, error) {
+	o.log.Info("PlaceOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, XozmakID: %s, SlotID: %s", req.UserID, req.XozmakID, req.SlotID)))
+
+	xozmak, err := o.storage.Order().GetXozmakByID(ctx, req.XozmakID)
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	if err := o.checkOrderingHours(ctx, xozmak, req.SlotID); err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	location, err := o.storage.Order().GetUserLocationByID(ctx, req.UserID, req.LocationID)
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	zone, err := o.deliveryZone(ctx, xozmak.ID, location)
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	productIDs := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := o.storage.Order().GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+	productsByID := make(map[string]entities.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	order := entities.Order{
+		ID:              uuid.NewString(),
+		UserID:          req.UserID,
+		XozmakID:        xozmak.ID,
+		LocationID:      location.ID,
+		AddressName:     location.Name,
+		AddressLocation: entities.Location{Lat: location.Latitude, Long: location.Longitude},
+		Status:          constants.OrderStatusNew,
+		PaymentMethod:   req.PaymentMethod,
+		Comment:         req.Comment,
+		Contactless:     req.Contactless,
+		DeliveryZoneID:  &zone.ID,
+		DeliveryFee:     zone.DeliveryFee,
+	}
+	if req.SlotID != "" {
+		order.SlotID = &req.SlotID
+	}
+	if order.PaymentMethod != constants.PaymentMethodCash {
+		order.Status = constants.OrderStatusAwaitingPayment
+	}
+
+	for _, item := range req.Items {
+		product, ok := productsByID[item.ProductID]
+		if !ok || product.XozmakID != xozmak.ID {
+			return entities.Order{}, e.ErrProductNotFound
+		}
+		order.Items = append(order.Items, entities.OrderItem{
+			ID:        uuid.NewString(),
+			OrderID:   order.ID,
+			ProductID: product.ID,
+			Name:      product.Name,
+			Price:     product.Price,
+			Quantity:  item.Quantity,
+			Modifiers: item.Modifiers,
+		})
+		order.ItemsTotal += product.Price * int64(item.Quantity)
+	}
+	order.HandoffCode, err = security.GenerateRandomCode(constants.HandoffCodeBytes)
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	if order.ItemsTotal < zone.MinOrder {
+		return entities.Order{}, e.ErrBelowMinOrder
+	}
+	if req.Tip != nil {
+		order.Tip = req.Tip.TipAmount(order.ItemsTotal)
+	}
+	order.Total = order.ItemsTotal + order.DeliveryFee + order.Tip
+
+	if order.PaymentMethod == constants.PaymentMethodWallet {
+		balance, err := o.storage.Wallet().GetWalletBalance(ctx, order.UserID)
+		if err != nil {
+			return entities.Order{}, o.internalError("PlaceOrder", err)
+		}
+		if balance < order.Total {
+			return entities.Order{}, e.ErrInsufficientFunds
+		}
+	}
+
+	order, err = o.storage.Order().CreateOrder(ctx, order, time.Now().Add(o.cfg.SlotLeadTime))
+	if err != nil {
+		return entities.Order{}, o.internalError("PlaceOrder", err)
+	}
+
+	switch order.PaymentMethod {
+	case constants.PaymentMethodCard:
+		// the order is kept when the invoice fails, the customer can ask for a new one
+		payment, err := o.payments.CreatePayment(ctx, order, req.PaymentProvider)
+		if err != nil {
+			o.log.Warn("could not create payment", zap.String("OrderID", order.ID), zap.Error(err))
+		} else {
+			order.Payment = &payment
+		}
+	case constants.PaymentMethodWallet:
+		// the wallet may have been spent meanwhile, the customer can pay again or with a card
+		payment, err := o.wallet.PayOrder(ctx, entities.Actor{ID: order.UserID, Role: constants.UserRole}, order.ID)
+		if err != nil {
+			o.log.Warn("could not pay from the wallet", zap.String("OrderID", order.ID), zap.Error(err))
+		} else {
+			order.Payment = &payment
+			order.Status = constants.OrderStatusNew
+			order.PaidAt = payment.PaidAt
+		}
+	}
+
+	o.log.Info("PlaceOrder finished", zap.String("OrderID", order.ID))
+	return order, nil
+}
+
+func (o orderController) GetOrderHistory(ctx context.Context, userId string, limit, page int) (entities.OrderList, error) {
+	o.log.Info("GetOrderHistory started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, Limit: %d, Page: %d", userId, limit, page)))
+
+	orders, count, err := o.storage.Order().GetUserOrders(ctx, userId, limit, (page-1)*limit)
+	if err != nil {
+		return entities.OrderList{}, o.internalError("GetOrderHistory", err)
+	}
+
+	o.log.Info("GetOrderHistory finished")
+	return entities.OrderList{Orders: orders, Count: count}, nil
+}
+
+func (o orderController) GetUserOrder(ctx context.Context, userId, orderId string) (entities.Order, error) {
+	o.log.Info("GetUserOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, OrderID: %s", userId, orderId)))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.Order{}, o.internalError("GetUserOrder", err)
+	}
+	if order.UserID != userId {
+		return entities.Order{}, e.ErrOrderNotFound
+	}
+
+	if !utils.InEnums(order.Status, finalStatuses) {
+		order.CustomerHandoffCode = order.HandoffCode
+	}
+
+	// the order is still useful without the ETA
+	order.ETA, err = o.orderETA(ctx, order)
+	if err != nil {
+		o.log.Error("error in GetUserOrder: ", zap.Error(err))
+	}
+
+	o.log.Info("GetUserOrder finished")
+	return order, nil
+}
+
+// Reorder rebuilds the user's cart from a past order with current prices and stock.
+// Items that can not be copied as they were are reported back as issues.
+func (o orderController) Reorder(ctx context.Context, userId, orderId string) (entities.ReorderRes, error) {
+	o.log.Info("Reorder started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, OrderID: %s", userId, orderId)))
+
+	order, err := o.GetUserOrder(ctx, userId, orderId)
+	if err != nil {
+		return entities.ReorderRes{}, err
+	}
+
+	if _, err := o.storage.Order().GetXozmakByID(ctx, order.XozmakID); err != nil {
+		return entities.ReorderRes{}, o.internalError("Reorder", err)
+	}
+
+	productIDs := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := o.storage.Order().GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		return entities.ReorderRes{}, o.internalError("Reorder", err)
+	}
+	productsByID := make(map[string]entities.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	res := entities.ReorderRes{
+		Cart:   entities.Cart{XozmakID: order.XozmakID, Items: []entities.CartItem{}},
+		Issues: []entities.ReorderIssue{},
+	}
+	for _, item := range order.Items {
+		issue := entities.ReorderIssue{
+			ProductID: item.ProductID,
+			Name:      item.Name,
+			Requested: item.Quantity,
+			OldPrice:  item.Price,
+		}
+
+		product, ok := productsByID[item.ProductID]
+		if !ok {
+			issue.Reason = constants.ReorderItemUnavailable
+			res.Issues = append(res.Issues, issue)
+			continue
+		}
+		issue.Available = product.Stock
+		issue.NewPrice = product.Price
+
+		if product.Stock == 0 {
+			issue.Reason = constants.ReorderItemOutOfStock
+			res.Issues = append(res.Issues, issue)
+			continue
+		}
+
+		quantity := item.Quantity
+		if product.Stock < quantity {
+			quantity = product.Stock
+			issue.Reason = constants.ReorderItemNotEnoughStock
+			res.Issues = append(res.Issues, issue)
+		}
+		if product.Price != item.Price {
+			issue.Reason = constants.ReorderItemPriceChanged
+			res.Issues = append(res.Issues, issue)
+		}
+
+		res.Cart.Items = append(res.Cart.Items, entities.CartItem{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Price:     product.Price,
+			Quantity:  quantity,
+		})
+		res.Cart.Total += product.Price * int64(quantity)
+	}
+
+	cart, err := json.Marshal(res.Cart)
+	if err != nil {
+		return entities.ReorderRes{}, o.internalError("Reorder", err)
+	}
+	err = o.redis.Set(ctx, constants.CartKeyPrefix+userId, cart, constants.CartTTL).Err()
+	if err != nil {
+		return entities.ReorderRes{}, o.internalError("Reorder", err)
+	}
+
+	o.log.Info("Reorder finished", zap.Int("Issues", len(res.Issues)))
+	return res, nil
+}
+
+func (o orderController) GetCart(ctx context.Context, userId string) (entities.Cart, error) {
+	o.log.Info("GetCart started: ", zap.String("UserID", userId))
+
+	data, err := o.redis.Get(ctx, constants.CartKeyPrefix+userId).Bytes()
+	if err == redis.Nil {
+		return entities.Cart{Items: []entities.CartItem{}}, nil
+	} else if err != nil {
+		return entities.Cart{}, o.internalError("GetCart", err)
+	}
+
+	var cart entities.Cart
+	if err := json.Unmarshal(data, &cart); err != nil {
+		return entities.Cart{}, o.internalError("GetCart", err)
+	}
+
+	if cart.XozmakID != "" {
+		xozmak, err := o.storage.Order().GetXozmakByID(ctx, cart.XozmakID)
+		if err != nil && !errors.Is(err, e.ErrXozmakNotFound) {
+			return entities.Cart{}, o.internalError("GetCart", err)
+		}
+		if err == nil {
+			now := o.now()
+			schedule, err := o.xozmakSchedule(ctx, xozmak, now)
+			if err != nil {
+				return entities.Cart{}, o.internalError("GetCart", err)
+			}
+			status := schedule.StatusAt(now)
+			cart.XozmakStatus = &status
+		}
+	}
+
+	o.log.Info("GetCart finished")
+	return cart, nil
+}
+
+// GetOrderTrack returns the recorded courier route of the order
+func (o orderController) GetOrderTrack(ctx context.Context, actor entities.Actor, orderId string) ([]entities.OrderTrackPoint, error) {
+	o.log.Info("GetOrderTrack started: ", zap.String("OrderID", orderId))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("GetOrderTrack", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	data, err := o.storage.Order().GetOrderTrack(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("GetOrderTrack", err)
+	}
+
+	o.log.Info("GetOrderTrack finished")
+	return data, nil
+}
@@ -0,0 +1,79 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/pkg/receipt"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// orderReceipt loads the order with its shop and the tip paid after the delivery for printing
+func (o orderController) orderReceipt(ctx context.Context, order entities.Order) (receipt.Receipt, error) {
+	xozmak, err := o.storage.Order().GetXozmakByID(ctx, order.XozmakID)
+	if err != nil {
+		return receipt.Receipt{}, o.internalError("orderReceipt", err)
+	}
+	r := receipt.Receipt{ShopName: xozmak.Name, Order: order}
+	if order.Status == constants.OrderStatusDelivered && order.Tip == 0 {
+		tip, err := o.storage.Payment().GetTipPayment(ctx, order.ID)
+		if err != nil && !errors.Is(err, e.ErrPaymentNotFound) {
+			return receipt.Receipt{}, o.internalError("orderReceipt", err)
+		}
+		r.LateTip = tip.Amount
+	}
+	return r, nil
+}
+
+// RenderCustomerReceipt returns the PDF receipt of an order
+func (o orderController) RenderCustomerReceipt(ctx context.Context, actor entities.Actor, orderId string) ([]byte, error) {
+	o.log.Info("RenderCustomerReceipt started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, ActorID: %s", orderId, actor.ID)))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("RenderCustomerReceipt", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	r, err := o.orderReceipt(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.PDF()
+	if err != nil {
+		return nil, o.internalError("RenderCustomerReceipt", err)
+	}
+
+	o.log.Info("RenderCustomerReceipt finished")
+	return data, nil
+}
+
+// RenderPrinterSlip returns the ESC/POS packer slip the seller sends to a thermal printer
+func (o orderController) RenderPrinterSlip(ctx context.Context, actor entities.Actor, orderId string, paperWidth int) ([]byte, error) {
+	o.log.Info("RenderPrinterSlip started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, SellerID: %s, PaperWidth: %d", orderId, actor.ID, paperWidth)))
+
+	order, err := o.sellerOrder(ctx, actor, orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := o.orderReceipt(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.ESCPOS(paperWidth)
+	if err != nil {
+		return nil, o.internalError("RenderPrinterSlip", err)
+	}
+
+	o.log.Info("RenderPrinterSlip finished")
+	return data, nil
+}
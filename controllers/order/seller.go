@@ -0,0 +1,305 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/pkg/receipt"
+	"delivery/pkg/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sellerActiveStatuses are shown in the seller console when no status filter is given
+var sellerActiveStatuses = []string{
+	constants.OrderStatusNew,
+	constants.OrderStatusAccepted,
+	constants.OrderStatusPreparing,
+	constants.OrderStatusReady,
+}
+
+// sellerOrder loads an order of the seller's own xozmak
+func (o orderController) sellerOrder(ctx context.Context, actor entities.Actor, orderId string) (entities.Order, error) {
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.Order{}, o.internalError("sellerOrder", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return entities.Order{}, err
+	}
+	return order, nil
+}
+
+func (o orderController) GetSellerOrders(ctx context.Context, actor entities.Actor, statuses []string, limit, page int) (entities.OrderList, error) {
+	o.log.Info("GetSellerOrders started: ",
+		zap.String("Request: ", fmt.Sprintf("SellerID: %s, Statuses: %v", actor.ID, statuses)))
+
+	xozmakId, err := o.storage.Order().GetUserXozmakID(ctx, actor.ID)
+	if err != nil {
+		if errors.Is(err, e.ErrXozmakNotFound) {
+			return entities.OrderList{}, e.ErrNotSeller
+		}
+		return entities.OrderList{}, o.internalError("GetSellerOrders", err)
+	}
+	if len(statuses) == 0 {
+		statuses = sellerActiveStatuses
+	}
+
+	orders, count, err := o.storage.Order().GetXozmakOrders(ctx, xozmakId, statuses, limit, (page-1)*limit)
+	if err != nil {
+		return entities.OrderList{}, o.internalError("GetSellerOrders", err)
+	}
+
+	o.log.Info("GetSellerOrders finished")
+	return entities.OrderList{Orders: orders, Count: count}, nil
+}
+
+func (o orderController) AcceptOrder(ctx context.Context, req entities.AcceptOrderReq) (entities.Order, error) {
+	o.log.Info("AcceptOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, SellerID: %s, PrepMinutes: %d", req.OrderID, req.Actor.ID, req.PrepMinutes)))
+
+	if req.PrepMinutes <= 0 || req.PrepMinutes > constants.MaxPrepMinutes {
+		return entities.Order{}, e.ErrInvalidPrepTime
+	}
+
+	order, err := o.sellerOrder(ctx, req.Actor, req.OrderID)
+	if err != nil {
+		return entities.Order{}, err
+	}
+	if order.Status != constants.OrderStatusNew {
+		return entities.Order{}, e.ErrOrderStatusChanged
+	}
+
+	now := time.Now()
+	err = o.storage.Order().UpdateOrderStatus(ctx, entities.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   constants.OrderStatusAccepted,
+		Fields: map[string]interface{}{
+			"prep_minutes": req.PrepMinutes,
+			"accepted_at":  now,
+		},
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionAccepted,
+			FromStatus: order.Status,
+			ToStatus:   constants.OrderStatusAccepted,
+			ActorRole:  req.Actor.Role,
+			ActorID:    &req.Actor.ID,
+			Note:       fmt.Sprintf("prep_minutes=%d", req.PrepMinutes),
+		},
+	})
+	if err != nil {
+		return entities.Order{}, o.internalError("AcceptOrder", err)
+	}
+
+	order.Status = constants.OrderStatusAccepted
+	order.PrepMinutes = &req.PrepMinutes
+	order.AcceptedAt = &now
+	o.publishStatus(ctx, order.ID, order.Status)
+
+	err = o.notifier.Notify(ctx, entities.Notification{
+		UserID:   order.UserID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventOrderAccepted,
+		Template: constants.TemplateOrderAccepted,
+		Vars: map[string]string{
+			"order_number": strconv.FormatInt(order.Number, 10),
+			"prep_minutes": strconv.Itoa(req.PrepMinutes),
+			"eta":          now.Add(time.Duration(req.PrepMinutes) * time.Minute).In(o.cfg.TimeZone).Format("15:04"),
+		},
+	})
+	if err != nil {
+		o.log.Error("error in AcceptOrder: ", zap.Error(err))
+	}
+
+	// the courier is looked for while the order is being prepared
+	if err := o.dispatcher.Dispatch(ctx, order.ID); err != nil {
+		o.log.Error("error in AcceptOrder: ", zap.Error(err))
+	}
+
+	o.log.Info("AcceptOrder finished")
+	return order, nil
+}
+
+// moveSellerOrder moves an order of the seller's xozmak to the next preparation status
+func (o orderController) moveSellerOrder(ctx context.Context, actor entities.Actor, orderId string, from []string, to, action string, fields map[string]interface{}) error {
+	order, err := o.sellerOrder(ctx, actor, orderId)
+	if err != nil {
+		return err
+	}
+	if !utils.InEnums(order.Status, from) {
+		return e.ErrOrderStatusChanged
+	}
+
+	err = o.storage.Order().UpdateOrderStatus(ctx, entities.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		Fields:     fields,
+		History: entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     action,
+			FromStatus: order.Status,
+			ToStatus:   to,
+			ActorRole:  actor.Role,
+			ActorID:    &actor.ID,
+		},
+	})
+	if err != nil {
+		return o.internalError("moveSellerOrder", err)
+	}
+	o.publishStatus(ctx, order.ID, to)
+	return nil
+}
+
+func (o orderController) StartPreparing(ctx context.Context, actor entities.Actor, orderId string) error {
+	o.log.Info("StartPreparing started: ", zap.String("OrderID", orderId))
+
+	err := o.moveSellerOrder(ctx, actor, orderId,
+		[]string{constants.OrderStatusAccepted},
+		constants.OrderStatusPreparing, constants.OrderActionPreparing, nil)
+	if err != nil {
+		return err
+	}
+
+	o.log.Info("StartPreparing finished")
+	return nil
+}
+
+func (o orderController) MarkOrderReady(ctx context.Context, actor entities.Actor, orderId string) error {
+	o.log.Info("MarkOrderReady started: ", zap.String("OrderID", orderId))
+
+	err := o.moveSellerOrder(ctx, actor, orderId,
+		[]string{constants.OrderStatusAccepted, constants.OrderStatusPreparing},
+		constants.OrderStatusReady, constants.OrderActionReady,
+		map[string]interface{}{"ready_at": time.Now()})
+	if err != nil {
+		return err
+	}
+
+	o.log.Info("MarkOrderReady finished")
+	return nil
+}
+
+// RejectOrder lets the seller decline a new order, the customer gets the full amount back
+func (o orderController) RejectOrder(ctx context.Context, req entities.CancelOrderReq) (entities.CancelOrderRes, error) {
+	o.log.Info("RejectOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, SellerID: %s, ReasonCode: %s", req.OrderID, req.Actor.ID, req.ReasonCode)))
+
+	if !utils.InEnums(req.ReasonCode, cancelReasons[constants.SellerRole]) {
+		return entities.CancelOrderRes{}, e.ErrInvalidReasonCode
+	}
+
+	order, err := o.sellerOrder(ctx, req.Actor, req.OrderID)
+	if err != nil {
+		return entities.CancelOrderRes{}, err
+	}
+	if order.Status != constants.OrderStatusNew {
+		return entities.CancelOrderRes{}, e.ErrOrderStatusChanged
+	}
+
+	res, err := o.closeOrder(ctx, order, constants.OrderStatusRejected, entities.OrderHistory{
+		OrderID:    order.ID,
+		Action:     constants.OrderActionRejected,
+		FromStatus: order.Status,
+		ActorRole:  req.Actor.Role,
+		ActorID:    &req.Actor.ID,
+		ReasonCode: req.ReasonCode,
+		Note:       req.Note,
+	})
+	if err != nil {
+		return entities.CancelOrderRes{}, err
+	}
+	o.notifyRejected(ctx, order)
+
+	o.log.Info("RejectOrder finished")
+	return res, nil
+}
+
+// RunAcceptTimeoutWorker rejects orders the seller did not accept in time and cancels
+// orders the customer did not pay in time until ctx is done
+func (o orderController) RunAcceptTimeoutWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.AcceptTimeoutCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.rejectExpiredOrders(ctx)
+			o.cancelUnpaidOrders(ctx)
+		}
+	}
+}
+
+func (o orderController) rejectExpiredOrders(ctx context.Context) {
+	orders, err := o.storage.Order().GetExpiredNewOrders(ctx, time.Now().Add(-o.cfg.OrderAcceptTimeout), 100)
+	if err != nil {
+		o.log.Error("error in rejectExpiredOrders: ", zap.Error(err))
+		return
+	}
+
+	for _, order := range orders {
+		_, err := o.closeOrder(ctx, order, constants.OrderStatusRejected, entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionRejected,
+			FromStatus: order.Status,
+			ActorRole:  constants.SystemRole,
+			ReasonCode: constants.CancelReasonAcceptTimeout,
+		})
+		if err != nil {
+			// the seller may have accepted the order in the meantime
+			o.log.Warn("could not auto reject order", zap.String("OrderID", order.ID), zap.Error(err))
+			continue
+		}
+		o.log.Info("order auto rejected", zap.String("OrderID", order.ID))
+		o.notifyRejected(ctx, order)
+	}
+}
+
+func (o orderController) cancelUnpaidOrders(ctx context.Context) {
+	orders, err := o.storage.Order().GetUnpaidOrders(ctx, time.Now().Add(-o.cfg.PaymentTimeout), 100)
+	if err != nil {
+		o.log.Error("error in cancelUnpaidOrders: ", zap.Error(err))
+		return
+	}
+
+	for _, order := range orders {
+		_, err := o.closeOrder(ctx, order, constants.OrderStatusCancelled, entities.OrderHistory{
+			OrderID:    order.ID,
+			Action:     constants.OrderActionCancelled,
+			FromStatus: order.Status,
+			ActorRole:  constants.SystemRole,
+			ReasonCode: constants.CancelReasonPaymentTimeout,
+		})
+		if err != nil {
+			// the customer may have paid the order in the meantime
+			o.log.Warn("could not cancel unpaid order", zap.String("OrderID", order.ID), zap.Error(err))
+			continue
+		}
+		o.log.Info("unpaid order cancelled", zap.String("OrderID", order.ID))
+	}
+}
+
+func (o orderController) notifyRejected(ctx context.Context, order entities.Order) {
+	err := o.notifier.Notify(ctx, entities.Notification{
+		UserID:   order.UserID,
+		OrderID:  &order.ID,
+		Event:    constants.PushEventOrderRejected,
+		Template: constants.TemplateOrderRejected,
+		Vars: map[string]string{
+			"order_number": strconv.FormatInt(order.Number, 10),
+			"amount":       receipt.FormatNumber(order.Total),
+		},
+	})
+	if err != nil {
+		o.log.Error("error in notifyRejected: ", zap.Error(err))
+	}
+}
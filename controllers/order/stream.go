@@ -0,0 +1,239 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// finalStatuses end the order stream
+var finalStatuses = []string{
+	constants.OrderStatusDelivered,
+	constants.OrderStatusCancelled,
+	constants.OrderStatusRejected,
+}
+
+func (o orderController) publishStatus(ctx context.Context, orderId, status string) {
+	o.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventStatus,
+		OrderID: orderId,
+		Status:  status,
+	})
+}
+
+// StreamOrder follows the order until it is finished or ctx is done. The current state
+// comes first, then status changes, courier positions and the ETA recalculated on each of them.
+func (o orderController) StreamOrder(ctx context.Context, actor entities.Actor, orderId string) (<-chan entities.OrderEvent, error) {
+	o.log.Info("StreamOrder started: ", zap.String("OrderID", orderId))
+
+	order, err := o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("StreamOrder", err)
+	}
+	if err := o.authorizeActor(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	// the subscription is active when it is returned, so nothing published after the snapshot is lost
+	sub, err := o.tracker.Subscribe(ctx, orderId)
+	if err != nil {
+		return nil, o.internalError("StreamOrder", err)
+	}
+
+	events := make(chan entities.OrderEvent, 16)
+	go o.streamOrder(ctx, sub, orderId, events)
+
+	o.log.Info("StreamOrder finished")
+	return events, nil
+}
+
+// orderStream is the state of one customer following the order
+type orderStream struct {
+	o      orderController
+	sub    *trackingcontroller.Subscription
+	events chan<- entities.OrderEvent
+	order  entities.Order
+	eta    etaInput
+	// batchChannels are the channels of other orders in the same batch,
+	// their status changes move the ETA of this one
+	batchChannels map[string]bool
+}
+
+func (o orderController) streamOrder(ctx context.Context, sub *trackingcontroller.Subscription, orderId string, events chan<- entities.OrderEvent) {
+	defer close(events)
+	defer sub.Close()
+
+	s := &orderStream{o: o, sub: sub, events: events}
+	if !s.reload(ctx, orderId, nil) {
+		return
+	}
+	if s.eta.Position != nil && !s.send(ctx, s.positionEvent(*s.eta.Position)) {
+		return
+	}
+	if !s.sendETA(ctx) {
+		return
+	}
+
+	orderChannel := trackingcontroller.OrderChannel(orderId)
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if msg.Channel == orderChannel {
+				if !s.handleOrderEvent(ctx, msg.Payload) {
+					return
+				}
+				continue
+			}
+			if s.batchChannels[msg.Channel] {
+				if !s.reloadETA(ctx) {
+					return
+				}
+				continue
+			}
+
+			var point entities.LocationPoint
+			if err := json.Unmarshal([]byte(msg.Payload), &point); err != nil {
+				o.log.Error("error in streamOrder: ", zap.Error(err))
+				continue
+			}
+			s.eta.Position = &point
+			if !s.send(ctx, s.positionEvent(point)) || !s.sendETA(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// handleOrderEvent passes the event on and refreshes the order it changed,
+// false means the stream is over
+func (s *orderStream) handleOrderEvent(ctx context.Context, payload string) bool {
+	var event entities.OrderEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		s.o.log.Error("error in handleOrderEvent: ", zap.Error(err))
+		return true
+	}
+	return s.reload(ctx, event.OrderID, &event) && s.sendETA(ctx)
+}
+
+// reload reads the order again, follows its current courier and sends the event,
+// the current status when event is nil
+func (s *orderStream) reload(ctx context.Context, orderId string, event *entities.OrderEvent) bool {
+	previousCourier := s.order.CourierID
+
+	order, err := s.o.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		s.o.log.Error("error in reload: ", zap.Error(err))
+		return false
+	}
+	s.order = order
+
+	if !sameCourier(previousCourier, order.CourierID) {
+		if previousCourier != nil {
+			s.sub.Unfollow(trackingcontroller.CourierChannel(*previousCourier))
+		}
+		if order.CourierID != nil {
+			s.sub.Follow(trackingcontroller.CourierChannel(*order.CourierID))
+		}
+	}
+
+	if utils.InEnums(order.Status, etaStatuses) {
+		s.eta, err = s.o.etaInput(ctx, order)
+		if err != nil {
+			s.o.log.Error("error in reload: ", zap.Error(err))
+		}
+		s.followBatch(ctx)
+	}
+
+	if event == nil {
+		event = &entities.OrderEvent{
+			Type:    constants.OrderEventStatus,
+			OrderID: order.ID,
+			Status:  order.Status,
+			At:      time.Now(),
+		}
+	}
+	event.CourierID = order.CourierID
+	if !s.send(ctx, *event) {
+		return false
+	}
+	return !utils.InEnums(order.Status, finalStatuses)
+}
+
+// followBatch subscribes to the other orders of the batch the order joined
+func (s *orderStream) followBatch(ctx context.Context) {
+	for _, order := range s.eta.Batch {
+		channel := trackingcontroller.OrderChannel(order.ID)
+		if order.ID == s.order.ID || s.batchChannels[channel] {
+			continue
+		}
+		s.sub.Follow(channel)
+		if s.batchChannels == nil {
+			s.batchChannels = make(map[string]bool)
+		}
+		s.batchChannels[channel] = true
+	}
+}
+
+// reloadETA recalculates the ETA after another order of the batch changed
+func (s *orderStream) reloadETA(ctx context.Context) bool {
+	eta, err := s.o.etaInput(ctx, s.order)
+	if err != nil {
+		s.o.log.Error("error in reloadETA: ", zap.Error(err))
+		return true
+	}
+	s.eta = eta
+	return s.sendETA(ctx)
+}
+
+func (s *orderStream) positionEvent(point entities.LocationPoint) entities.OrderEvent {
+	return entities.OrderEvent{
+		Type:      constants.OrderEventPosition,
+		OrderID:   s.order.ID,
+		CourierID: s.order.CourierID,
+		Position:  &point,
+		At:        point.RecordedAt,
+	}
+}
+
+func (s *orderStream) sendETA(ctx context.Context) bool {
+	if !utils.InEnums(s.order.Status, etaStatuses) || s.eta.Order.ID == "" {
+		return true
+	}
+	s.eta.Order = s.order
+	s.eta.Now = time.Now()
+	eta := s.o.estimateETA(s.eta)
+	return s.send(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventETA,
+		OrderID: s.order.ID,
+		ETA:     &eta,
+		At:      eta.CalculatedAt,
+	})
+}
+
+func (s *orderStream) send(ctx context.Context, event entities.OrderEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func sameCourier(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,56 @@
+package order
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TipOrder tips the courier of a delivered order within TipWindow. The tip is charged with
+// the payment method of the order, cash orders can be tipped only at checkout.
+func (o orderController) TipOrder(ctx context.Context, req entities.TipOrderReq) (entities.Payment, error) {
+	o.log.Info("TipOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, UserID: %s, Amount: %d, Percent: %d", req.OrderID, req.Actor.ID, req.Amount, req.Percent)))
+
+	order, err := o.storage.Order().GetOrder(ctx, req.OrderID)
+	if err != nil {
+		return entities.Payment{}, o.internalError("TipOrder", err)
+	}
+	if order.UserID != req.Actor.ID {
+		return entities.Payment{}, e.ErrOrderForbidden
+	}
+	if order.Status != constants.OrderStatusDelivered || order.DeliveredAt == nil || order.CourierID == nil {
+		return entities.Payment{}, e.ErrOrderNotDelivered
+	}
+	if time.Since(*order.DeliveredAt) > o.cfg.TipWindow {
+		return entities.Payment{}, e.ErrTipWindowClosed
+	}
+	if order.Tip > 0 {
+		return entities.Payment{}, e.ErrOrderAlreadyTipped
+	}
+	amount := req.TipAmount(order.ItemsTotal)
+	if amount <= 0 {
+		return entities.Payment{}, e.ErrInvalidTipAmount
+	}
+
+	var payment entities.Payment
+	switch order.PaymentMethod {
+	case constants.PaymentMethodWallet:
+		payment, err = o.wallet.PayTip(ctx, order, amount)
+	case constants.PaymentMethodCard:
+		payment, err = o.payments.CreateTipPayment(ctx, order, amount)
+	default:
+		err = e.ErrTipNotSupported
+	}
+	if err != nil {
+		return entities.Payment{}, err
+	}
+
+	o.log.Info("TipOrder finished", zap.String("PaymentID", payment.ID))
+	return payment, nil
+}
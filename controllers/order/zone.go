@@ -0,0 +1,128 @@
+package order
+
+import (
+	"context"
+	"delivery/entities"
+	e "delivery/errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+func (o orderController) CreateZone(ctx context.Context, req entities.DeliveryZone) error {
+	o.log.Info("CreateZone started: ",
+		zap.String("Request: ", fmt.Sprintf("XozmakID: %s, Name: %s, DeliveryFee: %d, MinOrder: %d", req.XozmakID, req.Name, req.DeliveryFee, req.MinOrder)))
+
+	err := o.storage.Order().CreateZone(ctx, req)
+	if err != nil {
+		return o.internalError("CreateZone", err)
+	}
+
+	o.log.Info("CreateZone finished")
+	return nil
+}
+
+func (o orderController) GetXozmakZones(ctx context.Context, xozmakId string) ([]entities.DeliveryZone, error) {
+	o.log.Info("GetXozmakZones started: ", zap.String("XozmakID", xozmakId))
+
+	data, err := o.storage.Order().GetZones(ctx, xozmakId)
+	if err != nil {
+		return []entities.DeliveryZone{}, o.internalError("GetXozmakZones", err)
+	}
+
+	o.log.Info("GetXozmakZones finished")
+	return data, nil
+}
+
+func (o orderController) UpdateZone(ctx context.Context, req entities.DeliveryZone) error {
+	o.log.Info("UpdateZone started: ", zap.String("ZoneID", req.ID))
+
+	err := o.storage.Order().UpdateZone(ctx, req)
+	if err != nil {
+		return o.internalError("UpdateZone", err)
+	}
+
+	o.log.Info("UpdateZone finished")
+	return nil
+}
+
+func (o orderController) DeleteZone(ctx context.Context, id string) error {
+	o.log.Info("DeleteZone started: ", zap.String("ZoneID", id))
+
+	err := o.storage.Order().DeleteZone(ctx, id)
+	if err != nil {
+		return o.internalError("DeleteZone", err)
+	}
+
+	o.log.Info("DeleteZone finished")
+	return nil
+}
+
+// deliveryZone returns the zone of the xozmak the address belongs to
+func (o orderController) deliveryZone(ctx context.Context, xozmakId string, location entities.UserLocation) (entities.DeliveryZone, error) {
+	zones, err := o.storage.Order().GetZones(ctx, xozmakId)
+	if err != nil {
+		return entities.DeliveryZone{}, err
+	}
+	zone, ok := entities.MatchZone(zones, location.Latitude, location.Longitude)
+	if !ok {
+		return entities.DeliveryZone{}, e.ErrOutOfDeliveryArea
+	}
+	return zone, nil
+}
+
+// GetDeliveringXozmaks lists xozmaks that deliver to one of the user's saved addresses
+func (o orderController) GetDeliveringXozmaks(ctx context.Context, userId, locationId string) ([]entities.DeliveringXozmak, error) {
+	o.log.Info("GetDeliveringXozmaks started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, LocationID: %s", userId, locationId)))
+
+	location, err := o.storage.Order().GetUserLocationByID(ctx, userId, locationId)
+	if err != nil {
+		return nil, o.internalError("GetDeliveringXozmaks", err)
+	}
+
+	zones, err := o.storage.Order().GetZonesAround(ctx, location.Latitude, location.Longitude)
+	if err != nil {
+		return nil, o.internalError("GetDeliveringXozmaks", err)
+	}
+	byXozmak := make(map[string][]entities.DeliveryZone)
+	for _, zone := range zones {
+		byXozmak[zone.XozmakID] = append(byXozmak[zone.XozmakID], zone)
+	}
+
+	xozmaks, err := o.storage.Admin().GetXozmak(ctx)
+	if err != nil {
+		return nil, o.internalError("GetDeliveringXozmaks", err)
+	}
+
+	res := make([]entities.DeliveringXozmak, 0)
+	for _, xozmak := range xozmaks {
+		zone, ok := entities.MatchZone(byXozmak[xozmak.ID], location.Latitude, location.Longitude)
+		if !ok {
+			continue
+		}
+		res = append(res, entities.DeliveringXozmak{
+			Xozmak:      xozmak,
+			ZoneID:      zone.ID,
+			DeliveryFee: zone.DeliveryFee,
+			MinOrder:    zone.MinOrder,
+		})
+	}
+
+	delivering := make([]entities.Xozmak, 0, len(res))
+	for _, xozmak := range res {
+		delivering = append(delivering, xozmak.Xozmak)
+	}
+	now := o.now()
+	schedules, err := o.xozmakSchedules(ctx, delivering, now)
+	if err != nil {
+		return nil, o.internalError("GetDeliveringXozmaks", err)
+	}
+	for i := range res {
+		status := schedules[res[i].ID].StatusAt(now)
+		res[i].Status = &status
+	}
+
+	o.log.Info("GetDeliveringXozmaks finished")
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package payme
+
+import "delivery/entities"
+
+// Error codes of the Payme merchant API. The -31050..-31099 range is left to the
+// merchant for errors about the account, i.e. the order being paid.
+const (
+	codeTransportError     = -32300
+	codeParseError         = -32700
+	codeInvalidRequest     = -32600
+	codeMethodNotFound     = -32601
+	codeInsufficientAccess = -32504
+	codeSystemError        = -32400
+
+	codeInvalidAmount       = -31001
+	codeTransactionNotFound = -31003
+	codeCannotCancel        = -31007
+	codeCannotPerform       = -31008
+
+	codeOrderNotFound   = -31050
+	codeOrderNotPayable = -31051
+	codeOrderBeingPaid  = -31052
+	accountOrderIDField = "order_id"
+)
+
+func rpcError(code int, ru, uz, en string) *entities.PaymeError {
+	return &entities.PaymeError{
+		Code:    code,
+		Message: entities.PaymeMessage{Ru: ru, Uz: uz, En: en},
+	}
+}
+
+// accountError is an error about the order, Data tells Payme which account field is wrong
+func accountError(code int, ru, uz, en string) *entities.PaymeError {
+	err := rpcError(code, ru, uz, en)
+	err.Data = accountOrderIDField
+	return err
+}
+
+func errTransport() *entities.PaymeError {
+	return rpcError(codeTransportError,
+		"Метод запроса должен быть POST",
+		"So'rov usuli POST bo'lishi kerak",
+		"Request method must be POST")
+}
+
+func errParse() *entities.PaymeError {
+	return rpcError(codeParseError,
+		"Ошибка разбора JSON",
+		"JSON ni o'qishda xatolik",
+		"JSON parse error")
+}
+
+func errInvalidRequest() *entities.PaymeError {
+	return rpcError(codeInvalidRequest,
+		"Отсутствуют обязательные поля в RPC-запросе",
+		"RPC so'rovida majburiy maydonlar yo'q",
+		"Required fields are missing in the RPC request")
+}
+
+func errMethodNotFound(method string) *entities.PaymeError {
+	err := rpcError(codeMethodNotFound,
+		"Запрашиваемый метод не найден",
+		"So'ralgan usul topilmadi",
+		"Method not found")
+	err.Data = method
+	return err
+}
+
+func errInsufficientAccess() *entities.PaymeError {
+	return rpcError(codeInsufficientAccess,
+		"Недостаточно привилегий для выполнения метода",
+		"Usulni bajarish uchun huquqlar yetarli emas",
+		"Insufficient privileges to perform the method")
+}
+
+func errSystem() *entities.PaymeError {
+	return rpcError(codeSystemError,
+		"Системная ошибка",
+		"Tizim xatoligi",
+		"System error")
+}
+
+func errInvalidAmount() *entities.PaymeError {
+	return rpcError(codeInvalidAmount,
+		"Неверная сумма",
+		"Noto'g'ri summa",
+		"Invalid amount")
+}
+
+func errTransactionNotFound() *entities.PaymeError {
+	return rpcError(codeTransactionNotFound,
+		"Транзакция не найдена",
+		"Tranzaksiya topilmadi",
+		"Transaction not found")
+}
+
+func errCannotCancel() *entities.PaymeError {
+	return rpcError(codeCannotCancel,
+		"Заказ выполнен, невозможно отменить транзакцию",
+		"Buyurtma bajarilgan, tranzaksiyani bekor qilib bo'lmaydi",
+		"The order is fulfilled, the transaction can not be cancelled")
+}
+
+func errCannotPerform() *entities.PaymeError {
+	return rpcError(codeCannotPerform,
+		"Невозможно выполнить данную операцию",
+		"Ushbu amalni bajarib bo'lmaydi",
+		"Unable to perform the operation")
+}
+
+func errOrderNotFound() *entities.PaymeError {
+	return accountError(codeOrderNotFound,
+		"Заказ не найден",
+		"Buyurtma topilmadi",
+		"Order not found")
+}
+
+func errOrderNotPayable() *entities.PaymeError {
+	return accountError(codeOrderNotPayable,
+		"Заказ не ожидает оплаты",
+		"Buyurtma to'lovni kutmayapti",
+		"The order is not waiting for a payment")
+}
+
+func errOrderBeingPaid() *entities.PaymeError {
+	return accountError(codeOrderBeingPaid,
+		"Заказ уже оплачивается другой транзакцией",
+		"Buyurtma boshqa tranzaksiya bilan to'lanmoqda",
+		"The order is being paid by another transaction")
+}
@@ -0,0 +1,437 @@
+package payme
+
+import (
+	"context"
+	"crypto/subtle"
+	"delivery/configs"
+	"delivery/constants"
+	ordercontroller "delivery/controllers/order"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	"delivery/pkg/utils"
+	"delivery/storage"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// paymeLogin is the login Payme sends in the Basic authorization header
+const paymeLogin = "Paycom"
+
+type PaymeController interface {
+	// Handle serves a call of the Payme merchant API, the response is always sent with HTTP 200
+	Handle(ctx context.Context, httpMethod, authorization string, body []byte) entities.PaymeResponse
+}
+
+type paymeController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	cfg     *configs.Configuration
+	orders  ordercontroller.OrderController
+	tracker trackingcontroller.TrackingController
+	// now is replaced in tests to check the transaction timeout
+	now func() time.Time
+}
+
+func NewPaymeController(log logger.LoggerI, storage storage.Storage, orders ordercontroller.OrderController, tracker trackingcontroller.TrackingController) PaymeController {
+	return paymeController{
+		log:     log,
+		storage: storage,
+		cfg:     configs.Config(),
+		orders:  orders,
+		tracker: tracker,
+		now:     time.Now,
+	}
+}
+
+// systemError logs err and hides it from Payme
+func (p paymeController) systemError(method string, err error) *entities.PaymeError {
+	p.log.Error("error in Payme "+method+": ", zap.Error(err))
+	return errSystem()
+}
+
+// authorized checks the "Paycom:KEY" credentials of the cashbox
+func (p paymeController) authorized(authorization string) bool {
+	const prefix = "Basic "
+	if p.cfg.PaymeKey == "" || !strings.HasPrefix(authorization, prefix) {
+		return false
+	}
+	credentials, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, prefix))
+	if err != nil {
+		return false
+	}
+	login, key, ok := strings.Cut(string(credentials), ":")
+	return ok && login == paymeLogin && subtle.ConstantTimeCompare([]byte(key), []byte(p.cfg.PaymeKey)) == 1
+}
+
+func (p paymeController) Handle(ctx context.Context, httpMethod, authorization string, body []byte) entities.PaymeResponse {
+	if httpMethod != http.MethodPost {
+		return entities.PaymeResponse{Error: errTransport()}
+	}
+	var req entities.PaymeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return entities.PaymeResponse{Error: errParse()}
+	}
+	// the request is parsed first so that the error is sent back with its id
+	if !p.authorized(authorization) {
+		return entities.PaymeResponse{ID: req.ID, Error: errInsufficientAccess()}
+	}
+	if req.Method == "" {
+		return entities.PaymeResponse{ID: req.ID, Error: errInvalidRequest()}
+	}
+
+	p.log.Info("Payme "+req.Method+" started: ",
+		zap.String("Request: ", fmt.Sprintf("ID: %s, OrderID: %s, Amount: %d",
+			req.Params.ID, req.Params.Account.OrderID, req.Params.Amount)))
+
+	var result interface{}
+	var rpcErr *entities.PaymeError
+	switch req.Method {
+	case "CheckPerformTransaction":
+		result, rpcErr = p.checkPerformTransaction(ctx, req.Params)
+	case "CreateTransaction":
+		result, rpcErr = p.createTransaction(ctx, req.Params)
+	case "PerformTransaction":
+		result, rpcErr = p.performTransaction(ctx, req.Params)
+	case "CancelTransaction":
+		result, rpcErr = p.cancelTransaction(ctx, req.Params)
+	case "CheckTransaction":
+		result, rpcErr = p.checkTransaction(ctx, req.Params)
+	case "GetStatement":
+		result, rpcErr = p.getStatement(ctx, req.Params)
+	default:
+		rpcErr = errMethodNotFound(req.Method)
+	}
+	if rpcErr != nil {
+		p.log.Warn("Payme "+req.Method+" refused", zap.Int("Code", rpcErr.Code), zap.String("ID", req.Params.ID))
+		return entities.PaymeResponse{ID: req.ID, Error: rpcErr}
+	}
+
+	p.log.Info("Payme " + req.Method + " finished")
+	return entities.PaymeResponse{ID: req.ID, Result: result}
+}
+
+// payableOrder returns the order of the account if it can be paid with the amount in tiyins
+func (p paymeController) payableOrder(ctx context.Context, params entities.PaymeParams) (entities.Order, *entities.PaymeError) {
+	if !utils.IsValidUUID(params.Account.OrderID) {
+		return entities.Order{}, errOrderNotFound()
+	}
+	order, err := p.storage.Order().GetOrder(ctx, params.Account.OrderID)
+	if errors.Is(err, e.ErrOrderNotFound) {
+		return entities.Order{}, errOrderNotFound()
+	}
+	if err != nil {
+		return entities.Order{}, p.systemError("payableOrder", err)
+	}
+	if order.Status != constants.OrderStatusAwaitingPayment || order.PaidAt != nil {
+		return entities.Order{}, errOrderNotPayable()
+	}
+	if params.Amount != order.Total*100 {
+		return entities.Order{}, errInvalidAmount()
+	}
+	return order, nil
+}
+
+func (p paymeController) transaction(ctx context.Context, id string) (entities.PaymeTransaction, *entities.PaymeError) {
+	if id == "" {
+		return entities.PaymeTransaction{}, errInvalidRequest()
+	}
+	transaction, err := p.storage.Payment().GetPaymeTransaction(ctx, id)
+	if errors.Is(err, e.ErrPaymeTransactionNotFound) {
+		return entities.PaymeTransaction{}, errTransactionNotFound()
+	}
+	if err != nil {
+		return entities.PaymeTransaction{}, p.systemError("transaction", err)
+	}
+	return transaction, nil
+}
+
+// expired tells whether Payme has not performed the created transaction in time
+func (p paymeController) expired(transaction entities.PaymeTransaction) bool {
+	return p.now().UnixMilli()-transaction.CreateTime > constants.PaymeTransactionTimeout.Milliseconds()
+}
+
+// payment returns the pending Payme payment the customer opened the checkout with,
+// a new one is made when the customer started paying in the Payme app
+func (p paymeController) payment(ctx context.Context, order entities.Order) (entities.Payment, error) {
+	payments, err := p.storage.Payment().GetOrderPayments(ctx, order.ID)
+	if err != nil {
+		return entities.Payment{}, err
+	}
+	for _, pay := range payments {
+		if pay.Provider == constants.PaymentProviderPayme && pay.Status == constants.PaymentStatusPending && pay.ProviderPaymentID == nil {
+			return pay, nil
+		}
+	}
+
+	now := time.Now()
+	return entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Provider:  constants.PaymentProviderPayme,
+		Amount:    order.Total,
+		Status:    constants.PaymentStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+func (p paymeController) checkPerformTransaction(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	if _, rpcErr := p.payableOrder(ctx, params); rpcErr != nil {
+		return nil, rpcErr
+	}
+	return entities.PaymeCheckPerformRes{Allow: true}, nil
+}
+
+func (p paymeController) createTransaction(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	transaction, rpcErr := p.transaction(ctx, params.ID)
+	switch {
+	case rpcErr == nil:
+		// Payme repeats the call until it gets an answer
+		if transaction.State != constants.PaymeStateCreated {
+			return nil, errCannotPerform()
+		}
+		if p.expired(transaction) {
+			if _, rpcErr := p.cancel(ctx, transaction, constants.PaymeStateCancelled, constants.PaymeReasonTimeout); rpcErr != nil {
+				return nil, rpcErr
+			}
+			return nil, errCannotPerform()
+		}
+		return createRes(transaction), nil
+	case rpcErr.Code != codeTransactionNotFound:
+		return nil, rpcErr
+	}
+
+	order, rpcErr := p.payableOrder(ctx, params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	_, err := p.storage.Payment().GetActivePaymeTransaction(ctx, order.ID)
+	if err == nil {
+		return nil, errOrderBeingPaid()
+	}
+	if !errors.Is(err, e.ErrPaymeTransactionNotFound) {
+		return nil, p.systemError("CreateTransaction", err)
+	}
+
+	pay, err := p.payment(ctx, order)
+	if err != nil {
+		return nil, p.systemError("CreateTransaction", err)
+	}
+	transaction = entities.PaymeTransaction{
+		ID:         params.ID,
+		PaymentID:  pay.ID,
+		OrderID:    order.ID,
+		Amount:     params.Amount,
+		State:      constants.PaymeStateCreated,
+		PaymeTime:  params.Time,
+		CreateTime: p.now().UnixMilli(),
+	}
+	err = p.storage.Payment().CreatePaymeTransaction(ctx, transaction, pay)
+	if errors.Is(err, e.ErrPaymeTransactionChanged) {
+		return nil, errOrderBeingPaid()
+	}
+	if err != nil {
+		return nil, p.systemError("CreateTransaction", err)
+	}
+	return createRes(transaction), nil
+}
+
+func (p paymeController) performTransaction(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	transaction, rpcErr := p.transaction(ctx, params.ID)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	switch transaction.State {
+	case constants.PaymeStatePerformed:
+		return performRes(transaction), nil
+	case constants.PaymeStateCreated:
+	default:
+		return nil, errCannotPerform()
+	}
+	if p.expired(transaction) {
+		if _, rpcErr := p.cancel(ctx, transaction, constants.PaymeStateCancelled, constants.PaymeReasonTimeout); rpcErr != nil {
+			return nil, rpcErr
+		}
+		return nil, errCannotPerform()
+	}
+
+	transaction.State = constants.PaymeStatePerformed
+	transaction.PerformTime = p.now().UnixMilli()
+	err := p.storage.Payment().PerformPaymeTransaction(ctx, transaction, entities.OrderHistory{
+		OrderID:    transaction.OrderID,
+		Action:     constants.OrderActionPaid,
+		FromStatus: constants.OrderStatusAwaitingPayment,
+		ToStatus:   constants.OrderStatusNew,
+		ActorRole:  constants.SystemRole,
+		Note:       constants.PaymentProviderPayme,
+	})
+	switch {
+	case errors.Is(err, e.ErrPaymeTransactionChanged):
+		// performed or cancelled by a concurrent call
+		transaction, rpcErr = p.transaction(ctx, params.ID)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if transaction.State != constants.PaymeStatePerformed {
+			return nil, errCannotPerform()
+		}
+		return performRes(transaction), nil
+	case errors.Is(err, e.ErrOrderNotAwaitingPayment), errors.Is(err, e.ErrPaymentStatusChanged):
+		// the order was cancelled while the customer was paying, Payme cancels the transaction
+		return nil, errCannotPerform()
+	case err != nil:
+		return nil, p.systemError("PerformTransaction", err)
+	}
+
+	p.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventStatus,
+		OrderID: transaction.OrderID,
+		Status:  constants.OrderStatusNew,
+	})
+	return performRes(transaction), nil
+}
+
+func (p paymeController) cancelTransaction(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	if params.Reason == nil {
+		return nil, errInvalidRequest()
+	}
+	transaction, rpcErr := p.transaction(ctx, params.ID)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	switch transaction.State {
+	case constants.PaymeStateCancelled, constants.PaymeStateCancelledAfterPerform:
+		return cancelRes(transaction), nil
+	case constants.PaymeStateCreated:
+		return p.cancel(ctx, transaction, constants.PaymeStateCancelled, *params.Reason)
+	}
+
+	// the money of a performed transaction goes back to the customer, so the order
+	// is cancelled unless the seller has started working on it
+	order, err := p.storage.Order().GetOrder(ctx, transaction.OrderID)
+	if err != nil {
+		return nil, p.systemError("CancelTransaction", err)
+	}
+	switch order.Status {
+	case constants.OrderStatusCancelled, constants.OrderStatusRejected:
+	case constants.OrderStatusNew:
+		_, err := p.orders.CancelOrder(ctx, entities.CancelOrderReq{
+			OrderID:    order.ID,
+			Actor:      entities.Actor{Role: constants.SystemRole},
+			ReasonCode: constants.CancelReasonPaymentCancelled,
+			Note:       fmt.Sprintf("payme transaction %s, reason %d", transaction.ID, *params.Reason),
+		})
+		if errors.Is(err, e.ErrOrderCancelNotAllowed) {
+			return nil, errCannotCancel()
+		}
+		if err != nil {
+			return nil, p.systemError("CancelTransaction", err)
+		}
+	default:
+		return nil, errCannotCancel()
+	}
+	return p.cancel(ctx, transaction, constants.PaymeStateCancelledAfterPerform, *params.Reason)
+}
+
+// cancel moves the transaction to the cancelled state with the reason
+func (p paymeController) cancel(ctx context.Context, transaction entities.PaymeTransaction, state, reason int) (interface{}, *entities.PaymeError) {
+	fromState := transaction.State
+	transaction.State = state
+	transaction.Reason = &reason
+	transaction.CancelTime = p.now().UnixMilli()
+
+	err := p.storage.Payment().CancelPaymeTransaction(ctx, transaction, fromState)
+	if errors.Is(err, e.ErrPaymeTransactionChanged) {
+		// changed by a concurrent call
+		var rpcErr *entities.PaymeError
+		transaction, rpcErr = p.transaction(ctx, transaction.ID)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if transaction.State != state {
+			return nil, errCannotCancel()
+		}
+		return cancelRes(transaction), nil
+	}
+	if err != nil {
+		return nil, p.systemError("CancelTransaction", err)
+	}
+	return cancelRes(transaction), nil
+}
+
+func (p paymeController) checkTransaction(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	transaction, rpcErr := p.transaction(ctx, params.ID)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	return entities.PaymeCheckRes{
+		CreateTime:  transaction.CreateTime,
+		PerformTime: transaction.PerformTime,
+		CancelTime:  transaction.CancelTime,
+		Transaction: transaction.PaymentID,
+		State:       transaction.State,
+		Reason:      transaction.Reason,
+	}, nil
+}
+
+func (p paymeController) getStatement(ctx context.Context, params entities.PaymeParams) (interface{}, *entities.PaymeError) {
+	if params.From <= 0 || params.To < params.From {
+		return nil, errInvalidRequest()
+	}
+	transactions, err := p.storage.Payment().GetPaymeTransactions(ctx, params.From, params.To)
+	if err != nil {
+		return nil, p.systemError("GetStatement", err)
+	}
+
+	res := entities.PaymeStatementRes{Transactions: make([]entities.PaymeStatementItem, 0, len(transactions))}
+	for _, transaction := range transactions {
+		res.Transactions = append(res.Transactions, entities.PaymeStatementItem{
+			ID:          transaction.ID,
+			Time:        transaction.PaymeTime,
+			Amount:      transaction.Amount,
+			Account:     entities.PaymeAccount{OrderID: transaction.OrderID},
+			CreateTime:  transaction.CreateTime,
+			PerformTime: transaction.PerformTime,
+			CancelTime:  transaction.CancelTime,
+			Transaction: transaction.PaymentID,
+			State:       transaction.State,
+			Reason:      transaction.Reason,
+		})
+	}
+	return res, nil
+}
+
+func createRes(transaction entities.PaymeTransaction) entities.PaymeCreateRes {
+	return entities.PaymeCreateRes{
+		CreateTime:  transaction.CreateTime,
+		Transaction: transaction.PaymentID,
+		State:       transaction.State,
+	}
+}
+
+func performRes(transaction entities.PaymeTransaction) entities.PaymePerformRes {
+	return entities.PaymePerformRes{
+		Transaction: transaction.PaymentID,
+		PerformTime: transaction.PerformTime,
+		State:       transaction.State,
+	}
+}
+
+func cancelRes(transaction entities.PaymeTransaction) entities.PaymeCancelRes {
+	return entities.PaymeCancelRes{
+		Transaction: transaction.PaymentID,
+		CancelTime:  transaction.CancelTime,
+		State:       transaction.State,
+	}
+}
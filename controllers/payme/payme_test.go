@@ -0,0 +1,577 @@
+package payme
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	ordercontroller "delivery/controllers/order"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	"delivery/storage"
+	"delivery/storage/repo"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	testKey     = "test-key"
+	testOrderID = "0b5d3c6e-8f5a-4f7e-9a61-3c2f5b1a9d10"
+	testUserID  = "5f0a6b2e-1c3d-4e5f-8a9b-0c1d2e3f4a5b"
+	// 125 000 so'm in tiyins
+	testAmount = 12500000
+)
+
+// fakeOrders keeps orders in memory, the methods the controller does not use panic
+type fakeOrders struct {
+	repo.IOrderStorage
+	orders map[string]entities.Order
+}
+
+func (f *fakeOrders) GetOrder(ctx context.Context, id string) (entities.Order, error) {
+	order, ok := f.orders[id]
+	if !ok {
+		return entities.Order{}, e.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// fakePayments follows the state changes of the postgres storage
+type fakePayments struct {
+	repo.IPaymentStorage
+	orders       *fakeOrders
+	payments     map[string]entities.Payment
+	transactions map[string]entities.PaymeTransaction
+	refunds      map[string]entities.Refund
+}
+
+func (f *fakePayments) GetOrderPayments(ctx context.Context, orderId string) ([]entities.Payment, error) {
+	var payments []entities.Payment
+	for _, pay := range f.payments {
+		if pay.OrderID == orderId {
+			payments = append(payments, pay)
+		}
+	}
+	return payments, nil
+}
+
+func (f *fakePayments) GetPaymeTransaction(ctx context.Context, id string) (entities.PaymeTransaction, error) {
+	transaction, ok := f.transactions[id]
+	if !ok {
+		return entities.PaymeTransaction{}, e.ErrPaymeTransactionNotFound
+	}
+	return transaction, nil
+}
+
+func (f *fakePayments) GetActivePaymeTransaction(ctx context.Context, orderId string) (entities.PaymeTransaction, error) {
+	for _, transaction := range f.transactions {
+		if transaction.OrderID == orderId && transaction.State == constants.PaymeStateCreated {
+			return transaction, nil
+		}
+	}
+	return entities.PaymeTransaction{}, e.ErrPaymeTransactionNotFound
+}
+
+func (f *fakePayments) GetPaymeTransactions(ctx context.Context, from, to int64) ([]entities.PaymeTransaction, error) {
+	var transactions []entities.PaymeTransaction
+	for _, transaction := range f.transactions {
+		if transaction.CreateTime >= from && transaction.CreateTime <= to {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+func (f *fakePayments) CreatePaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, payment entities.Payment) error {
+	if _, ok := f.transactions[transaction.ID]; ok {
+		return e.ErrPaymeTransactionChanged
+	}
+	payment.ProviderPaymentID = &transaction.ID
+	f.payments[payment.ID] = payment
+	f.transactions[transaction.ID] = transaction
+	return nil
+}
+
+func (f *fakePayments) PerformPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, history entities.OrderHistory) error {
+	if f.transactions[transaction.ID].State != constants.PaymeStateCreated {
+		return e.ErrPaymeTransactionChanged
+	}
+	pay := f.payments[transaction.PaymentID]
+	if pay.Status != constants.PaymentStatusPending {
+		return e.ErrPaymentStatusChanged
+	}
+	order := f.orders.orders[transaction.OrderID]
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return e.ErrOrderNotAwaitingPayment
+	}
+
+	now := time.Now()
+	pay.Status = constants.PaymentStatusPaid
+	pay.PaidAt = &now
+	f.payments[pay.ID] = pay
+	order.Status = constants.OrderStatusNew
+	order.PaidAt = &now
+	f.orders.orders[order.ID] = order
+	f.transactions[transaction.ID] = transaction
+	return nil
+}
+
+func (f *fakePayments) CancelPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, fromState int) error {
+	if f.transactions[transaction.ID].State != fromState {
+		return e.ErrPaymeTransactionChanged
+	}
+	f.transactions[transaction.ID] = transaction
+
+	pay := f.payments[transaction.PaymentID]
+	if fromState == constants.PaymeStateCreated {
+		if pay.Status == constants.PaymentStatusPending {
+			pay.Status = constants.PaymentStatusCancelled
+		}
+		f.payments[pay.ID] = pay
+		return nil
+	}
+	for id, refund := range f.refunds {
+		if refund.OrderID == transaction.OrderID {
+			refund.Status = constants.RefundStatusCompleted
+			f.refunds[id] = refund
+		}
+	}
+	pay.Status = constants.PaymentStatusRefunded
+	pay.RefundedAmount = pay.Amount
+	f.payments[pay.ID] = pay
+	return nil
+}
+
+type fakeStorage struct {
+	storage.Storage
+	orders   *fakeOrders
+	payments *fakePayments
+}
+
+func (s fakeStorage) Order() repo.IOrderStorage {
+	return s.orders
+}
+
+func (s fakeStorage) Payment() repo.IPaymentStorage {
+	return s.payments
+}
+
+// fakeOrderController cancels orders like the system actor of the order controller
+type fakeOrderController struct {
+	ordercontroller.OrderController
+	storage fakeStorage
+}
+
+func (f fakeOrderController) CancelOrder(ctx context.Context, req entities.CancelOrderReq) (entities.CancelOrderRes, error) {
+	order := f.storage.orders.orders[req.OrderID]
+	if req.Actor.Role != constants.SystemRole || order.Status != constants.OrderStatusNew {
+		return entities.CancelOrderRes{}, e.ErrOrderCancelNotAllowed
+	}
+	order.Status = constants.OrderStatusCancelled
+	f.storage.orders.orders[order.ID] = order
+	f.storage.payments.refunds[order.ID] = entities.Refund{
+		OrderID: order.ID,
+		Amount:  order.Total,
+		Status:  constants.RefundStatusPending,
+	}
+	return entities.CancelOrderRes{OrderID: order.ID, Status: order.Status, RefundAmount: order.Total}, nil
+}
+
+type fakeTracker struct {
+	trackingcontroller.TrackingController
+	events *[]entities.OrderEvent
+}
+
+func (f fakeTracker) PublishOrderEvent(ctx context.Context, event entities.OrderEvent) {
+	*f.events = append(*f.events, event)
+}
+
+type testEnv struct {
+	controller paymeController
+	storage    fakeStorage
+	events     []entities.OrderEvent
+	now        time.Time
+}
+
+// newTestEnv has one card order of 125 000 so'm waiting for a payment
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	orders := &fakeOrders{orders: map[string]entities.Order{
+		testOrderID: {
+			ID:            testOrderID,
+			UserID:        testUserID,
+			Status:        constants.OrderStatusAwaitingPayment,
+			PaymentMethod: constants.PaymentMethodCard,
+			Total:         testAmount / 100,
+		},
+	}}
+	strg := fakeStorage{
+		orders: orders,
+		payments: &fakePayments{
+			orders:       orders,
+			payments:     map[string]entities.Payment{},
+			transactions: map[string]entities.PaymeTransaction{},
+			refunds:      map[string]entities.Refund{},
+		},
+	}
+	env := &testEnv{storage: strg, now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	env.controller = paymeController{
+		log:     logger.NewLogger("test", "error"),
+		storage: strg,
+		cfg:     &configs.Configuration{PaymeKey: testKey},
+		orders:  fakeOrderController{storage: strg},
+		tracker: fakeTracker{events: &env.events},
+		now:     func() time.Time { return env.now },
+	}
+	return env
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage      `json:"id"`
+	Result json.RawMessage      `json:"result"`
+	Error  *entities.PaymeError `json:"error"`
+}
+
+func basicAuth(login, key string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login+":"+key))
+}
+
+// send posts the raw body as Payme does and decodes the response the way Payme sees it
+func (env *testEnv) send(t *testing.T, httpMethod, authorization, body string) rpcResponse {
+	t.Helper()
+
+	res := env.controller.Handle(context.Background(), httpMethod, authorization, []byte(body))
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	var decoded rpcResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal response %s: %v", data, err)
+	}
+	return decoded
+}
+
+func (env *testEnv) call(t *testing.T, method, params string) rpcResponse {
+	t.Helper()
+	return env.send(t, http.MethodPost, basicAuth("Paycom", testKey),
+		fmt.Sprintf(`{"id": 1042, "method": %q, "params": %s}`, method, params))
+}
+
+// result calls the method and decodes its result, the call must succeed
+func (env *testEnv) result(t *testing.T, method, params string, result interface{}) {
+	t.Helper()
+
+	res := env.call(t, method, params)
+	if res.Error != nil {
+		t.Fatalf("%s: unexpected error %+v", method, res.Error)
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		t.Fatalf("%s: decode result %s: %v", method, res.Result, err)
+	}
+}
+
+func (env *testEnv) expectError(t *testing.T, method, params string, code int) *entities.PaymeError {
+	t.Helper()
+
+	res := env.call(t, method, params)
+	if res.Error == nil {
+		t.Fatalf("%s: expected error %d, got result %s", method, code, res.Result)
+	}
+	if res.Error.Code != code {
+		t.Fatalf("%s: error code = %d, want %d (%+v)", method, res.Error.Code, code, res.Error)
+	}
+	if res.Error.Message.Ru == "" || res.Error.Message.Uz == "" || res.Error.Message.En == "" {
+		t.Fatalf("%s: error message is not localized: %+v", method, res.Error.Message)
+	}
+	return res.Error
+}
+
+func account(orderId string, amount int64) string {
+	return fmt.Sprintf(`"amount": %d, "account": {"order_id": %q}`, amount, orderId)
+}
+
+func createParams(id string, amount int64) string {
+	return fmt.Sprintf(`{"id": %q, "time": 1714564800000, %s}`, id, account(testOrderID, amount))
+}
+
+func TestPaymeProtocolErrors(t *testing.T) {
+	env := newTestEnv(t)
+	auth := basicAuth("Paycom", testKey)
+
+	if res := env.send(t, http.MethodGet, auth, ""); res.Error == nil || res.Error.Code != codeTransportError {
+		t.Fatalf("GET: %+v", res.Error)
+	}
+	if res := env.send(t, http.MethodPost, auth, `{"id": 1, "method":`); res.Error == nil || res.Error.Code != codeParseError {
+		t.Fatalf("broken JSON: %+v", res.Error)
+	}
+	for _, authorization := range []string{"", basicAuth("Paycom", "wrong"), basicAuth("Admin", testKey), "Bearer " + testKey} {
+		res := env.send(t, http.MethodPost, authorization, `{"id": 7, "method": "CheckTransaction", "params": {"id": "x"}}`)
+		if res.Error == nil || res.Error.Code != codeInsufficientAccess {
+			t.Fatalf("authorization %q: %+v", authorization, res.Error)
+		}
+		if string(res.ID) != "7" {
+			t.Fatalf("request id is not sent back: %s", res.ID)
+		}
+	}
+	if res := env.send(t, http.MethodPost, auth, `{"id": 1, "params": {}}`); res.Error == nil || res.Error.Code != codeInvalidRequest {
+		t.Fatalf("missing method: %+v", res.Error)
+	}
+	env.expectError(t, "ChangePassword", `{"password": "new"}`, codeMethodNotFound)
+}
+
+func TestPaymeCheckPerformTransaction(t *testing.T) {
+	env := newTestEnv(t)
+
+	var check entities.PaymeCheckPerformRes
+	env.result(t, "CheckPerformTransaction", "{"+account(testOrderID, testAmount)+"}", &check)
+	if !check.Allow {
+		t.Fatalf("payment of the order is not allowed")
+	}
+
+	env.expectError(t, "CheckPerformTransaction", "{"+account(testOrderID, testAmount-100)+"}", codeInvalidAmount)
+	for _, orderId := range []string{"", "42", "1b9d6bcd-bbfd-4b2d-9b5d-ab8dfbbd4bed"} {
+		rpcErr := env.expectError(t, "CheckPerformTransaction", "{"+account(orderId, testAmount)+"}", codeOrderNotFound)
+		if rpcErr.Data != "order_id" {
+			t.Fatalf("account field is not named: %+v", rpcErr)
+		}
+	}
+
+	order := env.storage.orders.orders[testOrderID]
+	order.Status = constants.OrderStatusCancelled
+	env.storage.orders.orders[testOrderID] = order
+	env.expectError(t, "CheckPerformTransaction", "{"+account(testOrderID, testAmount)+"}", codeOrderNotPayable)
+}
+
+// TestPaymePaySequence replays a successful payment: Payme checks the order, creates the
+// transaction, repeats the create call, performs it twice and checks it
+func TestPaymePaySequence(t *testing.T) {
+	env := newTestEnv(t)
+	const id = "5305e3bab097f420a62ced0b"
+
+	var check entities.PaymeCheckPerformRes
+	env.result(t, "CheckPerformTransaction", "{"+account(testOrderID, testAmount)+"}", &check)
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams(id, testAmount), &created)
+	if created.State != constants.PaymeStateCreated || created.CreateTime != env.now.UnixMilli() || created.Transaction == "" {
+		t.Fatalf("unexpected create result %+v", created)
+	}
+	pay := env.storage.payments.payments[created.Transaction]
+	if pay.OrderID != testOrderID || pay.Provider != constants.PaymentProviderPayme || pay.Amount != testAmount/100 ||
+		pay.ProviderPaymentID == nil || *pay.ProviderPaymentID != id {
+		t.Fatalf("transaction is not bound to a payment: %+v", pay)
+	}
+
+	env.now = env.now.Add(time.Minute)
+	var repeated entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams(id, testAmount), &repeated)
+	if repeated != created {
+		t.Fatalf("repeated create = %+v, want %+v", repeated, created)
+	}
+	if len(env.storage.payments.payments) != 1 {
+		t.Fatalf("repeated create made another payment")
+	}
+
+	var performed entities.PaymePerformRes
+	env.result(t, "PerformTransaction", fmt.Sprintf(`{"id": %q}`, id), &performed)
+	if performed.State != constants.PaymeStatePerformed || performed.PerformTime != env.now.UnixMilli() ||
+		performed.Transaction != created.Transaction {
+		t.Fatalf("unexpected perform result %+v", performed)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusNew || order.PaidAt == nil {
+		t.Fatalf("order is not passed to the seller: %+v", order)
+	}
+	if pay := env.storage.payments.payments[created.Transaction]; pay.Status != constants.PaymentStatusPaid {
+		t.Fatalf("payment status = %q", pay.Status)
+	}
+	if len(env.events) != 1 || env.events[0].Status != constants.OrderStatusNew {
+		t.Fatalf("order status is not published: %+v", env.events)
+	}
+
+	env.now = env.now.Add(time.Minute)
+	var performedAgain entities.PaymePerformRes
+	env.result(t, "PerformTransaction", fmt.Sprintf(`{"id": %q}`, id), &performedAgain)
+	if performedAgain != performed {
+		t.Fatalf("repeated perform = %+v, want %+v", performedAgain, performed)
+	}
+	env.expectError(t, "CreateTransaction", createParams(id, testAmount), codeCannotPerform)
+
+	var checked entities.PaymeCheckRes
+	env.result(t, "CheckTransaction", fmt.Sprintf(`{"id": %q}`, id), &checked)
+	if checked.State != constants.PaymeStatePerformed || checked.CreateTime != created.CreateTime ||
+		checked.PerformTime != performed.PerformTime || checked.CancelTime != 0 || checked.Reason != nil {
+		t.Fatalf("unexpected check result %+v", checked)
+	}
+
+	var statement entities.PaymeStatementRes
+	from, to := created.CreateTime-1000, created.CreateTime+1000
+	env.result(t, "GetStatement", fmt.Sprintf(`{"from": %d, "to": %d}`, from, to), &statement)
+	if len(statement.Transactions) != 1 {
+		t.Fatalf("statement has %d transactions", len(statement.Transactions))
+	}
+	item := statement.Transactions[0]
+	if item.ID != id || item.Amount != testAmount || item.Account.OrderID != testOrderID ||
+		item.Time != 1714564800000 || item.State != constants.PaymeStatePerformed {
+		t.Fatalf("unexpected statement item %+v", item)
+	}
+	env.result(t, "GetStatement", fmt.Sprintf(`{"from": %d, "to": %d}`, to+1, to+1000), &statement)
+	if len(statement.Transactions) != 0 {
+		t.Fatalf("statement of another period has transactions: %+v", statement.Transactions)
+	}
+}
+
+func TestPaymeCreateTransactionErrors(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.expectError(t, "CreateTransaction", createParams("a1", testAmount+1), codeInvalidAmount)
+	if len(env.storage.payments.transactions) != 0 {
+		t.Fatalf("transaction is created with a wrong amount")
+	}
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("a1", testAmount), &created)
+	// the order can not be paid by two transactions at once
+	env.expectError(t, "CreateTransaction", createParams("a2", testAmount), codeOrderBeingPaid)
+
+	env.expectError(t, "PerformTransaction", `{"id": "missing"}`, codeTransactionNotFound)
+	env.expectError(t, "CheckTransaction", `{"id": "missing"}`, codeTransactionNotFound)
+	env.expectError(t, "CancelTransaction", `{"id": "missing", "reason": 3}`, codeTransactionNotFound)
+}
+
+// TestPaymeCancelBeforePerform replays a transaction cancelled by Payme before it was performed,
+// the customer then pays the order with another transaction
+func TestPaymeCancelBeforePerform(t *testing.T) {
+	env := newTestEnv(t)
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("c1", testAmount), &created)
+
+	env.now = env.now.Add(time.Minute)
+	var cancelled entities.PaymeCancelRes
+	env.result(t, "CancelTransaction", `{"id": "c1", "reason": 3}`, &cancelled)
+	if cancelled.State != constants.PaymeStateCancelled || cancelled.CancelTime != env.now.UnixMilli() {
+		t.Fatalf("unexpected cancel result %+v", cancelled)
+	}
+	if pay := env.storage.payments.payments[created.Transaction]; pay.Status != constants.PaymentStatusCancelled {
+		t.Fatalf("payment status = %q", pay.Status)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusAwaitingPayment {
+		t.Fatalf("order status = %q, the customer can still pay it", order.Status)
+	}
+
+	env.now = env.now.Add(time.Minute)
+	var cancelledAgain entities.PaymeCancelRes
+	env.result(t, "CancelTransaction", `{"id": "c1", "reason": 3}`, &cancelledAgain)
+	if cancelledAgain != cancelled {
+		t.Fatalf("repeated cancel = %+v, want %+v", cancelledAgain, cancelled)
+	}
+	env.expectError(t, "PerformTransaction", `{"id": "c1"}`, codeCannotPerform)
+
+	var checked entities.PaymeCheckRes
+	env.result(t, "CheckTransaction", `{"id": "c1"}`, &checked)
+	if checked.State != constants.PaymeStateCancelled || checked.Reason == nil || *checked.Reason != 3 {
+		t.Fatalf("unexpected check result %+v", checked)
+	}
+
+	var second entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("c2", testAmount), &second)
+	if second.Transaction == created.Transaction {
+		t.Fatalf("new transaction reuses the cancelled payment")
+	}
+	var performed entities.PaymePerformRes
+	env.result(t, "PerformTransaction", `{"id": "c2"}`, &performed)
+	if performed.State != constants.PaymeStatePerformed {
+		t.Fatalf("unexpected perform result %+v", performed)
+	}
+}
+
+// TestPaymeCancelAfterPerform replays a refund: the paid order is cancelled unless it is in work
+func TestPaymeCancelAfterPerform(t *testing.T) {
+	env := newTestEnv(t)
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("r1", testAmount), &created)
+	var performed entities.PaymePerformRes
+	env.result(t, "PerformTransaction", `{"id": "r1"}`, &performed)
+
+	order := env.storage.orders.orders[testOrderID]
+	order.Status = constants.OrderStatusDelivered
+	env.storage.orders.orders[testOrderID] = order
+	env.expectError(t, "CancelTransaction", `{"id": "r1", "reason": 5}`, codeCannotCancel)
+	if transaction := env.storage.payments.transactions["r1"]; transaction.State != constants.PaymeStatePerformed {
+		t.Fatalf("transaction of a delivered order is cancelled: %+v", transaction)
+	}
+
+	order.Status = constants.OrderStatusNew
+	env.storage.orders.orders[testOrderID] = order
+	env.now = env.now.Add(time.Hour)
+	var cancelled entities.PaymeCancelRes
+	env.result(t, "CancelTransaction", `{"id": "r1", "reason": 5}`, &cancelled)
+	if cancelled.State != constants.PaymeStateCancelledAfterPerform || cancelled.CancelTime != env.now.UnixMilli() {
+		t.Fatalf("unexpected cancel result %+v", cancelled)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusCancelled {
+		t.Fatalf("order status = %q, want cancelled", order.Status)
+	}
+	if refund := env.storage.payments.refunds[testOrderID]; refund.Status != constants.RefundStatusCompleted {
+		t.Fatalf("refund status = %q, want completed", refund.Status)
+	}
+	pay := env.storage.payments.payments[created.Transaction]
+	if pay.Status != constants.PaymentStatusRefunded || pay.RefundedAmount != pay.Amount {
+		t.Fatalf("payment is not refunded: %+v", pay)
+	}
+
+	var cancelledAgain entities.PaymeCancelRes
+	env.result(t, "CancelTransaction", `{"id": "r1", "reason": 5}`, &cancelledAgain)
+	if cancelledAgain != cancelled {
+		t.Fatalf("repeated cancel = %+v, want %+v", cancelledAgain, cancelled)
+	}
+	env.expectError(t, "PerformTransaction", `{"id": "r1"}`, codeCannotPerform)
+}
+
+// TestPaymeTransactionTimeout checks that a transaction not performed in 12 hours is cancelled with reason 4
+func TestPaymeTransactionTimeout(t *testing.T) {
+	env := newTestEnv(t)
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("t1", testAmount), &created)
+
+	env.now = env.now.Add(constants.PaymeTransactionTimeout + time.Minute)
+	env.expectError(t, "PerformTransaction", `{"id": "t1"}`, codeCannotPerform)
+
+	var checked entities.PaymeCheckRes
+	env.result(t, "CheckTransaction", `{"id": "t1"}`, &checked)
+	if checked.State != constants.PaymeStateCancelled || checked.Reason == nil || *checked.Reason != constants.PaymeReasonTimeout {
+		t.Fatalf("unexpected check result %+v", checked)
+	}
+	if order := env.storage.orders.orders[testOrderID]; order.Status != constants.OrderStatusAwaitingPayment {
+		t.Fatalf("order status = %q", order.Status)
+	}
+}
+
+// TestPaymePerformOfClosedOrder checks that the money of an order cancelled while
+// the customer was paying is not taken
+func TestPaymePerformOfClosedOrder(t *testing.T) {
+	env := newTestEnv(t)
+
+	var created entities.PaymeCreateRes
+	env.result(t, "CreateTransaction", createParams("p1", testAmount), &created)
+
+	order := env.storage.orders.orders[testOrderID]
+	order.Status = constants.OrderStatusCancelled
+	env.storage.orders.orders[testOrderID] = order
+	env.expectError(t, "PerformTransaction", `{"id": "p1"}`, codeCannotPerform)
+
+	var cancelled entities.PaymeCancelRes
+	env.result(t, "CancelTransaction", `{"id": "p1", "reason": 3}`, &cancelled)
+	if cancelled.State != constants.PaymeStateCancelled {
+		t.Fatalf("unexpected cancel result %+v", cancelled)
+	}
+}
@@ -0,0 +1,540 @@
+package payment
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/pkg/payment"
+	"delivery/storage"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PaymentController interface {
+	CreatePayment(ctx context.Context, order entities.Order, provider string) (entities.Payment, error)
+	CreateTipPayment(ctx context.Context, order entities.Order, amount int64) (entities.Payment, error)
+	PayOrder(ctx context.Context, actor entities.Actor, orderId, provider string) (entities.Payment, error)
+	GetOrderPayments(ctx context.Context, actor entities.Actor, orderId string) ([]entities.Payment, error)
+	CheckPayment(ctx context.Context, actor entities.Actor, paymentId string) (entities.Payment, error)
+	CancelPendingPayments(ctx context.Context, orderId string) error
+	RunPaymentWorker(ctx context.Context)
+}
+
+type paymentController struct {
+	log       logger.LoggerI
+	storage   storage.Storage
+	cfg       *configs.Configuration
+	providers map[string]payment.PaymentProvider
+	// defaultProvider is used when the customer does not choose one
+	defaultProvider string
+	tracker         trackingcontroller.TrackingController
+}
+
+// NewPaymentController takes payments with the providers, the first one is the default
+func NewPaymentController(log logger.LoggerI, storage storage.Storage, tracker trackingcontroller.TrackingController, providers ...payment.PaymentProvider) PaymentController {
+	c := paymentController{
+		log:       log,
+		storage:   storage,
+		cfg:       configs.Config(),
+		providers: make(map[string]payment.PaymentProvider, len(providers)),
+		tracker:   tracker,
+	}
+	for _, provider := range providers {
+		c.providers[provider.Name()] = provider
+	}
+	if len(providers) > 0 {
+		c.defaultProvider = providers[0].Name()
+	}
+	return c
+}
+
+// provider returns the provider by its name, the default one for an empty name
+func (p paymentController) provider(name string) (payment.PaymentProvider, error) {
+	if name == "" {
+		name = p.defaultProvider
+	}
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, e.ErrUnknownPaymentProvider
+	}
+	return provider, nil
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (p paymentController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	p.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// authorizeActor lets only the customer of the order and admins see its payments
+func authorizeActor(order entities.Order, actor entities.Actor) error {
+	if actor.Role == constants.AdminRole || actor.Role == constants.SystemRole || order.UserID == actor.ID {
+		return nil
+	}
+	return e.ErrOrderForbidden
+}
+
+// CreatePayment issues an invoice for the order at the provider
+func (p paymentController) CreatePayment(ctx context.Context, order entities.Order, providerName string) (entities.Payment, error) {
+	p.log.Info("CreatePayment started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, Provider: %s", order.ID, providerName)))
+
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return entities.Payment{}, e.ErrOrderNotAwaitingPayment
+	}
+	provider, err := p.provider(providerName)
+	if err != nil {
+		return entities.Payment{}, err
+	}
+
+	now := time.Now()
+	pay := entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Purpose:   constants.PaymentPurposeOrder,
+		Provider:  provider.Name(),
+		Amount:    order.Total,
+		Status:    constants.PaymentStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	pay, err = p.issueInvoice(ctx, pay, provider, fmt.Sprintf("Buyurtma #%d", order.Number))
+	if err != nil {
+		return entities.Payment{}, p.internalError("CreatePayment", err)
+	}
+
+	p.log.Info("CreatePayment finished", zap.String("PaymentID", pay.ID))
+	return pay, nil
+}
+
+// CreateTipPayment issues an invoice for a tip given after the delivery with the provider the
+// order was paid with. Providers that take payments through their own merchant API know only
+// the order total and can not take a tip on its own.
+func (p paymentController) CreateTipPayment(ctx context.Context, order entities.Order, amount int64) (entities.Payment, error) {
+	p.log.Info("CreateTipPayment started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, Amount: %d", order.ID, amount)))
+
+	paid, err := p.storage.Payment().GetPaidPayment(ctx, order.ID)
+	if err != nil {
+		return entities.Payment{}, p.internalError("CreateTipPayment", err)
+	}
+	if paid.Provider == constants.PaymentProviderPayme || paid.Provider == constants.PaymentProviderClick {
+		return entities.Payment{}, e.ErrTipNotSupported
+	}
+	provider, ok := p.providers[paid.Provider]
+	if !ok {
+		return entities.Payment{}, e.ErrTipNotSupported
+	}
+
+	// the customer may be paying the same tip again, e.g. after closing the pay page
+	payments, err := p.storage.Payment().GetOrderPayments(ctx, order.ID)
+	if err != nil {
+		return entities.Payment{}, p.internalError("CreateTipPayment", err)
+	}
+	for i := len(payments) - 1; i >= 0; i-- {
+		pay := payments[i]
+		if pay.Purpose != constants.PaymentPurposeTip || pay.Status != constants.PaymentStatusPending || pay.Amount != amount {
+			continue
+		}
+		if pay, err = p.sync(ctx, pay); err != nil {
+			return entities.Payment{}, p.internalError("CreateTipPayment", err)
+		}
+		if pay.Status == constants.PaymentStatusPending {
+			p.log.Info("CreateTipPayment finished", zap.String("PaymentID", pay.ID))
+			return pay, nil
+		}
+		if pay.Status == constants.PaymentStatusPaid {
+			return entities.Payment{}, e.ErrOrderAlreadyTipped
+		}
+	}
+
+	now := time.Now()
+	pay := entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Purpose:   constants.PaymentPurposeTip,
+		Provider:  provider.Name(),
+		Amount:    amount,
+		Status:    constants.PaymentStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	pay, err = p.issueInvoice(ctx, pay, provider, fmt.Sprintf("Buyurtma #%d uchun choy puli", order.Number))
+	if err != nil {
+		return entities.Payment{}, p.internalError("CreateTipPayment", err)
+	}
+
+	p.log.Info("CreateTipPayment finished", zap.String("PaymentID", pay.ID))
+	return pay, nil
+}
+
+// issueInvoice saves the payment before the provider is called so that a failed attempt
+// is recorded as well
+func (p paymentController) issueInvoice(ctx context.Context, pay entities.Payment, provider payment.PaymentProvider, description string) (entities.Payment, error) {
+	if err := p.storage.Payment().CreatePayment(ctx, pay); err != nil {
+		return entities.Payment{}, err
+	}
+
+	invoice, err := provider.CreateInvoice(ctx, payment.Invoice{
+		PaymentID:   pay.ID,
+		OrderID:     pay.OrderID,
+		Amount:      pay.Amount,
+		Description: description,
+		ReturnURL:   p.cfg.PaymentReturnURL,
+	})
+	if err != nil {
+		fields := map[string]interface{}{"error": err.Error()}
+		if err := p.storage.Payment().UpdatePaymentStatus(ctx, pay.ID, pay.Status, constants.PaymentStatusFailed, fields); err != nil {
+			p.log.Error("error in issueInvoice: ", zap.Error(err))
+		}
+		return entities.Payment{}, err
+	}
+
+	// some providers tell the id only when the customer starts paying
+	pay.PayURL = invoice.PayURL
+	fields := map[string]interface{}{"pay_url": invoice.PayURL}
+	if invoice.ProviderPaymentID != "" {
+		pay.ProviderPaymentID = &invoice.ProviderPaymentID
+		fields["provider_payment_id"] = invoice.ProviderPaymentID
+	}
+	if err := p.storage.Payment().UpdatePaymentStatus(ctx, pay.ID, pay.Status, pay.Status, fields); err != nil {
+		return entities.Payment{}, err
+	}
+	return pay, nil
+}
+
+// PayOrder returns the pending invoice of the order with the provider or issues a new one,
+// e.g. after the customer's card was declined
+func (p paymentController) PayOrder(ctx context.Context, actor entities.Actor, orderId, providerName string) (entities.Payment, error) {
+	p.log.Info("PayOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, ActorID: %s, Provider: %s", orderId, actor.ID, providerName)))
+
+	provider, err := p.provider(providerName)
+	if err != nil {
+		return entities.Payment{}, err
+	}
+
+	order, err := p.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.Payment{}, p.internalError("PayOrder", err)
+	}
+	if order.UserID != actor.ID {
+		return entities.Payment{}, e.ErrOrderForbidden
+	}
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return entities.Payment{}, e.ErrOrderNotAwaitingPayment
+	}
+
+	payments, err := p.storage.Payment().GetOrderPayments(ctx, orderId)
+	if err != nil {
+		return entities.Payment{}, p.internalError("PayOrder", err)
+	}
+	for i := len(payments) - 1; i >= 0; i-- {
+		pay := payments[i]
+		if pay.Status != constants.PaymentStatusPending || pay.Provider != provider.Name() {
+			continue
+		}
+		// the customer may have paid it already
+		if pay, err = p.sync(ctx, pay); err != nil {
+			return entities.Payment{}, p.internalError("PayOrder", err)
+		}
+		if pay.Status == constants.PaymentStatusPending || pay.Status == constants.PaymentStatusPaid {
+			p.log.Info("PayOrder finished", zap.String("PaymentID", pay.ID))
+			return pay, nil
+		}
+	}
+
+	pay, err := p.CreatePayment(ctx, order, provider.Name())
+	if err != nil {
+		return entities.Payment{}, err
+	}
+
+	p.log.Info("PayOrder finished", zap.String("PaymentID", pay.ID))
+	return pay, nil
+}
+
+func (p paymentController) GetOrderPayments(ctx context.Context, actor entities.Actor, orderId string) ([]entities.Payment, error) {
+	p.log.Info("GetOrderPayments started: ", zap.String("OrderID", orderId))
+
+	order, err := p.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, p.internalError("GetOrderPayments", err)
+	}
+	if err := authorizeActor(order, actor); err != nil {
+		return nil, err
+	}
+
+	data, err := p.storage.Payment().GetOrderPayments(ctx, orderId)
+	if err != nil {
+		return nil, p.internalError("GetOrderPayments", err)
+	}
+
+	p.log.Info("GetOrderPayments finished")
+	return data, nil
+}
+
+// CheckPayment asks the provider for the status of a pending payment right away,
+// the app calls it when the customer comes back from the pay page
+func (p paymentController) CheckPayment(ctx context.Context, actor entities.Actor, paymentId string) (entities.Payment, error) {
+	p.log.Info("CheckPayment started: ", zap.String("PaymentID", paymentId))
+
+	pay, err := p.storage.Payment().GetPayment(ctx, paymentId)
+	if err != nil {
+		return entities.Payment{}, p.internalError("CheckPayment", err)
+	}
+	if actor.Role != constants.AdminRole && pay.UserID != actor.ID {
+		return entities.Payment{}, e.ErrOrderForbidden
+	}
+
+	pay, err = p.sync(ctx, pay)
+	if err != nil {
+		return entities.Payment{}, p.internalError("CheckPayment", err)
+	}
+
+	p.log.Info("CheckPayment finished", zap.String("Status", pay.Status))
+	return pay, nil
+}
+
+// CancelPendingPayments cancels the invoices of an order that is not going to be paid.
+// An invoice paid in the meantime is confirmed and refunded by the payment worker.
+func (p paymentController) CancelPendingPayments(ctx context.Context, orderId string) error {
+	p.log.Info("CancelPendingPayments started: ", zap.String("OrderID", orderId))
+
+	payments, err := p.storage.Payment().GetOrderPayments(ctx, orderId)
+	if err != nil {
+		return p.internalError("CancelPendingPayments", err)
+	}
+	for _, pay := range payments {
+		if pay.Status != constants.PaymentStatusPending {
+			continue
+		}
+		if err := p.cancelInvoice(ctx, pay); err != nil {
+			p.log.Warn("could not cancel invoice", zap.String("PaymentID", pay.ID), zap.Error(err))
+			continue
+		}
+		err := p.storage.Payment().UpdatePaymentStatus(ctx, pay.ID, pay.Status, constants.PaymentStatusCancelled, nil)
+		if err != nil && !errors.Is(err, e.ErrPaymentStatusChanged) {
+			return p.internalError("CancelPendingPayments", err)
+		}
+	}
+
+	p.log.Info("CancelPendingPayments finished")
+	return nil
+}
+
+func (p paymentController) cancelInvoice(ctx context.Context, pay entities.Payment) error {
+	provider, ok := p.providers[pay.Provider]
+	if !ok || pay.ProviderPaymentID == nil {
+		return nil
+	}
+	return provider.Cancel(ctx, *pay.ProviderPaymentID)
+}
+
+// sync applies the provider status of a pending payment
+func (p paymentController) sync(ctx context.Context, pay entities.Payment) (entities.Payment, error) {
+	provider, ok := p.providers[pay.Provider]
+	if !ok || pay.Status != constants.PaymentStatusPending || pay.ProviderPaymentID == nil {
+		return pay, nil
+	}
+	providerStatus, err := provider.CheckStatus(ctx, *pay.ProviderPaymentID)
+	if err != nil {
+		return entities.Payment{}, err
+	}
+
+	switch providerStatus {
+	case payment.StatusPaid:
+		if err := p.confirm(ctx, pay); err != nil {
+			return entities.Payment{}, err
+		}
+	case payment.StatusFailed, payment.StatusCancelled:
+		err := p.storage.Payment().UpdatePaymentStatus(ctx, pay.ID, pay.Status, providerStatus, nil)
+		if err != nil && !errors.Is(err, e.ErrPaymentStatusChanged) {
+			return entities.Payment{}, err
+		}
+	default:
+		return pay, nil
+	}
+	return p.storage.Payment().GetPayment(ctx, pay.ID)
+}
+
+// confirm marks the payment as paid and passes the order on to the seller
+func (p paymentController) confirm(ctx context.Context, pay entities.Payment) error {
+	if pay.Purpose == constants.PaymentPurposeTip {
+		return p.confirmTip(ctx, pay)
+	}
+	refunded, err := p.storage.Payment().ConfirmPayment(ctx, pay, entities.OrderHistory{
+		OrderID:    pay.OrderID,
+		Action:     constants.OrderActionPaid,
+		FromStatus: constants.OrderStatusAwaitingPayment,
+		ToStatus:   constants.OrderStatusNew,
+		ActorRole:  constants.SystemRole,
+		Note:       pay.Provider,
+	})
+	if errors.Is(err, e.ErrPaymentStatusChanged) {
+		// confirmed by a concurrent check
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if refunded {
+		p.log.Warn("order was paid after it had been closed, the payment is refunded",
+			zap.String("OrderID", pay.OrderID), zap.String("PaymentID", pay.ID))
+		return nil
+	}
+	p.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventStatus,
+		OrderID: pay.OrderID,
+		Status:  constants.OrderStatusNew,
+	})
+	return nil
+}
+
+// confirmTip marks the tip payment as paid and gives the tip to the courier of the order
+func (p paymentController) confirmTip(ctx context.Context, pay entities.Payment) error {
+	order, err := p.storage.Order().GetOrder(ctx, pay.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.CourierID == nil {
+		return e.ErrOrderNotDelivered
+	}
+	tip := entities.CourierEarning(uuid.NewString(), constants.LedgerKindTip, *order.CourierID, &order.ID, pay.Amount,
+		constants.LedgerAccountTipsHeld, fmt.Sprintf("order #%d tip", order.Number))
+	refunded, err := p.storage.Payment().ConfirmTipPayment(ctx, pay, tip)
+	if errors.Is(err, e.ErrPaymentStatusChanged) {
+		// confirmed by a concurrent check
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if refunded {
+		p.log.Warn("courier was already tipped, the tip payment is refunded",
+			zap.String("OrderID", pay.OrderID), zap.String("PaymentID", pay.ID))
+		return nil
+	}
+	p.log.Info("tip paid", zap.String("OrderID", pay.OrderID), zap.Int64("Amount", pay.Amount))
+	return nil
+}
+
+// RunPaymentWorker follows pending payments and sends pending refunds to the provider until ctx is done
+func (p paymentController) RunPaymentWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.PaymentCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.syncPendingPayments(ctx)
+			p.processRefunds(ctx)
+		}
+	}
+}
+
+func (p paymentController) syncPendingPayments(ctx context.Context) {
+	payments, err := p.storage.Payment().GetPendingPayments(ctx, constants.PaymentBatchLimit)
+	if err != nil {
+		p.log.Error("error in syncPendingPayments: ", zap.Error(err))
+		return
+	}
+
+	for _, pending := range payments {
+		pay, err := p.sync(ctx, pending)
+		if err != nil {
+			p.log.Warn("could not check payment", zap.String("PaymentID", pending.ID), zap.Error(err))
+			continue
+		}
+		if pay.Status != constants.PaymentStatusPending || time.Since(pay.CreatedAt) < p.cfg.PaymentTimeout {
+			continue
+		}
+
+		// the order is cancelled by the order controller, the invoice is not valid anymore
+		if err := p.cancelInvoice(ctx, pay); err != nil {
+			p.log.Warn("could not cancel expired invoice", zap.String("PaymentID", pay.ID), zap.Error(err))
+			continue
+		}
+		err = p.storage.Payment().UpdatePaymentStatus(ctx, pay.ID, pay.Status, constants.PaymentStatusCancelled,
+			map[string]interface{}{"error": "payment timeout"})
+		if err != nil && !errors.Is(err, e.ErrPaymentStatusChanged) {
+			p.log.Error("error in syncPendingPayments: ", zap.Error(err))
+		}
+	}
+}
+
+func (p paymentController) processRefunds(ctx context.Context) {
+	refunds, err := p.storage.Payment().GetPendingRefunds(ctx, constants.PaymentBatchLimit)
+	if err != nil {
+		p.log.Error("error in processRefunds: ", zap.Error(err))
+		return
+	}
+
+	for _, refund := range refunds {
+		err := p.refund(ctx, refund)
+		if errors.Is(err, payment.ErrRefundNotSupported) {
+			// the support team refunds it in the provider's cabinet
+			err = p.storage.Payment().UpdateRefundStatus(ctx, refund.ID, refund.Status, constants.RefundStatusManual, err.Error())
+			if err != nil {
+				p.log.Error("error in processRefunds: ", zap.Error(err))
+			}
+			continue
+		}
+		if err != nil {
+			p.log.Warn("refund failed", zap.String("RefundID", refund.ID), zap.Error(err))
+			if err := p.storage.Payment().FailRefundAttempt(ctx, refund, err.Error()); err != nil {
+				p.log.Error("error in processRefunds: ", zap.Error(err))
+			}
+			continue
+		}
+		p.log.Info("refund completed", zap.String("RefundID", refund.ID), zap.Int64("Amount", refund.Amount))
+	}
+}
+
+func (p paymentController) refund(ctx context.Context, refund entities.Refund) error {
+	var pay entities.Payment
+	var err error
+	if refund.PaymentID != nil {
+		pay, err = p.storage.Payment().GetPayment(ctx, *refund.PaymentID)
+	} else {
+		pay, err = p.storage.Payment().GetPaidPayment(ctx, refund.OrderID)
+	}
+	if err != nil {
+		return err
+	}
+	if pay.Provider == constants.PaymentProviderWallet {
+		// the money goes back to the wallet it was paid from
+		transaction := entities.WalletCredit(uuid.NewString(), constants.WalletKindRefund, pay.UserID, &refund.OrderID,
+			refund.Amount, constants.LedgerAccountOrderRevenue, "order refund")
+		transaction.RefundID = &refund.ID
+		return p.storage.Wallet().RefundToWallet(ctx, transaction, refund, pay)
+	}
+	provider, ok := p.providers[pay.Provider]
+	if !ok || pay.ProviderPaymentID == nil {
+		return fmt.Errorf("payment %s can not be refunded with the %s provider", pay.ID, pay.Provider)
+	}
+
+	if err := provider.Refund(ctx, *pay.ProviderPaymentID, refund.Amount); err != nil {
+		return err
+	}
+	return p.storage.Payment().CompleteRefund(ctx, refund, pay)
+}
@@ -0,0 +1,33 @@
+package settlement
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+)
+
+// orderCommission sums the commission of the order items. Every item falls under the most
+// specific rule that matches it, the items of one rule are charged together so that a fixed
+// rule is taken once per order and a tiered one by the items total. Items no rule matches
+// are charged defaultPercent.
+func orderCommission(order entities.Order, categories map[string]string, rules []entities.CommissionRule, defaultPercent float64) int64 {
+	grossByRule := make(map[int]int64)
+	for _, item := range order.Items {
+		best, bestScore := -1, -1
+		for i, rule := range rules {
+			if score := rule.Matches(order.XozmakID, categories[item.ProductID]); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		grossByRule[best] += item.Price * int64(item.Quantity)
+	}
+
+	var commission int64
+	for index, gross := range grossByRule {
+		if index < 0 {
+			commission += entities.CommissionRule{Type: constants.CommissionTypePercent, Percent: defaultPercent}.Commission(gross)
+			continue
+		}
+		commission += rules[index].Commission(gross)
+	}
+	return commission
+}
@@ -0,0 +1,66 @@
+package settlement
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	"testing"
+)
+
+func item(productId string, price int64, quantity int) entities.OrderItem {
+	return entities.OrderItem{ProductID: productId, Price: price, Quantity: quantity}
+}
+
+func TestOrderCommissionUsesTheMostSpecificRule(t *testing.T) {
+	xozmak, otherXozmak, drinks := "xozmak", "other", "drinks"
+	rules := []entities.CommissionRule{
+		{Type: constants.CommissionTypePercent, Percent: 15},
+		{CategoryID: &drinks, Type: constants.CommissionTypePercent, Percent: 5},
+		{XozmakID: &xozmak, Type: constants.CommissionTypePercent, Percent: 12},
+		{XozmakID: &otherXozmak, Type: constants.CommissionTypePercent, Percent: 1},
+	}
+	order := entities.Order{
+		XozmakID: xozmak,
+		Items:    []entities.OrderItem{item("plov", 50000, 2), item("cola", 10000, 1)},
+	}
+	categories := map[string]string{"cola": drinks}
+
+	// the xozmak rule beats the category rule for both items
+	if got := orderCommission(order, categories, rules, 10); got != 13200 {
+		t.Fatalf("expected 13200, got %d", got)
+	}
+
+	order.XozmakID = "third"
+	// plov falls under the default rule and cola under the category one
+	if got := orderCommission(order, categories, rules, 10); got != 15000+500 {
+		t.Fatalf("expected 15500, got %d", got)
+	}
+}
+
+func TestOrderCommissionFallsBackToTheDefaultPercent(t *testing.T) {
+	order := entities.Order{XozmakID: "xozmak", Items: []entities.OrderItem{item("plov", 45000, 1)}}
+	if got := orderCommission(order, nil, nil, 10); got != 4500 {
+		t.Fatalf("expected 4500, got %d", got)
+	}
+}
+
+func TestFixedAndTieredCommissions(t *testing.T) {
+	fixed := entities.CommissionRule{Type: constants.CommissionTypeFixed, FixedAmount: 5000}
+	if got := fixed.Commission(120000); got != 5000 {
+		t.Fatalf("expected 5000, got %d", got)
+	}
+	if got := fixed.Commission(3000); got != 3000 {
+		t.Fatalf("fixed commission must not exceed the items total, got %d", got)
+	}
+
+	tiered := entities.CommissionRule{Type: constants.CommissionTypeTiered, Tiers: entities.CommissionTiers{
+		{MinAmount: 0, Percent: 15},
+		{MinAmount: 100000, Percent: 10},
+		{MinAmount: 500000, Percent: 7},
+	}}
+	cases := map[int64]int64{50000: 7500, 100000: 10000, 600000: 42000}
+	for gross, expected := range cases {
+		if got := tiered.Commission(gross); got != expected {
+			t.Fatalf("gross %d: expected %d, got %d", gross, expected, got)
+		}
+	}
+}
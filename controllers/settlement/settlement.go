@@ -0,0 +1,310 @@
+package settlement
+
+import (
+	"bytes"
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/storage"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type SettlementController interface {
+	CreateCommissionRule(ctx context.Context, rule entities.CommissionRule) (entities.CommissionRule, error)
+	GetCommissionRules(ctx context.Context) ([]entities.CommissionRule, error)
+	UpdateCommissionRule(ctx context.Context, rule entities.CommissionRule) error
+	DeleteCommissionRule(ctx context.Context, id string) error
+	RecordCommission(ctx context.Context, order entities.Order) error
+	CreateSettlements(ctx context.Context, req entities.SettlementsReq) ([]entities.Settlement, error)
+	GetSettlements(ctx context.Context, actor entities.Actor, filter entities.SettlementFilter) (entities.SettlementList, error)
+	GetSettlement(ctx context.Context, actor entities.Actor, id string) (entities.Settlement, error)
+	ExportSettlement(ctx context.Context, actor entities.Actor, id string) ([]byte, error)
+	MarkSettled(ctx context.Context, actor entities.Actor, id string) (entities.Settlement, error)
+}
+
+type settlementController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	cfg     *configs.Configuration
+}
+
+func NewSettlementController(log logger.LoggerI, storage storage.Storage) SettlementController {
+	return settlementController{
+		log:     log,
+		storage: storage,
+		cfg:     configs.Config(),
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (s settlementController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	s.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// sellerXozmak returns the xozmak of a seller, admins see every xozmak and get an empty id
+func (s settlementController) sellerXozmak(ctx context.Context, actor entities.Actor) (string, error) {
+	if actor.Role == constants.AdminRole {
+		return "", nil
+	}
+	if actor.Role != constants.SellerRole {
+		return "", e.ErrNotSeller
+	}
+	xozmakId, err := s.storage.Order().GetUserXozmakID(ctx, actor.ID)
+	if errors.Is(err, e.ErrXozmakNotFound) {
+		return "", e.ErrNotSeller
+	}
+	return xozmakId, err
+}
+
+func (s settlementController) CreateCommissionRule(ctx context.Context, rule entities.CommissionRule) (entities.CommissionRule, error) {
+	s.log.Info("CreateCommissionRule started: ", zap.String("Type", rule.Type))
+
+	now := time.Now()
+	rule.ID = uuid.NewString()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	if err := s.storage.Settlement().CreateCommissionRule(ctx, rule); err != nil {
+		return entities.CommissionRule{}, s.internalError("CreateCommissionRule", err)
+	}
+
+	s.log.Info("CreateCommissionRule finished", zap.String("RuleID", rule.ID))
+	return rule, nil
+}
+
+func (s settlementController) GetCommissionRules(ctx context.Context) ([]entities.CommissionRule, error) {
+	s.log.Info("GetCommissionRules started")
+
+	rules, err := s.storage.Settlement().GetCommissionRules(ctx)
+	if err != nil {
+		return nil, s.internalError("GetCommissionRules", err)
+	}
+
+	s.log.Info("GetCommissionRules finished")
+	return rules, nil
+}
+
+func (s settlementController) UpdateCommissionRule(ctx context.Context, rule entities.CommissionRule) error {
+	s.log.Info("UpdateCommissionRule started: ", zap.String("RuleID", rule.ID))
+
+	if err := s.storage.Settlement().UpdateCommissionRule(ctx, rule); err != nil {
+		return s.internalError("UpdateCommissionRule", err)
+	}
+
+	s.log.Info("UpdateCommissionRule finished")
+	return nil
+}
+
+func (s settlementController) DeleteCommissionRule(ctx context.Context, id string) error {
+	s.log.Info("DeleteCommissionRule started: ", zap.String("RuleID", id))
+
+	if err := s.storage.Settlement().DeleteCommissionRule(ctx, id); err != nil {
+		return s.internalError("DeleteCommissionRule", err)
+	}
+
+	s.log.Info("DeleteCommissionRule finished")
+	return nil
+}
+
+// RecordCommission computes the commission of a delivered order with the rules of the
+// moment, later changes of the rules do not touch it
+func (s settlementController) RecordCommission(ctx context.Context, order entities.Order) error {
+	rules, err := s.storage.Settlement().GetCommissionRules(ctx)
+	if err != nil {
+		return err
+	}
+	productIds := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		productIds = append(productIds, item.ProductID)
+	}
+	categories, err := s.storage.Settlement().GetProductCategories(ctx, productIds)
+	if err != nil {
+		return err
+	}
+
+	// the commission belongs to the period of the delivery even when it is recorded later
+	createdAt := time.Now()
+	if order.DeliveredAt != nil {
+		createdAt = *order.DeliveredAt
+	}
+	commission := orderCommission(order, categories, rules, s.cfg.DefaultCommissionPercent)
+	err = s.storage.Settlement().RecordOrderCommission(ctx, entities.OrderCommission{
+		OrderID:    order.ID,
+		XozmakID:   order.XozmakID,
+		Gross:      order.ItemsTotal,
+		Commission: commission,
+		CreatedAt:  createdAt,
+	})
+	if err != nil {
+		return err
+	}
+	s.log.Info("commission recorded", zap.String("OrderID", order.ID), zap.Int64("Commission", commission))
+	return nil
+}
+
+// backfillCommissions records the commissions that failed to be recorded on delivery, so that
+// no delivered order is left out of the settlement. An order that fails again waits for the next run.
+func (s settlementController) backfillCommissions(ctx context.Context, before time.Time) {
+	orderIds, err := s.storage.Settlement().GetUncommissionedOrderIDs(ctx, before)
+	if err != nil {
+		s.log.Error("error in backfillCommissions: ", zap.Error(err))
+		return
+	}
+	for _, orderId := range orderIds {
+		order, err := s.storage.Order().GetOrder(ctx, orderId)
+		if err == nil {
+			err = s.RecordCommission(ctx, order)
+		}
+		if err != nil {
+			s.log.Error("error in backfillCommissions: ", zap.String("OrderID", orderId), zap.Error(err))
+		}
+	}
+}
+
+// CreateSettlements settles every xozmak for the orders delivered before the period end
+func (s settlementController) CreateSettlements(ctx context.Context, req entities.SettlementsReq) ([]entities.Settlement, error) {
+	s.log.Info("CreateSettlements started: ", zap.String("AdminID", req.Actor.ID))
+
+	periodEnd := time.Now()
+	if req.PeriodEnd != nil && req.PeriodEnd.Before(periodEnd) {
+		periodEnd = *req.PeriodEnd
+	}
+	var createdBy *string
+	if req.Actor.ID != "" {
+		createdBy = &req.Actor.ID
+	}
+
+	s.backfillCommissions(ctx, periodEnd)
+
+	settlements, err := s.storage.Settlement().CreateSettlements(ctx, periodEnd, createdBy)
+	if err != nil {
+		return nil, s.internalError("CreateSettlements", err)
+	}
+
+	s.log.Info("CreateSettlements finished", zap.Int("Settlements", len(settlements)))
+	return settlements, nil
+}
+
+// GetSettlements lists the settlements of all xozmaks for admins and of their own xozmak for sellers
+func (s settlementController) GetSettlements(ctx context.Context, actor entities.Actor, filter entities.SettlementFilter) (entities.SettlementList, error) {
+	s.log.Info("GetSettlements started: ",
+		zap.String("Request: ", fmt.Sprintf("ActorID: %s, XozmakID: %s, Status: %s", actor.ID, filter.XozmakID, filter.Status)))
+
+	xozmakId, err := s.sellerXozmak(ctx, actor)
+	if err != nil {
+		return entities.SettlementList{}, s.internalError("GetSettlements", err)
+	}
+	if xozmakId != "" {
+		filter.XozmakID = xozmakId
+	}
+
+	settlements, count, err := s.storage.Settlement().GetSettlements(ctx, filter)
+	if err != nil {
+		return entities.SettlementList{}, s.internalError("GetSettlements", err)
+	}
+
+	s.log.Info("GetSettlements finished")
+	return entities.SettlementList{Settlements: settlements, Count: count}, nil
+}
+
+func (s settlementController) GetSettlement(ctx context.Context, actor entities.Actor, id string) (entities.Settlement, error) {
+	s.log.Info("GetSettlement started: ", zap.String("SettlementID", id))
+
+	xozmakId, err := s.sellerXozmak(ctx, actor)
+	if err != nil {
+		return entities.Settlement{}, s.internalError("GetSettlement", err)
+	}
+	settlement, err := s.storage.Settlement().GetSettlement(ctx, id)
+	if err != nil {
+		return entities.Settlement{}, s.internalError("GetSettlement", err)
+	}
+	if xozmakId != "" && settlement.XozmakID != xozmakId {
+		return entities.Settlement{}, e.ErrSettlementNotFound
+	}
+
+	s.log.Info("GetSettlement finished")
+	return settlement, nil
+}
+
+// ExportSettlement renders the orders and refunds of the settlement as a CSV file
+func (s settlementController) ExportSettlement(ctx context.Context, actor entities.Actor, id string) ([]byte, error) {
+	settlement, err := s.GetSettlement(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{{"line", "order_id", "order_number", "date", "gross", "commission", "refund", "net"}}
+	for _, line := range settlement.OrderLines {
+		rows = append(rows, []string{
+			"order",
+			line.OrderID,
+			strconv.FormatInt(line.OrderNumber, 10),
+			line.CreatedAt.In(s.cfg.TimeZone).Format(time.DateOnly),
+			strconv.FormatInt(line.Gross, 10),
+			strconv.FormatInt(line.Commission, 10),
+			"0",
+			strconv.FormatInt(line.Gross-line.Commission, 10),
+		})
+	}
+	for _, line := range settlement.RefundLines {
+		rows = append(rows, []string{
+			"refund",
+			line.OrderID,
+			"",
+			line.CreatedAt.In(s.cfg.TimeZone).Format(time.DateOnly),
+			"0",
+			"0",
+			strconv.FormatInt(line.Amount, 10),
+			strconv.FormatInt(-line.Amount, 10),
+		})
+	}
+	rows = append(rows, []string{
+		"total",
+		settlement.XozmakName,
+		strconv.Itoa(settlement.Orders),
+		settlement.PeriodEnd.In(s.cfg.TimeZone).Format(time.DateOnly),
+		strconv.FormatInt(settlement.Gross, 10),
+		strconv.FormatInt(settlement.Commission, 10),
+		strconv.FormatInt(settlement.Refunds, 10),
+		strconv.FormatInt(settlement.NetPayable, 10),
+	})
+	if err := w.WriteAll(rows); err != nil {
+		return nil, s.internalError("ExportSettlement", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// MarkSettled records that the net payable was paid out to the xozmak
+func (s settlementController) MarkSettled(ctx context.Context, actor entities.Actor, id string) (entities.Settlement, error) {
+	s.log.Info("MarkSettled started: ",
+		zap.String("Request: ", fmt.Sprintf("SettlementID: %s, AdminID: %s", id, actor.ID)))
+
+	if err := s.storage.Settlement().MarkSettlementSettled(ctx, id, actor.ID); err != nil {
+		return entities.Settlement{}, s.internalError("MarkSettled", err)
+	}
+	settlement, err := s.storage.Settlement().GetSettlement(ctx, id)
+	if err != nil {
+		return entities.Settlement{}, s.internalError("MarkSettled", err)
+	}
+
+	s.log.Info("MarkSettled finished")
+	return settlement, nil
+}
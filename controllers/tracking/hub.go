@@ -0,0 +1,143 @@
+package tracking
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/logger"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// hub holds the one Redis subscription of the process and hands its messages
+// to the streams following each channel, so the number of Redis connections
+// does not grow with the number of connected customers
+type hub struct {
+	log   logger.LoggerI
+	redis *redis.Client
+
+	mu      sync.Mutex
+	started bool
+	// followers are the subscriptions of every followed channel
+	followers map[string]map[*Subscription]bool
+}
+
+func newHub(log logger.LoggerI, redis *redis.Client) *hub {
+	return &hub{
+		log:       log,
+		redis:     redis,
+		followers: make(map[string]map[*Subscription]bool),
+	}
+}
+
+// start subscribes to all order and courier channels once, it returns only after
+// Redis confirmed the subscription so nothing published afterwards is lost
+func (h *hub) start(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.started {
+		return nil
+	}
+	pubsub := h.redis.PSubscribe(context.Background(),
+		constants.OrderEventsChannelPrefix+"*",
+		constants.CourierEventsChannelPrefix+"*",
+	)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	h.started = true
+	go h.run(pubsub)
+	return nil
+}
+
+func (h *hub) run(pubsub *redis.PubSub) {
+	for msg := range pubsub.Channel() {
+		h.deliver(msg)
+	}
+}
+
+// deliver passes the message to the followers of its channel. A follower whose
+// buffer is full is closed instead of holding up the others, its client
+// reconnects and starts again from the current state.
+func (h *hub) deliver(msg *redis.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.followers[msg.Channel] {
+		select {
+		case sub.messages <- msg:
+		default:
+			h.log.Warn("tracking subscription is too slow, closing it", zap.String("Channel", msg.Channel))
+			h.close(sub)
+		}
+	}
+}
+
+// close must be called with mu held
+func (h *hub) close(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	for channel := range sub.channels {
+		h.unfollow(sub, channel)
+	}
+	close(sub.messages)
+}
+
+// unfollow must be called with mu held
+func (h *hub) unfollow(sub *Subscription, channel string) {
+	delete(sub.channels, channel)
+	delete(h.followers[channel], sub)
+	if len(h.followers[channel]) == 0 {
+		delete(h.followers, channel)
+	}
+}
+
+// Subscription is one stream's share of the process subscription, it receives
+// the messages of the channels it follows until it is closed
+type Subscription struct {
+	hub      *hub
+	messages chan *redis.Message
+	// channels and closed are guarded by hub.mu
+	channels map[string]bool
+	closed   bool
+}
+
+// Channel is closed when the subscription is closed
+func (s *Subscription) Channel() <-chan *redis.Message {
+	return s.messages
+}
+
+func (s *Subscription) Follow(channel string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if s.closed || s.channels[channel] {
+		return
+	}
+	s.channels[channel] = true
+	if s.hub.followers[channel] == nil {
+		s.hub.followers[channel] = make(map[*Subscription]bool)
+	}
+	s.hub.followers[channel][s] = true
+}
+
+func (s *Subscription) Unfollow(channel string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if s.channels[channel] {
+		s.hub.unfollow(s, channel)
+	}
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.close(s)
+}
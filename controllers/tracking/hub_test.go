@@ -0,0 +1,153 @@
+package tracking
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/logger"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// testRedis connects to the Redis at TEST_REDIS_ADDR or localhost and empties DB 15 for the test
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis is not available at %s: %v", addr, err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatalf("flush redis: %v", err)
+	}
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+	})
+	return client
+}
+
+func receive(t *testing.T, sub *Subscription) *redis.Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-sub.Channel():
+		if !ok {
+			t.Fatalf("subscription is closed")
+		}
+		return msg
+	case <-time.After(time.Second * 2):
+		t.Fatalf("no message received")
+	}
+	return nil
+}
+
+func nothingReceived(t *testing.T, sub *Subscription) {
+	t.Helper()
+
+	select {
+	case msg := <-sub.Channel():
+		t.Fatalf("unexpected message %v", msg)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestStreamsShareOneSubscription(t *testing.T) {
+	client := testRedis(t)
+	ctx := context.Background()
+	tracker := NewTrackingController(logger.NewLogger("test", "error"), client).(trackingController)
+
+	first, err := tracker.Subscribe(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer first.Close()
+	second, err := tracker.Subscribe(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer second.Close()
+	other, err := tracker.Subscribe(ctx, "order-2")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer other.Close()
+
+	if n := client.PubSubNumPat(ctx).Val(); n != 2 {
+		t.Fatalf("%d pattern subscriptions, want 2", n)
+	}
+
+	// both streams of the order get its events, the other order does not
+	tracker.PublishOrderEvent(ctx, entities.OrderEvent{Type: constants.OrderEventStatus, OrderID: "order-1", Status: constants.OrderStatusReady})
+	for _, sub := range []*Subscription{first, second} {
+		var event entities.OrderEvent
+		if err := json.Unmarshal([]byte(receive(t, sub).Payload), &event); err != nil {
+			t.Fatalf("event: %v", err)
+		}
+		if event.OrderID != "order-1" || event.Status != constants.OrderStatusReady {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+	nothingReceived(t, other)
+
+	// courier positions reach only the streams following the courier
+	first.Follow(CourierChannel("courier-1"))
+	tracker.PublishPosition(ctx, "courier-1", entities.LocationPoint{Lat: 41.3, Long: 69.2})
+	if msg := receive(t, first); msg.Channel != CourierChannel("courier-1") {
+		t.Fatalf("message from %s", msg.Channel)
+	}
+	nothingReceived(t, second)
+
+	first.Unfollow(CourierChannel("courier-1"))
+	tracker.PublishPosition(ctx, "courier-1", entities.LocationPoint{Lat: 41.3, Long: 69.2})
+	nothingReceived(t, first)
+
+	// a closed stream is forgotten
+	second.Close()
+	if _, ok := <-second.Channel(); ok {
+		t.Fatalf("closed subscription still receives")
+	}
+	if _, ok := tracker.hub.followers[OrderChannel("order-1")][second]; ok {
+		t.Fatalf("closed subscription still follows the order")
+	}
+}
+
+func TestSlowStreamIsClosed(t *testing.T) {
+	client := testRedis(t)
+	ctx := context.Background()
+	tracker := NewTrackingController(logger.NewLogger("test", "error"), client).(trackingController)
+
+	slow, err := tracker.Subscribe(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer slow.Close()
+	fast, err := tracker.Subscribe(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer fast.Close()
+
+	// slow never reads, fast keeps up
+	for i := 0; i <= constants.TrackingSubscriptionBuffer; i++ {
+		tracker.PublishOrderEvent(ctx, entities.OrderEvent{Type: constants.OrderEventStatus, OrderID: "order-1"})
+		receive(t, fast)
+	}
+
+	received := 0
+	for range slow.Channel() {
+		received++
+	}
+	if received != constants.TrackingSubscriptionBuffer {
+		t.Fatalf("slow stream received %d messages before it was closed, want %d", received, constants.TrackingSubscriptionBuffer)
+	}
+}
@@ -0,0 +1,83 @@
+package tracking
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	"delivery/logger"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// TrackingController fans order events out through Redis pub/sub, so a customer
+// connected to any replica gets updates made on the others
+type TrackingController interface {
+	PublishOrderEvent(ctx context.Context, event entities.OrderEvent)
+	PublishPosition(ctx context.Context, courierId string, point entities.LocationPoint)
+	Subscribe(ctx context.Context, orderId string) (*Subscription, error)
+}
+
+type trackingController struct {
+	log   logger.LoggerI
+	redis *redis.Client
+	hub   *hub
+}
+
+func NewTrackingController(log logger.LoggerI, redis *redis.Client) TrackingController {
+	return trackingController{
+		log:   log,
+		redis: redis,
+		hub:   newHub(log, redis),
+	}
+}
+
+func OrderChannel(orderId string) string {
+	return constants.OrderEventsChannelPrefix + orderId
+}
+
+func CourierChannel(courierId string) string {
+	return constants.CourierEventsChannelPrefix + courierId
+}
+
+// PublishOrderEvent sends the event to everybody following the order. Tracking is
+// best effort, a failed publish is logged and never fails the change itself.
+func (t trackingController) PublishOrderEvent(ctx context.Context, event entities.OrderEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	t.publish(ctx, OrderChannel(event.OrderID), event)
+}
+
+// PublishPosition sends the courier position to streams of the orders they carry
+func (t trackingController) PublishPosition(ctx context.Context, courierId string, point entities.LocationPoint) {
+	t.publish(ctx, CourierChannel(courierId), point)
+}
+
+func (t trackingController) publish(ctx context.Context, channel string, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.log.Error("error in publish: ", zap.Error(err))
+		return
+	}
+	if err := t.redis.Publish(ctx, channel, data).Err(); err != nil {
+		t.log.Error("error in publish: ", zap.String("Channel", channel), zap.Error(err))
+	}
+}
+
+// Subscribe follows the order channel on the shared subscription of the process, the caller
+// follows the courier channel once the courier is known and must close the subscription
+func (t trackingController) Subscribe(ctx context.Context, orderId string) (*Subscription, error) {
+	if err := t.hub.start(ctx); err != nil {
+		return nil, err
+	}
+	sub := &Subscription{
+		hub:      t.hub,
+		messages: make(chan *redis.Message, constants.TrackingSubscriptionBuffer),
+		channels: make(map[string]bool),
+	}
+	sub.Follow(OrderChannel(orderId))
+	return sub, nil
+}
@@ -0,0 +1,256 @@
+package wallet
+
+import (
+	"context"
+	"delivery/configs"
+	"delivery/constants"
+	trackingcontroller "delivery/controllers/tracking"
+	"delivery/entities"
+	e "delivery/errors"
+	"delivery/logger"
+	pkgerrors "delivery/pkg/errors"
+	"delivery/storage"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type WalletController interface {
+	GetWallet(ctx context.Context, userId string) (entities.Wallet, error)
+	GetWalletHistory(ctx context.Context, userId string, txType *int, limit, page int) (entities.WalletHistory, error)
+	PayOrder(ctx context.Context, actor entities.Actor, orderId string) (entities.Payment, error)
+	PayTip(ctx context.Context, order entities.Order, amount int64) (entities.Payment, error)
+	AddCashback(ctx context.Context, order entities.Order) error
+	RunBackfillWorker(ctx context.Context)
+	CreditWallet(ctx context.Context, req entities.WalletCreditReq) (entities.WalletTransaction, error)
+}
+
+type walletController struct {
+	log     logger.LoggerI
+	storage storage.Storage
+	cfg     *configs.Configuration
+	tracker trackingcontroller.TrackingController
+}
+
+func NewWalletController(log logger.LoggerI, storage storage.Storage, tracker trackingcontroller.TrackingController) WalletController {
+	return walletController{
+		log:     log,
+		storage: storage,
+		cfg:     configs.Config(),
+		tracker: tracker,
+	}
+}
+
+// internalError logs err and hides it from the client unless it is a status error
+func (w walletController) internalError(method string, err error) error {
+	if _, ok := pkgerrors.ExtractStatusCode(err); ok {
+		return err
+	}
+	w.log.Error("error in "+method+": ", zap.Error(err))
+	return status.Error(codes.Internal, "internal server error")
+}
+
+func (w walletController) GetWallet(ctx context.Context, userId string) (entities.Wallet, error) {
+	w.log.Info("GetWallet started: ", zap.String("UserID", userId))
+
+	balance, err := w.storage.Wallet().GetWalletBalance(ctx, userId)
+	if err != nil {
+		return entities.Wallet{}, w.internalError("GetWallet", err)
+	}
+
+	w.log.Info("GetWallet finished")
+	return entities.Wallet{UserID: userId, Balance: balance}, nil
+}
+
+func (w walletController) GetWalletHistory(ctx context.Context, userId string, txType *int, limit, page int) (entities.WalletHistory, error) {
+	w.log.Info("GetWalletHistory started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, Limit: %d, Page: %d", userId, limit, page)))
+
+	if txType != nil && !entities.ValidTransactionType(*txType) {
+		return entities.WalletHistory{}, e.ErrInvalidTransactionType
+	}
+	transactions, count, err := w.storage.Wallet().GetWalletTransactions(ctx, userId, txType, limit, (page-1)*limit)
+	if err != nil {
+		return entities.WalletHistory{}, w.internalError("GetWalletHistory", err)
+	}
+
+	w.log.Info("GetWalletHistory finished")
+	return entities.WalletHistory{Transactions: transactions, Count: count}, nil
+}
+
+// PayOrder pays the order waiting for the payment from the customer's wallet
+func (w walletController) PayOrder(ctx context.Context, actor entities.Actor, orderId string) (entities.Payment, error) {
+	w.log.Info("Wallet PayOrder started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, ActorID: %s", orderId, actor.ID)))
+
+	order, err := w.storage.Order().GetOrder(ctx, orderId)
+	if err != nil {
+		return entities.Payment{}, w.internalError("Wallet PayOrder", err)
+	}
+	if order.UserID != actor.ID {
+		return entities.Payment{}, e.ErrOrderForbidden
+	}
+	if order.Status != constants.OrderStatusAwaitingPayment {
+		return entities.Payment{}, e.ErrOrderNotAwaitingPayment
+	}
+
+	now := time.Now()
+	pay := entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Provider:  constants.PaymentProviderWallet,
+		Amount:    order.Total,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	pay.ProviderPaymentID = &pay.ID
+	transaction := entities.WalletDebit(pay.ID, constants.WalletKindOrderPayment, order.UserID, &order.ID,
+		order.Total, constants.LedgerAccountOrderRevenue, fmt.Sprintf("order #%d", order.Number))
+	err = w.storage.Wallet().PayOrderFromWallet(ctx, transaction, pay, entities.OrderHistory{
+		OrderID:    order.ID,
+		Action:     constants.OrderActionPaid,
+		FromStatus: constants.OrderStatusAwaitingPayment,
+		ToStatus:   constants.OrderStatusNew,
+		ActorRole:  actor.Role,
+		ActorID:    &actor.ID,
+		Note:       constants.PaymentProviderWallet,
+	})
+	if errors.Is(err, e.ErrWalletTransactionExists) {
+		// paid by a concurrent request
+		return entities.Payment{}, e.ErrOrderNotAwaitingPayment
+	}
+	if err != nil {
+		return entities.Payment{}, w.internalError("Wallet PayOrder", err)
+	}
+	pay.Status = constants.PaymentStatusPaid
+	pay.PaidAt = &now
+
+	w.tracker.PublishOrderEvent(ctx, entities.OrderEvent{
+		Type:    constants.OrderEventStatus,
+		OrderID: order.ID,
+		Status:  constants.OrderStatusNew,
+	})
+	w.log.Info("Wallet PayOrder finished", zap.String("PaymentID", pay.ID))
+	return pay, nil
+}
+
+// PayTip spends the tip for the courier of the delivered order from the customer's wallet
+func (w walletController) PayTip(ctx context.Context, order entities.Order, amount int64) (entities.Payment, error) {
+	w.log.Info("Wallet PayTip started: ",
+		zap.String("Request: ", fmt.Sprintf("OrderID: %s, Amount: %d", order.ID, amount)))
+
+	if order.CourierID == nil {
+		return entities.Payment{}, e.ErrOrderNotDelivered
+	}
+
+	now := time.Now()
+	pay := entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Purpose:   constants.PaymentPurposeTip,
+		Provider:  constants.PaymentProviderWallet,
+		Amount:    amount,
+		Status:    constants.PaymentStatusPaid,
+		CreatedAt: now,
+		UpdatedAt: now,
+		PaidAt:    &now,
+	}
+	pay.ProviderPaymentID = &pay.ID
+	note := fmt.Sprintf("order #%d tip", order.Number)
+	transaction := entities.WalletDebit(pay.ID, constants.WalletKindTip, order.UserID, &order.ID,
+		amount, constants.LedgerAccountTipsHeld, note)
+	tip := entities.CourierEarning(uuid.NewString(), constants.LedgerKindTip, *order.CourierID, &order.ID,
+		amount, constants.LedgerAccountTipsHeld, note)
+	if err := w.storage.Wallet().PayTipFromWallet(ctx, transaction, pay, tip); err != nil {
+		return entities.Payment{}, w.internalError("Wallet PayTip", err)
+	}
+
+	w.log.Info("Wallet PayTip finished", zap.String("PaymentID", pay.ID))
+	return pay, nil
+}
+
+// AddCashback gives the customer CashbackPercent of the items total of the delivered order,
+// an order gets its cashback only once
+func (w walletController) AddCashback(ctx context.Context, order entities.Order) error {
+	amount := order.ItemsTotal * w.cfg.CashbackPercent / 100
+	if amount <= 0 {
+		return nil
+	}
+	transaction := entities.WalletCredit(uuid.NewString(), constants.WalletKindCashback, order.UserID, &order.ID,
+		amount, constants.LedgerAccountCashbackExpense, fmt.Sprintf("order #%d", order.Number))
+	err := w.storage.Wallet().PostWalletTransaction(ctx, transaction)
+	if errors.Is(err, e.ErrWalletTransactionExists) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add cashback: %w", err)
+	}
+	w.log.Info("cashback added", zap.String("OrderID", order.ID), zap.Int64("Amount", amount))
+	return nil
+}
+
+// RunBackfillWorker gives the cashback that failed to be given on delivery until ctx is done
+func (w walletController) RunBackfillWorker(ctx context.Context) {
+	ticker := time.NewTicker(constants.BackfillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.backfillCashback(ctx)
+		}
+	}
+}
+
+// backfillCashback gives the cashback of delivered orders that have none,
+// an order that fails again waits for the next run
+func (w walletController) backfillCashback(ctx context.Context) {
+	if w.cfg.CashbackPercent <= 0 {
+		return
+	}
+	// smaller orders get no cashback at all
+	minItemsTotal := (100 + w.cfg.CashbackPercent - 1) / w.cfg.CashbackPercent
+	orderIds, err := w.storage.Wallet().GetOrderIDsWithoutCashback(ctx, time.Now().Add(-constants.BackfillWindow),
+		minItemsTotal, constants.BackfillBatchLimit)
+	if err != nil {
+		w.log.Error("error in backfillCashback: ", zap.Error(err))
+		return
+	}
+	for _, orderId := range orderIds {
+		order, err := w.storage.Order().GetOrder(ctx, orderId)
+		if err == nil {
+			err = w.AddCashback(ctx, order)
+		}
+		if err != nil {
+			w.log.Error("error in backfillCashback: ", zap.String("OrderID", orderId), zap.Error(err))
+		}
+	}
+}
+
+// CreditWallet puts money into the customer's wallet by an admin
+func (w walletController) CreditWallet(ctx context.Context, req entities.WalletCreditReq) (entities.WalletTransaction, error) {
+	w.log.Info("CreditWallet started: ",
+		zap.String("Request: ", fmt.Sprintf("UserID: %s, Kind: %s, Amount: %d", req.UserID, req.Kind, req.Amount)))
+
+	from := constants.LedgerAccountWalletTopUp
+	if req.Kind == constants.WalletKindRefund {
+		from = constants.LedgerAccountOrderRevenue
+	}
+	transaction := entities.WalletCredit(uuid.NewString(), req.Kind, req.UserID, req.OrderID, req.Amount, from, req.Note)
+	transaction.CreatedBy = &req.Actor.ID
+	if err := w.storage.Wallet().PostWalletTransaction(ctx, transaction); err != nil {
+		return entities.WalletTransaction{}, w.internalError("CreditWallet", err)
+	}
+
+	w.log.Info("CreditWallet finished", zap.String("TransactionID", transaction.ID))
+	return transaction, nil
+}
@@ -0,0 +1,14 @@
+CREATE TABLE products (
+    id uuid not null PRIMARY KEY,
+    xozmak_id uuid not null REFERENCES xozmaks(id),
+    sub_category_id uuid REFERENCES sub_category(id),
+    name VARCHAR(200) NOT NULL,
+    photo VARCHAR,
+    price BIGINT NOT NULL CHECK (price >= 0),
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    state numeric(1) not null DEFAULT 1,
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX products_xozmak_id_idx ON products(xozmak_id);
@@ -0,0 +1,15 @@
+CREATE TABLE delivery_slots (
+    id uuid not null PRIMARY KEY,
+    xozmak_id uuid not null REFERENCES xozmaks(id),
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    capacity INTEGER NOT NULL CHECK (capacity >= 0),
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= capacity),
+    state numeric(1) not null DEFAULT 1,
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX delivery_slots_xozmak_id_starts_at_idx ON delivery_slots(xozmak_id, starts_at);
@@ -0,0 +1,32 @@
+CREATE TABLE orders (
+    id uuid not null PRIMARY KEY,
+    number BIGSERIAL UNIQUE,
+    user_id uuid NOT NULL REFERENCES users(id),
+    xozmak_id uuid NOT NULL REFERENCES xozmaks(id),
+    slot_id uuid REFERENCES delivery_slots(id),
+    location_id BIGINT REFERENCES users_locations(id),
+    address_name VARCHAR(255),
+    address_location json NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'new',
+    items_total BIGINT NOT NULL,
+    delivery_fee BIGINT NOT NULL DEFAULT 0,
+    total BIGINT NOT NULL,
+    payment_method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    comment VARCHAR,
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX orders_user_id_idx ON orders(user_id, created_at DESC);
+CREATE INDEX orders_xozmak_id_status_idx ON orders(xozmak_id, status);
+
+CREATE TABLE order_items (
+    id uuid not null PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(id),
+    product_id uuid NOT NULL REFERENCES products(id),
+    name VARCHAR(200) NOT NULL,
+    price BIGINT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX order_items_order_id_idx ON order_items(order_id);
@@ -0,0 +1,35 @@
+-- sellers are linked to the xozmak they work for
+ALTER TABLE users
+     ADD xozmak_id uuid REFERENCES xozmaks(id);
+
+ALTER TABLE orders
+     ADD courier_id uuid REFERENCES users(id),
+     ADD cancel_fee BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE order_history (
+    id uuid not null DEFAULT uuid_generate_v4() PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(id),
+    action VARCHAR(30) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    actor_role VARCHAR(20) NOT NULL,
+    actor_id uuid,
+    reason_code VARCHAR(50),
+    fee BIGINT NOT NULL DEFAULT 0,
+    note VARCHAR,
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX order_history_order_id_idx ON order_history(order_id, created_at);
+
+CREATE TABLE refunds (
+    id uuid not null PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    payment_method VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refunds_status_idx ON refunds(status);
@@ -0,0 +1,18 @@
+ALTER TABLE orders
+     ADD prep_minutes INTEGER,
+     ADD accepted_at TIMESTAMP,
+     ADD ready_at TIMESTAMP;
+
+CREATE INDEX orders_status_created_at_idx ON orders(status, created_at);
+
+CREATE TABLE notifications (
+    id uuid not null PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES users(id),
+    title VARCHAR(255) NOT NULL,
+    body VARCHAR NOT NULL,
+    order_id uuid REFERENCES orders(id),
+    is_read BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP not null DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications(user_id, created_at DESC);
@@ -0,0 +1,24 @@
+ALTER TYPE role ADD VALUE IF NOT EXISTS 'courier';
+
+CREATE TABLE couriers (
+    user_id uuid NOT NULL PRIMARY KEY REFERENCES users(id),
+    vehicle_type VARCHAR(20) NOT NULL,
+    documents json,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    blocked_reason VARCHAR,
+    is_online BOOLEAN NOT NULL DEFAULT false,
+    state numeric(2) NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE courier_shifts (
+    id uuid NOT NULL DEFAULT uuid_generate_v4() PRIMARY KEY,
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ended_at TIMESTAMPTZ
+);
+
+-- a courier can have only one open shift
+CREATE UNIQUE INDEX courier_shifts_open_idx ON courier_shifts(courier_id) WHERE ended_at IS NULL;
+CREATE INDEX courier_shifts_courier_id_idx ON courier_shifts(courier_id, started_at);
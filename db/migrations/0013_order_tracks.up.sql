@@ -0,0 +1,15 @@
+-- downsampled courier positions recorded while an order is being delivered
+CREATE TABLE order_tracks (
+    id BIGSERIAL PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(id),
+    courier_id uuid NOT NULL REFERENCES users(id),
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    accuracy DOUBLE PRECISION,
+    speed DOUBLE PRECISION,
+    heading DOUBLE PRECISION,
+    recorded_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX order_tracks_order_id_idx ON order_tracks(order_id, recorded_at);
+CREATE INDEX orders_courier_id_idx ON orders(courier_id, status);
@@ -0,0 +1,2 @@
+ALTER TABLE couriers
+     ADD rating NUMERIC(3,2) NOT NULL DEFAULT 5;
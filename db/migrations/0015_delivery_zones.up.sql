@@ -0,0 +1,30 @@
+CREATE TABLE delivery_zones (
+    id uuid NOT NULL PRIMARY KEY,
+    xozmak_id uuid NOT NULL REFERENCES xozmaks(id),
+    name VARCHAR NOT NULL,
+    area json NOT NULL,
+    delivery_fee BIGINT NOT NULL DEFAULT 0 CHECK (delivery_fee >= 0),
+    min_order BIGINT NOT NULL DEFAULT 0 CHECK (min_order >= 0),
+    state numeric(1) NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX delivery_zones_xozmak_id_idx ON delivery_zones(xozmak_id) WHERE state = 1;
+
+ALTER TABLE orders
+     ADD delivery_zone_id uuid REFERENCES delivery_zones(id);
+
+-- existing xozmaks keep delivering roughly within the old 10 km radius until admins draw real zones
+INSERT INTO delivery_zones (id, xozmak_id, name, area)
+SELECT uuid_generate_v4(), x.id, 'default',
+       json_build_object('type', 'Polygon', 'coordinates', json_build_array(json_build_array(
+           json_build_array(lng - 0.12, lat - 0.09),
+           json_build_array(lng + 0.12, lat - 0.09),
+           json_build_array(lng + 0.12, lat + 0.09),
+           json_build_array(lng - 0.12, lat + 0.09),
+           json_build_array(lng - 0.12, lat - 0.09)
+       )))
+  FROM (SELECT id, (location->>'lat')::float8 AS lat, (location->>'long')::float8 AS lng
+          FROM xozmaks
+         WHERE state = 1 AND location IS NOT NULL) x;
@@ -0,0 +1,15 @@
+ALTER TABLE orders
+     ADD handoff_code VARCHAR(4),
+     ADD picked_up_at TIMESTAMPTZ,
+     ADD delivered_at TIMESTAMPTZ;
+
+CREATE TABLE delivery_proofs (
+    order_id uuid NOT NULL PRIMARY KEY REFERENCES orders(id),
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    method VARCHAR(10) NOT NULL,
+    photo_url VARCHAR,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    accuracy DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
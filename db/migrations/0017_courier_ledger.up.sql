@@ -0,0 +1,50 @@
+ALTER TABLE couriers
+     ADD payout_account VARCHAR(34);
+
+CREATE TABLE payout_batches (
+    id uuid NOT NULL PRIMARY KEY,
+    created_by uuid,
+    period_end TIMESTAMPTZ NOT NULL,
+    total BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE payouts (
+    id uuid NOT NULL PRIMARY KEY,
+    batch_id uuid NOT NULL REFERENCES payout_batches(id),
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    payout_account VARCHAR(34),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX payouts_batch_id_idx ON payouts(batch_id);
+
+-- amount is the change of what the platform owes the courier, entries always sum up to zero
+CREATE TABLE ledger_transactions (
+    id uuid NOT NULL PRIMARY KEY,
+    kind VARCHAR(30) NOT NULL,
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    order_id uuid REFERENCES orders(id),
+    payout_id uuid REFERENCES payouts(id),
+    amount BIGINT NOT NULL,
+    note VARCHAR,
+    created_by uuid,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ledger_transactions_courier_id_idx ON ledger_transactions(courier_id, created_at);
+CREATE INDEX ledger_transactions_unpaid_idx ON ledger_transactions(created_at) WHERE payout_id IS NULL;
+-- an order is paid out to the courier only once
+CREATE UNIQUE INDEX ledger_transactions_order_kind_idx ON ledger_transactions(order_id, kind)
+    WHERE kind IN ('delivery_base', 'delivery_distance', 'tip');
+
+CREATE TABLE ledger_entries (
+    id uuid NOT NULL DEFAULT uuid_generate_v4() PRIMARY KEY,
+    transaction_id uuid NOT NULL REFERENCES ledger_transactions(id),
+    account VARCHAR(100) NOT NULL,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ledger_entries_account_idx ON ledger_entries(account, created_at);
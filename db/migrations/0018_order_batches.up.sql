@@ -0,0 +1,12 @@
+CREATE TABLE order_batches (
+    id uuid NOT NULL PRIMARY KEY,
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- orders of a batch are picked up and delivered in the order of batch_seq
+ALTER TABLE orders
+     ADD batch_id uuid REFERENCES order_batches(id),
+     ADD batch_seq INT;
+
+CREATE INDEX orders_batch_id_idx ON orders(batch_id) WHERE batch_id IS NOT NULL;
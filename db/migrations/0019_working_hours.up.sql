@@ -0,0 +1,30 @@
+ALTER TABLE xozmaks
+     ADD orders_paused BOOLEAN NOT NULL DEFAULT false,
+     ADD allow_preorders BOOLEAN NOT NULL DEFAULT false;
+
+-- weekday is 0 for Sunday, times are HH:MM in Asia/Tashkent and closes_at not after
+-- opens_at means the xozmak closes after midnight
+CREATE TABLE working_hours (
+    id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+    xozmak_id uuid NOT NULL REFERENCES xozmaks(id),
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens_at VARCHAR(5) NOT NULL,
+    closes_at VARCHAR(5) NOT NULL
+);
+
+CREATE INDEX working_hours_xozmak_id_idx ON working_hours(xozmak_id);
+
+-- exceptions replace the weekly hours of one date, without a xozmak they are
+-- holidays of all xozmaks
+CREATE TABLE hours_exceptions (
+    id uuid NOT NULL PRIMARY KEY,
+    xozmak_id uuid REFERENCES xozmaks(id),
+    date DATE NOT NULL,
+    closed BOOLEAN NOT NULL,
+    opens_at VARCHAR(5),
+    closes_at VARCHAR(5),
+    note VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX hours_exceptions_xozmak_date_idx ON hours_exceptions(COALESCE(xozmak_id, '00000000-0000-0000-0000-000000000000'), date);
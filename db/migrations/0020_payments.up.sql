@@ -0,0 +1,28 @@
+ALTER TABLE orders
+     ADD paid_at TIMESTAMP;
+
+-- every attempt to pay an order online is a payment, the order is paid by the first one that succeeds
+CREATE TABLE payments (
+    id uuid NOT NULL PRIMARY KEY,
+    order_id uuid NOT NULL REFERENCES orders(id),
+    user_id uuid NOT NULL REFERENCES users(id),
+    provider VARCHAR(20) NOT NULL,
+    provider_payment_id VARCHAR(100),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    refunded_amount BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    pay_url VARCHAR,
+    error VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    paid_at TIMESTAMPTZ
+);
+
+CREATE INDEX payments_order_id_idx ON payments(order_id, created_at);
+CREATE INDEX payments_pending_idx ON payments(created_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX payments_provider_payment_id_idx ON payments(provider, provider_payment_id);
+
+ALTER TABLE refunds
+     ADD payment_id uuid REFERENCES payments(id),
+     ADD attempts INTEGER NOT NULL DEFAULT 0,
+     ADD error VARCHAR;
@@ -0,0 +1,18 @@
+-- transactions Payme creates through the merchant API, every one is bound to a payment of the order.
+-- Amounts are in tiyins and times are Unix milliseconds as Payme sends and expects them.
+CREATE TABLE payme_transactions (
+    id VARCHAR(50) NOT NULL PRIMARY KEY,
+    payment_id uuid NOT NULL REFERENCES payments(id),
+    order_id uuid NOT NULL REFERENCES orders(id),
+    amount BIGINT NOT NULL,
+    state SMALLINT NOT NULL,
+    reason SMALLINT,
+    payme_time BIGINT NOT NULL,
+    create_time BIGINT NOT NULL,
+    perform_time BIGINT NOT NULL DEFAULT 0,
+    cancel_time BIGINT NOT NULL DEFAULT 0
+);
+
+-- an order is paid by one transaction at a time
+CREATE UNIQUE INDEX payme_transactions_active_order_idx ON payme_transactions(order_id) WHERE state = 1;
+CREATE INDEX payme_transactions_create_time_idx ON payme_transactions(create_time);
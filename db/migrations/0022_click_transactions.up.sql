@@ -0,0 +1,17 @@
+-- payments Click makes through the SHOP API callbacks, the id is sent to Click
+-- as merchant_prepare_id and merchant_confirm_id
+CREATE TABLE click_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    click_trans_id BIGINT NOT NULL UNIQUE,
+    click_paydoc_id BIGINT NOT NULL,
+    payment_id uuid NOT NULL REFERENCES payments(id),
+    order_id uuid NOT NULL REFERENCES orders(id),
+    amount BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'prepared',
+    error VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ
+);
+
+CREATE INDEX click_transactions_order_id_idx ON click_transactions(order_id);
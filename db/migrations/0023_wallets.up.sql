@@ -0,0 +1,49 @@
+-- balance is what the platform owes the customer, it is kept in step with the wallet
+-- ledger entries and can never go below zero
+CREATE TABLE wallets (
+    user_id uuid NOT NULL PRIMARY KEY REFERENCES users(id),
+    balance BIGINT NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- type is 0 for income, 1 for expense and 2 for transfer; amount is the change of the balance
+CREATE TABLE wallet_transactions (
+    id uuid NOT NULL PRIMARY KEY,
+    type SMALLINT NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    user_id uuid NOT NULL REFERENCES users(id),
+    order_id uuid REFERENCES orders(id),
+    refund_id uuid REFERENCES refunds(id),
+    amount BIGINT NOT NULL,
+    note VARCHAR,
+    created_by uuid,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX wallet_transactions_user_id_idx ON wallet_transactions(user_id, created_at);
+-- an order gets cashback and is paid from the wallet only once, a refund is credited only once
+CREATE UNIQUE INDEX wallet_transactions_order_kind_idx ON wallet_transactions(order_id, kind)
+    WHERE kind IN ('cashback', 'order_payment');
+CREATE UNIQUE INDEX wallet_transactions_refund_id_idx ON wallet_transactions(refund_id);
+
+CREATE TABLE wallet_entries (
+    id uuid NOT NULL DEFAULT uuid_generate_v4() PRIMARY KEY,
+    transaction_id uuid NOT NULL REFERENCES wallet_transactions(id),
+    account VARCHAR(100) NOT NULL,
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX wallet_entries_account_idx ON wallet_entries(account, created_at);
+
+-- the ledger is append-only, a mistake is corrected with another transaction
+CREATE FUNCTION wallet_ledger_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'wallet ledger rows can not be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_transactions_immutable BEFORE UPDATE OR DELETE ON wallet_transactions
+    FOR EACH ROW EXECUTE FUNCTION wallet_ledger_immutable();
+CREATE TRIGGER wallet_entries_immutable BEFORE UPDATE OR DELETE ON wallet_entries
+    FOR EACH ROW EXECUTE FUNCTION wallet_ledger_immutable();
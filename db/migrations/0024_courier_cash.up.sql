@@ -0,0 +1,28 @@
+-- cash_balance is the cash the courier collected from customers and has not handed over yet
+ALTER TABLE couriers ADD COLUMN cash_balance BIGINT NOT NULL DEFAULT 0 CHECK (cash_balance >= 0);
+
+-- one row per delivered cash order
+CREATE TABLE cash_collections (
+    id uuid NOT NULL PRIMARY KEY,
+    order_id uuid NOT NULL UNIQUE REFERENCES orders(id),
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX cash_collections_courier_id_idx ON cash_collections(courier_id, created_at);
+
+-- receiver is office or xozmak, xozmak_id is set for the latter
+CREATE TABLE cash_handovers (
+    id uuid NOT NULL PRIMARY KEY,
+    courier_id uuid NOT NULL REFERENCES couriers(user_id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    receiver VARCHAR(20) NOT NULL,
+    xozmak_id uuid REFERENCES xozmaks(id),
+    received_by uuid NOT NULL REFERENCES users(id),
+    note VARCHAR,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX cash_handovers_courier_id_idx ON cash_handovers(courier_id, created_at);
+CREATE INDEX cash_handovers_xozmak_id_idx ON cash_handovers(xozmak_id, created_at);
@@ -0,0 +1,61 @@
+-- a rule applies to the items of one xozmak, of one category or of both, the rule with
+-- neither is the platform default. percent and tiers percents are in percents of the items total.
+CREATE TABLE commission_rules (
+    id uuid NOT NULL PRIMARY KEY,
+    xozmak_id uuid REFERENCES xozmaks(id),
+    category_id uuid REFERENCES category(id),
+    type VARCHAR(20) NOT NULL,
+    percent NUMERIC(5,2) NOT NULL DEFAULT 0,
+    fixed_amount BIGINT NOT NULL DEFAULT 0,
+    tiers json,
+    state numeric(1) NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX commission_rules_scope_idx ON commission_rules(
+    COALESCE(xozmak_id, '00000000-0000-0000-0000-000000000000'),
+    COALESCE(category_id, '00000000-0000-0000-0000-000000000000')
+) WHERE state = 1;
+
+CREATE TABLE settlements (
+    id uuid NOT NULL PRIMARY KEY,
+    xozmak_id uuid NOT NULL REFERENCES xozmaks(id),
+    period_end TIMESTAMPTZ NOT NULL,
+    orders INTEGER NOT NULL,
+    gross BIGINT NOT NULL,
+    commission BIGINT NOT NULL,
+    refunds BIGINT NOT NULL,
+    net_payable BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_by uuid,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    settled_by uuid,
+    settled_at TIMESTAMPTZ
+);
+
+CREATE INDEX settlements_xozmak_id_idx ON settlements(xozmak_id, created_at);
+
+-- the commission of every delivered order, settlement_id is set once it is settled
+CREATE TABLE order_commissions (
+    order_id uuid NOT NULL PRIMARY KEY REFERENCES orders(id),
+    xozmak_id uuid NOT NULL REFERENCES xozmaks(id),
+    gross BIGINT NOT NULL,
+    commission BIGINT NOT NULL,
+    settlement_id uuid REFERENCES settlements(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_commissions_unsettled_idx ON order_commissions(created_at) WHERE settlement_id IS NULL;
+
+-- refunds of delivered orders taken from the xozmak. source_id is a refund or a wallet
+-- refund transaction, each one is deducted only once.
+CREATE TABLE settlement_refunds (
+    source_id uuid NOT NULL PRIMARY KEY,
+    settlement_id uuid NOT NULL REFERENCES settlements(id),
+    order_id uuid NOT NULL REFERENCES orders(id),
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX settlement_refunds_settlement_id_idx ON settlement_refunds(settlement_id);
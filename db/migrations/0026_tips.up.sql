@@ -0,0 +1,12 @@
+-- the tip chosen at checkout is a part of the order total and goes to the courier on delivery
+ALTER TABLE orders
+     ADD tip BIGINT NOT NULL DEFAULT 0 CHECK (tip >= 0);
+
+-- a tip given after the delivery is paid on its own
+ALTER TABLE payments
+     ADD purpose VARCHAR(10) NOT NULL DEFAULT 'order';
+
+-- a tip is spent from the wallet only once per order
+DROP INDEX wallet_transactions_order_kind_idx;
+CREATE UNIQUE INDEX wallet_transactions_order_kind_idx ON wallet_transactions(order_id, kind)
+    WHERE kind IN ('cashback', 'order_payment', 'tip');
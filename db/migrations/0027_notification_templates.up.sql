@@ -0,0 +1,14 @@
+-- notifications are sent in the language the user chose in the profile
+ALTER TABLE users
+     ADD preferred_language VARCHAR(2) NOT NULL DEFAULT 'uz' CHECK (preferred_language IN ('uz', 'ru'));
+
+-- the built-in texts live in the code, a row here is the text an admin edited
+CREATE TABLE notification_templates (
+    key VARCHAR(50) NOT NULL,
+    language VARCHAR(2) NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    updated_by uuid,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (key, language)
+);
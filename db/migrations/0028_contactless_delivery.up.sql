@@ -0,0 +1,3 @@
+-- a contactless order is left at the door, the courier proves the delivery with a photo
+ALTER TABLE orders
+     ADD contactless BOOLEAN NOT NULL DEFAULT false;
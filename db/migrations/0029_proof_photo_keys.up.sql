@@ -0,0 +1,4 @@
+-- proof photos are uploaded to the media store, the proof keeps the key of the object
+-- instead of a url chosen by the app
+ALTER TABLE delivery_proofs
+     RENAME COLUMN photo_url TO photo_key;
@@ -0,0 +1,21 @@
+-- the bounding box of every zone, so that zones around a point are found in SQL
+-- before their polygons are checked
+ALTER TABLE delivery_zones
+     ADD min_lat DOUBLE PRECISION NOT NULL DEFAULT 0,
+     ADD max_lat DOUBLE PRECISION NOT NULL DEFAULT 0,
+     ADD min_long DOUBLE PRECISION NOT NULL DEFAULT 0,
+     ADD max_long DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE delivery_zones z
+   SET min_lat = b.min_lat, max_lat = b.max_lat, min_long = b.min_long, max_long = b.max_long
+  FROM (SELECT id,
+               min((p->>1)::float8) AS min_lat, max((p->>1)::float8) AS max_lat,
+               min((p->>0)::float8) AS min_long, max((p->>0)::float8) AS max_long
+          FROM delivery_zones,
+               jsonb_path_query(area::jsonb, CASE area->>'type'
+                   WHEN 'MultiPolygon' THEN 'strict $.coordinates[*][*][*]'
+                   ELSE 'strict $.coordinates[*][*]' END::jsonpath) p
+         GROUP BY id) b
+ WHERE b.id = z.id;
+
+CREATE INDEX delivery_zones_bounds_idx ON delivery_zones(min_lat, max_lat, min_long, max_long) WHERE state = 1;
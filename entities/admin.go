@@ -3,7 +3,9 @@ package entities
 import (
 	"database/sql"
 	"database/sql/driver"
+	"delivery/pkg/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -14,6 +16,10 @@ type Xozmak struct {
 	CreatedBy sql.NullString `gorm:"column:created_by"`
 	UpdatedBy sql.NullString `gorm:"column:updated_by"`
 	Location  Location       `json:"location" gorm:"column:location;type:json"`
+	// ordering switches are changed only through their own endpoint
+	OrdersPaused   bool          `json:"orders_paused" gorm:"column:orders_paused;->"`
+	AllowPreorders bool          `json:"allow_preorders" gorm:"column:allow_preorders;->"`
+	Status         *XozmakStatus `json:"status,omitempty" gorm:"-"`
 }
 
 type Location struct {
@@ -38,6 +44,31 @@ type SubCategory struct {
 	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at"`
 }
 
+type Product struct {
+	ID            string    `json:"id" gorm:"column:id"`
+	XozmakID      string    `json:"xozmak_id" gorm:"column:xozmak_id"`
+	SubCategoryID string    `json:"sub_category_id" gorm:"column:sub_category_id"`
+	Name          string    `json:"name" gorm:"column:name"`
+	Photo         string    `json:"photo" gorm:"column:photo"`
+	Price         int64     `json:"price" gorm:"column:price"`
+	Stock         int       `json:"stock" gorm:"column:stock"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (p *Product) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if !utils.IsValidUUID(p.XozmakID) || !utils.IsValidUUID(p.SubCategoryID) {
+		return errors.New("xozmak_id and sub_category_id must be valid uuids")
+	}
+	if p.Price < 0 || p.Stock < 0 {
+		return errors.New("price and stock can not be negative")
+	}
+	return nil
+}
+
 func (l *Location) Scan(value interface{}) error {
 	bytes, ok := value.([]byte)
 	if !ok {
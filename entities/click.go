@@ -0,0 +1,43 @@
+package entities
+
+import "time"
+
+// ClickTransaction is a payment Click prepared for an order, ID is our merchant_prepare_id
+type ClickTransaction struct {
+	ID            int64      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ClickTransID  int64      `json:"click_trans_id" gorm:"column:click_trans_id"`
+	ClickPaydocID int64      `json:"click_paydoc_id" gorm:"column:click_paydoc_id"`
+	PaymentID     string     `json:"payment_id" gorm:"column:payment_id"`
+	OrderID       string     `json:"order_id" gorm:"column:order_id"`
+	Amount        int64      `json:"amount" gorm:"column:amount"`
+	Status        string     `json:"status" gorm:"column:status"`
+	Error         string     `json:"error,omitempty" gorm:"column:error"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at"`
+	CompletedAt   *time.Time `json:"completed_at" gorm:"column:completed_at"`
+	CancelledAt   *time.Time `json:"cancelled_at" gorm:"column:cancelled_at"`
+}
+
+// ClickRequest is the form Click posts to the prepare and complete callbacks. The values
+// are kept as sent because sign_string is calculated over them.
+type ClickRequest struct {
+	ClickTransID      string `form:"click_trans_id"`
+	ServiceID         string `form:"service_id"`
+	ClickPaydocID     string `form:"click_paydoc_id"`
+	MerchantTransID   string `form:"merchant_trans_id"`
+	MerchantPrepareID string `form:"merchant_prepare_id"`
+	Amount            string `form:"amount"`
+	Action            string `form:"action"`
+	Error             string `form:"error"`
+	ErrorNote         string `form:"error_note"`
+	SignTime          string `form:"sign_time"`
+	SignString        string `form:"sign_string"`
+}
+
+type ClickResponse struct {
+	ClickTransID      int64  `json:"click_trans_id"`
+	MerchantTransID   string `json:"merchant_trans_id"`
+	MerchantPrepareID int64  `json:"merchant_prepare_id,omitempty"`
+	MerchantConfirmID int64  `json:"merchant_confirm_id,omitempty"`
+	Error             int    `json:"error"`
+	ErrorNote         string `json:"error_note"`
+}
@@ -0,0 +1,215 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var vehicleTypes = []string{
+	constants.VehicleFoot,
+	constants.VehicleBicycle,
+	constants.VehicleScooter,
+	constants.VehicleCar,
+}
+
+var courierDocumentTypes = []string{
+	constants.CourierDocumentPassport,
+	constants.CourierDocumentDriverLicense,
+	constants.CourierDocumentVehicle,
+}
+
+type CourierDocument struct {
+	Type   string `json:"type"`
+	Number string `json:"number"`
+	Photo  string `json:"photo"`
+}
+
+type CourierDocuments []CourierDocument
+
+func (d *CourierDocuments) Scan(value interface{}) error {
+	if value == nil {
+		*d = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to scan CourierDocuments, unexpected type %T", value)
+	}
+	if err := json.Unmarshal(bytes, d); err != nil {
+		return fmt.Errorf("failed to unmarshal CourierDocuments JSON: %w", err)
+	}
+	return nil
+}
+
+func (d CourierDocuments) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+// Courier is the courier profile, name and phone number come from the users table
+type Courier struct {
+	ID            string           `json:"id" gorm:"column:user_id;primaryKey"`
+	PhoneNumber   string           `json:"phone_number" gorm:"column:phone_number;->"`
+	Firstname     string           `json:"firstname" gorm:"column:firstname;->"`
+	Surname       string           `json:"surname" gorm:"column:surname;->"`
+	VehicleType   string           `json:"vehicle_type" gorm:"column:vehicle_type"`
+	Documents     CourierDocuments `json:"documents" gorm:"column:documents;type:json"`
+	Status        string           `json:"status" gorm:"column:status"`
+	BlockedReason string           `json:"blocked_reason,omitempty" gorm:"column:blocked_reason"`
+	IsOnline      bool             `json:"is_online" gorm:"column:is_online"`
+	Rating        float64          `json:"rating" gorm:"column:rating;default:5"`
+	PayoutAccount string           `json:"payout_account" gorm:"column:payout_account"`
+	CashBalance   int64            `json:"cash_balance" gorm:"column:cash_balance;->"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     time.Time        `json:"updated_at" gorm:"column:updated_at"`
+}
+
+type CourierReq struct {
+	ID          string           `json:"-"`
+	PhoneNumber string           `json:"phone_number"`
+	Firstname   string           `json:"firstname"`
+	Surname     string           `json:"surname"`
+	VehicleType string           `json:"vehicle_type"`
+	Documents   CourierDocuments `json:"documents"`
+	// PayoutAccount is the card or bank account earnings are sent to
+	PayoutAccount string `json:"payout_account"`
+}
+
+func (req *CourierReq) Validate() error {
+	if !utils.IsPhoneValid(req.PhoneNumber) {
+		return errors.New("invalid phone number: must be in format +99XXXXXXXXXX")
+	}
+	if req.Firstname == "" {
+		return errors.New("firstname is required")
+	}
+	if !utils.InEnums(req.VehicleType, vehicleTypes) {
+		return fmt.Errorf("vehicle_type must be one of %v", vehicleTypes)
+	}
+	for _, document := range req.Documents {
+		if !utils.InEnums(document.Type, courierDocumentTypes) {
+			return fmt.Errorf("document type must be one of %v", courierDocumentTypes)
+		}
+		if document.Number == "" {
+			return errors.New("document number is required")
+		}
+	}
+	if req.PayoutAccount != "" && !payoutAccountRegex.MatchString(req.PayoutAccount) {
+		return errors.New("payout_account must be 8 to 34 letters or digits")
+	}
+	return nil
+}
+
+var payoutAccountRegex = regexp.MustCompile(`^[A-Za-z0-9]{8,34}$`)
+
+type CourierList struct {
+	Couriers []Courier `json:"couriers"`
+	Count    int64     `json:"count"`
+}
+
+type BlockCourierReq struct {
+	Reason string `json:"reason"`
+}
+
+// CourierShift is a period when the courier was available to take orders
+type CourierShift struct {
+	ID        string     `json:"id" gorm:"column:id;default:uuid_generate_v4()"`
+	CourierID string     `json:"courier_id" gorm:"column:courier_id"`
+	StartedAt time.Time  `json:"started_at" gorm:"column:started_at"`
+	EndedAt   *time.Time `json:"ended_at" gorm:"column:ended_at"`
+}
+
+// LocationPoint is a GPS position sent by the courier app
+type LocationPoint struct {
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	Accuracy   float64   `json:"accuracy"`
+	Speed      float64   `json:"speed"`
+	Heading    float64   `json:"heading"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// LocationBatch accepts either a single point or {"points": [...]} buffered while offline
+type LocationBatch struct {
+	Points []LocationPoint `json:"points"`
+}
+
+func (b *LocationBatch) UnmarshalJSON(data []byte) error {
+	var batch struct {
+		Points []LocationPoint `json:"points"`
+	}
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return err
+	}
+	if batch.Points != nil {
+		b.Points = batch.Points
+		return nil
+	}
+
+	var point LocationPoint
+	if err := json.Unmarshal(data, &point); err != nil {
+		return err
+	}
+	b.Points = []LocationPoint{point}
+	return nil
+}
+
+// Validate checks the points and sets the time of points sent without it
+func (b *LocationBatch) Validate(now time.Time) error {
+	if len(b.Points) == 0 {
+		return errors.New("at least one point is required")
+	}
+	if len(b.Points) > constants.MaxLocationBatch {
+		return fmt.Errorf("at most %d points can be sent at once", constants.MaxLocationBatch)
+	}
+	for i := range b.Points {
+		point := &b.Points[i]
+		// the points go to the Redis courier map, which does not take the polar regions
+		if point.Lat < -constants.MaxGeoLatitude || point.Lat > constants.MaxGeoLatitude || point.Long < -180 || point.Long > 180 {
+			return errors.New("invalid coordinates")
+		}
+		if point.RecordedAt.IsZero() {
+			point.RecordedAt = now
+		}
+		if point.RecordedAt.After(now.Add(constants.LocationMaxClockSkew)) {
+			return errors.New("recorded_at can not be in the future")
+		}
+	}
+	return nil
+}
+
+type LocationIngestRes struct {
+	Accepted int `json:"accepted"`
+	Tracked  int `json:"tracked"`
+}
+
+// OrderTrackPoint is a courier position stored for replaying the delivery of an order
+type OrderTrackPoint struct {
+	ID         int64     `json:"-" gorm:"column:id;->"`
+	OrderID    string    `json:"order_id" gorm:"column:order_id"`
+	CourierID  string    `json:"courier_id" gorm:"column:courier_id"`
+	Latitude   float64   `json:"latitude" gorm:"column:latitude"`
+	Longitude  float64   `json:"longitude" gorm:"column:longitude"`
+	Accuracy   float64   `json:"accuracy" gorm:"column:accuracy"`
+	Speed      float64   `json:"speed" gorm:"column:speed"`
+	Heading    float64   `json:"heading" gorm:"column:heading"`
+	RecordedAt time.Time `json:"recorded_at" gorm:"column:recorded_at"`
+}
+
+// CourierActiveOrder is what the location pushes of the courier need from an order they carry,
+// it is cached in Redis so that the pushes do not query the database
+type CourierActiveOrder struct {
+	ID              string   `json:"id"`
+	Number          int64    `json:"number"`
+	UserID          string   `json:"user_id"`
+	Status          string   `json:"status"`
+	AddressLocation Location `json:"address_location"`
+	HandoffCode     string   `json:"handoff_code"`
+}
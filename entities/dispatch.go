@@ -0,0 +1,54 @@
+package entities
+
+import "time"
+
+// DispatchCandidate is a courier that can be offered an order
+type DispatchCandidate struct {
+	CourierID  string  `json:"courier_id" gorm:"column:courier_id"`
+	DistanceKm float64 `json:"distance_km" gorm:"-"`
+	Rating     float64 `json:"rating" gorm:"column:rating"`
+	Load       int     `json:"load" gorm:"column:load"`
+	// CashBalance is the collected cash the courier has not handed over yet
+	CashBalance int64 `json:"cash_balance" gorm:"column:cash_balance"`
+	// Score is the ranking of the candidate, lower is better
+	Score float64 `json:"score" gorm:"-"`
+}
+
+// CourierOffer is an order waiting for the courier's answer, orders offered
+// together with it are in Order.Batch
+type CourierOffer struct {
+	Order     Order     `json:"order"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CourierAssignment sets the courier of an order if it is still assigned to FromCourierID
+type CourierAssignment struct {
+	OrderID       string
+	CourierID     string
+	FromCourierID *string
+	Statuses      []string
+	History       OrderHistory
+}
+
+// BatchAssignment gives several orders to one courier, Orders are picked up
+// and delivered in the order of the list
+type BatchAssignment struct {
+	BatchID   string
+	CourierID string
+	Orders    []CourierAssignment
+}
+
+// WaitingOrder is an order looking for a courier together with where it is picked up
+type WaitingOrder struct {
+	Order
+	Pickup Location `gorm:"column:pickup;type:json"`
+}
+
+type AssignCourierReq struct {
+	OrderID   string `json:"-"`
+	CourierID string `json:"courier_id"`
+	// Force assigns a courier who is offline, fully loaded or over the cash limit,
+	// the broken rules are written to the order history
+	Force bool  `json:"force"`
+	Actor Actor `json:"-"`
+}
@@ -0,0 +1,242 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// clockLayout is the format of opening and closing times
+const clockLayout = "15:04"
+
+// WorkingHours is one opening interval of a weekday, a xozmak can have several a day.
+// ClosesAt before OpensAt means the xozmak closes after midnight, equal times mean 24 hours.
+type WorkingHours struct {
+	XozmakID string `json:"-" gorm:"column:xozmak_id"`
+	// Weekday is 0 for Sunday like time.Weekday
+	Weekday  int    `json:"weekday" gorm:"column:weekday"`
+	OpensAt  string `json:"opens_at" gorm:"column:opens_at"`
+	ClosesAt string `json:"closes_at" gorm:"column:closes_at"`
+}
+
+func (h *WorkingHours) Validate() error {
+	if h.Weekday < 0 || h.Weekday > 6 {
+		return errors.New("weekday must be from 0 (Sunday) to 6 (Saturday)")
+	}
+	return validateClock(h.OpensAt, h.ClosesAt)
+}
+
+func validateClock(opensAt, closesAt string) error {
+	if _, err := time.Parse(clockLayout, opensAt); err != nil {
+		return errors.New("opens_at must be in HH:MM format")
+	}
+	if _, err := time.Parse(clockLayout, closesAt); err != nil {
+		return errors.New("closes_at must be in HH:MM format")
+	}
+	return nil
+}
+
+type WorkingHoursReq struct {
+	XozmakID string         `json:"-"`
+	Hours    []WorkingHours `json:"hours"`
+}
+
+func (r *WorkingHoursReq) Validate() error {
+	for i := range r.Hours {
+		if err := r.Hours[i].Validate(); err != nil {
+			return fmt.Errorf("hours[%d]: %w", i, err)
+		}
+		r.Hours[i].XozmakID = r.XozmakID
+	}
+	return nil
+}
+
+// HoursException replaces the weekly hours of one date, a closed one is a day off.
+// Exceptions without a xozmak are holidays of all xozmaks, the xozmak's own exception wins.
+type HoursException struct {
+	ID        string    `json:"id" gorm:"column:id"`
+	XozmakID  *string   `json:"xozmak_id" gorm:"column:xozmak_id"`
+	Date      string    `json:"date" gorm:"column:date"`
+	Closed    bool      `json:"closed" gorm:"column:closed"`
+	OpensAt   *string   `json:"opens_at" gorm:"column:opens_at"`
+	ClosesAt  *string   `json:"closes_at" gorm:"column:closes_at"`
+	Note      string    `json:"note" gorm:"column:note"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+func (h *HoursException) Validate() error {
+	if _, err := time.Parse(time.DateOnly, h.Date); err != nil {
+		return errors.New("date must be in YYYY-MM-DD format")
+	}
+	if h.Closed {
+		h.OpensAt, h.ClosesAt = nil, nil
+		return nil
+	}
+	if h.OpensAt == nil || h.ClosesAt == nil {
+		return errors.New("opens_at and closes_at are required unless the day is closed")
+	}
+	return validateClock(*h.OpensAt, *h.ClosesAt)
+}
+
+// XozmakOrderingReq switches taking orders and pre-orders of a xozmak, nil fields are not changed
+type XozmakOrderingReq struct {
+	XozmakID       string `json:"-"`
+	OrdersPaused   *bool  `json:"orders_paused"`
+	AllowPreorders *bool  `json:"allow_preorders"`
+}
+
+// XozmakStatus tells customers whether they can order from the xozmak now
+type XozmakStatus struct {
+	IsOpen bool `json:"is_open"`
+	// Paused is set when the xozmak stopped taking orders by hand
+	Paused bool `json:"orders_paused"`
+	// ClosesAt is the end of the current opening, nil when closed or open around the clock
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+	// NextOpenAt is the start of the next opening, nil when open, paused or unknown
+	NextOpenAt *time.Time `json:"next_open_at,omitempty"`
+	// AcceptsPreorders means orders for a later delivery slot are taken while closed
+	AcceptsPreorders bool `json:"accepts_preorders"`
+}
+
+// XozmakSchedule is everything the open status of a xozmak depends on. A xozmak
+// without weekly hours is open around the clock except on closed days.
+type XozmakSchedule struct {
+	Hours          []WorkingHours   `json:"hours"`
+	Exceptions     []HoursException `json:"exceptions"`
+	Paused         bool             `json:"orders_paused"`
+	AllowPreorders bool             `json:"allow_preorders"`
+	Status         XozmakStatus     `json:"status"`
+}
+
+// scheduleDays is how far ahead the next opening is looked for
+const scheduleDays = 14
+
+type openInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// StatusAt returns the open status at now, times are in the location of now
+func (s XozmakSchedule) StatusAt(now time.Time) XozmakStatus {
+	status := XozmakStatus{
+		Paused:           s.Paused,
+		AcceptsPreorders: s.AllowPreorders && !s.Paused,
+	}
+	if s.Paused {
+		return status
+	}
+
+	if open, closesAt := s.openAt(now); open {
+		status.IsOpen = true
+		status.ClosesAt = closesAt
+		return status
+	}
+	status.NextOpenAt = s.nextOpening(now)
+	return status
+}
+
+// OpenAt tells whether the xozmak works at t, the pause is not taken into account
+func (s XozmakSchedule) OpenAt(t time.Time) bool {
+	open, _ := s.openAt(t)
+	return open
+}
+
+func (s XozmakSchedule) openAt(t time.Time) (bool, *time.Time) {
+	day := startOfDay(t)
+	// an interval of yesterday may last after midnight
+	for _, date := range []time.Time{day.AddDate(0, 0, -1), day} {
+		for _, interval := range s.intervals(date) {
+			if t.Before(interval.Start) || !t.Before(interval.End) {
+				continue
+			}
+			end, ok := s.extend(interval.End)
+			if !ok {
+				return true, nil
+			}
+			return true, &end
+		}
+	}
+	return false, nil
+}
+
+// extend follows intervals that start right when the previous one ends, like a xozmak
+// open until midnight and again from midnight. False means it does not close within scheduleDays.
+func (s XozmakSchedule) extend(end time.Time) (time.Time, bool) {
+	for i := 0; i < scheduleDays; i++ {
+		next, ok := end, false
+		for _, interval := range s.intervals(startOfDay(end)) {
+			if interval.Start.Equal(end) && interval.End.After(next) {
+				next, ok = interval.End, true
+			}
+		}
+		if !ok {
+			return end, true
+		}
+		end = next
+	}
+	return end, false
+}
+
+func (s XozmakSchedule) nextOpening(now time.Time) *time.Time {
+	day := startOfDay(now)
+	for i := 0; i <= scheduleDays; i++ {
+		var next *time.Time
+		for _, interval := range s.intervals(day.AddDate(0, 0, i)) {
+			if interval.Start.After(now) && (next == nil || interval.Start.Before(*next)) {
+				start := interval.Start
+				next = &start
+			}
+		}
+		if next != nil {
+			return next
+		}
+	}
+	return nil
+}
+
+// intervals returns the openings that start on the date
+func (s XozmakSchedule) intervals(date time.Time) []openInterval {
+	var exception *HoursException
+	for i := range s.Exceptions {
+		if s.Exceptions[i].Date != date.Format(time.DateOnly) {
+			continue
+		}
+		if exception == nil || s.Exceptions[i].XozmakID != nil {
+			exception = &s.Exceptions[i]
+		}
+	}
+	if exception != nil {
+		if exception.Closed || exception.OpensAt == nil || exception.ClosesAt == nil {
+			return nil
+		}
+		return []openInterval{newInterval(date, *exception.OpensAt, *exception.ClosesAt)}
+	}
+
+	if len(s.Hours) == 0 {
+		return []openInterval{{Start: date, End: date.AddDate(0, 0, 1)}}
+	}
+	var intervals []openInterval
+	for _, hours := range s.Hours {
+		if hours.Weekday == int(date.Weekday()) {
+			intervals = append(intervals, newInterval(date, hours.OpensAt, hours.ClosesAt))
+		}
+	}
+	return intervals
+}
+
+func newInterval(date time.Time, opensAt, closesAt string) openInterval {
+	interval := openInterval{Start: atClock(date, opensAt), End: atClock(date, closesAt)}
+	if !interval.End.After(interval.Start) {
+		interval.End = atClock(date.AddDate(0, 0, 1), closesAt)
+	}
+	return interval
+}
+
+func atClock(date time.Time, clock string) time.Time {
+	t, _ := time.Parse(clockLayout, clock)
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location())
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,206 @@
+package entities
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestScheduleStatusAt(t *testing.T) {
+	tashkent, err := time.LoadLocation("Asia/Tashkent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xozmakId := "xozmak-1"
+	clock := func(s string) *string { return &s }
+	at := func(loc *time.Location, day, hour, minute int) time.Time {
+		// May 2024 starts on Wednesday
+		return time.Date(2024, time.May, day, hour, minute, 0, 0, loc)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	eveningHours := []WorkingHours{{Weekday: 3, OpensAt: "18:00", ClosesAt: "02:00"}}
+	dayHours := []WorkingHours{
+		{Weekday: 3, OpensAt: "09:00", ClosesAt: "18:00"},
+		{Weekday: 4, OpensAt: "09:00", ClosesAt: "18:00"},
+	}
+	holiday := HoursException{Date: "2024-05-01", Closed: true}
+
+	cases := []struct {
+		name     string
+		schedule XozmakSchedule
+		now      time.Time
+		open     bool
+		closesAt *time.Time
+		nextOpen *time.Time
+	}{
+		{
+			name:     "open before midnight of an interval crossing it",
+			schedule: XozmakSchedule{Hours: eveningHours},
+			now:      at(time.UTC, 1, 23, 0),
+			open:     true,
+			closesAt: ptr(at(time.UTC, 2, 2, 0)),
+		},
+		{
+			name:     "open after midnight on the next weekday",
+			schedule: XozmakSchedule{Hours: eveningHours},
+			now:      at(time.UTC, 2, 1, 59),
+			open:     true,
+			closesAt: ptr(at(time.UTC, 2, 2, 0)),
+		},
+		{
+			name:     "closed when the interval crossing midnight ends",
+			schedule: XozmakSchedule{Hours: eveningHours},
+			now:      at(time.UTC, 2, 2, 0),
+			nextOpen: ptr(at(time.UTC, 8, 18, 0)),
+		},
+		{
+			name:     "closed before the opening of the day",
+			schedule: XozmakSchedule{Hours: dayHours},
+			now:      at(time.UTC, 1, 8, 0),
+			nextOpen: ptr(at(time.UTC, 1, 9, 0)),
+		},
+		{
+			name:     "after the closing the next day opening is found",
+			schedule: XozmakSchedule{Hours: dayHours},
+			now:      at(time.UTC, 1, 18, 0),
+			nextOpen: ptr(at(time.UTC, 2, 9, 0)),
+		},
+		{
+			name: "exception hours replace the weekly hours",
+			schedule: XozmakSchedule{Hours: dayHours, Exceptions: []HoursException{
+				{XozmakID: &xozmakId, Date: "2024-05-01", OpensAt: clock("12:00"), ClosesAt: clock("14:00")},
+			}},
+			now:      at(time.UTC, 1, 10, 0),
+			nextOpen: ptr(at(time.UTC, 1, 12, 0)),
+		},
+		{
+			name: "open within the exception hours",
+			schedule: XozmakSchedule{Hours: dayHours, Exceptions: []HoursException{
+				{XozmakID: &xozmakId, Date: "2024-05-01", OpensAt: clock("12:00"), ClosesAt: clock("14:00")},
+			}},
+			now:      at(time.UTC, 1, 13, 0),
+			open:     true,
+			closesAt: ptr(at(time.UTC, 1, 14, 0)),
+		},
+		{
+			name:     "holiday closes the day with weekly hours",
+			schedule: XozmakSchedule{Hours: dayHours, Exceptions: []HoursException{holiday}},
+			now:      at(time.UTC, 1, 10, 0),
+			nextOpen: ptr(at(time.UTC, 2, 9, 0)),
+		},
+		{
+			name:     "holiday closes a xozmak open around the clock",
+			schedule: XozmakSchedule{Exceptions: []HoursException{holiday}},
+			now:      at(time.UTC, 1, 10, 0),
+			nextOpen: ptr(at(time.UTC, 2, 0, 0)),
+		},
+		{
+			name: "own exception of the xozmak wins over the holiday",
+			schedule: XozmakSchedule{Hours: dayHours, Exceptions: []HoursException{
+				holiday,
+				{XozmakID: &xozmakId, Date: "2024-05-01", OpensAt: clock("10:00"), ClosesAt: clock("16:00")},
+			}},
+			now:      at(time.UTC, 1, 11, 0),
+			open:     true,
+			closesAt: ptr(at(time.UTC, 1, 16, 0)),
+		},
+		{
+			name: "intervals meeting at midnight are one opening",
+			schedule: XozmakSchedule{Hours: []WorkingHours{
+				{Weekday: 3, OpensAt: "18:00", ClosesAt: "00:00"},
+				{Weekday: 4, OpensAt: "00:00", ClosesAt: "03:00"},
+			}},
+			now:      at(time.UTC, 1, 20, 0),
+			open:     true,
+			closesAt: ptr(at(time.UTC, 2, 3, 0)),
+		},
+		{
+			name: "open around the clock every day has no closing",
+			schedule: XozmakSchedule{Hours: []WorkingHours{
+				{Weekday: 0, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 1, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 2, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 3, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 4, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 5, OpensAt: "00:00", ClosesAt: "00:00"},
+				{Weekday: 6, OpensAt: "00:00", ClosesAt: "00:00"},
+			}},
+			now:  at(time.UTC, 1, 3, 0),
+			open: true,
+		},
+		{
+			name:     "without weekly hours the xozmak is open around the clock",
+			schedule: XozmakSchedule{},
+			now:      at(time.UTC, 1, 3, 0),
+			open:     true,
+		},
+		{
+			name: "no opening within the search days",
+			schedule: XozmakSchedule{Hours: []WorkingHours{{Weekday: 3, OpensAt: "09:00", ClosesAt: "18:00"}}, Exceptions: []HoursException{
+				{Date: "2024-05-08", Closed: true},
+				{Date: "2024-05-15", Closed: true},
+			}},
+			now: at(time.UTC, 1, 19, 0),
+		},
+		{
+			name:     "closing at midnight in Tashkent",
+			schedule: XozmakSchedule{Hours: []WorkingHours{{Weekday: 3, OpensAt: "09:00", ClosesAt: "00:00"}}},
+			now:      time.Date(2024, time.May, 1, 18, 30, 0, 0, time.UTC).In(tashkent),
+			open:     true,
+			closesAt: ptr(at(tashkent, 2, 0, 0)),
+		},
+		{
+			name:     "Tashkent weekday starts while it is still the previous day in UTC",
+			schedule: XozmakSchedule{Hours: []WorkingHours{{Weekday: 3, OpensAt: "09:00", ClosesAt: "00:00"}}},
+			now:      time.Date(2024, time.May, 1, 19, 30, 0, 0, time.UTC).In(tashkent),
+			nextOpen: ptr(at(tashkent, 8, 9, 0)),
+		},
+		{
+			name: "exception date is the Tashkent date",
+			schedule: XozmakSchedule{Hours: []WorkingHours{{Weekday: 3, OpensAt: "09:00", ClosesAt: "00:00"}}, Exceptions: []HoursException{
+				{XozmakID: &xozmakId, Date: "2024-05-02", OpensAt: clock("00:00"), ClosesAt: clock("01:00")},
+			}},
+			now:      time.Date(2024, time.May, 1, 19, 30, 0, 0, time.UTC).In(tashkent),
+			open:     true,
+			closesAt: ptr(at(tashkent, 2, 1, 0)),
+		},
+	}
+	for _, c := range cases {
+		status := c.schedule.StatusAt(c.now)
+		if status.IsOpen != c.open {
+			t.Fatalf("%s: open %v, want %v", c.name, status.IsOpen, c.open)
+		}
+		if c.schedule.OpenAt(c.now) != c.open {
+			t.Fatalf("%s: OpenAt does not match the status", c.name)
+		}
+		if !sameTime(status.ClosesAt, c.closesAt) {
+			t.Fatalf("%s: closes at %v, want %v", c.name, status.ClosesAt, c.closesAt)
+		}
+		if !sameTime(status.NextOpenAt, c.nextOpen) {
+			t.Fatalf("%s: next opening %v, want %v", c.name, status.NextOpenAt, c.nextOpen)
+		}
+	}
+}
+
+func TestPausedScheduleIsClosed(t *testing.T) {
+	schedule := XozmakSchedule{Paused: true, AllowPreorders: true}
+	status := schedule.StatusAt(time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC))
+	if status.IsOpen || !status.Paused || status.NextOpenAt != nil || status.AcceptsPreorders {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	schedule.Paused = false
+	schedule.Exceptions = []HoursException{{Date: "2024-05-01", Closed: true}}
+	status = schedule.StatusAt(time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC))
+	if status.IsOpen || !status.AcceptsPreorders {
+		t.Fatalf("a closed xozmak does not take pre-orders: %+v", status)
+	}
+}
+
+func sameTime(got, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == nil && want == nil
+	}
+	return got.Equal(*want)
+}
@@ -0,0 +1,233 @@
+package entities
+
+import (
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"errors"
+	"time"
+)
+
+// LedgerTransaction is one business event of the courier ledger. Its entries move
+// money between accounts and always sum up to zero.
+type LedgerTransaction struct {
+	ID        string        `json:"id" gorm:"column:id"`
+	Kind      string        `json:"kind" gorm:"column:kind"`
+	CourierID string        `json:"courier_id" gorm:"column:courier_id"`
+	OrderID   *string       `json:"order_id" gorm:"column:order_id"`
+	PayoutID  *string       `json:"payout_id" gorm:"column:payout_id"`
+	Amount    int64         `json:"amount" gorm:"column:amount"`
+	Note      string        `json:"note" gorm:"column:note"`
+	CreatedBy *string       `json:"created_by,omitempty" gorm:"column:created_by"`
+	CreatedAt time.Time     `json:"created_at" gorm:"column:created_at"`
+	Entries   []LedgerEntry `json:"entries,omitempty" gorm:"-"`
+}
+
+// Balanced tells whether debits and credits of the transaction are equal
+func (t LedgerTransaction) Balanced() bool {
+	if len(t.Entries) < 2 {
+		return false
+	}
+	var sum int64
+	for _, entry := range t.Entries {
+		sum += entry.Amount
+	}
+	return sum == 0
+}
+
+func CourierPayableAccount(courierId string) string {
+	return constants.LedgerAccountCourierPayablePrefix + courierId
+}
+
+// CourierEarning credits the courier with amount and debits the account the money comes from
+func CourierEarning(id, kind, courierId string, orderId *string, amount int64, from, note string) LedgerTransaction {
+	return LedgerTransaction{
+		ID:        id,
+		Kind:      kind,
+		CourierID: courierId,
+		OrderID:   orderId,
+		Amount:    amount,
+		Note:      note,
+		CreatedAt: time.Now(),
+		Entries: []LedgerEntry{
+			{Account: from, Amount: amount},
+			{Account: CourierPayableAccount(courierId), Amount: -amount},
+		},
+	}
+}
+
+// LedgerEntry is a debit (positive) or a credit (negative) of one account
+type LedgerEntry struct {
+	ID            string    `json:"id" gorm:"column:id;default:uuid_generate_v4()"`
+	TransactionID string    `json:"transaction_id" gorm:"column:transaction_id"`
+	Account       string    `json:"account" gorm:"column:account"`
+	Amount        int64     `json:"amount" gorm:"column:amount"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+var adjustmentKinds = []string{
+	constants.LedgerKindBonus,
+	constants.LedgerKindPenalty,
+}
+
+// EarningAdjustmentReq is a bonus or a penalty given by an admin
+type EarningAdjustmentReq struct {
+	CourierID string  `json:"-"`
+	Actor     Actor   `json:"-"`
+	Kind      string  `json:"kind"`
+	Amount    int64   `json:"amount"`
+	OrderID   *string `json:"order_id"`
+	Note      string  `json:"note"`
+}
+
+func (r *EarningAdjustmentReq) Validate() error {
+	if !utils.InEnums(r.Kind, adjustmentKinds) {
+		return errors.New("kind must be bonus or penalty")
+	}
+	if r.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if r.OrderID != nil && !utils.IsValidUUID(*r.OrderID) {
+		return errors.New("order_id must be a valid uuid")
+	}
+	if r.Note == "" {
+		return errors.New("note is required")
+	}
+	return nil
+}
+
+// EarningsSummary breaks what the courier earned down by kind
+type EarningsSummary struct {
+	Deliveries int   `json:"deliveries"`
+	Base       int64 `json:"base"`
+	Distance   int64 `json:"distance"`
+	Tips       int64 `json:"tips"`
+	Bonuses    int64 `json:"bonuses"`
+	Penalties  int64 `json:"penalties"`
+	Total      int64 `json:"total"`
+}
+
+// Add counts the transaction in the summary, payouts are not earnings and are skipped
+func (s *EarningsSummary) Add(t LedgerTransaction) {
+	switch t.Kind {
+	case constants.LedgerKindDeliveryBase:
+		s.Deliveries++
+		s.Base += t.Amount
+	case constants.LedgerKindDeliveryDistance:
+		s.Distance += t.Amount
+	case constants.LedgerKindTip:
+		s.Tips += t.Amount
+	case constants.LedgerKindBonus:
+		s.Bonuses += t.Amount
+	case constants.LedgerKindPenalty:
+		s.Penalties -= t.Amount
+	default:
+		return
+	}
+	s.Total += t.Amount
+}
+
+type StatementDay struct {
+	Date string `json:"date"`
+	EarningsSummary
+}
+
+// CourierStatement lists the courier earnings of a day or a week
+type CourierStatement struct {
+	CourierID    string              `json:"courier_id"`
+	Period       string              `json:"period"`
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	Days         []StatementDay      `json:"days"`
+	Totals       EarningsSummary     `json:"totals"`
+	Paid         int64               `json:"paid"`
+	Unpaid       int64               `json:"unpaid"`
+	Transactions []LedgerTransaction `json:"transactions"`
+	// Balance is what the platform owes the courier right now over all time
+	Balance int64 `json:"balance"`
+}
+
+// PayoutBatch pays all unpaid earnings made before PeriodEnd
+type PayoutBatch struct {
+	ID        string    `json:"id" gorm:"column:id"`
+	CreatedBy *string   `json:"created_by" gorm:"column:created_by"`
+	PeriodEnd time.Time `json:"period_end" gorm:"column:period_end"`
+	Total     int64     `json:"total" gorm:"column:total"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	Payouts   []Payout  `json:"payouts,omitempty" gorm:"-"`
+}
+
+type Payout struct {
+	ID            string    `json:"id" gorm:"column:id"`
+	BatchID       string    `json:"batch_id" gorm:"column:batch_id"`
+	CourierID     string    `json:"courier_id" gorm:"column:courier_id"`
+	Amount        int64     `json:"amount" gorm:"column:amount"`
+	PayoutAccount *string   `json:"payout_account" gorm:"column:payout_account"`
+	Firstname     string    `json:"firstname" gorm:"column:firstname;->"`
+	Surname       string    `json:"surname" gorm:"column:surname;->"`
+	PhoneNumber   string    `json:"phone_number" gorm:"column:phone_number;->"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+type PayoutBatchReq struct {
+	Actor Actor `json:"-"`
+	// PeriodEnd defaults to now
+	PeriodEnd *time.Time `json:"period_end"`
+}
+
+// CashCollection is the cash the courier took from the customer of a cash order
+type CashCollection struct {
+	ID        string    `json:"id" gorm:"column:id"`
+	OrderID   string    `json:"order_id" gorm:"column:order_id"`
+	CourierID string    `json:"courier_id" gorm:"column:courier_id"`
+	Amount    int64     `json:"amount" gorm:"column:amount"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// CashHandover is cash the courier gave to the office or to a xozmak
+type CashHandover struct {
+	ID         string    `json:"id" gorm:"column:id"`
+	CourierID  string    `json:"courier_id" gorm:"column:courier_id"`
+	Amount     int64     `json:"amount" gorm:"column:amount"`
+	Receiver   string    `json:"receiver" gorm:"column:receiver"`
+	XozmakID   *string   `json:"xozmak_id" gorm:"column:xozmak_id"`
+	ReceivedBy string    `json:"received_by" gorm:"column:received_by"`
+	Note       string    `json:"note" gorm:"column:note"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// CashHandoverReq is recorded by whoever received the cash: an admin for the office or a seller
+type CashHandoverReq struct {
+	CourierID string `json:"-"`
+	Actor     Actor  `json:"-"`
+	Amount    int64  `json:"amount"`
+	Note      string `json:"note"`
+}
+
+func (r *CashHandoverReq) Validate() error {
+	if r.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	return nil
+}
+
+// CashOrdersBlocked tells whether a courier holding balance of cash can not take cash orders
+func CashOrdersBlocked(balance, limit int64) bool {
+	return limit > 0 && balance > limit
+}
+
+// CourierCashStatement lists the cash the courier collected and handed over in a day or a week
+type CourierCashStatement struct {
+	CourierID   string           `json:"courier_id"`
+	Period      string           `json:"period"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Collected   int64            `json:"collected"`
+	HandedOver  int64            `json:"handed_over"`
+	Collections []CashCollection `json:"collections"`
+	Handovers   []CashHandover   `json:"handovers"`
+	// Balance is the cash the courier holds right now over all time
+	Balance int64 `json:"balance"`
+	Limit   int64 `json:"limit"`
+	// CashOrdersBlocked is set while the balance is over the limit
+	CashOrdersBlocked bool `json:"cash_orders_blocked"`
+}
@@ -0,0 +1,449 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type DeliverySlot struct {
+	ID        string    `json:"id" gorm:"column:id"`
+	XozmakID  string    `json:"xozmak_id" gorm:"column:xozmak_id"`
+	StartsAt  time.Time `json:"starts_at" gorm:"column:starts_at"`
+	EndsAt    time.Time `json:"ends_at" gorm:"column:ends_at"`
+	Capacity  int       `json:"capacity" gorm:"column:capacity"`
+	Reserved  int       `json:"reserved" gorm:"column:reserved"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (s *DeliverySlot) Validate() error {
+	if s.StartsAt.IsZero() || !s.EndsAt.After(s.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if s.Capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	return nil
+}
+
+// SlotAvailability is a delivery slot as seen by a customer
+type SlotAvailability struct {
+	ID        string    `json:"id"`
+	XozmakID  string    `json:"xozmak_id"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Available int       `json:"available"`
+	// ClosesAt is the last moment an order can be placed for the slot
+	ClosesAt time.Time `json:"closes_at"`
+	IsOpen   bool      `json:"is_open"`
+}
+
+type SlotsReq struct {
+	UserID     string
+	XozmakID   string
+	LocationID string
+	From       time.Time
+	To         time.Time
+}
+
+type Order struct {
+	ID              string     `json:"id" gorm:"column:id"`
+	Number          int64      `json:"number" gorm:"column:number;->"`
+	UserID          string     `json:"user_id" gorm:"column:user_id"`
+	XozmakID        string     `json:"xozmak_id" gorm:"column:xozmak_id"`
+	SlotID          *string    `json:"slot_id" gorm:"column:slot_id"`
+	LocationID      string     `json:"location_id" gorm:"column:location_id"`
+	AddressName     string     `json:"address_name" gorm:"column:address_name"`
+	AddressLocation Location   `json:"address_location" gorm:"column:address_location;type:json"`
+	Status          string     `json:"status" gorm:"column:status"`
+	ItemsTotal      int64      `json:"items_total" gorm:"column:items_total"`
+	DeliveryFee     int64      `json:"delivery_fee" gorm:"column:delivery_fee"`
+	Tip             int64      `json:"tip" gorm:"column:tip"`
+	Total           int64      `json:"total" gorm:"column:total"`
+	PaymentMethod   string     `json:"payment_method" gorm:"column:payment_method"`
+	Comment         string     `json:"comment" gorm:"column:comment"`
+	Contactless     bool       `json:"contactless" gorm:"column:contactless"`
+	CourierID       *string    `json:"courier_id" gorm:"column:courier_id"`
+	DeliveryZoneID  *string    `json:"delivery_zone_id" gorm:"column:delivery_zone_id"`
+	CancelFee       int64      `json:"cancel_fee" gorm:"column:cancel_fee"`
+	PrepMinutes     *int       `json:"prep_minutes" gorm:"column:prep_minutes"`
+	AcceptedAt      *time.Time `json:"accepted_at" gorm:"column:accepted_at"`
+	ReadyAt         *time.Time `json:"ready_at" gorm:"column:ready_at"`
+	PickedUpAt      *time.Time `json:"picked_up_at" gorm:"column:picked_up_at"`
+	DeliveredAt     *time.Time `json:"delivered_at" gorm:"column:delivered_at"`
+	PaidAt          *time.Time `json:"paid_at" gorm:"column:paid_at"`
+	// orders of one batch are carried by one courier and delivered in BatchSeq order
+	BatchID  *string `json:"batch_id" gorm:"column:batch_id"`
+	BatchSeq *int    `json:"batch_seq" gorm:"column:batch_seq"`
+	// HandoffCode is never sent to couriers and sellers, the customer gets it as CustomerHandoffCode
+	HandoffCode         string      `json:"-" gorm:"column:handoff_code"`
+	CustomerHandoffCode string      `json:"handoff_code,omitempty" gorm:"-"`
+	CreatedAt           time.Time   `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt           time.Time   `json:"updated_at" gorm:"column:updated_at"`
+	Items               []OrderItem `json:"items,omitempty" gorm:"-"`
+	ETA                 *OrderETA   `json:"eta,omitempty" gorm:"-"`
+	// Batch are the other orders the courier carries with this one
+	Batch []Order `json:"batch,omitempty" gorm:"-"`
+	// Payment is the invoice the customer pays a card order with
+	Payment *Payment `json:"payment,omitempty" gorm:"-"`
+}
+
+// ExpectedReadyAt is when the seller is expected to hand the order over, never before now
+func (o Order) ExpectedReadyAt(now time.Time) time.Time {
+	switch o.Status {
+	case constants.OrderStatusReady, constants.OrderStatusPickedUp:
+		if o.ReadyAt != nil {
+			return *o.ReadyAt
+		}
+		return now
+	default:
+		prep := constants.DefaultPrepMinutes
+		if o.PrepMinutes != nil {
+			prep = *o.PrepMinutes
+		}
+		start := now
+		if o.AcceptedAt != nil {
+			start = *o.AcceptedAt
+		}
+		readyAt := start.Add(time.Duration(prep) * time.Minute)
+		if readyAt.Before(now) {
+			return now
+		}
+		return readyAt
+	}
+}
+
+type OrderItem struct {
+	ID        string    `json:"id" gorm:"column:id"`
+	OrderID   string    `json:"order_id" gorm:"column:order_id"`
+	ProductID string    `json:"product_id" gorm:"column:product_id"`
+	Name      string    `json:"name" gorm:"column:name"`
+	Price     int64     `json:"price" gorm:"column:price"`
+	Quantity  int       `json:"quantity" gorm:"column:quantity"`
+	Modifiers Modifiers `json:"modifiers" gorm:"column:modifiers;type:json"`
+}
+
+// Modifiers are customer wishes for an item like "no onions" or "extra sauce"
+type Modifiers []string
+
+func (m *Modifiers) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to scan Modifiers, unexpected type %T", value)
+	}
+	if err := json.Unmarshal(bytes, m); err != nil {
+		return fmt.Errorf("failed to unmarshal Modifiers JSON: %w", err)
+	}
+	return nil
+}
+
+func (m Modifiers) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+type PlaceOrderItem struct {
+	ProductID string    `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Modifiers Modifiers `json:"modifiers"`
+}
+
+type PlaceOrderReq struct {
+	UserID     string `json:"-"`
+	XozmakID   string `json:"xozmak_id"`
+	SlotID     string `json:"slot_id"`
+	LocationID string `json:"location_id"`
+	Comment    string `json:"comment"`
+	// PaymentMethod is cash, card or wallet, cash by default
+	PaymentMethod string `json:"payment_method"`
+	// PaymentProvider takes a card payment, the default one when empty
+	PaymentProvider string           `json:"payment_provider"`
+	Items           []PlaceOrderItem `json:"items"`
+	// Tip for the courier, paid together with the order
+	Tip *TipReq `json:"tip"`
+	// Contactless orders are left at the door, they can not be paid in cash
+	Contactless bool `json:"contactless"`
+}
+
+func (req *PlaceOrderReq) Validate() error {
+	if !utils.IsValidUUID(req.XozmakID) {
+		return errors.New("invalid xozmak_id")
+	}
+	if req.SlotID != "" && !utils.IsValidUUID(req.SlotID) {
+		return errors.New("invalid slot_id")
+	}
+	if req.LocationID == "" {
+		return errors.New("location_id is required")
+	}
+	if req.PaymentMethod == "" {
+		req.PaymentMethod = constants.PaymentMethodCash
+	}
+	if !utils.InEnums(req.PaymentMethod, []string{constants.PaymentMethodCash, constants.PaymentMethodCard, constants.PaymentMethodWallet}) {
+		return errors.New("payment_method must be cash, card or wallet")
+	}
+	if req.Contactless && req.PaymentMethod == constants.PaymentMethodCash {
+		return errors.New("contactless orders can not be paid in cash")
+	}
+	if len(req.Items) == 0 {
+		return errors.New("order must contain at least one item")
+	}
+	if req.Tip != nil {
+		if err := req.Tip.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, item := range req.Items {
+		if !utils.IsValidUUID(item.ProductID) {
+			return errors.New("invalid product_id")
+		}
+		if item.Quantity <= 0 {
+			return errors.New("quantity must be positive")
+		}
+		if len(item.Modifiers) > constants.MaxItemModifiers {
+			return fmt.Errorf("an item can have at most %d modifiers", constants.MaxItemModifiers)
+		}
+	}
+	return nil
+}
+
+// TipReq is a tip of Amount so'm or of Percent of the items total
+type TipReq struct {
+	Amount  int64 `json:"amount"`
+	Percent int64 `json:"percent"`
+}
+
+func (t *TipReq) Validate() error {
+	if t.Amount < 0 || t.Percent < 0 {
+		return errors.New("tip can not be negative")
+	}
+	if t.Amount > 0 && t.Percent > 0 {
+		return errors.New("tip must be either an amount or a percent")
+	}
+	if t.Percent > constants.MaxTipPercent {
+		return fmt.Errorf("tip can be at most %d percent", constants.MaxTipPercent)
+	}
+	return nil
+}
+
+// TipAmount is the tip in so'm for an order with the items total
+func (t TipReq) TipAmount(itemsTotal int64) int64 {
+	if t.Percent > 0 {
+		return itemsTotal * t.Percent / 100
+	}
+	return t.Amount
+}
+
+// TipOrderReq tips the courier after the order is delivered
+type TipOrderReq struct {
+	OrderID string `json:"-"`
+	Actor   Actor  `json:"-"`
+	TipReq
+}
+
+func (r *TipOrderReq) Validate() error {
+	if r.Amount == 0 && r.Percent == 0 {
+		return errors.New("amount or percent of the tip is required")
+	}
+	return r.TipReq.Validate()
+}
+
+type OrderList struct {
+	Orders []Order `json:"orders"`
+	Count  int64   `json:"count"`
+}
+
+type CartItem struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	Price     int64  `json:"price"`
+	Quantity  int    `json:"quantity"`
+}
+
+type Cart struct {
+	XozmakID string     `json:"xozmak_id"`
+	Items    []CartItem `json:"items"`
+	Total    int64      `json:"total"`
+	// XozmakStatus tells at checkout whether the xozmak takes the order now
+	XozmakStatus *XozmakStatus `json:"xozmak_status,omitempty" gorm:"-"`
+}
+
+// ReorderIssue describes an item of the past order that could not be copied to the cart as is
+type ReorderIssue struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+	OldPrice  int64  `json:"old_price"`
+	NewPrice  int64  `json:"new_price"`
+}
+
+type ReorderRes struct {
+	Cart   Cart           `json:"cart"`
+	Issues []ReorderIssue `json:"issues"`
+}
+
+// OrderHistory is an audit record of every decision made about an order
+type OrderHistory struct {
+	ID         string    `json:"id" gorm:"column:id;default:uuid_generate_v4()"`
+	OrderID    string    `json:"order_id" gorm:"column:order_id"`
+	Action     string    `json:"action" gorm:"column:action"`
+	FromStatus string    `json:"from_status" gorm:"column:from_status"`
+	ToStatus   string    `json:"to_status" gorm:"column:to_status"`
+	ActorRole  string    `json:"actor_role" gorm:"column:actor_role"`
+	ActorID    *string   `json:"actor_id" gorm:"column:actor_id"`
+	ReasonCode string    `json:"reason_code" gorm:"column:reason_code"`
+	Fee        int64     `json:"fee" gorm:"column:fee"`
+	Note       string    `json:"note" gorm:"column:note"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+type Refund struct {
+	ID            string `json:"id" gorm:"column:id"`
+	OrderID       string `json:"order_id" gorm:"column:order_id"`
+	Amount        int64  `json:"amount" gorm:"column:amount"`
+	PaymentMethod string `json:"payment_method" gorm:"column:payment_method"`
+	Status        string `json:"status" gorm:"column:status"`
+	// PaymentID is the payment the money goes back to, found by the order when it is nil
+	PaymentID *string   `json:"payment_id" gorm:"column:payment_id"`
+	Attempts  int       `json:"attempts" gorm:"column:attempts"`
+	Error     string    `json:"error,omitempty" gorm:"column:error"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// Actor is whoever acts on an order: the customer, a seller, a courier, an admin or the system
+type Actor struct {
+	ID   string
+	Role string
+}
+
+type CancelOrderReq struct {
+	OrderID    string `json:"-"`
+	Actor      Actor  `json:"-"`
+	ReasonCode string `json:"reason_code"`
+	Note       string `json:"note"`
+}
+
+// OrderCancellation is everything that has to be written atomically when an order is cancelled
+type OrderCancellation struct {
+	Order    Order
+	ToStatus string
+	History  OrderHistory
+	Refund   *Refund
+}
+
+type CancelOrderRes struct {
+	OrderID      string `json:"order_id"`
+	Status       string `json:"status"`
+	Fee          int64  `json:"fee"`
+	RefundAmount int64  `json:"refund_amount"`
+}
+
+// OrderStatusChange moves an order from one status to another, Fields are updated together with the status
+type OrderStatusChange struct {
+	OrderID    string
+	FromStatus string
+	ToStatus   string
+	Fields     map[string]interface{}
+	History    OrderHistory
+	// Proof is saved together with the delivered status
+	Proof *DeliveryProof
+}
+
+type AcceptOrderReq struct {
+	OrderID     string `json:"-"`
+	Actor       Actor  `json:"-"`
+	PrepMinutes int    `json:"prep_minutes"`
+}
+
+// Notification goes to the user's inbox and as a push to their device,
+// Event tells the app which screen the push opens. Title and Body are rendered
+// from Template with Vars in the user's preferred language.
+type Notification struct {
+	ID        string            `json:"id" gorm:"column:id"`
+	UserID    string            `json:"user_id" gorm:"column:user_id"`
+	Title     string            `json:"title" gorm:"column:title"`
+	Body      string            `json:"body" gorm:"column:body"`
+	OrderID   *string           `json:"order_id" gorm:"column:order_id"`
+	IsRead    bool              `json:"is_read" gorm:"column:is_read"`
+	CreatedAt time.Time         `json:"created_at" gorm:"column:created_at"`
+	Event     string            `json:"-" gorm:"-"`
+	Template  string            `json:"-" gorm:"-"`
+	Vars      map[string]string `json:"-" gorm:"-"`
+}
+
+// OrderETA is when the order is expected to be ready, picked up and delivered
+type OrderETA struct {
+	ReadyAt           time.Time  `json:"ready_at"`
+	PickupAt          *time.Time `json:"pickup_at,omitempty"`
+	DeliveryAt        time.Time  `json:"delivery_at"`
+	DeliveryInMinutes int        `json:"delivery_in_minutes"`
+	CourierDistanceKm *float64   `json:"courier_distance_km,omitempty"`
+	CalculatedAt      time.Time  `json:"calculated_at"`
+}
+
+// DeliveryProof shows where, when and how the order was handed over
+type DeliveryProof struct {
+	OrderID   string    `json:"order_id" gorm:"column:order_id"`
+	CourierID string    `json:"courier_id" gorm:"column:courier_id"`
+	Method    string    `json:"method" gorm:"column:method"`
+	PhotoKey  *string   `json:"photo_key" gorm:"column:photo_key"`
+	Latitude  float64   `json:"latitude" gorm:"column:latitude"`
+	Longitude float64   `json:"longitude" gorm:"column:longitude"`
+	Accuracy  float64   `json:"accuracy" gorm:"column:accuracy"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// ConfirmDeliveryReq completes the order by support after they talked to the customer
+type ConfirmDeliveryReq struct {
+	OrderID string `json:"-"`
+	Actor   Actor  `json:"-"`
+	Note    string `json:"note"`
+}
+
+func (r *ConfirmDeliveryReq) Validate() error {
+	if strings.TrimSpace(r.Note) == "" {
+		return errors.New("note on how the handover was confirmed is required")
+	}
+	return nil
+}
+
+// DeliverOrderReq completes the order with the handoff code or a photo of the drop,
+// the photo is uploaded first and its key is sent here
+type DeliverOrderReq struct {
+	OrderID  string  `json:"-"`
+	Actor    Actor   `json:"-"`
+	Code     string  `json:"code"`
+	PhotoKey string  `json:"photo_key"`
+	Lat      float64 `json:"lat"`
+	Long     float64 `json:"long"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+func (r *DeliverOrderReq) Validate() error {
+	if r.Lat < -90 || r.Lat > 90 || r.Long < -180 || r.Long > 180 || (r.Lat == 0 && r.Long == 0) {
+		return errors.New("lat and long of the handover place are required")
+	}
+	if r.Accuracy < 0 {
+		return errors.New("accuracy can not be negative")
+	}
+	return nil
+}
+
+// ProofPhoto is an uploaded photo of the drop, its key is sent with the delivery
+type ProofPhoto struct {
+	Key string `json:"photo_key"`
+}
@@ -0,0 +1,107 @@
+package entities
+
+import "encoding/json"
+
+// PaymeTransaction is a transaction Payme created for a payment of an order.
+// Amount is in tiyins and the times are Unix milliseconds as in the merchant API.
+type PaymeTransaction struct {
+	ID          string `json:"id" gorm:"column:id"`
+	PaymentID   string `json:"payment_id" gorm:"column:payment_id"`
+	OrderID     string `json:"order_id" gorm:"column:order_id"`
+	Amount      int64  `json:"amount" gorm:"column:amount"`
+	State       int    `json:"state" gorm:"column:state"`
+	Reason      *int   `json:"reason" gorm:"column:reason"`
+	PaymeTime   int64  `json:"payme_time" gorm:"column:payme_time"`
+	CreateTime  int64  `json:"create_time" gorm:"column:create_time"`
+	PerformTime int64  `json:"perform_time" gorm:"column:perform_time"`
+	CancelTime  int64  `json:"cancel_time" gorm:"column:cancel_time"`
+}
+
+// PaymeRequest is a JSON-RPC call of the Payme merchant API
+type PaymeRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params PaymeParams     `json:"params"`
+}
+
+// PaymeParams holds the params of all the methods, every method uses a part of them
+type PaymeParams struct {
+	ID      string       `json:"id"`
+	Time    int64        `json:"time"`
+	Amount  int64        `json:"amount"`
+	Account PaymeAccount `json:"account"`
+	Reason  *int         `json:"reason"`
+	From    int64        `json:"from"`
+	To      int64        `json:"to"`
+}
+
+type PaymeAccount struct {
+	OrderID string `json:"order_id"`
+}
+
+type PaymeResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  *PaymeError     `json:"error,omitempty"`
+}
+
+type PaymeError struct {
+	Code    int          `json:"code"`
+	Message PaymeMessage `json:"message"`
+	// Data names the account field the error is about
+	Data string `json:"data,omitempty"`
+}
+
+type PaymeMessage struct {
+	Ru string `json:"ru"`
+	Uz string `json:"uz"`
+	En string `json:"en"`
+}
+
+type PaymeCheckPerformRes struct {
+	Allow bool `json:"allow"`
+}
+
+type PaymeCreateRes struct {
+	CreateTime  int64  `json:"create_time"`
+	Transaction string `json:"transaction"`
+	State       int    `json:"state"`
+}
+
+type PaymePerformRes struct {
+	Transaction string `json:"transaction"`
+	PerformTime int64  `json:"perform_time"`
+	State       int    `json:"state"`
+}
+
+type PaymeCancelRes struct {
+	Transaction string `json:"transaction"`
+	CancelTime  int64  `json:"cancel_time"`
+	State       int    `json:"state"`
+}
+
+type PaymeCheckRes struct {
+	CreateTime  int64  `json:"create_time"`
+	PerformTime int64  `json:"perform_time"`
+	CancelTime  int64  `json:"cancel_time"`
+	Transaction string `json:"transaction"`
+	State       int    `json:"state"`
+	Reason      *int   `json:"reason"`
+}
+
+type PaymeStatementRes struct {
+	Transactions []PaymeStatementItem `json:"transactions"`
+}
+
+type PaymeStatementItem struct {
+	ID          string       `json:"id"`
+	Time        int64        `json:"time"`
+	Amount      int64        `json:"amount"`
+	Account     PaymeAccount `json:"account"`
+	CreateTime  int64        `json:"create_time"`
+	PerformTime int64        `json:"perform_time"`
+	CancelTime  int64        `json:"cancel_time"`
+	Transaction string       `json:"transaction"`
+	State       int          `json:"state"`
+	Reason      *int         `json:"reason"`
+}
@@ -0,0 +1,21 @@
+package entities
+
+import "time"
+
+// Payment is one attempt to pay an order online, or a tip after the order is delivered
+type Payment struct {
+	ID                string     `json:"id" gorm:"column:id"`
+	OrderID           string     `json:"order_id" gorm:"column:order_id"`
+	UserID            string     `json:"user_id" gorm:"column:user_id"`
+	Purpose           string     `json:"purpose" gorm:"column:purpose;default:order"`
+	Provider          string     `json:"provider" gorm:"column:provider"`
+	ProviderPaymentID *string    `json:"provider_payment_id" gorm:"column:provider_payment_id"`
+	Amount            int64      `json:"amount" gorm:"column:amount"`
+	RefundedAmount    int64      `json:"refunded_amount" gorm:"column:refunded_amount"`
+	Status            string     `json:"status" gorm:"column:status"`
+	PayURL            string     `json:"pay_url,omitempty" gorm:"column:pay_url"`
+	Error             string     `json:"error,omitempty" gorm:"column:error"`
+	CreatedAt         time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"column:updated_at"`
+	PaidAt            *time.Time `json:"paid_at" gorm:"column:paid_at"`
+}
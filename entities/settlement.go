@@ -0,0 +1,201 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var commissionTypes = []string{
+	constants.CommissionTypePercent,
+	constants.CommissionTypeFixed,
+	constants.CommissionTypeTiered,
+}
+
+// CommissionTier applies Percent to items totals from MinAmount up to the next tier
+type CommissionTier struct {
+	MinAmount int64   `json:"min_amount"`
+	Percent   float64 `json:"percent"`
+}
+
+type CommissionTiers []CommissionTier
+
+func (t *CommissionTiers) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to scan CommissionTiers, unexpected type %T", value)
+	}
+	if err := json.Unmarshal(bytes, t); err != nil {
+		return fmt.Errorf("failed to unmarshal CommissionTiers JSON: %w", err)
+	}
+	return nil
+}
+
+func (t CommissionTiers) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+// CommissionRule is what the platform takes from the items of a xozmak, of a category
+// or of both. The rule with neither is the default one.
+type CommissionRule struct {
+	ID          string          `json:"id" gorm:"column:id"`
+	XozmakID    *string         `json:"xozmak_id" gorm:"column:xozmak_id"`
+	CategoryID  *string         `json:"category_id" gorm:"column:category_id"`
+	Type        string          `json:"type" gorm:"column:type"`
+	Percent     float64         `json:"percent" gorm:"column:percent"`
+	FixedAmount int64           `json:"fixed_amount" gorm:"column:fixed_amount"`
+	Tiers       CommissionTiers `json:"tiers" gorm:"column:tiers;type:json"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func validPercent(percent float64) bool {
+	return percent >= 0 && percent <= 100
+}
+
+func (r *CommissionRule) Validate() error {
+	if r.XozmakID != nil && !utils.IsValidUUID(*r.XozmakID) {
+		return errors.New("xozmak_id must be a valid uuid")
+	}
+	if r.CategoryID != nil && !utils.IsValidUUID(*r.CategoryID) {
+		return errors.New("category_id must be a valid uuid")
+	}
+	if !utils.InEnums(r.Type, commissionTypes) {
+		return errors.New("type must be percent, fixed or tiered")
+	}
+	switch r.Type {
+	case constants.CommissionTypePercent:
+		if !validPercent(r.Percent) {
+			return errors.New("percent must be between 0 and 100")
+		}
+	case constants.CommissionTypeFixed:
+		if r.FixedAmount <= 0 {
+			return errors.New("fixed_amount must be positive")
+		}
+	case constants.CommissionTypeTiered:
+		if len(r.Tiers) == 0 || r.Tiers[0].MinAmount != 0 {
+			return errors.New("tiers must start with min_amount 0")
+		}
+		for i, tier := range r.Tiers {
+			if !validPercent(tier.Percent) {
+				return errors.New("tier percent must be between 0 and 100")
+			}
+			if i > 0 && tier.MinAmount <= r.Tiers[i-1].MinAmount {
+				return errors.New("tiers must be sorted by min_amount")
+			}
+		}
+	}
+	return nil
+}
+
+// Matches tells how well the rule fits an item of the xozmak in the category,
+// -1 when it does not apply and 3 when both the xozmak and the category match
+func (r CommissionRule) Matches(xozmakId, categoryId string) int {
+	if r.XozmakID != nil && *r.XozmakID != xozmakId {
+		return -1
+	}
+	if r.CategoryID != nil && *r.CategoryID != categoryId {
+		return -1
+	}
+	score := 0
+	if r.XozmakID != nil {
+		score += 2
+	}
+	if r.CategoryID != nil {
+		score++
+	}
+	return score
+}
+
+func percentOf(amount int64, percent float64) int64 {
+	return int64(math.Round(float64(amount) * percent / 100))
+}
+
+// Commission is what the rule takes from the items total it applies to
+func (r CommissionRule) Commission(gross int64) int64 {
+	switch r.Type {
+	case constants.CommissionTypeFixed:
+		return min(r.FixedAmount, gross)
+	case constants.CommissionTypeTiered:
+		percent := 0.0
+		for _, tier := range r.Tiers {
+			if gross >= tier.MinAmount {
+				percent = tier.Percent
+			}
+		}
+		return percentOf(gross, percent)
+	default:
+		return percentOf(gross, r.Percent)
+	}
+}
+
+// OrderCommission is the commission of a delivered order, SettlementID is set once it is settled
+type OrderCommission struct {
+	OrderID      string    `json:"order_id" gorm:"column:order_id"`
+	OrderNumber  int64     `json:"order_number" gorm:"column:number;->"`
+	XozmakID     string    `json:"xozmak_id" gorm:"column:xozmak_id"`
+	Gross        int64     `json:"gross" gorm:"column:gross"`
+	Commission   int64     `json:"commission" gorm:"column:commission"`
+	SettlementID *string   `json:"settlement_id" gorm:"column:settlement_id"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// SettlementRefund is a refund of a delivered order taken from the xozmak's payout
+type SettlementRefund struct {
+	SourceID     string    `json:"source_id" gorm:"column:source_id"`
+	SettlementID string    `json:"settlement_id" gorm:"column:settlement_id"`
+	OrderID      string    `json:"order_id" gorm:"column:order_id"`
+	Amount       int64     `json:"amount" gorm:"column:amount"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// Settlement is what the platform owes a xozmak for the orders delivered before PeriodEnd
+type Settlement struct {
+	ID         string     `json:"id" gorm:"column:id"`
+	XozmakID   string     `json:"xozmak_id" gorm:"column:xozmak_id"`
+	XozmakName string     `json:"xozmak_name" gorm:"column:xozmak_name;->"`
+	PeriodEnd  time.Time  `json:"period_end" gorm:"column:period_end"`
+	Orders     int        `json:"orders" gorm:"column:orders"`
+	Gross      int64      `json:"gross" gorm:"column:gross"`
+	Commission int64      `json:"commission" gorm:"column:commission"`
+	Refunds    int64      `json:"refunds" gorm:"column:refunds"`
+	NetPayable int64      `json:"net_payable" gorm:"column:net_payable"`
+	Status     string     `json:"status" gorm:"column:status"`
+	CreatedBy  *string    `json:"created_by" gorm:"column:created_by"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at"`
+	SettledBy  *string    `json:"settled_by" gorm:"column:settled_by"`
+	SettledAt  *time.Time `json:"settled_at" gorm:"column:settled_at"`
+
+	OrderLines  []OrderCommission  `json:"order_lines,omitempty" gorm:"-"`
+	RefundLines []SettlementRefund `json:"refund_lines,omitempty" gorm:"-"`
+}
+
+type SettlementList struct {
+	Settlements []Settlement `json:"settlements"`
+	Count       int64        `json:"count"`
+}
+
+type SettlementsReq struct {
+	Actor Actor `json:"-"`
+	// PeriodEnd defaults to now
+	PeriodEnd *time.Time `json:"period_end"`
+}
+
+type SettlementFilter struct {
+	XozmakID string
+	Status   string
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"delivery/pkg/templates"
+	"delivery/pkg/utils"
+	"errors"
+	"time"
+)
+
+// NotificationTemplate is the text of a template in one language. The built-in texts
+// live in pkg/templates, the ones admins edited are kept in notification_templates
+// and are marked Edited.
+type NotificationTemplate struct {
+	Key       string     `json:"key" gorm:"column:key"`
+	Language  string     `json:"language" gorm:"column:language"`
+	Title     string     `json:"title" gorm:"column:title"`
+	Body      string     `json:"body" gorm:"column:body"`
+	Variables []string   `json:"variables" gorm:"-"`
+	Edited    bool       `json:"edited" gorm:"-"`
+	UpdatedBy *string    `json:"updated_by" gorm:"column:updated_by"`
+	UpdatedAt *time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+func (t *NotificationTemplate) Validate() error {
+	if !utils.InEnums(t.Language, templates.Languages) {
+		return errors.New("language must be uz or ru")
+	}
+	return nil
+}
+
+// TemplatePreviewReq renders Title and Body, or the current text of the template
+// when they are empty, with Vars or with sample values
+type TemplatePreviewReq struct {
+	Key      string            `json:"-"`
+	Language string            `json:"-"`
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Vars     map[string]string `json:"vars"`
+}
+
+func (r *TemplatePreviewReq) Validate() error {
+	if !utils.InEnums(r.Language, templates.Languages) {
+		return errors.New("language must be uz or ru")
+	}
+	return nil
+}
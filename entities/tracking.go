@@ -0,0 +1,14 @@
+package entities
+
+import "time"
+
+// OrderEvent is sent to customers following the order in real time
+type OrderEvent struct {
+	Type      string         `json:"type"`
+	OrderID   string         `json:"order_id"`
+	Status    string         `json:"status,omitempty"`
+	CourierID *string        `json:"courier_id,omitempty"`
+	Position  *LocationPoint `json:"position,omitempty"`
+	ETA       *OrderETA      `json:"eta,omitempty"`
+	At        time.Time      `json:"at"`
+}
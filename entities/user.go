@@ -52,6 +52,7 @@ type UserProfile struct {
 	PhoneNumber string   `json:"phone_number" gorm:"column:phone_number"`
 	Birthdate  time.Time `json:"birthdate" gorm:"column:birthdate"`
 	Gender     string    `json:"gender" gorm:"column:gender"`
+	PreferredLanguage string `json:"preferred_language" gorm:"column:preferred_language"`
 	CreatedBy  string    `json:"created_by" gorm:"column:created_by"`
 	UpdatedBy  string    `json:"updated_by" gorm:"column:updated_by"`
 	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
@@ -66,3 +67,9 @@ type UserLocation struct {
 	Latitude  float64 `gorm:"latitude" json:"latitude"`
 	Longitude float64 `gorm:"longitude" json:"longitude"`
 }
+
+// UserAccount is used to issue tokens for an already registered phone number
+type UserAccount struct {
+	ID   string `gorm:"column:id"`
+	Role string `gorm:"column:role"`
+}
@@ -0,0 +1,135 @@
+package entities
+
+import (
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"errors"
+	"time"
+)
+
+// WalletTransaction is one change of the customer's wallet. It is never updated,
+// its entries move money between the wallet and another account and sum up to zero.
+type WalletTransaction struct {
+	ID       string  `json:"id" gorm:"column:id"`
+	Type     int     `json:"type" gorm:"column:type"`
+	Kind     string  `json:"kind" gorm:"column:kind"`
+	UserID   string  `json:"user_id" gorm:"column:user_id"`
+	OrderID  *string `json:"order_id" gorm:"column:order_id"`
+	RefundID *string `json:"refund_id,omitempty" gorm:"column:refund_id"`
+	// Amount is the change of the balance, negative when the money is spent
+	Amount    int64         `json:"amount" gorm:"column:amount"`
+	Note      string        `json:"note" gorm:"column:note"`
+	CreatedBy *string       `json:"created_by,omitempty" gorm:"column:created_by"`
+	CreatedAt time.Time     `json:"created_at" gorm:"column:created_at"`
+	Entries   []LedgerEntry `json:"entries,omitempty" gorm:"-"`
+}
+
+// Balanced tells whether debits and credits of the transaction are equal
+func (t WalletTransaction) Balanced() bool {
+	if len(t.Entries) < 2 {
+		return false
+	}
+	var sum int64
+	for _, entry := range t.Entries {
+		sum += entry.Amount
+	}
+	return sum == 0
+}
+
+// walletKindTypes is the transaction type of every wallet kind
+var walletKindTypes = map[string]int{
+	constants.WalletKindCashback:     constants.IncomeTransactionID,
+	constants.WalletKindRefund:       constants.IncomeTransactionID,
+	constants.WalletKindTopUp:        constants.IncomeTransactionID,
+	constants.WalletKindOrderPayment: constants.ExpenseTransactionID,
+	constants.WalletKindTip:          constants.ExpenseTransactionID,
+}
+
+// ValidTransactionType tells whether t is one of the transaction types
+func ValidTransactionType(t int) bool {
+	return t == constants.IncomeTransactionID || t == constants.ExpenseTransactionID || t == constants.TransferTransactionID
+}
+
+func WalletAccount(userId string) string {
+	return constants.LedgerAccountWalletPrefix + userId
+}
+
+// WalletCredit puts amount into the wallet, the money is taken from the from account
+func WalletCredit(id, kind, userId string, orderId *string, amount int64, from, note string) WalletTransaction {
+	return WalletTransaction{
+		ID:        id,
+		Type:      walletKindTypes[kind],
+		Kind:      kind,
+		UserID:    userId,
+		OrderID:   orderId,
+		Amount:    amount,
+		Note:      note,
+		CreatedAt: time.Now(),
+		Entries: []LedgerEntry{
+			{Account: from, Amount: amount},
+			{Account: WalletAccount(userId), Amount: -amount},
+		},
+	}
+}
+
+// WalletDebit spends amount from the wallet on the to account
+func WalletDebit(id, kind, userId string, orderId *string, amount int64, to, note string) WalletTransaction {
+	return WalletTransaction{
+		ID:        id,
+		Type:      walletKindTypes[kind],
+		Kind:      kind,
+		UserID:    userId,
+		OrderID:   orderId,
+		Amount:    -amount,
+		Note:      note,
+		CreatedAt: time.Now(),
+		Entries: []LedgerEntry{
+			{Account: WalletAccount(userId), Amount: amount},
+			{Account: to, Amount: -amount},
+		},
+	}
+}
+
+type Wallet struct {
+	UserID  string `json:"user_id"`
+	Balance int64  `json:"balance"`
+}
+
+type WalletHistory struct {
+	Transactions []WalletTransaction `json:"transactions"`
+	Count        int64               `json:"count"`
+}
+
+var creditKinds = []string{
+	constants.WalletKindTopUp,
+	constants.WalletKindRefund,
+}
+
+// WalletCreditReq is a top-up or a goodwill refund given by an admin
+type WalletCreditReq struct {
+	UserID  string  `json:"-"`
+	Actor   Actor   `json:"-"`
+	Kind    string  `json:"kind"`
+	Amount  int64   `json:"amount"`
+	OrderID *string `json:"order_id"`
+	Note    string  `json:"note"`
+}
+
+func (r *WalletCreditReq) Validate() error {
+	if r.Kind == "" {
+		r.Kind = constants.WalletKindTopUp
+	}
+	if !utils.InEnums(r.Kind, creditKinds) {
+		return errors.New("kind must be top_up or refund")
+	}
+	if r.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if r.OrderID != nil && !utils.IsValidUUID(*r.OrderID) {
+		return errors.New("order_id must be a valid uuid")
+	}
+	if r.Note == "" {
+		return errors.New("note is required")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"delivery/constants"
+	"delivery/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DeliveryZone is an area drawn by admins where a xozmak delivers with its own fee and minimum order
+type DeliveryZone struct {
+	ID          string    `json:"id" gorm:"column:id"`
+	XozmakID    string    `json:"xozmak_id" gorm:"column:xozmak_id"`
+	Name        string    `json:"name" gorm:"column:name"`
+	Area        GeoArea   `json:"area" gorm:"column:area;type:json"`
+	DeliveryFee int64     `json:"delivery_fee" gorm:"column:delivery_fee"`
+	MinOrder    int64     `json:"min_order" gorm:"column:min_order"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+	// the bounding box of the area, zones around a point are looked up by it
+	MinLat  float64 `json:"-" gorm:"column:min_lat"`
+	MaxLat  float64 `json:"-" gorm:"column:max_lat"`
+	MinLong float64 `json:"-" gorm:"column:min_long"`
+	MaxLong float64 `json:"-" gorm:"column:max_long"`
+}
+
+func (z *DeliveryZone) Validate() error {
+	if z.Name == "" {
+		return errors.New("name is required")
+	}
+	if z.DeliveryFee < 0 || z.MinOrder < 0 {
+		return errors.New("delivery_fee and min_order can not be negative")
+	}
+	if err := z.Area.Validate(); err != nil {
+		return err
+	}
+	z.MinLat, z.MaxLat, z.MinLong, z.MaxLong = z.Area.bounds()
+	return nil
+}
+
+// Contains reports whether the point is inside the zone
+func (z DeliveryZone) Contains(lat, long float64) bool {
+	for _, polygon := range z.Area.polygons() {
+		if utils.PointInPolygon(lat, long, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchZone picks the zone that delivers to the point. When zones overlap the one with the
+// lowest delivery fee wins, then the one with the lowest minimum order, then the first one.
+func MatchZone(zones []DeliveryZone, lat, long float64) (DeliveryZone, bool) {
+	var (
+		match DeliveryZone
+		found bool
+	)
+	for _, zone := range zones {
+		if !zone.Contains(lat, long) {
+			continue
+		}
+		cheaper := zone.DeliveryFee < match.DeliveryFee ||
+			(zone.DeliveryFee == match.DeliveryFee && zone.MinOrder < match.MinOrder)
+		if !found || cheaper {
+			match = zone
+			found = true
+		}
+	}
+	return match, found
+}
+
+// GeoArea is a GeoJSON Polygon or MultiPolygon geometry with [long, lat] positions
+type GeoArea struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// polygons returns the area as a list of polygons, nil when the geometry is broken
+func (a GeoArea) polygons() [][][][]float64 {
+	switch a.Type {
+	case constants.GeoJSONPolygon:
+		var polygon [][][]float64
+		if err := json.Unmarshal(a.Coordinates, &polygon); err != nil {
+			return nil
+		}
+		return [][][][]float64{polygon}
+	case constants.GeoJSONMultiPolygon:
+		var polygons [][][][]float64
+		if err := json.Unmarshal(a.Coordinates, &polygons); err != nil {
+			return nil
+		}
+		return polygons
+	}
+	return nil
+}
+
+// bounds returns the box around the outer rings of the area
+func (a GeoArea) bounds() (minLat, maxLat, minLong, maxLong float64) {
+	first := true
+	for _, polygon := range a.polygons() {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, position := range polygon[0] {
+			if len(position) < 2 {
+				continue
+			}
+			long, lat := position[0], position[1]
+			if first {
+				minLat, maxLat, minLong, maxLong = lat, lat, long, long
+				first = false
+				continue
+			}
+			minLat, maxLat = math.Min(minLat, lat), math.Max(maxLat, lat)
+			minLong, maxLong = math.Min(minLong, long), math.Max(maxLong, long)
+		}
+	}
+	return minLat, maxLat, minLong, maxLong
+}
+
+func (a GeoArea) Validate() error {
+	if a.Type != constants.GeoJSONPolygon && a.Type != constants.GeoJSONMultiPolygon {
+		return errors.New("area must be a GeoJSON Polygon or MultiPolygon")
+	}
+	polygons := a.polygons()
+	if len(polygons) == 0 {
+		return errors.New("area coordinates are invalid")
+	}
+	for _, polygon := range polygons {
+		if len(polygon) == 0 {
+			return errors.New("polygon must have an outer ring")
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				return errors.New("polygon ring must have at least 4 positions")
+			}
+			for _, position := range ring {
+				if len(position) < 2 || position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+					return errors.New("positions must be [long, lat] pairs")
+				}
+			}
+			first, last := ring[0], ring[len(ring)-1]
+			if first[0] != last[0] || first[1] != last[1] {
+				return errors.New("polygon ring must be closed")
+			}
+		}
+	}
+	return nil
+}
+
+func (a *GeoArea) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to scan GeoArea, unexpected type %T", value)
+	}
+	if err := json.Unmarshal(bytes, a); err != nil {
+		return fmt.Errorf("failed to unmarshal GeoArea JSON: %w", err)
+	}
+	return nil
+}
+
+func (a GeoArea) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// DeliveringXozmak is a xozmak that delivers to the customer's address
+type DeliveringXozmak struct {
+	Xozmak
+	ZoneID      string `json:"zone_id"`
+	DeliveryFee int64  `json:"delivery_fee"`
+	MinOrder    int64  `json:"min_order"`
+}
@@ -0,0 +1,113 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// square returns a GeoJSON polygon ring around the point, positions are [long, lat]
+func square(lat, long, half float64) [][]float64 {
+	return [][]float64{
+		{long - half, lat - half},
+		{long + half, lat - half},
+		{long + half, lat + half},
+		{long - half, lat + half},
+		{long - half, lat - half},
+	}
+}
+
+func area(t *testing.T, geometryType string, coordinates interface{}) GeoArea {
+	t.Helper()
+	raw, err := json.Marshal(coordinates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return GeoArea{Type: geometryType, Coordinates: raw}
+}
+
+func TestGeoAreaValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		area  GeoArea
+		valid bool
+	}{
+		{"polygon", area(t, "Polygon", [][][]float64{square(41.3, 69.2, 0.1)}), true},
+		{"multipolygon", area(t, "MultiPolygon", [][][][]float64{{square(41.3, 69.2, 0.1)}, {square(40.1, 67.8, 0.1)}}), true},
+		{"polygon with a hole", area(t, "Polygon", [][][]float64{square(41.3, 69.2, 0.1), square(41.3, 69.2, 0.01)}), true},
+		{"point", area(t, "Point", []float64{69.2, 41.3}), false},
+		{"ring that is not closed", area(t, "Polygon", [][][]float64{square(41.3, 69.2, 0.1)[:4]}), false},
+		{"ring with three positions", area(t, "Polygon", [][][]float64{{{69.1, 41.2}, {69.3, 41.2}, {69.1, 41.2}}}), false},
+		{"lat outside its range", area(t, "Polygon", [][][]float64{square(95, 69.2, 0.1)}), false},
+		{"long outside its range", area(t, "Polygon", [][][]float64{square(41.3, 185, 0.1)}), false},
+		{"position without lat", area(t, "Polygon", [][][]float64{{{69.1}, {69.3}, {69.2}, {69.1}}}), false},
+		{"polygon coordinates given as a multipolygon", area(t, "Polygon", [][][][]float64{{square(41.3, 69.2, 0.1)}}), false},
+		{"polygon without rings", area(t, "Polygon", [][][]float64{}), false},
+	}
+	for _, c := range cases {
+		err := c.area.Validate()
+		if (err == nil) != c.valid {
+			t.Fatalf("%s: Validate() = %v, want valid %v", c.name, err, c.valid)
+		}
+	}
+}
+
+func TestZoneValidateSetsBounds(t *testing.T) {
+	zone := DeliveryZone{
+		Name: "Chilonzor",
+		Area: area(t, "MultiPolygon", [][][][]float64{{square(41.3, 69.2, 0.1)}, {square(41.5, 69.5, 0.05)}}),
+	}
+	if err := zone.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	near := func(a, b float64) bool { return a-b < 1e-9 && b-a < 1e-9 }
+	if !near(zone.MinLat, 41.2) || !near(zone.MaxLat, 41.55) || !near(zone.MinLong, 69.1) || !near(zone.MaxLong, 69.55) {
+		t.Fatalf("unexpected bounds %v %v %v %v", zone.MinLat, zone.MaxLat, zone.MinLong, zone.MaxLong)
+	}
+}
+
+func TestMatchZone(t *testing.T) {
+	// the city zone overlaps the centre zones, positions are [long, lat]
+	city := DeliveryZone{ID: "city", DeliveryFee: 15000, MinOrder: 30000,
+		Area: area(t, "Polygon", [][][]float64{square(41.3, 69.25, 0.1)})}
+	centre := DeliveryZone{ID: "centre", DeliveryFee: 8000, MinOrder: 60000,
+		Area: area(t, "Polygon", [][][]float64{square(41.31, 69.24, 0.02)})}
+	centreLowMin := DeliveryZone{ID: "centre-low-min", DeliveryFee: 8000, MinOrder: 40000,
+		Area: area(t, "Polygon", [][][]float64{square(41.31, 69.24, 0.01)})}
+	suburbs := DeliveryZone{ID: "suburbs", DeliveryFee: 20000,
+		Area: area(t, "MultiPolygon", [][][][]float64{{square(41.5, 69.5, 0.05)}, {square(41.1, 69.0, 0.05)}})}
+	zones := []DeliveryZone{city, centre, centreLowMin, suburbs}
+
+	cases := []struct {
+		name string
+		lat  float64
+		long float64
+		want string
+	}{
+		{"only in the city zone", 41.25, 69.3, "city"},
+		{"the lower fee wins over the city zone", 41.325, 69.255, "centre"},
+		{"the lower minimum order wins at the same fee", 41.31, 69.24, "centre-low-min"},
+		{"on the border of the centre zone", 41.29, 69.24, "centre"},
+		{"in the second polygon of a multipolygon", 41.1, 69.0, "suburbs"},
+		{"outside every zone", 40.0, 68.0, ""},
+		{"lat and long swapped", 69.24, 41.31, ""},
+	}
+	for _, c := range cases {
+		zone, ok := MatchZone(zones, c.lat, c.long)
+		if c.want == "" {
+			if ok {
+				t.Fatalf("%s: matched %s", c.name, zone.ID)
+			}
+			continue
+		}
+		if !ok || zone.ID != c.want {
+			t.Fatalf("%s: matched %q (%v), want %q", c.name, zone.ID, ok, c.want)
+		}
+	}
+
+	// the zone listed first wins when the fee and minimum order are the same
+	twin := centreLowMin
+	twin.ID = "twin"
+	if zone, _ := MatchZone([]DeliveryZone{centreLowMin, twin}, 41.31, 69.24); zone.ID != "centre-low-min" {
+		t.Fatalf("matched %s, want the first zone", zone.ID)
+	}
+}
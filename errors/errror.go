@@ -22,3 +22,116 @@ var (
 
 	ErrInvalidInput = e.NewError(http.StatusBadRequest, "invalid input")
 )
+
+var (
+	ErrProductNotFound   = e.NewError(http.StatusBadRequest, "product not found")
+	ErrProductOutOfStock = e.NewError(http.StatusBadRequest, "product is out of stock")
+	ErrXozmakNotFound    = e.NewError(http.StatusNotFound, "xozmak not found")
+	ErrLocationNotFound  = e.NewError(http.StatusNotFound, "location not found")
+	ErrSlotNotFound      = e.NewError(http.StatusNotFound, "delivery slot not found")
+	ErrSlotUnavailable   = e.NewError(http.StatusBadRequest, "delivery slot is full or closed")
+	ErrOutOfDeliveryArea = e.NewError(http.StatusBadRequest, "address is outside of the delivery area")
+	ErrOrderNotFound     = e.NewError(http.StatusNotFound, "order not found")
+)
+
+var (
+	ErrOrderCancelNotAllowed = e.NewError(http.StatusBadRequest, "order can not be cancelled in its current state")
+	ErrInvalidReasonCode     = e.NewError(http.StatusBadRequest, "invalid reason code")
+	ErrOrderStatusChanged    = e.NewError(http.StatusBadRequest, "order status has been changed, please retry")
+	ErrOrderForbidden        = e.NewError(http.StatusForbidden, "you are not allowed to manage this order")
+)
+
+var (
+	ErrInvalidPrepTime = e.NewError(http.StatusBadRequest, "invalid preparation time")
+	ErrNotSeller       = e.NewError(http.StatusForbidden, "user is not linked to any xozmak")
+)
+
+var (
+	ErrCourierNotFound      = e.NewError(http.StatusNotFound, "courier not found")
+	ErrCourierAlreadyExists = e.NewError(http.StatusBadRequest, "courier with this phone number already exists")
+	ErrCourierBlocked       = e.NewError(http.StatusForbidden, "courier is blocked")
+	ErrCourierOffline       = e.NewError(http.StatusBadRequest, "courier is not on a shift")
+)
+
+var (
+	ErrOfferNotFound           = e.NewError(http.StatusBadRequest, "order offer expired or not found")
+	ErrCourierAssignNotAllowed = e.NewError(http.StatusBadRequest, "courier can not be assigned to the order in its current state")
+	ErrCourierFullyLoaded      = e.NewError(http.StatusBadRequest, "courier already carries as many orders as allowed")
+	ErrCourierOverCashLimit    = e.NewError(http.StatusBadRequest, "courier holds too much cash to take a cash order")
+)
+
+var (
+	ErrZoneNotFound  = e.NewError(http.StatusNotFound, "delivery zone not found")
+	ErrBelowMinOrder = e.NewError(http.StatusBadRequest, "order total is below the minimum order of the delivery zone")
+)
+
+var (
+	ErrProofRequired       = e.NewError(http.StatusBadRequest, "handoff code or photo is required to complete the order")
+	ErrInvalidHandoffCode  = e.NewError(http.StatusBadRequest, "handoff code is wrong")
+	ErrHandoffCodeLocked   = e.NewError(http.StatusTooManyRequests, "too many wrong handoff codes, the order is sent to support")
+	ErrHandoffCodeRequired = e.NewError(http.StatusBadRequest, "handoff code is required, a photo is enough only for contactless orders")
+	ErrProofNotFound       = e.NewError(http.StatusNotFound, "proof of delivery not found")
+	ErrUnsupportedPhoto    = e.NewError(http.StatusBadRequest, "photo must be a jpeg, png or webp image")
+	ErrProofPhotoNotFound  = e.NewError(http.StatusBadRequest, "photo is not uploaded for the order")
+)
+
+var (
+	ErrUnbalancedTransaction = e.NewError(http.StatusInternalServerError, "ledger transaction is not balanced")
+	ErrEarningAlreadyPosted  = e.NewError(http.StatusBadRequest, "earning is already posted for the order")
+	ErrPayoutBatchNotFound   = e.NewError(http.StatusNotFound, "payout batch not found")
+	ErrNothingToPay          = e.NewError(http.StatusBadRequest, "there are no unpaid earnings")
+)
+var (
+	ErrHoursExceptionExists   = e.NewError(http.StatusBadRequest, "there is already an exception for the date")
+	ErrHoursExceptionNotFound = e.NewError(http.StatusNotFound, "hours exception not found")
+	ErrXozmakPaused           = e.NewError(http.StatusBadRequest, "xozmak is not taking orders right now")
+	ErrXozmakClosed           = e.NewError(http.StatusBadRequest, "xozmak is closed, choose a delivery slot for a pre-order")
+	ErrSlotOutsideHours       = e.NewError(http.StatusBadRequest, "delivery slot starts while the xozmak is closed")
+)
+
+var (
+	ErrPaymentNotFound         = e.NewError(http.StatusNotFound, "payment not found")
+	ErrPaymentStatusChanged    = e.NewError(http.StatusBadRequest, "payment status has been changed, please retry")
+	ErrOrderNotAwaitingPayment = e.NewError(http.StatusBadRequest, "order is not waiting for a payment")
+	ErrUnknownPaymentProvider  = e.NewError(http.StatusBadRequest, "unknown payment provider")
+)
+
+var (
+	ErrPaymeTransactionNotFound = e.NewError(http.StatusNotFound, "payme transaction not found")
+	ErrPaymeTransactionChanged  = e.NewError(http.StatusBadRequest, "payme transaction state has been changed")
+)
+
+var (
+	ErrHandoverExceedsCash = e.NewError(http.StatusBadRequest, "courier does not hold that much cash")
+)
+
+var (
+	ErrInsufficientFunds         = e.NewError(http.StatusBadRequest, "there is not enough money in the wallet")
+	ErrWalletTransactionExists   = e.NewError(http.StatusBadRequest, "wallet transaction has already been posted")
+	ErrWalletTransactionNotFound = e.NewError(http.StatusNotFound, "wallet transaction not found")
+)
+
+var (
+	ErrClickTransactionNotFound = e.NewError(http.StatusNotFound, "click transaction not found")
+	ErrClickTransactionChanged  = e.NewError(http.StatusBadRequest, "click transaction status has been changed")
+)
+
+var (
+	ErrCommissionRuleNotFound   = e.NewError(http.StatusNotFound, "commission rule not found")
+	ErrCommissionRuleExists     = e.NewError(http.StatusBadRequest, "there is already a commission rule for the xozmak and category")
+	ErrSettlementNotFound       = e.NewError(http.StatusNotFound, "settlement not found")
+	ErrSettlementAlreadySettled = e.NewError(http.StatusBadRequest, "settlement is already settled")
+	ErrNothingToSettle          = e.NewError(http.StatusBadRequest, "there are no unsettled orders")
+)
+
+var (
+	ErrOrderNotDelivered  = e.NewError(http.StatusBadRequest, "order is not delivered")
+	ErrTipWindowClosed    = e.NewError(http.StatusBadRequest, "it is too late to tip the courier of the order")
+	ErrOrderAlreadyTipped = e.NewError(http.StatusBadRequest, "the courier of the order is already tipped")
+	ErrTipNotSupported    = e.NewError(http.StatusBadRequest, "a tip after the delivery can not be paid with the payment method of the order")
+	ErrInvalidTipAmount   = e.NewError(http.StatusBadRequest, "tip must be more than zero")
+)
+
+var (
+	ErrTemplateNotFound = e.NewError(http.StatusNotFound, "notification template not found")
+)
@@ -15,7 +15,7 @@ func (h *Handler) CreateXozmak(c *gin.Context) {
 	var body entities.Xozmak
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
-		h.handleResponse(c, http.BadRequest, constants.BadRequest)
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 		return
 	}
 	body.ID = uuid.NewString()
@@ -23,7 +23,7 @@ func (h *Handler) CreateXozmak(c *gin.Context) {
 
 	err = h.adminController.CreateXozmak(c, body)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 		return
 	}
 
@@ -33,7 +33,7 @@ func (h *Handler) CreateXozmak(c *gin.Context) {
 func (h *Handler) GetXozmak(c *gin.Context) {
 	data, err := h.adminController.GetXozmak(c)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, data)
 }
@@ -42,7 +42,7 @@ func (h *Handler) UpdateXozmak(c *gin.Context) {
 	var body entities.Xozmak
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
-		h.handleResponse(c, http.BadRequest, constants.BadRequest)
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 		return
 	}
 	body.ID = c.Param("id")
@@ -53,7 +53,7 @@ func (h *Handler) UpdateXozmak(c *gin.Context) {
 	}
 	err = h.adminController.UpdateXozmak(c, body)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -68,7 +68,7 @@ func (h *Handler) DeleteXozmak(c *gin.Context) {
 
 	err := h.adminController.DeleteXozmak(c, id)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -77,14 +77,14 @@ func (h *Handler) CreateCategory(c *gin.Context) {
      var req entities.Category
 	 err := c.ShouldBindJSON(&req)
 	 if err != nil {
-		h.handleResponse(c, http.BadRequest, constants.BadRequest)
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 		return
 	}
 	req.ID = uuid.NewString()
 
 	err = h.adminController.CreateCategory(c, req)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -92,7 +92,7 @@ func (h *Handler) CreateCategory(c *gin.Context) {
 func (h *Handler) GetCategory(c *gin.Context) {
     data, err := h.adminController.GetCategory(c)
 	if err != nil {
-        h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+        h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, data)
 }
@@ -101,7 +101,7 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 	var req entities.Category
     err := c.ShouldBindJSON(&req)
 	if err != nil {
-		h.handleResponse(c, http.BadRequest, constants.BadRequest)
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 		return
 	}
 	req.ID=c.Param("id")
@@ -112,7 +112,7 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 	req.UpdatedAt=time.Now()
 	err = h.adminController.UpdateCategory(c, req)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -127,7 +127,7 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 
 	err := h.adminController.DeleteCategory(c, category_id)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -136,14 +136,14 @@ func (h *Handler) CreateSubCategory(c *gin.Context) {
 	var req entities.SubCategory
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
-	   h.handleResponse(c, http.BadRequest, constants.BadRequest)
+	   h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 	   return
    }
    req.ID = uuid.NewString()
 
    err = h.adminController.CreateSubCategory(c, req)
    if err != nil {
-	   h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+	   h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
    }
    h.handleResponse(c, http.OK, constants.Success)
 }
@@ -151,7 +151,7 @@ func (h *Handler) CreateSubCategory(c *gin.Context) {
 func (h *Handler) GetSubCategory(c *gin.Context) {
     data, err := h.adminController.GetSubCategory(c)
 	if err != nil {
-        h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+        h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, data)
 }
@@ -160,7 +160,7 @@ func (h *Handler) UpdateSubCategory(c *gin.Context) {
 	var req entities.SubCategory
     err := c.ShouldBindJSON(&req)
 	if err != nil {
-		h.handleResponse(c, http.BadRequest, constants.BadRequest)
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
 		return
 	}
 	req.ID=c.Param("id")
@@ -171,7 +171,7 @@ func (h *Handler) UpdateSubCategory(c *gin.Context) {
 	req.UpdatedAt=time.Now()
 	err = h.adminController.UpdateSubCategory(c, req)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -186,7 +186,98 @@ func (h *Handler) DeleteSubCategory(c *gin.Context) {
 
 	err := h.adminController.DeleteCategory(c, sub_category_id)
 	if err != nil {
-		h.handleResponse(c, http.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
+	}
+	h.handleResponse(c, http.OK, constants.Success)
+}
+
+func (h *Handler) CreateProduct(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.Product
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, http.InvalidArgument, err.Error())
+		return
+	}
+	req.ID = uuid.NewString()
+
+	err = h.adminController.CreateProduct(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, http.OK, req)
+}
+
+func (h *Handler) GetProducts(c *gin.Context) {
+	xozmakId := c.Param("id")
+	if !utils.IsValidUUID(xozmakId) {
+		h.handleResponse(c, http.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.adminController.GetProducts(c, xozmakId)
+	if err != nil {
+		h.handleResponse(c, http.InternalServerError, h.message(c, constants.TemplateInternalError))
+		return
+	}
+	h.handleResponse(c, http.OK, data)
+}
+
+func (h *Handler) UpdateProduct(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.Product
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, http.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.ID = c.Param("id")
+	if !utils.IsValidUUID(req.ID) {
+		h.handleResponse(c, http.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, http.InvalidArgument, err.Error())
+		return
+	}
+	req.UpdatedAt = time.Now()
+
+	err = h.adminController.UpdateProduct(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, http.OK, constants.Success)
+}
+
+func (h *Handler) DeleteProduct(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, http.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.adminController.DeleteProduct(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
 	}
 	h.handleResponse(c, http.OK, constants.Success)
 }
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"delivery/entities"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClickPrepare serves the prepare callback of the Click SHOP API
+func (h *Handler) ClickPrepare(c *gin.Context) {
+	var req entities.ClickRequest
+	// a broken form is refused by the controller as a bad request from Click
+	_ = c.ShouldBind(&req)
+	c.JSON(http.StatusOK, h.clickController.Prepare(c, req))
+}
+
+// ClickComplete serves the complete callback of the Click SHOP API
+func (h *Handler) ClickComplete(c *gin.Context) {
+	var req entities.ClickRequest
+	_ = c.ShouldBind(&req)
+	c.JSON(http.StatusOK, h.clickController.Complete(c, req))
+}
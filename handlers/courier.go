@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// courierFromToken returns the caller if they have the courier role
+func (h *Handler) courierFromToken(c *gin.Context) (entities.Actor, bool) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return entities.Actor{}, false
+	}
+	if actor.Role != constants.CourierRole {
+		h.handleResponse(c, htp.Forbidden, "only couriers can use this endpoint")
+		return entities.Actor{}, false
+	}
+	return actor, true
+}
+
+// adminFromToken returns the caller if they have the admin role
+func (h *Handler) adminFromToken(c *gin.Context) (entities.Actor, bool) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return entities.Actor{}, false
+	}
+	if actor.Role != constants.AdminRole {
+		h.handleResponse(c, htp.Forbidden, "only admins can use this endpoint")
+		return entities.Actor{}, false
+	}
+	return actor, true
+}
+
+func (h *Handler) CreateCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.CourierReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.ID = uuid.NewString()
+
+	data, err := h.courierController.CreateCourier(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+// GetCouriers lists couriers, status query filters by active or blocked
+func (h *Handler) GetCouriers(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != constants.CourierStatusActive && status != constants.CourierStatusBlocked {
+		h.handleResponse(c, htp.BadRequest, "status must be active or blocked")
+		return
+	}
+
+	data, err := h.courierController.GetCouriers(c, status, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.courierController.GetCourier(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) UpdateCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.CourierReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.ID = c.Param("id")
+	if !utils.IsValidUUID(req.ID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	err = h.courierController.UpdateCourier(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) DeleteCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.courierController.DeleteCourier(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) BlockCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.BlockCourierReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	if req.Reason == "" {
+		h.handleResponse(c, htp.InvalidArgument, "reason is required")
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err = h.courierController.BlockCourier(c, id, req.Reason)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) UnblockCourier(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.courierController.UnblockCourier(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) GetCourierProfile(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.courierController.GetCourier(c, actor.ID)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GoOnline(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.courierController.GoOnline(c, actor.ID)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GoOffline(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.courierController.GoOffline(c, actor.ID)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetCourierShifts(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	data, err := h.courierController.GetCourierShifts(c, actor.ID, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// PushLocation accepts a single point or a batch of points buffered while the phone was offline
+func (h *Handler) PushLocation(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.LocationBatch
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	err = req.Validate(time.Now())
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	data, err := h.courierController.IngestLocation(c, actor.ID, req.Points)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// PickUpOrder marks the order as taken from the xozmak
+func (h *Handler) PickUpOrder(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.orderController.PickUpOrder(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// DeliverOrder completes the order with the customer's handoff code or a photo of the drop
+// UploadProofPhoto stores the photo of the drop sent as the "photo" form file
+func (h *Handler) UploadProofPhoto(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	maxSize := h.cfg.MaxFileSizeInMBs << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	file, err := c.FormFile("photo")
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, "photo file is required")
+		return
+	}
+	if file.Size > maxSize {
+		h.handleResponse(c, htp.BadRequest, fmt.Sprintf("photo can not be larger than %d MB", h.cfg.MaxFileSizeInMBs))
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, "photo file is required")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, "photo file is required")
+		return
+	}
+
+	res, err := h.orderController.UploadProofPhoto(c, actor, orderId, data)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, res)
+}
+
+func (h *Handler) DeliverOrder(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	var req entities.DeliverOrderReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.Actor = actor
+
+	err := h.orderController.DeliverOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// GetHandoffLockedOrders lists orders waiting for support because the handoff code got locked
+func (h *Handler) GetHandoffLockedOrders(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	data, err := h.orderController.GetHandoffLockedOrders(c, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// ConfirmDelivery completes the order after support confirmed the handover with the customer
+func (h *Handler) ConfirmDelivery(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.ConfirmDeliveryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.Actor = actor
+
+	err := h.orderController.ConfirmDelivery(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
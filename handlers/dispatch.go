@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCourierOffer returns the order currently offered to the courier, data is null when there is none
+func (h *Handler) GetCourierOffer(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.dispatchController.GetCourierOffer(c, actor.ID)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) AcceptOffer(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.dispatchController.AcceptOffer(c, actor.ID, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) DeclineOffer(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.dispatchController.DeclineOffer(c, actor.ID, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// AssignCourier assigns the courier to the order or replaces the current one
+func (h *Handler) AssignCourier(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.AssignCourierReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) || !utils.IsValidUUID(req.CourierID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	req.Actor = actor
+
+	err = h.dispatchController.AssignCourier(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statementQuery reads period=day|week and date=YYYY-MM-DD, today by default
+func (h *Handler) statementQuery(c *gin.Context) (string, time.Time, bool) {
+	period := c.DefaultQuery("period", constants.StatementPeriodDay)
+	if period != constants.StatementPeriodDay && period != constants.StatementPeriodWeek {
+		h.handleResponse(c, htp.BadRequest, "period must be day or week")
+		return "", time.Time{}, false
+	}
+
+	day := time.Now().In(h.cfg.TimeZone)
+	if date := c.Query("date"); date != "" {
+		var err error
+		day, err = time.ParseInLocation(time.DateOnly, date, h.cfg.TimeZone)
+		if err != nil {
+			h.handleResponse(c, htp.BadRequest, "date must be in YYYY-MM-DD format")
+			return "", time.Time{}, false
+		}
+	}
+	return period, day, true
+}
+
+// GetMyEarnings returns the daily or weekly statement of the calling courier
+func (h *Handler) GetMyEarnings(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	period, day, ok := h.statementQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.earningsController.GetStatement(c, actor.ID, period, day)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetCourierEarnings(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	courierId := c.Param("id")
+	if !utils.IsValidUUID(courierId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	period, day, ok := h.statementQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.earningsController.GetStatement(c, courierId, period, day)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// AddEarningAdjustment gives the courier a bonus or a penalty
+func (h *Handler) AddEarningAdjustment(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.EarningAdjustmentReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.CourierID = c.Param("id")
+	if !utils.IsValidUUID(req.CourierID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.Actor = actor
+
+	data, err := h.earningsController.AddAdjustment(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+// CreatePayoutBatch pays out all unpaid courier earnings
+func (h *Handler) CreatePayoutBatch(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.PayoutBatchReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+			return
+		}
+	}
+	req.Actor = actor
+
+	data, err := h.earningsController.CreatePayoutBatch(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+func (h *Handler) GetPayoutBatches(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	data, err := h.earningsController.GetPayoutBatches(c, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetPayoutBatch(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.earningsController.GetPayoutBatch(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// ExportPayoutBatch downloads the batch as a CSV file for the bank
+func (h *Handler) ExportPayoutBatch(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.earningsController.ExportPayoutBatch(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=payouts-%s.csv", id))
+	c.Data(http.StatusOK, "text/csv", data)
+}
+
+// GetMyCash returns the cash the calling courier collected and handed over in a day or a week
+func (h *Handler) GetMyCash(c *gin.Context) {
+	actor, ok := h.courierFromToken(c)
+	if !ok {
+		return
+	}
+	period, day, ok := h.statementQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.earningsController.GetCashStatement(c, actor.ID, period, day)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetCourierCash(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	courierId := c.Param("id")
+	if !utils.IsValidUUID(courierId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	period, day, ok := h.statementQuery(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.earningsController.GetCashStatement(c, courierId, period, day)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// RecordCashHandover records the cash an admin or a seller took from the courier
+func (h *Handler) RecordCashHandover(c *gin.Context) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+	if actor.Role != constants.AdminRole && actor.Role != constants.SellerRole {
+		h.handleResponse(c, htp.Forbidden, "only admins and sellers can take cash from couriers")
+		return
+	}
+
+	var req entities.CashHandoverReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.CourierID = c.Param("id")
+	if !utils.IsValidUUID(req.CourierID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.Actor = actor
+
+	data, err := h.earningsController.RecordCashHandover(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
@@ -5,6 +5,16 @@ import (
 
 	"delivery/configs"
 	adminController "delivery/controllers/admin"
+	clickController "delivery/controllers/click"
+	courierController "delivery/controllers/courier"
+	dispatchController "delivery/controllers/dispatch"
+	earningsController "delivery/controllers/earnings"
+	notificationController "delivery/controllers/notification"
+	orderController "delivery/controllers/order"
+	paymeController "delivery/controllers/payme"
+	paymentController "delivery/controllers/payment"
+	settlementController "delivery/controllers/settlement"
+	walletController "delivery/controllers/wallet"
 	"delivery/logger"
 	e "delivery/pkg/errors"
 
@@ -15,23 +25,53 @@ import (
 )
 
 type Handler struct {
-	cfg             *configs.Configuration
-	log             logger.LoggerI
-	adminController adminController.AdminController
-	redis           *redis.Client
+	cfg                    *configs.Configuration
+	log                    logger.LoggerI
+	adminController        adminController.AdminController
+	orderController        orderController.OrderController
+	notificationController notificationController.NotificationController
+	courierController      courierController.CourierController
+	dispatchController     dispatchController.DispatchController
+	earningsController     earningsController.EarningsController
+	paymentController      paymentController.PaymentController
+	paymeController        paymeController.PaymeController
+	clickController        clickController.ClickController
+	walletController       walletController.WalletController
+	settlementController   settlementController.SettlementController
+	redis                  *redis.Client
 }
 
 func New(
 	cfg *configs.Configuration,
 	log logger.LoggerI,
 	adminController adminController.AdminController,
+	orderController orderController.OrderController,
+	notificationController notificationController.NotificationController,
+	courierController courierController.CourierController,
+	dispatchController dispatchController.DispatchController,
+	earningsController earningsController.EarningsController,
+	paymentController paymentController.PaymentController,
+	paymeController paymeController.PaymeController,
+	clickController clickController.ClickController,
+	walletController walletController.WalletController,
+	settlementController settlementController.SettlementController,
 	redis *redis.Client,
 ) Handler {
 	return Handler{
-		cfg:             cfg,
-		log:             log,
-		adminController: adminController,
-		redis:           redis,
+		cfg:                    cfg,
+		log:                    log,
+		adminController:        adminController,
+		orderController:        orderController,
+		notificationController: notificationController,
+		courierController:      courierController,
+		dispatchController:     dispatchController,
+		earningsController:     earningsController,
+		paymentController:      paymentController,
+		paymeController:        paymeController,
+		clickController:        clickController,
+		walletController:       walletController,
+		settlementController:   settlementController,
+		redis:                  redis,
 	}
 }
 
@@ -108,6 +148,12 @@ func StatusFromError(err error) httppkg.Status {
 			Status:      "FORBIDDEN",
 			Description: err.Error(),
 		}
+	} else if code == http.StatusTooManyRequests {
+		return httppkg.Status{
+			Code:        http.StatusTooManyRequests,
+			Status:      "TOO_MANY_REQUESTS",
+			Description: err.Error(),
+		}
 	} else {
 		return httppkg.Status{
 			Code:        http.StatusInternalServerError,
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetWorkingHours replaces the weekly hours of the xozmak, an empty list means open around the clock
+func (h *Handler) SetWorkingHours(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.WorkingHoursReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.XozmakID = c.Param("id")
+	if !utils.IsValidUUID(req.XozmakID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	err = h.orderController.SetWorkingHours(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// GetXozmakSchedule is public, customers see when the xozmak is open
+func (h *Handler) GetXozmakSchedule(c *gin.Context) {
+	xozmakId := c.Param("id")
+	if !utils.IsValidUUID(xozmakId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.orderController.GetXozmakSchedule(c, xozmakId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// GetXozmakHours is the schedule of the xozmak on the admin routes
+func (h *Handler) GetXozmakHours(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	h.GetXozmakSchedule(c)
+}
+
+// CreateHoursException adds a day off or special hours of one xozmak
+func (h *Handler) CreateHoursException(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.HoursException
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	xozmakId := c.Param("id")
+	if !utils.IsValidUUID(xozmakId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	req.XozmakID = &xozmakId
+	h.createHoursException(c, req)
+}
+
+// CreateHoliday adds a day off or special hours of all xozmaks
+func (h *Handler) CreateHoliday(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.HoursException
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.XozmakID = nil
+	h.createHoursException(c, req)
+}
+
+func (h *Handler) createHoursException(c *gin.Context, req entities.HoursException) {
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	data, err := h.orderController.CreateHoursException(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+func (h *Handler) DeleteHoursException(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.orderController.DeleteHoursException(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// SetXozmakOrdering pauses the xozmak or allows pre-orders while it is closed
+func (h *Handler) SetXozmakOrdering(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.XozmakOrderingReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.XozmakID = c.Param("id")
+	if !utils.IsValidUUID(req.XozmakID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if req.OrdersPaused == nil && req.AllowPreorders == nil {
+		h.handleResponse(c, htp.InvalidArgument, "orders_paused or allow_preorders is required")
+		return
+	}
+
+	err = h.orderController.SetXozmakOrdering(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// PauseOrders stops new orders to the seller's xozmak until they are resumed
+func (h *Handler) PauseOrders(c *gin.Context) {
+	h.setOrdersPaused(c, true)
+}
+
+func (h *Handler) ResumeOrders(c *gin.Context) {
+	h.setOrdersPaused(c, false)
+}
+
+func (h *Handler) setOrdersPaused(c *gin.Context, paused bool) {
+	actor, ok := h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	err := h.orderController.SetSellerOrdersPaused(c, actor, paused)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
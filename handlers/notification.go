@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	htp "delivery/pkg/http"
+	jwta "delivery/pkg/jwt"
+	"delivery/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetNotifications(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	data, err := h.notificationController.GetUserNotifications(c, userId, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	jwta "delivery/pkg/jwt"
+	"delivery/pkg/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) CreateSlot(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.DeliverySlot
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.XozmakID = c.Param("id")
+	if !utils.IsValidUUID(req.XozmakID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.ID = uuid.NewString()
+	req.Reserved = 0
+
+	err = h.orderController.CreateSlot(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, req)
+}
+
+func (h *Handler) GetXozmakSlots(c *gin.Context) {
+	xozmakId := c.Param("id")
+	if !utils.IsValidUUID(xozmakId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.orderController.GetXozmakSlots(c, xozmakId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) UpdateSlot(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.DeliverySlot
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.ID = c.Param("id")
+	if !utils.IsValidUUID(req.ID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.UpdatedAt = time.Now()
+
+	err = h.orderController.UpdateSlot(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) DeleteSlot(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.orderController.DeleteSlot(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// GetAvailableSlots returns slots of a xozmak for one of the user's saved addresses.
+// Optional date query (YYYY-MM-DD) narrows the result to a single day.
+func (h *Handler) GetAvailableSlots(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	req := entities.SlotsReq{
+		UserID:     userId,
+		XozmakID:   c.Query("xozmak_id"),
+		LocationID: c.Query("location_id"),
+		From:       time.Now(),
+	}
+	if !utils.IsValidUUID(req.XozmakID) || req.LocationID == "" {
+		h.handleResponse(c, htp.BadRequest, "xozmak_id and location_id are required")
+		return
+	}
+	req.To = req.From.AddDate(0, 0, constants.SlotsDefaultDays)
+
+	if date := c.Query("date"); date != "" {
+		day, err := time.ParseInLocation(time.DateOnly, date, h.cfg.TimeZone)
+		if err != nil {
+			h.handleResponse(c, htp.BadRequest, "date must be in YYYY-MM-DD format")
+			return
+		}
+		req.From = day
+		req.To = day.AddDate(0, 0, 1)
+	}
+
+	data, err := h.orderController.GetAvailableSlots(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) PlaceOrder(c *gin.Context) {
+	var req entities.PlaceOrderReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	req.UserID, err = jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.PlaceOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+func (h *Handler) GetOrderHistory(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	data, err := h.orderController.GetOrderHistory(c, userId, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetOrder(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetUserOrder(c, userId, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) Reorder(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.Reorder(c, userId, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetCart(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetCart(c, userId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// actorFromToken returns the id and the role of the caller
+func (h *Handler) actorFromToken(c *gin.Context) (entities.Actor, error) {
+	id, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		return entities.Actor{}, err
+	}
+	role, err := jwta.ExtractRoleFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		return entities.Actor{}, err
+	}
+	return entities.Actor{ID: id, Role: role}, nil
+}
+
+func (h *Handler) CancelOrder(c *gin.Context) {
+	var req entities.CancelOrderReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	req.Actor, err = h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.CancelOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// TipOrder tips the courier of a delivered order with an amount or a percent of the items total
+func (h *Handler) TipOrder(c *gin.Context) {
+	var req entities.TipOrderReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+
+	req.Actor, err = h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.TipOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+func (h *Handler) GetOrderTimeline(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetOrderTimeline(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// GetOrderReceipt returns the PDF receipt of the order
+func (h *Handler) GetOrderReceipt(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.RenderCustomerReceipt(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=receipt-%s.pdf", orderId))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// GetOrderTrack returns the courier route of the order for replay
+func (h *Handler) GetOrderTrack(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetOrderTrack(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// GetOrderETA returns when the order is expected to be picked up and delivered
+func (h *Handler) GetOrderETA(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetOrderETA(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// StreamOrder sends order updates as server-sent events until the order is finished
+// or the client goes away
+func (h *Handler) StreamOrder(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	events, err := h.orderController.StreamOrder(c.Request.Context(), actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+
+	heartbeat := time.NewTicker(constants.TrackingHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// tells nginx not to buffer the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
+
+// GetDeliveryProof returns how the order was handed over
+func (h *Handler) GetDeliveryProof(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetDeliveryProof(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// GetDeliveryProofPhoto returns the photo of the drop
+func (h *Handler) GetDeliveryProofPhoto(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.orderController.GetDeliveryProofPhoto(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	c.Data(http.StatusOK, data.ContentType, data.Data)
+}
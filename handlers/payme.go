@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Payme serves the Payme merchant API, errors are sent in the JSON-RPC body with HTTP 200
+func (h *Handler) Payme(c *gin.Context) {
+	// a body that can not be read is answered as a parse error
+	body, _ := io.ReadAll(c.Request.Body)
+	res := h.paymeController.Handle(c, c.Request.Method, c.GetHeader("Authorization"), body)
+	c.JSON(http.StatusOK, res)
+}
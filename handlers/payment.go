@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PayOrder returns the invoice the customer pays a card order with, the provider
+// query parameter chooses another provider than the default one
+func (h *Handler) PayOrder(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.paymentController.PayOrder(c, actor, orderId, c.Query("provider"))
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetOrderPayments(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.paymentController.GetOrderPayments(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// CheckPayment refreshes the payment status from the provider
+func (h *Handler) CheckPayment(c *gin.Context) {
+	paymentId := c.Param("id")
+	if !utils.IsValidUUID(paymentId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.paymentController.CheckPayment(c, actor, paymentId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sellerFromToken returns the caller if they have the seller role
+func (h *Handler) sellerFromToken(c *gin.Context) (entities.Actor, bool) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return entities.Actor{}, false
+	}
+	if actor.Role != constants.SellerRole {
+		h.handleResponse(c, htp.Forbidden, "only sellers can manage shop orders")
+		return entities.Actor{}, false
+	}
+	return actor, true
+}
+
+// GetSellerOrders lists orders of the seller's xozmak, status query accepts a comma separated list
+func (h *Handler) GetSellerOrders(c *gin.Context) {
+	actor, ok := h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+
+	var statuses []string
+	if status := c.Query("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+
+	data, err := h.orderController.GetSellerOrders(c, actor, statuses, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) AcceptOrder(c *gin.Context) {
+	var req entities.AcceptOrderReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	var ok bool
+	req.Actor, ok = h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.orderController.AcceptOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) StartPreparing(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, ok := h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	err := h.orderController.StartPreparing(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) MarkOrderReady(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	actor, ok := h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	err := h.orderController.MarkOrderReady(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) RejectOrder(c *gin.Context) {
+	var req entities.CancelOrderReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.OrderID = c.Param("id")
+	if !utils.IsValidUUID(req.OrderID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	var ok bool
+	req.Actor, ok = h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.orderController.RejectOrder(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// GetOrderSlip returns the packer slip as raw ESC/POS bytes, width query is the paper width in mm
+func (h *Handler) GetOrderSlip(c *gin.Context) {
+	actor, ok := h.sellerFromToken(c)
+	if !ok {
+		return
+	}
+
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	width, err := strconv.Atoi(c.DefaultQuery("width", strconv.Itoa(constants.ReceiptPaper80mm)))
+	if err != nil || (width != constants.ReceiptPaper58mm && width != constants.ReceiptPaper80mm) {
+		h.handleResponse(c, htp.BadRequest, "width must be 58 or 80")
+		return
+	}
+
+	data, err := h.orderController.RenderPrinterSlip(c, actor, orderId, width)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
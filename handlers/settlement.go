@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) CreateCommissionRule(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.CommissionRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	data, err := h.settlementController.CreateCommissionRule(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+func (h *Handler) GetCommissionRules(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	data, err := h.settlementController.GetCommissionRules(c)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) UpdateCommissionRule(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.CommissionRule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.ID = c.Param("id")
+	if !utils.IsValidUUID(req.ID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	err := h.settlementController.UpdateCommissionRule(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) DeleteCommissionRule(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.settlementController.DeleteCommissionRule(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// CreateSettlements settles all xozmaks for the orders delivered before period_end
+func (h *Handler) CreateSettlements(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.SettlementsReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+			return
+		}
+	}
+	req.Actor = actor
+
+	data, err := h.settlementController.CreateSettlements(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
+
+// GetSettlements lists settlements, admins filter them with the xozmak_id and status query parameters
+func (h *Handler) GetSettlements(c *gin.Context) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+	filter := entities.SettlementFilter{
+		XozmakID: c.Query("xozmak_id"),
+		Status:   c.Query("status"),
+		Limit:    limit,
+		Offset:   (page - 1) * limit,
+	}
+	if filter.XozmakID != "" && !utils.IsValidUUID(filter.XozmakID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if filter.Status != "" && !utils.InEnums(filter.Status, []string{constants.SettlementStatusPending, constants.SettlementStatusSettled}) {
+		h.handleResponse(c, htp.BadRequest, "status must be pending or settled")
+		return
+	}
+
+	data, err := h.settlementController.GetSettlements(c, actor, filter)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetSettlement(c *gin.Context) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.settlementController.GetSettlement(c, actor, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// ExportSettlement downloads the settlement as a CSV file
+func (h *Handler) ExportSettlement(c *gin.Context) {
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.settlementController.ExportSettlement(c, actor, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=settlement-%s.csv", id))
+	c.Data(http.StatusOK, "text/csv", data)
+}
+
+// MarkSettlementSettled records that the xozmak was paid
+func (h *Handler) MarkSettlementSettled(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.settlementController.MarkSettled(c, actor, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
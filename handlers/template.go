@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	"delivery/pkg/templates"
+	"delivery/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// message returns the text of the template in the language of the Accept-Language header
+func (h *Handler) message(c *gin.Context, key string) string {
+	return h.notificationController.Text(c, key, templates.Language(c.GetHeader("Accept-Language")), nil).Body
+}
+
+// GetNotificationTemplates lists the texts of every template in uz and ru
+func (h *Handler) GetNotificationTemplates(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	data, err := h.notificationController.GetTemplates(c)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) UpdateNotificationTemplate(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.NotificationTemplate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.Key = c.Param("key")
+	req.Language = c.Param("language")
+	req.UpdatedBy = &actor.ID
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	data, err := h.notificationController.UpdateTemplate(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// ResetNotificationTemplate brings back the built-in text of the template
+func (h *Handler) ResetNotificationTemplate(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	language := c.Param("language")
+	if !utils.InEnums(language, templates.Languages) {
+		h.handleResponse(c, htp.BadRequest, "language must be uz or ru")
+		return
+	}
+
+	data, err := h.notificationController.ResetTemplate(c, c.Param("key"), language)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+// PreviewNotificationTemplate renders the text with the given or sample variables
+func (h *Handler) PreviewNotificationTemplate(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.TemplatePreviewReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+			return
+		}
+	}
+	req.Key = c.Param("key")
+	req.Language = c.Param("language")
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+
+	data, err := h.notificationController.PreviewTemplate(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
@@ -6,6 +6,7 @@ import (
 	"delivery/logger"
 	htp "delivery/pkg/http"
 	jwta "delivery/pkg/jwt"
+	"delivery/pkg/templates"
 	"delivery/pkg/utils"
 	"time"
 
@@ -36,7 +37,7 @@ func (h *Handler) SendCode(c *gin.Context) {
 		h.log.Error("Redisda kodni saqlashda xatolik1", logger.Error(err))
 	}
 
-	h.handleResponse(c, htp.OK, "Telefon raqamingizga 6 xonali kod yuborildi")
+	h.handleResponse(c, htp.OK, h.message(c, constants.TemplateCodeSent))
 }
 
 
@@ -69,6 +70,7 @@ func (h *Handler) InsertUserLocation(c *gin.Context) {
 	err := c.ShouldBindJSON(&location)
 	if err != nil {
 		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
 	}
 	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
 	if err != nil {
@@ -78,7 +80,7 @@ func (h *Handler) InsertUserLocation(c *gin.Context) {
 	location.UserID = userId
 	err = h.adminController.InsertUserLocation(c, location)
 	if err != nil {
-		h.handleResponse(c, htp.InternalServerError, err.Error())
+		h.handleResponse(c, StatusFromError(err), err.Error())
 		return
 	}
 	h.handleResponse(c, htp.OK, constants.Success)
@@ -97,6 +99,10 @@ func (h *Handler) UpdateProfile(c *gin.Context) {
 	}
 	req.ID=userId
 	req.UpdatedBy = userId
+	if req.PreferredLanguage != "" && !utils.InEnums(req.PreferredLanguage, templates.Languages) {
+		h.handleResponse(c, htp.BadRequest, "preferred_language must be uz or ru")
+		return
+	}
 	req.UpdatedAt = time.Now()
 	if err := h.adminController.UpdateUserProfile(c.Request.Context(), req); err != nil {
 		h.handleResponse(c, htp.InternalServerError, err.Error())
@@ -114,7 +120,7 @@ func (h *Handler) GetProfile(c *gin.Context) {
 
 	data, err := h.adminController.GetUserProfile(c, userId)
 	if err != nil {
-		h.handleResponse(c, htp.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, htp.InternalServerError, h.message(c, constants.TemplateInternalError))
 		return
 	}
 	h.handleResponse(c, htp.OK, data)
@@ -128,7 +134,7 @@ func (h *Handler) GetUserLocation(c *gin.Context) {
 	}
 	data, err := h.adminController.GetUserLocation(c, userId)
 	if err != nil {
-		h.handleResponse(c, htp.InternalServerError, constants.InternelServError)
+		h.handleResponse(c, htp.InternalServerError, h.message(c, constants.TemplateInternalError))
 		return
 	}
 	h.handleResponse(c, htp.OK, data)
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	jwta "delivery/pkg/jwt"
+	"delivery/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// walletHistory lists the wallet transactions of the user, the type query parameter
+// filters them by the transaction type
+func (h *Handler) walletHistory(c *gin.Context, userId string) {
+	limit, page, err := utils.Pagination(c)
+	if err != nil || limit <= 0 || page <= 0 {
+		h.handleResponse(c, htp.BadRequest, "limit and page must be positive numbers")
+		return
+	}
+	var txType *int
+	if value := c.Query("type"); value != "" {
+		t, err := strconv.Atoi(value)
+		if err != nil {
+			h.handleResponse(c, htp.BadRequest, "type must be a number")
+			return
+		}
+		txType = &t
+	}
+
+	data, err := h.walletController.GetWalletHistory(c, userId, txType, limit, page)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetMyWallet(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.walletController.GetWallet(c, userId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetMyWalletTransactions(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+	h.walletHistory(c, userId)
+}
+
+// PayOrderFromWallet pays an order waiting for the payment with the wallet balance
+func (h *Handler) PayOrderFromWallet(c *gin.Context) {
+	orderId := c.Param("id")
+	if !utils.IsValidUUID(orderId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	actor, err := h.actorFromToken(c)
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	data, err := h.walletController.PayOrder(c, actor, orderId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetUserWallet(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	userId := c.Param("id")
+	if !utils.IsValidUUID(userId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.walletController.GetWallet(c, userId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) GetUserWalletTransactions(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+	userId := c.Param("id")
+	if !utils.IsValidUUID(userId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	h.walletHistory(c, userId)
+}
+
+// CreditUserWallet tops the user's wallet up or refunds money to it
+func (h *Handler) CreditUserWallet(c *gin.Context) {
+	actor, ok := h.adminFromToken(c)
+	if !ok {
+		return
+	}
+
+	var req entities.WalletCreditReq
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.UserID = c.Param("id")
+	if !utils.IsValidUUID(req.UserID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.handleResponse(c, htp.BadRequest, err.Error())
+		return
+	}
+	req.Actor = actor
+
+	data, err := h.walletController.CreditWallet(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, data)
+}
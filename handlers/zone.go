@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"delivery/constants"
+	"delivery/entities"
+	htp "delivery/pkg/http"
+	jwta "delivery/pkg/jwt"
+	"delivery/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) CreateZone(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.DeliveryZone
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.XozmakID = c.Param("id")
+	if !utils.IsValidUUID(req.XozmakID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.ID = uuid.NewString()
+
+	err = h.orderController.CreateZone(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.Created, req)
+}
+
+func (h *Handler) GetXozmakZones(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	xozmakId := c.Param("id")
+	if !utils.IsValidUUID(xozmakId) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	data, err := h.orderController.GetXozmakZones(c, xozmakId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
+
+func (h *Handler) UpdateZone(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	var req entities.DeliveryZone
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		h.handleResponse(c, htp.BadRequest, h.message(c, constants.TemplateBadRequest))
+		return
+	}
+	req.ID = c.Param("id")
+	if !utils.IsValidUUID(req.ID) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+	err = req.Validate()
+	if err != nil {
+		h.handleResponse(c, htp.InvalidArgument, err.Error())
+		return
+	}
+	req.UpdatedAt = time.Now()
+
+	err = h.orderController.UpdateZone(c, req)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+func (h *Handler) DeleteZone(c *gin.Context) {
+	if _, ok := h.adminFromToken(c); !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if !utils.IsValidUUID(id) {
+		h.handleResponse(c, htp.BadRequest, "Invalid UUID format")
+		return
+	}
+
+	err := h.orderController.DeleteZone(c, id)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, constants.Success)
+}
+
+// GetDeliveringXozmaks lists xozmaks that deliver to the saved address from location_id query
+func (h *Handler) GetDeliveringXozmaks(c *gin.Context) {
+	userId, err := jwta.ExtractUserIDFromToken(c, []byte(h.cfg.JWTSecretKey))
+	if err != nil {
+		h.handleResponse(c, htp.Unauthorized, "Invalid or expired token")
+		return
+	}
+
+	locationId := c.Query("location_id")
+	if !utils.IsValidUUID(locationId) {
+		h.handleResponse(c, htp.BadRequest, "location_id is required")
+		return
+	}
+
+	data, err := h.orderController.GetDeliveringXozmaks(c, userId, locationId)
+	if err != nil {
+		h.handleResponse(c, StatusFromError(err), err.Error())
+		return
+	}
+	h.handleResponse(c, htp.OK, data)
+}
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
 	"delivery/configs"
 	"delivery/constants"
 	admincontroller "delivery/controllers/admin"
+	clickcontroller "delivery/controllers/click"
+	couriercontroller "delivery/controllers/courier"
+	dispatchcontroller "delivery/controllers/dispatch"
+	earningscontroller "delivery/controllers/earnings"
+	notificationcontroller "delivery/controllers/notification"
+	ordercontroller "delivery/controllers/order"
+	paymecontroller "delivery/controllers/payme"
+	paymentcontroller "delivery/controllers/payment"
+	settlementcontroller "delivery/controllers/settlement"
+	trackingcontroller "delivery/controllers/tracking"
+	walletcontroller "delivery/controllers/wallet"
 	"delivery/handlers"
 	"delivery/logger"
 	"delivery/middlewares"
+	"delivery/pkg/media"
+	"delivery/pkg/payment"
+	"delivery/pkg/push"
 	pkgutil "delivery/pkg/utils"
 	"delivery/routers"
 	"delivery/storage"
@@ -37,19 +52,70 @@ func main() {
 
 	redisClient := pkgutil.NewRedisClient(*cfg)
 
+	paymentProvider, err := payment.New(cfg.PaymentProvider, cfg.PaymentStubURL)
+	if err != nil {
+		log.Fatal("error creating payment provider", logger.Error(err))
+	}
+	paymentProviders := []payment.PaymentProvider{paymentProvider}
+	if cfg.PaymeMerchantID != "" {
+		paymentProviders = append(paymentProviders, payment.NewPayme(cfg.PaymeMerchantID, cfg.PaymeCheckoutURL))
+	}
+	if cfg.ClickServiceID != "" {
+		paymentProviders = append(paymentProviders, payment.NewClick(cfg.ClickServiceID, cfg.ClickMerchantID, cfg.ClickCheckoutURL))
+	}
+
+	pushSender, err := push.New(cfg.PushProvider, cfg.FCMCredentialsFile)
+	if err != nil {
+		log.Fatal("error creating push sender", logger.Error(err))
+	}
+
+	mediaStore, err := media.New(cfg.MediaProvider, cfg.MediaDir)
+	if err != nil {
+		log.Fatal("error creating media store", logger.Error(err))
+	}
+
 	//controllers init
 	admincontroller := admincontroller.NewAdminController(log, strg, redisClient)
+	notificationcontroller := notificationcontroller.NewNotificationController(log, strg, pushSender)
+	trackingcontroller := trackingcontroller.NewTrackingController(log, redisClient)
+	dispatchcontroller := dispatchcontroller.NewDispatchController(log, strg, redisClient, notificationcontroller, trackingcontroller)
+	earningscontroller := earningscontroller.NewEarningsController(log, strg)
+	paymentcontroller := paymentcontroller.NewPaymentController(log, strg, trackingcontroller, paymentProviders...)
+	walletcontroller := walletcontroller.NewWalletController(log, strg, trackingcontroller)
+	settlementcontroller := settlementcontroller.NewSettlementController(log, strg)
+	ordercontroller := ordercontroller.NewOrderController(log, strg, redisClient, notificationcontroller, dispatchcontroller, trackingcontroller, earningscontroller, paymentcontroller, walletcontroller, settlementcontroller, mediaStore)
+	couriercontroller := couriercontroller.NewCourierController(log, strg, redisClient, trackingcontroller, notificationcontroller)
+	paymecontroller := paymecontroller.NewPaymeController(log, strg, ordercontroller, trackingcontroller)
+	clickcontroller := clickcontroller.NewClickController(log, strg, trackingcontroller)
+
+	//background workers
+	go ordercontroller.RunAcceptTimeoutWorker(context.Background())
+	go dispatchcontroller.RunDispatchWorker(context.Background())
+	go paymentcontroller.RunPaymentWorker(context.Background())
+	go earningscontroller.RunBackfillWorker(context.Background())
+	go walletcontroller.RunBackfillWorker(context.Background())
 
 	//handlers init
 	h := handlers.New(
 		cfg,
 		log,
 		admincontroller,
+		ordercontroller,
+		notificationcontroller,
+		couriercontroller,
+		dispatchcontroller,
+		earningscontroller,
+		paymentcontroller,
+		paymecontroller,
+		clickcontroller,
+		walletcontroller,
+		settlementcontroller,
 		redisClient,
 	)
 
 	//routers
-	router := routers.New(h, cfg, log, &middlewares.JWTRoleAuthorizer{})
+	idempotency := middlewares.NewIdempotency(redisClient, cfg.IdempotencyKeyTTL, cfg.JWTSecretKey, log)
+	router := routers.New(h, cfg, log, &middlewares.JWTRoleAuthorizer{}, idempotency)
 
 	router.Start()
 
@@ -0,0 +1,201 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"delivery/logger"
+	htp "delivery/pkg/http"
+	"delivery/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyMaxLength = 255
+	// a key stays locked for idempotencyLockTTL while its first request is processed,
+	// a crashed request does not block the key for the whole TTL
+	idempotencyLockTTL = time.Minute
+)
+
+// idempotencyRecord is what is kept in Redis for a key, Status is 0 while the first
+// request is still being processed
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency replays the stored response of requests retried with the same Idempotency-Key
+type Idempotency struct {
+	redis      *redis.Client
+	ttl        time.Duration
+	signingKey []byte
+	logger     logger.LoggerI
+}
+
+func NewIdempotency(redis *redis.Client, ttl time.Duration, signingKey string, logger logger.LoggerI) *Idempotency {
+	return &Idempotency{
+		redis:      redis,
+		ttl:        ttl,
+		signingKey: []byte(signingKey),
+		logger:     logger,
+	}
+}
+
+// responseRecorder keeps a copy of the response body to store it for the retries
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware is used on unsafe endpoints, requests without the Idempotency-Key header pass through.
+// The first request with a key is processed and its response is stored, retries with the
+// same key and body get the stored response and a reused key with another body is rejected.
+// Server errors are not stored so that the client can retry them.
+func (i *Idempotency) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			abortWithStatus(c, htp.BadRequest, "Idempotency-Key is too long")
+			return
+		}
+		// keys are scoped by the user, requests without a valid token are rejected by the handler
+		userId, err := jwt.ExtractUserIDFromToken(c, i.signingKey)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithStatus(c, htp.BadRequest, "failed to read the request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		redisKey := "idempotency:" + userId + ":" + key
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		locked, err := i.lock(ctx, redisKey, fingerprint)
+		if err != nil {
+			// the request is still processed when Redis is down, only without the replay
+			i.logger.Error("failed to lock idempotency key", zap.Error(err))
+			c.Next()
+			return
+		}
+		if !locked {
+			i.replay(c, redisKey, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// the request context may be cancelled by now, the response must be stored anyway
+		ctx = context.Background()
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := i.redis.Del(ctx, redisKey).Err(); err != nil {
+				i.logger.Error("failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+		record, err := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err == nil {
+			err = i.redis.Set(ctx, redisKey, record, i.ttl).Err()
+		}
+		if err != nil {
+			i.logger.Error("failed to store idempotent response", zap.Error(err))
+		}
+	}
+}
+
+// lock reserves the key for the request, it reports false when the key was already used
+func (i *Idempotency) lock(ctx context.Context, redisKey, fingerprint string) (bool, error) {
+	record, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return false, err
+	}
+	return i.redis.SetNX(ctx, redisKey, record, idempotencyLockTTL).Result()
+}
+
+// replay answers a retry with the stored response of the first request
+func (i *Idempotency) replay(c *gin.Context, redisKey, fingerprint string) {
+	data, err := i.redis.Get(c.Request.Context(), redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// the first request failed and released the key meanwhile
+		abortWithStatus(c, htp.Conflict, "the previous request with this Idempotency-Key failed, try again")
+		return
+	}
+	var record idempotencyRecord
+	if err == nil {
+		err = json.Unmarshal(data, &record)
+	}
+	if err != nil {
+		i.logger.Error("failed to read idempotent response", zap.Error(err))
+		abortWithStatus(c, htp.InternalServerError, "internal server error")
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		abortWithStatus(c, htp.UnprocessableEntity, "Idempotency-Key was already used for another request")
+	case record.Status == 0:
+		abortWithStatus(c, htp.Conflict, "the request with this Idempotency-Key is still being processed")
+	default:
+		c.Header(IdempotencyReplayedHeader, "true")
+		c.Data(record.Status, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint identifies the request a key was first used for
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// abortWithStatus answers in the same shape as the handlers do
+func abortWithStatus(c *gin.Context, status htp.Status, message string) {
+	c.AbortWithStatusJSON(status.Code, htp.Response{
+		Status:      status.Status,
+		Description: status.Description,
+		Data:        []interface{}{message},
+	})
+}
@@ -0,0 +1,233 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"delivery/logger"
+	"delivery/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const testSigningKey = "test-signing-key"
+
+// testRedis connects to the Redis at TEST_REDIS_ADDR or localhost and empties DB 15 for the test
+func testRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis is not available at %s: %v", addr, err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatalf("flush redis: %v", err)
+	}
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+	})
+	return client
+}
+
+func testToken(t *testing.T, userId string) string {
+	t.Helper()
+
+	token, err := jwt.GenerateNewJWTToken(map[string]string{"id": userId, "role": "user"}, time.Hour, testSigningKey)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	return token
+}
+
+// testRouter serves POST /orders and POST /payments with handler behind the middleware
+func testRouter(t *testing.T, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	idempotency := NewIdempotency(testRedis(t), time.Hour, testSigningKey, logger.NewLogger("test", "error"))
+	router := gin.New()
+	router.POST("/orders", idempotency.Middleware(), handler)
+	router.POST("/payments", idempotency.Middleware(), handler)
+	return router
+}
+
+func send(router http.Handler, token, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+// countingHandler creates a new order on every call and answers with its number
+func countingHandler(calls *int32) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n := atomic.AddInt32(calls, 1)
+		c.JSON(http.StatusCreated, gin.H{"number": n})
+	}
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	var calls int32
+	router := testRouter(t, countingHandler(&calls))
+	token := testToken(t, "user-1")
+
+	first := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: %d %s", first.Code, first.Body)
+	}
+	if first.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Fatalf("first request is marked as replayed")
+	}
+
+	retry := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry got %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Fatalf("retry is not marked as replayed")
+	}
+	if !strings.HasPrefix(retry.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("retry content type is %q", retry.Header().Get("Content-Type"))
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+
+	// keys are scoped by the user and requests without a key are never replayed
+	if res := send(router, testToken(t, "user-2"), "/orders", "key-1", `{"slot_id":"a"}`); res.Code != http.StatusCreated {
+		t.Fatalf("other user: %d %s", res.Code, res.Body)
+	}
+	if res := send(router, token, "/orders", "", `{"slot_id":"a"}`); res.Code != http.StatusCreated {
+		t.Fatalf("without a key: %d %s", res.Code, res.Body)
+	}
+	if calls != 3 {
+		t.Fatalf("handler called %d times, want 3", calls)
+	}
+}
+
+func TestIdempotencyRejectsKeyReusedForAnotherRequest(t *testing.T) {
+	var calls int32
+	router := testRouter(t, countingHandler(&calls))
+	token := testToken(t, "user-1")
+
+	if res := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`); res.Code != http.StatusCreated {
+		t.Fatalf("first request: %d %s", res.Code, res.Body)
+	}
+
+	cases := map[string]struct {
+		path string
+		body string
+	}{
+		"another body": {"/orders", `{"slot_id":"b"}`},
+		"another path": {"/payments", `{"slot_id":"a"}`},
+	}
+	for name, c := range cases {
+		res := send(router, token, c.path, "key-1", c.body)
+		if res.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: got %d %s, want 422", name, res.Code, res.Body)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyRejectsRequestWhileKeyIsLocked(t *testing.T) {
+	var calls int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := testRouter(t, func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			close(entered)
+			<-release
+		}
+		c.JSON(http.StatusCreated, gin.H{"number": n})
+	})
+	token := testToken(t, "user-1")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	}()
+	<-entered
+
+	// the first request holds the key until it is answered
+	if res := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`); res.Code != http.StatusConflict {
+		t.Fatalf("request during the first one: %d %s, want 409", res.Code, res.Body)
+	}
+	// a different body is refused even while the key is locked
+	if res := send(router, token, "/orders", "key-1", `{"slot_id":"b"}`); res.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("another body during the first one: %d %s, want 422", res.Code, res.Body)
+	}
+
+	close(release)
+	first := <-done
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request: %d %s", first.Code, first.Body)
+	}
+	retry := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry after the first one: %d %s, want %s", retry.Code, retry.Body, first.Body)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls int32
+	router := testRouter(t, func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"number": n})
+	})
+	token := testToken(t, "user-1")
+
+	if res := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`); res.Code != http.StatusInternalServerError {
+		t.Fatalf("first request: %d %s", res.Code, res.Body)
+	}
+	// the failed request released the key, the retry is processed again
+	retry := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Fatalf("retry after a server error: %d %s", retry.Code, retry.Body)
+	}
+	replay := send(router, token, "/orders", "key-1", `{"slot_id":"a"}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != `{"number":2}` {
+		t.Fatalf("replay of the retry: %d %s", replay.Code, replay.Body)
+	}
+	if calls != 2 {
+		t.Fatalf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotencyRejectsLongKey(t *testing.T) {
+	var calls int32
+	router := testRouter(t, countingHandler(&calls))
+
+	res := send(router, testToken(t, "user-1"), "/orders", strings.Repeat("k", idempotencyKeyMaxLength+1), `{}`)
+	if res.Code != http.StatusBadRequest || calls != 0 {
+		t.Fatalf("long key: %d %s, handler called %d times", res.Code, res.Body, calls)
+	}
+}
@@ -48,6 +48,16 @@ var (
 		Status:      "FORBIDDEN",
 		Description: "...",
 	}
+	Conflict = Status{
+		Code:        409,
+		Status:      "CONFLICT",
+		Description: "The request conflicts with a request that is still being processed",
+	}
+	UnprocessableEntity = Status{
+		Code:        422,
+		Status:      "UNPROCESSABLE_ENTITY",
+		Description: "The request is well formed but cannot be processed",
+	}
 	TooManyRequests = Status{
 		Code:        429,
 		Status:      "TOO_MANY_REQUESTS",
@@ -75,6 +75,25 @@ func ExtractUserIDFromToken(c *gin.Context, secretKey []byte) (string, error) {
 	return userIDStr, nil
 }
 
+// ExtractRoleFromToken extracts the role of the user from the Authorization header
+func ExtractRoleFromToken(c *gin.Context, secretKey []byte) (string, error) {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		return "", errors.New("authorization header is missing")
+	}
+
+	role, err := ExtractFromClaims("role", token, secretKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract role from token: %w", err)
+	}
+
+	roleStr, ok := role.(string)
+	if !ok {
+		return "", errors.New("invalid role type")
+	}
+
+	return roleStr, nil
+}
 
 // GenerateNewJWTToken generates a new JWT token
 func GenerateNewJWTToken(tokenMetadata map[string]string, tokenExpireTime time.Duration, signingKey string) (string, error) {
@@ -0,0 +1,97 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const DiskStoreName = "disk"
+
+// Disk keeps objects as files under the root directory, the content type is
+// taken from the extension of the key
+type Disk struct {
+	root string
+}
+
+func NewDisk(root string) (*Disk, error) {
+	if root == "" {
+		return nil, errors.New("media directory is not set")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+	return &Disk{root: root}, nil
+}
+
+// path maps the key to a file under the root, keys leaving the root are refused
+func (d *Disk) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean != "/"+key {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return filepath.Join(d.root, filepath.FromSlash(strings.TrimPrefix(clean, "/"))), nil
+}
+
+func (d *Disk) Put(ctx context.Context, key string, object Object) error {
+	name, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return fmt.Errorf("failed to create media directory: %w", err)
+	}
+	// the file appears under its name only when it is written completely
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(object.Data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to store media: %w", err)
+	}
+	return nil
+}
+
+func (d *Disk) Get(ctx context.Context, key string) (Object, error) {
+	name, err := d.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to read media: %w", err)
+	}
+	return Object{Data: data, ContentType: mime.TypeByExtension(path.Ext(key))}, nil
+}
+
+func (d *Disk) Exists(ctx context.Context, key string) (bool, error) {
+	name, err := d.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check media: %w", err)
+	}
+	return true, nil
+}
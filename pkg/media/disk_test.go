@@ -0,0 +1,40 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDiskStore(t *testing.T) {
+	store, err := NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDisk: %v", err)
+	}
+	ctx := context.Background()
+
+	key := "proofs/order-1/photo.jpg"
+	if err := store.Put(ctx, key, Object{Data: []byte("jpeg"), ContentType: "image/jpeg"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	exists, err := store.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("stored object does not exist: %v %v", exists, err)
+	}
+	object, err := store.Get(ctx, key)
+	if err != nil || string(object.Data) != "jpeg" || object.ContentType != "image/jpeg" {
+		t.Fatalf("unexpected object %q %q %v", object.Data, object.ContentType, err)
+	}
+
+	if _, err := store.Get(ctx, "proofs/order-1/other.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing object: %v", err)
+	}
+	if exists, err := store.Exists(ctx, "proofs/order-1/other.jpg"); err != nil || exists {
+		t.Fatalf("missing object exists: %v %v", exists, err)
+	}
+	for _, key := range []string{"", "../secret", "proofs/../../secret", "/etc/passwd", "proofs//photo.jpg"} {
+		if err := store.Put(ctx, key, Object{Data: []byte("x")}); err == nil {
+			t.Fatalf("key %q leaving the store is accepted", key)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package media
+
+import (
+	"context"
+	"sync"
+)
+
+const FakeStoreName = "fake"
+
+// Fake keeps objects in memory
+type Fake struct {
+	mu      sync.Mutex
+	objects map[string]Object
+}
+
+func NewFake() *Fake {
+	return &Fake{objects: make(map[string]Object)}
+}
+
+func (f *Fake) Put(ctx context.Context, key string, object Object) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = object
+	return nil
+}
+
+func (f *Fake) Get(ctx context.Context, key string) (Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[key]
+	if !ok {
+		return Object{}, ErrNotFound
+	}
+	return object, nil
+}
+
+func (f *Fake) Exists(ctx context.Context, key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.objects[key]
+	return ok, nil
+}
@@ -0,0 +1,35 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when there is no object with the key
+var ErrNotFound = errors.New("media object not found")
+
+// Object is a stored file with its content type
+type Object struct {
+	Data        []byte
+	ContentType string
+}
+
+// MediaStore keeps files uploaded by the apps, like photos of delivered orders.
+// Keys are made by the server, clients only get them back from an upload.
+type MediaStore interface {
+	Put(ctx context.Context, key string, object Object) error
+	Get(ctx context.Context, key string) (Object, error)
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// New returns the store with the name, the disk one keeps files under dir
+func New(name, dir string) (MediaStore, error) {
+	switch name {
+	case FakeStoreName:
+		return NewFake(), nil
+	case DiskStoreName:
+		return NewDisk(dir)
+	}
+	return nil, fmt.Errorf("unknown media store %q", name)
+}
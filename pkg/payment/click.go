@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+const ClickProviderName = "click"
+
+// clickProvider sends the customer to the Click pay page. Click calls our prepare and
+// complete callbacks while the customer pays, so the status is not polled.
+type clickProvider struct {
+	serviceID   string
+	merchantID  string
+	checkoutURL string
+}
+
+func NewClick(serviceID, merchantID, checkoutURL string) PaymentProvider {
+	return clickProvider{
+		serviceID:   serviceID,
+		merchantID:  merchantID,
+		checkoutURL: checkoutURL,
+	}
+}
+
+func (c clickProvider) Name() string {
+	return ClickProviderName
+}
+
+// CreateInvoice builds the pay page link, the Click transaction id is known only
+// after Click calls prepare
+func (c clickProvider) CreateInvoice(ctx context.Context, invoice Invoice) (InvoiceRes, error) {
+	query := url.Values{}
+	query.Set("service_id", c.serviceID)
+	query.Set("merchant_id", c.merchantID)
+	query.Set("amount", strconv.FormatInt(invoice.Amount, 10))
+	query.Set("transaction_param", invoice.OrderID)
+	if invoice.ReturnURL != "" {
+		query.Set("return_url", invoice.ReturnURL)
+	}
+	return InvoiceRes{PayURL: c.checkoutURL + "?" + query.Encode()}, nil
+}
+
+func (c clickProvider) CheckStatus(ctx context.Context, providerPaymentId string) (string, error) {
+	return StatusPending, nil
+}
+
+// Cancel does nothing, Click is answered with an error when it completes a payment of a closed order
+func (c clickProvider) Cancel(ctx context.Context, providerPaymentId string) error {
+	return nil
+}
+
+func (c clickProvider) Refund(ctx context.Context, providerPaymentId string, amount int64) error {
+	return ErrRefundNotSupported
+}
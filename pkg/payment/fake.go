@@ -0,0 +1,107 @@
+package payment
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const FakeProviderName = "fake"
+
+type fakeInvoice struct {
+	Invoice
+	status   string
+	refunded int64
+}
+
+// Fake keeps invoices in memory, tests pay them with SetStatus
+type Fake struct {
+	mu       sync.Mutex
+	invoices map[string]*fakeInvoice
+}
+
+func NewFake() *Fake {
+	return &Fake{invoices: make(map[string]*fakeInvoice)}
+}
+
+func (f *Fake) Name() string {
+	return FakeProviderName
+}
+
+func (f *Fake) CreateInvoice(ctx context.Context, invoice Invoice) (InvoiceRes, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := uuid.NewString()
+	f.invoices[id] = &fakeInvoice{Invoice: invoice, status: StatusPending}
+	return InvoiceRes{ProviderPaymentID: id, PayURL: "https://pay.example.com/" + id}, nil
+}
+
+func (f *Fake) CheckStatus(ctx context.Context, providerPaymentId string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[providerPaymentId]
+	if !ok {
+		return "", ErrInvoiceNotFound
+	}
+	return invoice.status, nil
+}
+
+func (f *Fake) Cancel(ctx context.Context, providerPaymentId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[providerPaymentId]
+	if !ok {
+		return ErrInvoiceNotFound
+	}
+	if invoice.status != StatusPending && invoice.status != StatusCancelled {
+		return ErrInvalidState
+	}
+	invoice.status = StatusCancelled
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, providerPaymentId string, amount int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[providerPaymentId]
+	if !ok {
+		return ErrInvoiceNotFound
+	}
+	if invoice.status != StatusPaid || amount <= 0 || invoice.refunded+amount > invoice.Amount {
+		return ErrInvalidState
+	}
+	invoice.refunded += amount
+	if invoice.refunded == invoice.Amount {
+		invoice.status = StatusRefunded
+	}
+	return nil
+}
+
+// SetStatus plays the customer paying or the provider declining the invoice
+func (f *Fake) SetStatus(providerPaymentId, status string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	invoice, ok := f.invoices[providerPaymentId]
+	if !ok {
+		return ErrInvoiceNotFound
+	}
+	invoice.status = status
+	return nil
+}
+
+// Refunded returns how much of the invoice was refunded
+func (f *Fake) Refunded(providerPaymentId string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if invoice, ok := f.invoices[providerPaymentId]; ok {
+		return invoice.refunded
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package payment
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const PaymeProviderName = "payme"
+
+// paymeProvider sends the customer to the Payme checkout. Payme does not have an
+// invoice API for merchants: it calls our merchant endpoint while the customer pays,
+// so the status of a payment is changed there and not polled.
+type paymeProvider struct {
+	merchantID  string
+	checkoutURL string
+}
+
+func NewPayme(merchantID, checkoutURL string) PaymentProvider {
+	return paymeProvider{
+		merchantID:  merchantID,
+		checkoutURL: strings.TrimRight(checkoutURL, "/"),
+	}
+}
+
+func (p paymeProvider) Name() string {
+	return PaymeProviderName
+}
+
+// CreateInvoice builds the checkout link, the Payme transaction id is known only
+// after Payme calls CreateTransaction
+func (p paymeProvider) CreateInvoice(ctx context.Context, invoice Invoice) (InvoiceRes, error) {
+	params := fmt.Sprintf("m=%s;ac.order_id=%s;a=%d", p.merchantID, invoice.OrderID, invoice.Amount*100)
+	if invoice.ReturnURL != "" {
+		params += ";c=" + invoice.ReturnURL
+	}
+	return InvoiceRes{
+		PayURL: p.checkoutURL + "/" + base64.StdEncoding.EncodeToString([]byte(params)),
+	}, nil
+}
+
+func (p paymeProvider) CheckStatus(ctx context.Context, providerPaymentId string) (string, error) {
+	return StatusPending, nil
+}
+
+// Cancel does nothing, a transaction of a cancelled order is refused when Payme tries to perform it
+func (p paymeProvider) Cancel(ctx context.Context, providerPaymentId string) error {
+	return nil
+}
+
+func (p paymeProvider) Refund(ctx context.Context, providerPaymentId string, amount int64) error {
+	return ErrRefundNotSupported
+}
@@ -0,0 +1,63 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Payment statuses reported by providers
+const (
+	StatusPending   = "pending"
+	StatusPaid      = "paid"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusRefunded  = "refunded"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrInvalidState is returned when the invoice can not be cancelled or refunded in its status
+	ErrInvalidState = errors.New("invoice is in a wrong state")
+	// ErrRefundNotSupported is returned by providers that refund only from their own cabinet
+	ErrRefundNotSupported = errors.New("provider does not support refunds through the API")
+)
+
+// Invoice asks the customer to pay Amount in soums for the order
+type Invoice struct {
+	PaymentID   string
+	OrderID     string
+	Amount      int64
+	Description string
+	// ReturnURL is where the customer is sent after paying
+	ReturnURL string
+}
+
+type InvoiceRes struct {
+	// ProviderPaymentID identifies the invoice at the provider
+	ProviderPaymentID string
+	// PayURL is the page the customer pays on
+	PayURL string
+}
+
+// PaymentProvider takes online payments. Amounts are in soums.
+type PaymentProvider interface {
+	Name() string
+	CreateInvoice(ctx context.Context, invoice Invoice) (InvoiceRes, error)
+	CheckStatus(ctx context.Context, providerPaymentId string) (string, error)
+	// Cancel cancels an invoice that is not paid yet
+	Cancel(ctx context.Context, providerPaymentId string) error
+	// Refund returns amount of a paid invoice to the customer, several partial refunds are allowed
+	Refund(ctx context.Context, providerPaymentId string, amount int64) error
+}
+
+// New returns the provider with the name, the stub one calls the stand-in server at stubURL
+func New(name, stubURL string) (PaymentProvider, error) {
+	switch name {
+	case FakeProviderName:
+		return NewFake(), nil
+	case StubProviderName:
+		return NewStub(stubURL), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
@@ -0,0 +1,263 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const StubProviderName = "stub"
+
+// stubProvider talks to the stand-in server, it is used in development instead of a real provider
+type stubProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewStub(baseURL string) PaymentProvider {
+	return stubProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s stubProvider) Name() string {
+	return StubProviderName
+}
+
+type stubInvoiceReq struct {
+	PaymentID   string `json:"payment_id"`
+	OrderID     string `json:"order_id"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+	ReturnURL   string `json:"return_url"`
+}
+
+type stubInvoiceRes struct {
+	ID     string `json:"id"`
+	PayURL string `json:"pay_url,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+type stubRefundReq struct {
+	Amount int64 `json:"amount"`
+}
+
+func (s stubProvider) CreateInvoice(ctx context.Context, invoice Invoice) (InvoiceRes, error) {
+	var res stubInvoiceRes
+	err := s.do(ctx, http.MethodPost, "/invoices", stubInvoiceReq{
+		PaymentID:   invoice.PaymentID,
+		OrderID:     invoice.OrderID,
+		Amount:      invoice.Amount,
+		Description: invoice.Description,
+		ReturnURL:   invoice.ReturnURL,
+	}, &res)
+	if err != nil {
+		return InvoiceRes{}, err
+	}
+	return InvoiceRes{ProviderPaymentID: res.ID, PayURL: res.PayURL}, nil
+}
+
+func (s stubProvider) CheckStatus(ctx context.Context, providerPaymentId string) (string, error) {
+	var res stubInvoiceRes
+	if err := s.do(ctx, http.MethodGet, "/invoices/"+providerPaymentId, nil, &res); err != nil {
+		return "", err
+	}
+	return res.Status, nil
+}
+
+func (s stubProvider) Cancel(ctx context.Context, providerPaymentId string) error {
+	return s.do(ctx, http.MethodPost, "/invoices/"+providerPaymentId+"/cancel", nil, nil)
+}
+
+func (s stubProvider) Refund(ctx context.Context, providerPaymentId string, amount int64) error {
+	return s.do(ctx, http.MethodPost, "/invoices/"+providerPaymentId+"/refund", stubRefundReq{Amount: amount}, nil)
+}
+
+func (s stubProvider) do(ctx context.Context, method, path string, body, res interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("payment stub is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrInvoiceNotFound
+	case http.StatusConflict:
+		return ErrInvalidState
+	default:
+		return fmt.Errorf("payment stub responded with status %d", resp.StatusCode)
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// StubServer is the local stand-in of a payment provider. The customer pays or
+// declines an invoice on its pay page and is sent back to the return URL.
+type StubServer struct {
+	invoices *Fake
+	mux      *http.ServeMux
+}
+
+func NewStubServer() *StubServer {
+	s := &StubServer{invoices: NewFake(), mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /invoices", s.createInvoice)
+	s.mux.HandleFunc("GET /invoices/{id}", s.getInvoice)
+	s.mux.HandleFunc("POST /invoices/{id}/cancel", s.cancelInvoice)
+	s.mux.HandleFunc("POST /invoices/{id}/refund", s.refundInvoice)
+	s.mux.HandleFunc("GET /pay/{id}", s.payPage)
+	s.mux.HandleFunc("POST /pay/{id}", s.pay)
+	return s
+}
+
+func (s *StubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *StubServer) createInvoice(w http.ResponseWriter, r *http.Request) {
+	var req stubInvoiceReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 {
+		http.Error(w, "invalid invoice", http.StatusBadRequest)
+		return
+	}
+	res, _ := s.invoices.CreateInvoice(r.Context(), Invoice{
+		PaymentID:   req.PaymentID,
+		OrderID:     req.OrderID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		ReturnURL:   req.ReturnURL,
+	})
+	writeJSON(w, stubInvoiceRes{
+		ID:     res.ProviderPaymentID,
+		PayURL: fmt.Sprintf("http://%s/pay/%s", r.Host, res.ProviderPaymentID),
+		Status: StatusPending,
+	})
+}
+
+func (s *StubServer) getInvoice(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	status, err := s.invoices.CheckStatus(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, stubInvoiceRes{ID: id, Status: status})
+}
+
+func (s *StubServer) cancelInvoice(w http.ResponseWriter, r *http.Request) {
+	if err := s.invoices.Cancel(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *StubServer) refundInvoice(w http.ResponseWriter, r *http.Request) {
+	var req stubRefundReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid refund", http.StatusBadRequest)
+		return
+	}
+	if err := s.invoices.Refund(r.Context(), r.PathValue("id"), req.Amount); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+var payPage = template.Must(template.New("pay").Parse(`<!DOCTYPE html>
+<html><body>
+<h2>{{.Description}}</h2>
+<p>Amount: {{.Amount}} so'm</p>
+<form method="post">
+<button name="action" value="pay">Pay</button>
+<button name="action" value="decline">Decline</button>
+</form>
+</body></html>`))
+
+func (s *StubServer) payPage(w http.ResponseWriter, r *http.Request) {
+	invoice, ok := s.invoice(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = payPage.Execute(w, invoice)
+}
+
+func (s *StubServer) pay(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	invoice, ok := s.invoice(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if status, _ := s.invoices.CheckStatus(r.Context(), id); status != StatusPending {
+		http.Error(w, "invoice is "+status, http.StatusConflict)
+		return
+	}
+
+	status := StatusFailed
+	if r.FormValue("action") == "pay" {
+		status = StatusPaid
+	}
+	_ = s.invoices.SetStatus(id, status)
+
+	if invoice.ReturnURL == "" {
+		fmt.Fprintf(w, "invoice is %s", status)
+		return
+	}
+	http.Redirect(w, r, invoice.ReturnURL, http.StatusSeeOther)
+}
+
+func (s *StubServer) invoice(id string) (Invoice, bool) {
+	s.invoices.mu.Lock()
+	defer s.invoices.mu.Unlock()
+
+	invoice, ok := s.invoices.invoices[id]
+	if !ok {
+		return Invoice{}, false
+	}
+	return invoice.Invoice, true
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
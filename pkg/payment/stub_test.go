@@ -0,0 +1,93 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStubInvoiceLifecycle(t *testing.T) {
+	server := httptest.NewServer(NewStubServer())
+	defer server.Close()
+	provider := NewStub(server.URL)
+	ctx := context.Background()
+
+	res, err := provider.CreateInvoice(ctx, Invoice{PaymentID: "p1", OrderID: "o1", Amount: 50000, Description: "order #1"})
+	if err != nil {
+		t.Fatalf("CreateInvoice: %v", err)
+	}
+	if res.ProviderPaymentID == "" || res.PayURL != server.URL+"/pay/"+res.ProviderPaymentID {
+		t.Fatalf("unexpected invoice %+v", res)
+	}
+	if status, _ := provider.CheckStatus(ctx, res.ProviderPaymentID); status != StatusPending {
+		t.Fatalf("status = %q, want pending", status)
+	}
+	if err := provider.Refund(ctx, res.ProviderPaymentID, 100); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("refund of an unpaid invoice: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(res.PayURL, url.Values{"action": {"pay"}})
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	resp.Body.Close()
+	if status, _ := provider.CheckStatus(ctx, res.ProviderPaymentID); status != StatusPaid {
+		t.Fatalf("status = %q, want paid", status)
+	}
+	if err := provider.Cancel(ctx, res.ProviderPaymentID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("cancel of a paid invoice: %v", err)
+	}
+
+	if err := provider.Refund(ctx, res.ProviderPaymentID, 20000); err != nil {
+		t.Fatalf("partial refund: %v", err)
+	}
+	if status, _ := provider.CheckStatus(ctx, res.ProviderPaymentID); status != StatusPaid {
+		t.Fatalf("status after a partial refund = %q, want paid", status)
+	}
+	if err := provider.Refund(ctx, res.ProviderPaymentID, 40000); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("refund over the amount: %v", err)
+	}
+	if err := provider.Refund(ctx, res.ProviderPaymentID, 30000); err != nil {
+		t.Fatalf("rest refund: %v", err)
+	}
+	if status, _ := provider.CheckStatus(ctx, res.ProviderPaymentID); status != StatusRefunded {
+		t.Fatalf("status = %q, want refunded", status)
+	}
+
+	if _, err := provider.CheckStatus(ctx, "missing"); !errors.Is(err, ErrInvoiceNotFound) {
+		t.Fatalf("unknown invoice: %v", err)
+	}
+}
+
+func TestStubDeclineAndCancel(t *testing.T) {
+	server := httptest.NewServer(NewStubServer())
+	defer server.Close()
+	provider := NewStub(server.URL)
+	ctx := context.Background()
+
+	declined, _ := provider.CreateInvoice(ctx, Invoice{Amount: 1000, ReturnURL: "https://app.example.com/paid"})
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(declined.PayURL, url.Values{"action": {"decline"}})
+	if err != nil {
+		t.Fatalf("decline: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "https://app.example.com/paid" {
+		t.Fatalf("customer is not sent back: %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if status, _ := provider.CheckStatus(ctx, declined.ProviderPaymentID); status != StatusFailed {
+		t.Fatalf("status = %q, want failed", status)
+	}
+
+	cancelled, _ := provider.CreateInvoice(ctx, Invoice{Amount: 1000})
+	if err := provider.Cancel(ctx, cancelled.ProviderPaymentID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if status, _ := provider.CheckStatus(ctx, cancelled.ProviderPaymentID); status != StatusCancelled {
+		t.Fatalf("status = %q, want cancelled", status)
+	}
+}
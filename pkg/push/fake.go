@@ -0,0 +1,44 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+const FakeSenderName = "fake"
+
+// Fake keeps sent messages in memory, tests expire tokens with Invalidate
+type Fake struct {
+	mu      sync.Mutex
+	sent    []Message
+	invalid map[string]bool
+}
+
+func NewFake() *Fake {
+	return &Fake{invalid: make(map[string]bool)}
+}
+
+func (f *Fake) Send(ctx context.Context, message Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.invalid[message.Token] {
+		return ErrInvalidToken
+	}
+	f.sent = append(f.sent, message)
+	return nil
+}
+
+// Invalidate plays the app being uninstalled from the device with the token
+func (f *Fake) Invalidate(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalid[token] = true
+}
+
+// Sent returns the messages delivered so far
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
@@ -0,0 +1,226 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	FCMSenderName = "fcm"
+
+	fcmBaseURL = "https://fcm.googleapis.com"
+	fcmScope   = "https://www.googleapis.com/auth/firebase.messaging"
+	// access tokens are renewed a bit before they expire so that a request does not race the expiry
+	fcmTokenLeeway = time.Minute
+)
+
+// ServiceAccount is the part of the Firebase service account key file the sender needs
+type ServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// fcmSender sends messages through the FCM HTTP v1 API, it signs in with the
+// service account and keeps the access token until it expires
+type fcmSender struct {
+	account ServiceAccount
+	key     *rsa.PrivateKey
+	baseURL string
+	client  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMFromFile reads the service account key file downloaded from the Firebase console
+func NewFCMFromFile(credentialsFile string) (PushSender, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FCM credentials: %w", err)
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse FCM credentials: %w", err)
+	}
+	return NewFCM(account, fcmBaseURL)
+}
+
+func NewFCM(account ServiceAccount, baseURL string) (PushSender, error) {
+	if account.ProjectID == "" || account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, errors.New("FCM credentials must have project_id, client_email and token_uri")
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FCM private key: %w", err)
+	}
+	return &fcmSender{
+		account: account,
+		key:     key,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmSendReq struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmErrorRes struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type            string `json:"@type"`
+			ErrorCode       string `json:"errorCode"`
+			FieldViolations []struct {
+				Field string `json:"field"`
+			} `json:"fieldViolations"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (f *fcmSender) Send(ctx context.Context, message Message) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(fcmSendReq{Message: fcmMessage{
+		Token:        message.Token,
+		Notification: fcmNotification{Title: message.Title, Body: message.Body},
+		Data:         message.Data,
+	}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		f.baseURL+"/v1/projects/"+f.account.ProjectID+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call FCM: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var res fcmErrorRes
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("FCM responded with status %d", resp.StatusCode)
+	}
+	if invalidToken(res) {
+		return ErrInvalidToken
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// the access token was revoked, the next message signs in again
+		f.mu.Lock()
+		f.accessToken = ""
+		f.mu.Unlock()
+	}
+	return fmt.Errorf("FCM responded with %s: %s", res.Error.Status, res.Error.Message)
+}
+
+// invalidToken reports whether FCM rejected the device token itself: the app was
+// uninstalled (UNREGISTERED) or the token is malformed
+func invalidToken(res fcmErrorRes) bool {
+	for _, detail := range res.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return true
+		}
+		for _, violation := range detail.FieldViolations {
+			if violation.Field == "message.token" {
+				return true
+			}
+		}
+	}
+	return res.Error.Status == "NOT_FOUND"
+}
+
+type fcmTokenRes struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// token returns the cached access token or exchanges a signed JWT for a new one
+func (f *fcmSender) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.accessToken != "" && now.Before(f.expiresAt) {
+		return f.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign FCM assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get FCM access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token endpoint responded with status %d", resp.StatusCode)
+	}
+	var res fcmTokenRes
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("failed to decode FCM access token: %w", err)
+	}
+
+	f.accessToken = res.AccessToken
+	f.expiresAt = now.Add(time.Duration(res.ExpiresIn)*time.Second - fcmTokenLeeway)
+	return f.accessToken, nil
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestFCMSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var tokenCalls int
+	var sent []fcmMessage
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenCalls++
+		assertion, err := jwt.Parse(r.FormValue("assertion"), func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		if err != nil || assertion.Claims.(jwt.MapClaims)["scope"] != fcmScope {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(fcmTokenRes{AccessToken: "access", ExpiresIn: 3600})
+	})
+	mux.HandleFunc("/v1/projects/shop/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req fcmSendReq
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Message.Token == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND",
+				"details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`))
+			return
+		}
+		sent = append(sent, req.Message)
+		w.Write([]byte(`{"name":"projects/shop/messages/1"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sender, err := NewFCM(ServiceAccount{
+		ProjectID:   "shop",
+		ClientEmail: "push@shop.iam.gserviceaccount.com",
+		PrivateKey:  string(privateKey),
+		TokenURI:    server.URL + "/token",
+	}, server.URL)
+	if err != nil {
+		t.Fatalf("NewFCM: %v", err)
+	}
+	ctx := context.Background()
+
+	message := Message{Token: "device", Title: "Buyurtma yetkazildi", Body: "#12", Data: map[string]string{"order_id": "o1"}}
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(ctx, message); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if tokenCalls != 1 {
+		t.Fatalf("access token requested %d times, want it cached", tokenCalls)
+	}
+	if len(sent) != 2 || sent[0].Token != "device" || sent[0].Notification.Title != message.Title || sent[0].Data["order_id"] != "o1" {
+		t.Fatalf("unexpected messages %+v", sent)
+	}
+
+	message.Token = "gone"
+	if err := sender.Send(ctx, message); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("send to an unregistered token: %v", err)
+	}
+}
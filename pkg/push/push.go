@@ -0,0 +1,36 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidToken is returned when the device token is no longer valid,
+// the token has to be forgotten so that it is not used again
+var ErrInvalidToken = errors.New("invalid device token")
+
+// Message is a push notification to one device. Data is delivered to the app
+// together with the notification, the app opens the screen it points to.
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// PushSender delivers push notifications to the users' devices
+type PushSender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New returns the sender with the name, the fcm one reads the service account from credentialsFile
+func New(name, credentialsFile string) (PushSender, error) {
+	switch name {
+	case FakeSenderName:
+		return NewFake(), nil
+	case FCMSenderName:
+		return NewFCMFromFile(credentialsFile)
+	}
+	return nil, fmt.Errorf("unknown push sender %q", name)
+}
@@ -0,0 +1,181 @@
+package receipt
+
+import (
+	"bytes"
+	"delivery/constants"
+	"fmt"
+	"strings"
+)
+
+// ESC/POS commands understood by common 58mm and 80mm thermal printers
+var (
+	escInit        = []byte{0x1B, 0x40}
+	escAlignLeft   = []byte{0x1B, 0x61, 0x00}
+	escAlignCenter = []byte{0x1B, 0x61, 0x01}
+	escBoldOn      = []byte{0x1B, 0x45, 0x01}
+	escBoldOff     = []byte{0x1B, 0x45, 0x00}
+	escDoubleSize  = []byte{0x1D, 0x21, 0x11}
+	escNormalSize  = []byte{0x1D, 0x21, 0x00}
+	escFeedAndCut  = []byte{0x1D, 0x56, 0x42, 0x03}
+)
+
+// charsPerLine returns how many characters of the default font fit on the paper
+func charsPerLine(paperWidth int) (int, error) {
+	switch paperWidth {
+	case constants.ReceiptPaper58mm:
+		return 32, nil
+	case constants.ReceiptPaper80mm:
+		return 48, nil
+	default:
+		return 0, fmt.Errorf("unsupported paper width %dmm", paperWidth)
+	}
+}
+
+// ESCPOS renders the packer slip as a raw byte stream for a thermal printer
+func (r Receipt) ESCPOS(paperWidth int) ([]byte, error) {
+	width, err := charsPerLine(paperWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.Write(escInit)
+
+	b.Write(escAlignCenter)
+	b.Write(escBoldOn)
+	writeLine(&b, r.ShopName)
+	b.Write(escDoubleSize)
+	writeLine(&b, r.title())
+	b.Write(escNormalSize)
+	b.Write(escBoldOff)
+	writeLine(&b, r.createdAt())
+
+	b.Write(escAlignLeft)
+	writeLine(&b, strings.Repeat("-", width))
+	for _, l := range wrap("Manzil: "+r.address(), width) {
+		writeLine(&b, l)
+	}
+	if r.Order.Comment != "" {
+		for _, l := range wrap("Izoh: "+r.Order.Comment, width) {
+			writeLine(&b, l)
+		}
+	}
+	writeLine(&b, strings.Repeat("-", width))
+
+	for _, l := range r.itemLines() {
+		if l.indent {
+			for _, w := range wrap(l.left, width-2) {
+				writeLine(&b, "  "+w)
+			}
+			continue
+		}
+		writeColumns(&b, l.left, l.right, width)
+	}
+	writeLine(&b, strings.Repeat("-", width))
+
+	for _, l := range r.totalLines() {
+		writeColumns(&b, l.left, l.right, width)
+	}
+	b.Write(escBoldOn)
+	writeColumns(&b, "JAMI", FormatAmount(r.Order.Total), width)
+	b.Write(escBoldOff)
+	for _, l := range r.afterTotalLines() {
+		writeColumns(&b, l.left, l.right, width)
+	}
+	writeColumns(&b, "To'lov turi", r.Order.PaymentMethod, width)
+
+	b.WriteByte('\n')
+	b.Write(escAlignCenter)
+	writeQR(&b, r.QRPayload())
+	b.WriteByte('\n')
+	b.Write(escFeedAndCut)
+
+	return b.Bytes(), nil
+}
+
+// writeQR prints a QR code with the printer's own GS ( k commands
+func writeQR(b *bytes.Buffer, data string) {
+	// model 2
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00})
+	// module size in dots
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x43, 0x06})
+	// error correction level M
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x45, 0x31})
+	// store the data
+	size := len(data) + 3
+	b.Write([]byte{0x1D, 0x28, 0x6B, byte(size % 256), byte(size / 256), 0x31, 0x50, 0x30})
+	b.WriteString(data)
+	// print the stored symbol
+	b.Write([]byte{0x1D, 0x28, 0x6B, 0x03, 0x00, 0x31, 0x51, 0x30})
+}
+
+func writeLine(b *bytes.Buffer, s string) {
+	b.WriteString(toASCII(s))
+	b.WriteByte('\n')
+}
+
+// writeColumns prints left aligned text and right aligned amount on the same line,
+// the text is wrapped when both do not fit
+func writeColumns(b *bytes.Buffer, left, right string, width int) {
+	left, right = toASCII(left), toASCII(right)
+	if width-len(right)-1 <= 0 {
+		// the amount leaves no room for the text, it goes on a line of its own
+		for _, l := range wrap(left, width) {
+			writeLine(b, l)
+		}
+		pad := width - len(right)
+		if pad < 0 {
+			pad = 0
+		}
+		writeLine(b, strings.Repeat(" ", pad)+right)
+		return
+	}
+
+	lines := wrap(left, width-len(right)-1)
+	for i, l := range lines {
+		if i < len(lines)-1 {
+			writeLine(b, l)
+			continue
+		}
+		pad := width - len(l) - len(right)
+		if pad < 1 {
+			pad = 1
+		}
+		writeLine(b, l+strings.Repeat(" ", pad)+right)
+	}
+}
+
+// wrap splits s into lines not longer than width, breaking on spaces where possible
+func wrap(s string, width int) []string {
+	s = toASCII(s)
+	if width <= 0 {
+		return []string{s}
+	}
+
+	var lines []string
+	for len(s) > width {
+		cut := strings.LastIndex(s[:width+1], " ")
+		if cut <= 0 {
+			cut = width
+		}
+		lines = append(lines, strings.TrimRight(s[:cut], " "))
+		s = strings.TrimLeft(s[cut:], " ")
+	}
+	return append(lines, s)
+}
+
+// toASCII keeps the text printable with the default code page of the printer
+func toASCII(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 128:
+			b.WriteRune(r)
+		case r == 'ʻ' || r == 'ʼ' || r == '‘' || r == '’':
+			b.WriteByte('\'')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
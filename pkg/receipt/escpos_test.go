@@ -0,0 +1,129 @@
+package receipt
+
+import (
+	"bytes"
+	"delivery/constants"
+	"delivery/entities"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	cases := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"Osh", 10, []string{"Osh"}},
+		{"", 10, []string{""}},
+		{"Choy puli berildi", 8, []string{"Choy", "puli", "berildi"}},
+		{"Mahsulotlar ro'yxati", 11, []string{"Mahsulotlar", "ro'yxati"}},
+		// a word longer than the line is cut
+		{"Qovurilgantovuq", 6, []string{"Qovuri", "lganto", "vuq"}},
+		{"a  b", 1, []string{"a", "b"}},
+		{"Osh palov", 0, []string{"Osh palov"}},
+		{"Osh palov", -3, []string{"Osh palov"}},
+		{"Toʻlov", 10, []string{"To'lov"}},
+	}
+	for _, c := range cases {
+		if got := wrap(c.text, c.width); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("wrap(%q, %d) = %q, want %q", c.text, c.width, got, c.want)
+		}
+	}
+}
+
+func TestWriteColumns(t *testing.T) {
+	cases := []struct {
+		left  string
+		right string
+		width int
+		want  string
+	}{
+		{"Osh x1", "45 000 so'm", 20, "Osh x1   45 000 so'm\n"},
+		{"Mahsulotlar", "100", 15, "Mahsulotlar 100\n"},
+		{"Yetkazib berish", "9 000 so'm", 20, "Yetkazib\nberish    9 000 so'm\n"},
+		// the amount leaves no room for the text
+		{"JAMI", "1 000 000 so'm", 10, "JAMI\n1 000 000 so'm\n"},
+		{"JAMI", "12 000 so'm", 12, "JAMI\n 12 000 so'm\n"},
+		{"To'lov turi", "card", 5, "To'lo\nv\nturi\n card\n"},
+	}
+	for _, c := range cases {
+		var b bytes.Buffer
+		writeColumns(&b, c.left, c.right, c.width)
+		if got := b.String(); got != c.want {
+			t.Fatalf("writeColumns(%q, %q, %d) = %q, want %q", c.left, c.right, c.width, got, c.want)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	cases := map[string]string{
+		"Osh palov":   "Osh palov",
+		"Oʻzbekiston": "O'zbekiston",
+		"Gʻoʼsht":     "G'o'sht",
+		"‘Choy’":      "'Choy'",
+		"Плов":        "????",
+		"№12":         "?12",
+	}
+	for text, want := range cases {
+		if got := toASCII(text); got != want {
+			t.Fatalf("toASCII(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestESCPOS(t *testing.T) {
+	r := Receipt{
+		ShopName: "Xozmak",
+		Order: entities.Order{
+			ID:            "order-1",
+			Number:        12,
+			AddressName:   "Chilonzor 9",
+			ItemsTotal:    90000,
+			DeliveryFee:   9000,
+			Total:         99000,
+			PaymentMethod: constants.PaymentMethodCard,
+			CreatedAt:     time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC),
+			Items: []entities.OrderItem{
+				{Name: "Osh", Price: 45000, Quantity: 2, Modifiers: entities.Modifiers{"piyozsiz"}},
+			},
+		},
+	}
+
+	if _, err := r.ESCPOS(70); err == nil {
+		t.Fatal("an unsupported paper width is accepted")
+	}
+
+	data, err := r.ESCPOS(constants.ReceiptPaper58mm)
+	if err != nil {
+		t.Fatalf("ESCPOS: %v", err)
+	}
+	if !bytes.HasPrefix(data, append(append([]byte{}, escInit...), escAlignCenter...)) {
+		t.Fatalf("the stream does not start with init and center: % x", data[:8])
+	}
+	if !bytes.HasSuffix(data, append([]byte{'\n'}, escFeedAndCut...)) {
+		t.Fatalf("the stream does not end with feed and cut: % x", data[len(data)-8:])
+	}
+
+	payload := r.QRPayload()
+	size := len(payload) + 3
+	store := append([]byte{0x1D, 0x28, 0x6B, byte(size % 256), byte(size / 256), 0x31, 0x50, 0x30}, payload...)
+	if !bytes.Contains(data, store) {
+		t.Fatal("the QR code data is not stored")
+	}
+
+	for _, want := range []string{
+		"Buyurtma #12\n",
+		"01.05.2024 14:30\n",
+		strings.Repeat("-", 32) + "\n",
+		"Osh x2               90 000 so'm\n",
+		"  - piyozsiz\n",
+		"To'lov turi                 card\n",
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Fatalf("the stream has no line %q", want)
+		}
+	}
+}
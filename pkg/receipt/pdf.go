@@ -0,0 +1,96 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	pdfPageWidth  = 100.0
+	pdfMargin     = 8.0
+	pdfLineHeight = 5.0
+	pdfQRSize     = 30.0
+)
+
+// PDF renders the customer receipt as a single PDF page
+func (r Receipt) PDF() ([]byte, error) {
+	lines := r.itemLines()
+	// the page grows with the number of items so that the receipt is never split
+	height := 110 + float64(len(lines))*pdfLineHeight + pdfQRSize
+
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: pdfPageWidth, Ht: height},
+	})
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	width := pdfPageWidth - 2*pdfMargin
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(width, 7, tr(r.ShopName), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(width, pdfLineHeight, tr(r.title()), "", 1, "C", false, 0, "")
+	pdf.CellFormat(width, pdfLineHeight, r.createdAt(), "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(width, pdfLineHeight, tr("Manzil:"), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.MultiCell(width, pdfLineHeight, tr(r.address()), "", "L", false)
+	pdf.Ln(1)
+
+	pdf.Line(pdfMargin, pdf.GetY(), pdfPageWidth-pdfMargin, pdf.GetY())
+	pdf.Ln(1)
+	for _, l := range lines {
+		if l.indent {
+			pdf.SetFont("Helvetica", "I", 8)
+			pdf.CellFormat(4, pdfLineHeight, "", "", 0, "L", false, 0, "")
+			pdf.CellFormat(width-4, pdfLineHeight, tr(l.left), "", 1, "L", false, 0, "")
+			continue
+		}
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(width*0.65, pdfLineHeight, tr(l.left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(width*0.35, pdfLineHeight, tr(l.right), "", 1, "R", false, 0, "")
+	}
+	pdf.Line(pdfMargin, pdf.GetY(), pdfPageWidth-pdfMargin, pdf.GetY())
+	pdf.Ln(1)
+
+	for _, l := range r.totalLines() {
+		pdf.CellFormat(width*0.65, pdfLineHeight, tr(l.left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(width*0.35, pdfLineHeight, tr(l.right), "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(width*0.5, 7, tr("Jami"), "", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.5, 7, tr(FormatAmount(r.Order.Total)), "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, l := range r.afterTotalLines() {
+		pdf.CellFormat(width*0.65, pdfLineHeight, tr(l.left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(width*0.35, pdfLineHeight, tr(l.right), "", 1, "R", false, 0, "")
+	}
+	pdf.CellFormat(width, pdfLineHeight, tr("To'lov turi: "+r.Order.PaymentMethod), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	qr, err := qrcode.Encode(r.QRPayload(), qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate order qr code: %w", err)
+	}
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("qr", options, bytes.NewReader(qr))
+	pdf.ImageOptions("qr", (pdfPageWidth-pdfQRSize)/2, pdf.GetY(), pdfQRSize, pdfQRSize, false, options, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render receipt pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r Receipt) address() string {
+	location := r.Order.AddressLocation
+	return fmt.Sprintf("%s (%.6f, %.6f)", r.Order.AddressName, location.Lat, location.Long)
+}
@@ -0,0 +1,96 @@
+package receipt
+
+import (
+	"delivery/entities"
+	"fmt"
+	"strings"
+)
+
+// Receipt holds the data printed on a customer receipt or a packer slip
+type Receipt struct {
+	ShopName string
+	Order    entities.Order
+	// LateTip is the tip paid on its own after the delivery, it is not a part of the total
+	LateTip int64
+}
+
+// QRPayload is encoded into the order QR code, scanning it gives the order id
+func (r Receipt) QRPayload() string {
+	return "order:" + r.Order.ID
+}
+
+// FormatAmount formats the amount in so'm with thousands separated by spaces
+func FormatAmount(amount int64) string {
+	return FormatNumber(amount) + " so'm"
+}
+
+// FormatNumber separates thousands of the number by spaces
+func FormatNumber(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := fmt.Sprintf("%d", amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// lines of the receipt body shared by every output format
+type line struct {
+	left  string
+	right string
+	// indent marks modifiers printed under their item
+	indent bool
+}
+
+func (r Receipt) itemLines() []line {
+	var lines []line
+	for _, item := range r.Order.Items {
+		lines = append(lines, line{
+			left:  fmt.Sprintf("%s x%d", item.Name, item.Quantity),
+			right: FormatAmount(item.Price * int64(item.Quantity)),
+		})
+		for _, modifier := range item.Modifiers {
+			lines = append(lines, line{left: "- " + modifier, indent: true})
+		}
+	}
+	return lines
+}
+
+func (r Receipt) totalLines() []line {
+	lines := []line{
+		{left: "Mahsulotlar", right: FormatAmount(r.Order.ItemsTotal)},
+		{left: "Yetkazib berish", right: FormatAmount(r.Order.DeliveryFee)},
+	}
+	if r.Order.Tip > 0 {
+		lines = append(lines, line{left: "Choy puli", right: FormatAmount(r.Order.Tip)})
+	}
+	if r.Order.CancelFee > 0 {
+		lines = append(lines, line{left: "Bekor qilish to'lovi", right: FormatAmount(r.Order.CancelFee)})
+	}
+	return lines
+}
+
+// afterTotalLines are printed under the total
+func (r Receipt) afterTotalLines() []line {
+	if r.LateTip <= 0 {
+		return nil
+	}
+	return []line{{left: "Choy puli (yetkazilgandan keyin)", right: FormatAmount(r.LateTip)}}
+}
+
+func (r Receipt) title() string {
+	return fmt.Sprintf("Buyurtma #%d", r.Order.Number)
+}
+
+func (r Receipt) createdAt() string {
+	return r.Order.CreatedAt.Format("02.01.2006 15:04")
+}
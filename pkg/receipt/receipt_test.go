@@ -0,0 +1,28 @@
+package receipt
+
+import (
+	"testing"
+)
+
+func TestFormatNumber(t *testing.T) {
+	cases := map[int64]string{
+		0:          "0",
+		7:          "7",
+		999:        "999",
+		1000:       "1 000",
+		45000:      "45 000",
+		100000:     "100 000",
+		1234567:    "1 234 567",
+		-1500:      "-1 500",
+		-999:       "-999",
+		1000000000: "1 000 000 000",
+	}
+	for amount, want := range cases {
+		if got := FormatNumber(amount); got != want {
+			t.Fatalf("FormatNumber(%d) = %q, want %q", amount, got, want)
+		}
+	}
+	if got := FormatAmount(12000); got != "12 000 so'm" {
+		t.Fatalf("FormatAmount(12000) = %q", got)
+	}
+}
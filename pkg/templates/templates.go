@@ -0,0 +1,272 @@
+package templates
+
+import (
+	"delivery/constants"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Text is a notification title and body, message templates have only the body.
+// Variables are written as {{.order_number}}.
+type Text struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Definition is a built-in template with the variables its texts may use
+type Definition struct {
+	Key       string
+	Variables []string
+	Texts     map[string]Text
+}
+
+// Languages are the languages every template has a text in
+var Languages = []string{constants.UzLang, constants.RuLang}
+
+// samples fill the variables in previews and when an edited text is checked
+var samples = map[string]string{
+	"order_number":  "1024",
+	"prep_minutes":  "20",
+	"eta":           "14:30",
+	"amount":        "45 000",
+	"handoff_code":  "4821",
+	"offer_seconds": "30",
+	"order_count":   "2",
+	"order_numbers": "#1024, #1025",
+}
+
+var definitions = []Definition{
+	{
+		Key:       constants.TemplateOrderAccepted,
+		Variables: []string{"order_number", "prep_minutes", "eta"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Buyurtma qabul qilindi",
+				Body:  "#{{.order_number}} raqamli buyurtmangiz qabul qilindi va {{.prep_minutes}} daqiqada tayyor bo'ladi.",
+			},
+			constants.RuLang: {
+				Title: "Заказ принят",
+				Body:  "Ваш заказ №{{.order_number}} принят и будет готов через {{.prep_minutes}} мин.",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateOrderRejected,
+		Variables: []string{"order_number", "amount"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Buyurtma bekor qilindi",
+				Body:  "Afsuski, #{{.order_number}} raqamli buyurtmangiz do'kon tomonidan qabul qilinmadi. To'lov qaytariladi.",
+			},
+			constants.RuLang: {
+				Title: "Заказ отменён",
+				Body:  "К сожалению, магазин не принял ваш заказ №{{.order_number}}. Оплата будет возвращена.",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateCourierAssigned,
+		Variables: []string{"order_number"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Kuryer biriktirildi",
+				Body:  "#{{.order_number}} raqamli buyurtmangizni kuryer olib keladi.",
+			},
+			constants.RuLang: {
+				Title: "Курьер назначен",
+				Body:  "Курьер доставит ваш заказ №{{.order_number}}.",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateOrderPickedUp,
+		Variables: []string{"order_number", "handoff_code"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Buyurtma yo'lda",
+				Body:  "Kuryer #{{.order_number}} raqamli buyurtmangizni olib ketdi. Topshirish kodi: {{.handoff_code}}",
+			},
+			constants.RuLang: {
+				Title: "Заказ в пути",
+				Body:  "Курьер забрал ваш заказ №{{.order_number}}. Код получения: {{.handoff_code}}",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateCourierArriving,
+		Variables: []string{"order_number", "handoff_code"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Kuryer yetib kelmoqda",
+				Body:  "Kuryer #{{.order_number}} raqamli buyurtmangiz bilan yetib kelmoqda. Topshirish kodi: {{.handoff_code}}",
+			},
+			constants.RuLang: {
+				Title: "Курьер подъезжает",
+				Body:  "Курьер с вашим заказом №{{.order_number}} уже подъезжает. Код получения: {{.handoff_code}}",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateOrderDelivered,
+		Variables: []string{"order_number", "amount"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Buyurtma yetkazildi",
+				Body:  "#{{.order_number}} raqamli buyurtmangiz yetkazib berildi. Yoqimli ishtaha!",
+			},
+			constants.RuLang: {
+				Title: "Заказ доставлен",
+				Body:  "Ваш заказ №{{.order_number}} доставлен. Приятного аппетита!",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateCourierOffer,
+		Variables: []string{"order_number", "offer_seconds"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Yangi buyurtma",
+				Body:  "#{{.order_number}} raqamli buyurtma sizga taklif qilindi. Qabul qilish uchun {{.offer_seconds}} soniya vaqtingiz bor.",
+			},
+			constants.RuLang: {
+				Title: "Новый заказ",
+				Body:  "Вам предложен заказ №{{.order_number}}. На ответ у вас {{.offer_seconds}} сек.",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateCourierBatchOffer,
+		Variables: []string{"order_count", "order_numbers", "offer_seconds"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Yangi buyurtma",
+				Body:  "{{.order_count}} ta buyurtma ({{.order_numbers}}) sizga birga taklif qilindi. Qabul qilish uchun {{.offer_seconds}} soniya vaqtingiz bor.",
+			},
+			constants.RuLang: {
+				Title: "Новые заказы",
+				Body:  "Вам предложены заказы {{.order_numbers}}, всего: {{.order_count}}. На ответ у вас {{.offer_seconds}} сек.",
+			},
+		},
+	},
+	{
+		Key:       constants.TemplateCourierOrderAssigned,
+		Variables: []string{"order_number"},
+		Texts: map[string]Text{
+			constants.UzLang: {
+				Title: "Yangi buyurtma",
+				Body:  "#{{.order_number}} raqamli buyurtma sizga biriktirildi.",
+			},
+			constants.RuLang: {
+				Title: "Новый заказ",
+				Body:  "Вам назначен заказ №{{.order_number}}.",
+			},
+		},
+	},
+	{
+		Key: constants.TemplateCodeSent,
+		Texts: map[string]Text{
+			constants.UzLang: {Body: "Telefon raqamingizga 6 xonali kod yuborildi"},
+			constants.RuLang: {Body: "На ваш номер телефона отправлен 6-значный код"},
+		},
+	},
+	{
+		Key: constants.TemplateInternalError,
+		Texts: map[string]Text{
+			constants.UzLang: {Body: "Sizni so'rovingizni bajarishda kutilmagan xatolik, Iltimos keyinroq urunib ko'ring"},
+			constants.RuLang: {Body: "При выполнении запроса произошла непредвиденная ошибка, пожалуйста, попробуйте позже"},
+		},
+	},
+	{
+		Key: constants.TemplateBadRequest,
+		Texts: map[string]Text{
+			constants.UzLang: {Body: "Yuborgan so'rovingizda xatolik"},
+			constants.RuLang: {Body: "В отправленном запросе ошибка"},
+		},
+	},
+}
+
+// All returns the built-in templates
+func All() []Definition {
+	return definitions
+}
+
+// Lookup returns the built-in template with the key
+func Lookup(key string) (Definition, bool) {
+	for _, def := range definitions {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Text returns the built-in text in the language, or in the default one
+func (d Definition) Text(language string) Text {
+	if text, ok := d.Texts[language]; ok {
+		return text
+	}
+	return d.Texts[constants.DefaultLang]
+}
+
+// Sample returns example values of the template variables
+func (d Definition) Sample() map[string]string {
+	vars := make(map[string]string, len(d.Variables))
+	for _, name := range d.Variables {
+		vars[name] = samples[name]
+	}
+	return vars
+}
+
+// Validate checks that the text is a valid template that uses only the variables of the definition
+func (d Definition) Validate(text Text) error {
+	if strings.TrimSpace(text.Body) == "" {
+		return errors.New("body is required")
+	}
+	_, err := Render(text, d.Sample())
+	return err
+}
+
+// Render fills the variables of the text, a variable missing in vars is an error
+func Render(text Text, vars map[string]string) (Text, error) {
+	title, err := render(text.Title, vars)
+	if err != nil {
+		return Text{}, fmt.Errorf("title: %w", err)
+	}
+	body, err := render(text.Body, vars)
+	if err != nil {
+		return Text{}, fmt.Errorf("body: %w", err)
+	}
+	return Text{Title: title, Body: body}, nil
+}
+
+func render(text string, vars map[string]string) (string, error) {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Language picks a supported language from a profile value or an Accept-Language
+// header like "ru-RU,ru;q=0.9", the default language is used otherwise
+func Language(value string) string {
+	for _, part := range strings.Split(value, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag, _, _ = strings.Cut(strings.ToLower(tag), "-")
+		for _, language := range Languages {
+			if tag == language {
+				return language
+			}
+		}
+	}
+	return constants.DefaultLang
+}
@@ -0,0 +1,53 @@
+package templates
+
+import (
+	"testing"
+)
+
+func TestDefinitionsRender(t *testing.T) {
+	for _, def := range All() {
+		for _, language := range Languages {
+			text, ok := def.Texts[language]
+			if !ok {
+				t.Fatalf("%s has no %s text", def.Key, language)
+			}
+			if err := def.Validate(text); err != nil {
+				t.Fatalf("%s %s: %v", def.Key, language, err)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	text, err := Render(Text{Title: "№{{.order_number}}", Body: "{{.amount}} сум"}, map[string]string{"order_number": "7", "amount": "12 000"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if text.Title != "№7" || text.Body != "12 000 сум" {
+		t.Fatalf("unexpected text %+v", text)
+	}
+
+	def, _ := Lookup("order_delivered")
+	if err := def.Validate(Text{Body: "{{.courier_name}} yetkazdi"}); err == nil {
+		t.Fatal("a variable the template does not have is accepted")
+	}
+	if err := def.Validate(Text{Body: "{{.order_number"}); err == nil {
+		t.Fatal("a broken template is accepted")
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                   "uz",
+		"ru":                 "ru",
+		"ru-RU,ru;q=0.9":     "ru",
+		"en-US,uz;q=0.8":     "uz",
+		"en-US,en;q=0.9":     "uz",
+		"UZ-Latn-UZ, ru;q=1": "uz",
+	}
+	for value, want := range cases {
+		if got := Language(value); got != want {
+			t.Fatalf("Language(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
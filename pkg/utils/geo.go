@@ -0,0 +1,96 @@
+package utils
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between two points in kilometers
+func DistanceKm(lat1, long1, lat2, long2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// BearingDeg returns the initial compass direction from the first point to the second,
+// 0 is north and 90 is east
+func BearingDeg(lat1, long1, lat2, long2 float64) float64 {
+	dLong := toRadians(long2 - long1)
+	y := math.Sin(dLong) * math.Cos(toRadians(lat2))
+	x := math.Cos(toRadians(lat1))*math.Sin(toRadians(lat2)) -
+		math.Sin(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Cos(dLong)
+
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// AngleDiffDeg returns the smallest angle between two directions, from 0 to 180
+func AngleDiffDeg(a, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
+
+// PointInPolygon reports whether the point lies inside the GeoJSON polygon. Positions are
+// [long, lat], the first ring is the outer border and the rest are holes. A point on a
+// border, of the polygon or of a hole, is inside.
+func PointInPolygon(lat, long float64, polygon [][][]float64) bool {
+	if len(polygon) == 0 {
+		return false
+	}
+	if pointOnRing(lat, long, polygon[0]) {
+		return true
+	}
+	if !pointInRing(lat, long, polygon[0]) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if pointInRing(lat, long, hole) && !pointOnRing(lat, long, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// borderEpsilon is how far from an edge, in degrees, a point still counts as lying on it
+const borderEpsilon = 1e-9
+
+// pointOnRing reports whether the point lies on one of the edges of the ring
+func pointOnRing(lat, long float64, ring [][]float64) bool {
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if long < math.Min(xi, xj)-borderEpsilon || long > math.Max(xi, xj)+borderEpsilon ||
+			lat < math.Min(yi, yj)-borderEpsilon || lat > math.Max(yi, yj)+borderEpsilon {
+			continue
+		}
+		// the cross product is the doubled area of the triangle, divided by the edge length
+		// it is the distance from the line of the edge
+		cross := (xj-xi)*(lat-yi) - (yj-yi)*(long-xi)
+		if math.Abs(cross) <= borderEpsilon*math.Max(math.Hypot(xj-xi, yj-yi), 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointInRing casts a ray from the point and counts how many edges of the ring it crosses
+func pointInRing(lat, long float64, ring [][]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && long < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestPointInPolygon(t *testing.T) {
+	// a square in Tashkent with a hole in the middle, positions are [long, lat]
+	outer := [][]float64{{69.2, 41.2}, {69.3, 41.2}, {69.3, 41.3}, {69.2, 41.3}, {69.2, 41.2}}
+	hole := [][]float64{{69.24, 41.24}, {69.26, 41.24}, {69.26, 41.26}, {69.24, 41.26}, {69.24, 41.24}}
+	square := [][][]float64{outer}
+	withHole := [][][]float64{outer, hole}
+	notClosed := [][][]float64{outer[:4]}
+	triangle := [][][]float64{{{0, 0}, {2, 0}, {0, 2}, {0, 0}}}
+
+	cases := []struct {
+		name    string
+		polygon [][][]float64
+		lat     float64
+		long    float64
+		want    bool
+	}{
+		{"inside", square, 41.25, 69.25, true},
+		{"outside to the east", square, 41.25, 69.35, false},
+		{"outside to the north", square, 41.35, 69.25, false},
+		{"on the bottom edge", square, 41.2, 69.25, true},
+		{"on the top edge", square, 41.3, 69.25, true},
+		{"on the left edge", square, 41.25, 69.2, true},
+		{"on the right edge", square, 41.25, 69.3, true},
+		{"on the top right vertex", square, 41.3, 69.3, true},
+		{"on the bottom left vertex", square, 41.2, 69.2, true},
+		{"just outside the top edge", square, 41.300001, 69.25, false},
+		{"lat and long swapped", square, 69.25, 41.25, false},
+		{"in the hole", withHole, 41.25, 69.25, false},
+		{"on the border of the hole", withHole, 41.24, 69.25, true},
+		{"between the border and the hole", withHole, 41.22, 69.25, true},
+		{"ring that is not closed", notClosed, 41.25, 69.25, true},
+		{"outside a ring that is not closed", notClosed, 41.25, 69.35, false},
+		{"on the closing edge of a ring that is not closed", notClosed, 41.25, 69.2, true},
+		{"on a slanted edge", triangle, 1, 1, true},
+		{"next to a slanted edge", triangle, 1.01, 1, false},
+		{"empty polygon", nil, 41.25, 69.25, false},
+	}
+	for _, c := range cases {
+		if got := PointInPolygon(c.lat, c.long, c.polygon); got != c.want {
+			t.Fatalf("%s: PointInPolygon(%v, %v) = %v, want %v", c.name, c.lat, c.long, got, c.want)
+		}
+	}
+}
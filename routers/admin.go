@@ -14,4 +14,56 @@ func (r Router) AdminRouters() {
 	adminGroup.GET("/subcategory", r.handler.GetSubCategory)
 	adminGroup.PUT("/subcategory/:id", r.handler.UpdateSubCategory)
 	adminGroup.DELETE("/subcategory/:id", r.handler.DeleteSubCategory)
+	adminGroup.POST("/product", r.handler.CreateProduct)
+	adminGroup.GET("/xozmak/:id/product", r.handler.GetProducts)
+	adminGroup.PUT("/product/:id", r.handler.UpdateProduct)
+	adminGroup.DELETE("/product/:id", r.handler.DeleteProduct)
+	adminGroup.POST("/xozmak/:id/slot", r.handler.CreateSlot)
+	adminGroup.GET("/xozmak/:id/slot", r.handler.GetXozmakSlots)
+	adminGroup.PUT("/slot/:id", r.handler.UpdateSlot)
+	adminGroup.DELETE("/slot/:id", r.handler.DeleteSlot)
+	adminGroup.POST("/xozmak/:id/zone", r.handler.CreateZone)
+	adminGroup.GET("/xozmak/:id/zone", r.handler.GetXozmakZones)
+	adminGroup.PUT("/zone/:id", r.handler.UpdateZone)
+	adminGroup.DELETE("/zone/:id", r.handler.DeleteZone)
+	adminGroup.PUT("/xozmak/:id/hours", r.handler.SetWorkingHours)
+	adminGroup.GET("/xozmak/:id/hours", r.handler.GetXozmakHours)
+	adminGroup.POST("/xozmak/:id/exceptions", r.handler.CreateHoursException)
+	adminGroup.POST("/holidays", r.handler.CreateHoliday)
+	adminGroup.DELETE("/exceptions/:id", r.handler.DeleteHoursException)
+	adminGroup.PUT("/xozmak/:id/ordering", r.handler.SetXozmakOrdering)
+	adminGroup.POST("/courier", r.handler.CreateCourier)
+	adminGroup.GET("/courier", r.handler.GetCouriers)
+	adminGroup.GET("/courier/:id", r.handler.GetCourier)
+	adminGroup.PUT("/courier/:id", r.handler.UpdateCourier)
+	adminGroup.DELETE("/courier/:id", r.handler.DeleteCourier)
+	adminGroup.POST("/courier/:id/block", r.handler.BlockCourier)
+	adminGroup.POST("/courier/:id/unblock", r.handler.UnblockCourier)
+	adminGroup.GET("/courier/:id/earnings", r.handler.GetCourierEarnings)
+	adminGroup.POST("/courier/:id/adjustments", r.handler.AddEarningAdjustment)
+	adminGroup.GET("/courier/:id/cash", r.handler.GetCourierCash)
+	adminGroup.POST("/courier/:id/cash/handovers", r.handler.RecordCashHandover)
+	adminGroup.POST("/payouts", r.idempotency.Middleware(), r.handler.CreatePayoutBatch)
+	adminGroup.GET("/payouts", r.handler.GetPayoutBatches)
+	adminGroup.GET("/payouts/:id", r.handler.GetPayoutBatch)
+	adminGroup.GET("/payouts/:id/export", r.handler.ExportPayoutBatch)
+	adminGroup.POST("/orders/:id/courier", r.handler.AssignCourier)
+	adminGroup.GET("/orders/handoff-locked", r.handler.GetHandoffLockedOrders)
+	adminGroup.POST("/orders/:id/deliver", r.handler.ConfirmDelivery)
+	adminGroup.GET("/users/:id/wallet", r.handler.GetUserWallet)
+	adminGroup.GET("/users/:id/wallet/transactions", r.handler.GetUserWalletTransactions)
+	adminGroup.POST("/users/:id/wallet", r.idempotency.Middleware(), r.handler.CreditUserWallet)
+	adminGroup.POST("/commission-rules", r.handler.CreateCommissionRule)
+	adminGroup.GET("/commission-rules", r.handler.GetCommissionRules)
+	adminGroup.PUT("/commission-rules/:id", r.handler.UpdateCommissionRule)
+	adminGroup.DELETE("/commission-rules/:id", r.handler.DeleteCommissionRule)
+	adminGroup.POST("/settlements", r.handler.CreateSettlements)
+	adminGroup.GET("/settlements", r.handler.GetSettlements)
+	adminGroup.GET("/settlements/:id", r.handler.GetSettlement)
+	adminGroup.GET("/settlements/:id/export", r.handler.ExportSettlement)
+	adminGroup.POST("/settlements/:id/settle", r.handler.MarkSettlementSettled)
+	adminGroup.GET("/notification-templates", r.handler.GetNotificationTemplates)
+	adminGroup.PUT("/notification-templates/:key/:language", r.handler.UpdateNotificationTemplate)
+	adminGroup.DELETE("/notification-templates/:key/:language", r.handler.ResetNotificationTemplate)
+	adminGroup.POST("/notification-templates/:key/:language/preview", r.handler.PreviewNotificationTemplate)
 }
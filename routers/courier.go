@@ -0,0 +1,18 @@
+package routers
+
+func (r Router) CourierRouters() {
+	courierGroup := r.router.Group("/api/v1/courier")
+	courierGroup.GET("/profile", r.handler.GetCourierProfile)
+	courierGroup.POST("/online", r.handler.GoOnline)
+	courierGroup.POST("/offline", r.handler.GoOffline)
+	courierGroup.GET("/shifts", r.handler.GetCourierShifts)
+	courierGroup.GET("/earnings", r.handler.GetMyEarnings)
+	courierGroup.GET("/cash", r.handler.GetMyCash)
+	courierGroup.POST("/location", r.handler.PushLocation)
+	courierGroup.GET("/offer", r.handler.GetCourierOffer)
+	courierGroup.POST("/offers/:id/accept", r.handler.AcceptOffer)
+	courierGroup.POST("/offers/:id/decline", r.handler.DeclineOffer)
+	courierGroup.POST("/orders/:id/pickup", r.handler.PickUpOrder)
+	courierGroup.POST("/orders/:id/photo", r.handler.UploadProofPhoto)
+	courierGroup.POST("/orders/:id/deliver", r.handler.DeliverOrder)
+}
@@ -0,0 +1,35 @@
+package routers
+
+func (r Router) OrderRouters() {
+	orderGroup := r.router.Group("/api/v1")
+	// retries of order and payment requests with the same Idempotency-Key get the first response
+	orderGroup.GET("/slots", r.handler.GetAvailableSlots)
+	orderGroup.GET("/xozmaks", r.handler.GetDeliveringXozmaks)
+	orderGroup.GET("/xozmaks/:id/hours", r.handler.GetXozmakSchedule)
+	orderGroup.POST("/orders", r.idempotency.Middleware(), r.handler.PlaceOrder)
+	orderGroup.GET("/orders", r.handler.GetOrderHistory)
+	orderGroup.GET("/orders/:id", r.handler.GetOrder)
+	orderGroup.POST("/orders/:id/reorder", r.idempotency.Middleware(), r.handler.Reorder)
+	orderGroup.POST("/orders/:id/cancel", r.idempotency.Middleware(), r.handler.CancelOrder)
+	orderGroup.GET("/orders/:id/history", r.handler.GetOrderTimeline)
+	orderGroup.GET("/orders/:id/receipt", r.handler.GetOrderReceipt)
+	orderGroup.GET("/orders/:id/track", r.handler.GetOrderTrack)
+	orderGroup.GET("/orders/:id/eta", r.handler.GetOrderETA)
+	orderGroup.GET("/orders/:id/stream", r.handler.StreamOrder)
+	orderGroup.GET("/orders/:id/proof", r.handler.GetDeliveryProof)
+	orderGroup.GET("/orders/:id/proof/photo", r.handler.GetDeliveryProofPhoto)
+	orderGroup.POST("/orders/:id/pay", r.idempotency.Middleware(), r.handler.PayOrder)
+	orderGroup.POST("/orders/:id/pay/wallet", r.idempotency.Middleware(), r.handler.PayOrderFromWallet)
+	orderGroup.POST("/orders/:id/tip", r.idempotency.Middleware(), r.handler.TipOrder)
+	orderGroup.GET("/orders/:id/payments", r.handler.GetOrderPayments)
+	orderGroup.GET("/payments/:id", r.handler.CheckPayment)
+	// Payme calls it with its own Basic authorization, every HTTP method is answered with a JSON-RPC error but POST
+	orderGroup.Any("/payme", r.handler.Payme)
+	// Click signs its callbacks with the secret key instead of a token
+	orderGroup.POST("/click/prepare", r.handler.ClickPrepare)
+	orderGroup.POST("/click/complete", r.handler.ClickComplete)
+	orderGroup.GET("/wallet", r.handler.GetMyWallet)
+	orderGroup.GET("/wallet/transactions", r.handler.GetMyWalletTransactions)
+	orderGroup.GET("/cart", r.handler.GetCart)
+	orderGroup.GET("/notifications", r.handler.GetNotifications)
+}
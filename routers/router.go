@@ -15,10 +15,11 @@ type Router struct {
 	router  *gin.Engine
 	logger  logger.LoggerI
 	middlewares *middlewares.JWTRoleAuthorizer
+	idempotency *middlewares.Idempotency
 }
 
 // New creates a new router
-func New(h handlers.Handler, cfg *configs.Configuration, logger logger.LoggerI, mw *middlewares.JWTRoleAuthorizer) Router {
+func New(h handlers.Handler, cfg *configs.Configuration, logger logger.LoggerI, mw *middlewares.JWTRoleAuthorizer, idempotency *middlewares.Idempotency) Router {
 	r := gin.New()
 
 	return Router{
@@ -27,6 +28,7 @@ func New(h handlers.Handler, cfg *configs.Configuration, logger logger.LoggerI,
 		logger:  logger,
 		config:  cfg,
 		middlewares: mw,
+		idempotency: idempotency,
 	}
 
 }
@@ -39,6 +41,9 @@ func (r Router) Start() {
 
 	r.UserRouters()
 	r.AdminRouters()
+	r.OrderRouters()
+	r.SellerRouters()
+	r.CourierRouters()
 
 	r.logger.Info("HTTP: Server being started...", logger.String("port", r.config.HTTPPort))
 
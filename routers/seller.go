@@ -0,0 +1,17 @@
+package routers
+
+func (r Router) SellerRouters() {
+	sellerGroup := r.router.Group("/api/v1/seller")
+	sellerGroup.GET("/orders", r.handler.GetSellerOrders)
+	sellerGroup.POST("/orders/:id/accept", r.handler.AcceptOrder)
+	sellerGroup.POST("/orders/:id/preparing", r.handler.StartPreparing)
+	sellerGroup.POST("/orders/:id/reject", r.handler.RejectOrder)
+	sellerGroup.POST("/orders/:id/ready", r.handler.MarkOrderReady)
+	sellerGroup.GET("/orders/:id/slip", r.handler.GetOrderSlip)
+	sellerGroup.POST("/pause", r.handler.PauseOrders)
+	sellerGroup.POST("/resume", r.handler.ResumeOrders)
+	sellerGroup.POST("/couriers/:id/cash/handovers", r.handler.RecordCashHandover)
+	sellerGroup.GET("/settlements", r.handler.GetSettlements)
+	sellerGroup.GET("/settlements/:id", r.handler.GetSettlement)
+	sellerGroup.GET("/settlements/:id/export", r.handler.ExportSettlement)
+}
@@ -243,6 +243,59 @@ func (a adminRepo) DeleteSubCategory(ctx context.Context, sub_categoryId string)
 }
 
 
+func (a *adminRepo) CreateProduct(ctx context.Context, req entities.Product) error {
+	res := a.db.WithContext(ctx).Table("products").Create(&req)
+	if res.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(res.Error, &pgErr) && pgErr.Code == constants.PGForeignKeyViolationCode {
+			return e.ErrInvalidInput
+		}
+		return fmt.Errorf("error in CreateProduct: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("error in CreateProduct: %w", constants.ErrRowsAffectedIsZero)
+	}
+	return nil
+}
+
+func (a *adminRepo) GetProducts(ctx context.Context, xozmakId string) ([]entities.Product, error) {
+	var products []entities.Product
+	err := a.db.WithContext(ctx).Table("products").
+		Where("xozmak_id = ? AND state = ?", xozmakId, constants.Active).
+		Order("name").
+		Find(&products).Error
+	if err != nil {
+		return []entities.Product{}, fmt.Errorf("error in GetProducts: %w", err)
+	}
+	return products, nil
+}
+
+func (a *adminRepo) UpdateProduct(ctx context.Context, req entities.Product) error {
+	res := a.db.WithContext(ctx).Table("products").
+		Where("id = ? AND state = ?", req.ID, constants.Active).
+		Select("sub_category_id", "name", "photo", "price", "stock", "updated_at").
+		Updates(req)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update product: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrProductNotFound
+	}
+	return nil
+}
+
+func (a *adminRepo) DeleteProduct(ctx context.Context, id string) error {
+	res := a.db.WithContext(ctx).Table("products").Where("id = ?", id).Update("state", constants.InActive)
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete product: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrProductNotFound
+	}
+	return nil
+}
+
+
 //func(a *adminRepo) GetProfile(ctx context.Context)()
 
 // func (a adminRepo) GetUserByPhone(ctx context.Context, phoneNumber string) (entities.LoginPostgres, error) {
@@ -263,3 +316,18 @@ func (a adminRepo) DeleteSubCategory(ctx context.Context, sub_categoryId string)
 
 // 	return user, nil
 // }
+
+func (a *adminRepo) GetUserByPhone(ctx context.Context, phoneNumber string) (entities.UserAccount, error) {
+	var account entities.UserAccount
+	err := a.db.WithContext(ctx).Table("users").
+		Select("id, COALESCE(role::text, '') AS role").
+		Where("phone_number = ?", phoneNumber).
+		Take(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.UserAccount{}, e.ErrAccountNotExists
+		}
+		return entities.UserAccount{}, fmt.Errorf("error in GetUserByPhone: %w", err)
+	}
+	return account, nil
+}
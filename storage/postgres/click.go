@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+func (p *paymentRepo) GetClickTransaction(ctx context.Context, id int64) (entities.ClickTransaction, error) {
+	var transaction entities.ClickTransaction
+	err := p.db.WithContext(ctx).Table("click_transactions").Where("id = ?", id).Take(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.ClickTransaction{}, e.ErrClickTransactionNotFound
+		}
+		return entities.ClickTransaction{}, fmt.Errorf("error in GetClickTransaction: %w", err)
+	}
+	return transaction, nil
+}
+
+func (p *paymentRepo) GetClickTransactionByClickID(ctx context.Context, clickTransId int64) (entities.ClickTransaction, error) {
+	var transaction entities.ClickTransaction
+	err := p.db.WithContext(ctx).Table("click_transactions").Where("click_trans_id = ?", clickTransId).Take(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.ClickTransaction{}, e.ErrClickTransactionNotFound
+		}
+		return entities.ClickTransaction{}, fmt.Errorf("error in GetClickTransactionByClickID: %w", err)
+	}
+	return transaction, nil
+}
+
+// CreateClickTransaction binds the prepared transaction to the pending payment, the payment
+// is created when the customer did not ask for an invoice in the app before paying
+func (p *paymentRepo) CreateClickTransaction(ctx context.Context, transaction entities.ClickTransaction, payment entities.Payment) (entities.ClickTransaction, error) {
+	clickTransId := fmt.Sprint(transaction.ClickTransID)
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("payments").
+			Where("id = ? AND status = ? AND provider_payment_id IS NULL", payment.ID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"provider_payment_id": clickTransId,
+				"updated_at":          time.Now(),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to bind payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			payment.ProviderPaymentID = &clickTransId
+			if err := tx.Table("payments").Create(&payment).Error; err != nil {
+				return fmt.Errorf("failed to create payment: %w", err)
+			}
+		}
+
+		if err := tx.Table("click_transactions").Create(&transaction).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+				return e.ErrClickTransactionChanged
+			}
+			return fmt.Errorf("failed to create click transaction: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return entities.ClickTransaction{}, err
+	}
+	return transaction, nil
+}
+
+// CompleteClickTransaction marks the transaction and its payment as paid and passes the
+// order on to the seller. Nothing is changed when the order is not waiting for the payment.
+func (p *paymentRepo) CompleteClickTransaction(ctx context.Context, transaction entities.ClickTransaction, history entities.OrderHistory) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("click_transactions").
+			Where("id = ? AND status = ?", transaction.ID, constants.ClickStatusPrepared).
+			Updates(map[string]interface{}{
+				"status":       constants.ClickStatusCompleted,
+				"completed_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to complete click transaction: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrClickTransactionChanged
+		}
+
+		res = tx.Table("payments").
+			Where("id = ? AND status = ?", transaction.PaymentID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status":     constants.PaymentStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to confirm payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymentStatusChanged
+		}
+
+		res = tx.Table("orders").
+			Where("id = ? AND status = ? AND paid_at IS NULL", transaction.OrderID, constants.OrderStatusAwaitingPayment).
+			Updates(map[string]interface{}{
+				"status":     constants.OrderStatusNew,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to mark order as paid: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderNotAwaitingPayment
+		}
+
+		if err := tx.Table("order_history").Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return nil
+	})
+}
+
+// CancelClickTransaction cancels the prepared transaction together with its payment
+func (p *paymentRepo) CancelClickTransaction(ctx context.Context, transaction entities.ClickTransaction, reason string) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("click_transactions").
+			Where("id = ? AND status = ?", transaction.ID, constants.ClickStatusPrepared).
+			Updates(map[string]interface{}{
+				"status":       constants.ClickStatusCancelled,
+				"error":        reason,
+				"cancelled_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to cancel click transaction: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrClickTransactionChanged
+		}
+
+		err := tx.Table("payments").
+			Where("id = ? AND status = ?", transaction.PaymentID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status":     constants.PaymentStatusCancelled,
+				"error":      reason,
+				"updated_at": now,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel payment: %w", err)
+		}
+		return nil
+	})
+}
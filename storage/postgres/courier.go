@@ -0,0 +1,297 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+type courierRepo struct {
+	db *gorm.DB
+}
+
+func NewCourier(db *gorm.DB) *courierRepo {
+	return &courierRepo{db: db}
+}
+
+// CreateCourier creates the courier account and profile together
+func (c *courierRepo) CreateCourier(ctx context.Context, req entities.CourierReq) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO users (id, phone_number, firstname, surname, role) VALUES (?, ?, ?, ?, ?)",
+			req.ID, req.PhoneNumber, req.Firstname, req.Surname, constants.CourierRole).Error
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+				return e.ErrCourierAlreadyExists
+			}
+			return fmt.Errorf("failed to create courier account: %w", err)
+		}
+
+		courier := entities.Courier{
+			ID:            req.ID,
+			VehicleType:   req.VehicleType,
+			Documents:     req.Documents,
+			Status:        constants.CourierStatusActive,
+			PayoutAccount: req.PayoutAccount,
+		}
+		if err := tx.Table("couriers").Create(&courier).Error; err != nil {
+			return fmt.Errorf("failed to create courier: %w", err)
+		}
+		return nil
+	})
+}
+
+// couriers selects active profiles joined with their accounts
+func (c *courierRepo) couriers(ctx context.Context) *gorm.DB {
+	return c.db.WithContext(ctx).Table("couriers c").
+		Joins("JOIN users u ON u.id = c.user_id").
+		Where("c.state = ?", constants.Active)
+}
+
+func (c *courierRepo) GetCouriers(ctx context.Context, status string, limit, offset int) ([]entities.Courier, int64, error) {
+	var (
+		couriers []entities.Courier
+		count    int64
+	)
+	query := c.couriers(ctx)
+	if status != "" {
+		query = query.Where("c.status = ?", status)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.Courier{}, 0, fmt.Errorf("error in GetCouriers: %w", err)
+	}
+	err := query.Select("c.*, u.phone_number, u.firstname, u.surname").
+		Order("c.created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&couriers).Error
+	if err != nil {
+		return []entities.Courier{}, 0, fmt.Errorf("error in GetCouriers: %w", err)
+	}
+	return couriers, count, nil
+}
+
+func (c *courierRepo) GetCourier(ctx context.Context, id string) (entities.Courier, error) {
+	var courier entities.Courier
+	err := c.couriers(ctx).
+		Select("c.*, u.phone_number, u.firstname, u.surname").
+		Where("c.user_id = ?", id).
+		Take(&courier).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Courier{}, e.ErrCourierNotFound
+		}
+		return entities.Courier{}, fmt.Errorf("error in GetCourier: %w", err)
+	}
+	return courier, nil
+}
+
+func (c *courierRepo) UpdateCourier(ctx context.Context, req entities.CourierReq) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("couriers").
+			Where("user_id = ? AND state = ?", req.ID, constants.Active).
+			Updates(map[string]interface{}{
+				"vehicle_type":   req.VehicleType,
+				"documents":      req.Documents,
+				"payout_account": req.PayoutAccount,
+				"updated_at":     now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to update courier: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrCourierNotFound
+		}
+
+		err := tx.Table("users").
+			Where("id = ?", req.ID).
+			Updates(map[string]interface{}{
+				"phone_number": req.PhoneNumber,
+				"firstname":    req.Firstname,
+				"surname":      req.Surname,
+				"updated_at":   now,
+			}).Error
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+				return e.ErrCourierAlreadyExists
+			}
+			return fmt.Errorf("failed to update courier account: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *courierRepo) DeleteCourier(ctx context.Context, id string) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("couriers").
+			Where("user_id = ? AND state = ?", id, constants.Active).
+			Updates(map[string]interface{}{
+				"state":      constants.InActive,
+				"is_online":  false,
+				"updated_at": time.Now(),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to delete courier: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrCourierNotFound
+		}
+		return closeShift(tx, id)
+	})
+}
+
+// SetCourierStatus activates or blocks the courier, a blocked courier is taken off the shift
+func (c *courierRepo) SetCourierStatus(ctx context.Context, id, status, reason string) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fields := map[string]interface{}{
+			"status":         status,
+			"blocked_reason": nil,
+			"updated_at":     time.Now(),
+		}
+		if status == constants.CourierStatusBlocked {
+			fields["blocked_reason"] = reason
+			fields["is_online"] = false
+		}
+
+		res := tx.Table("couriers").Where("user_id = ? AND state = ?", id, constants.Active).Updates(fields)
+		if res.Error != nil {
+			return fmt.Errorf("failed to set courier status: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrCourierNotFound
+		}
+		if status == constants.CourierStatusBlocked {
+			return closeShift(tx, id)
+		}
+		return nil
+	})
+}
+
+// StartShift puts an active courier online, an already open shift is returned as is
+func (c *courierRepo) StartShift(ctx context.Context, courierId string) (entities.CourierShift, error) {
+	var shift entities.CourierShift
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("couriers").
+			Where("user_id = ? AND status = ? AND state = ?", courierId, constants.CourierStatusActive, constants.Active).
+			Updates(map[string]interface{}{"is_online": true, "updated_at": now})
+		if res.Error != nil {
+			return fmt.Errorf("failed to put courier online: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrCourierBlocked
+		}
+
+		err := tx.Table("courier_shifts").Where("courier_id = ? AND ended_at IS NULL", courierId).Take(&shift).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get open shift: %w", err)
+		}
+
+		shift = entities.CourierShift{CourierID: courierId, StartedAt: now}
+		if err := tx.Table("courier_shifts").Create(&shift).Error; err != nil {
+			return fmt.Errorf("failed to start shift: %w", err)
+		}
+		return nil
+	})
+	return shift, err
+}
+
+// EndShift closes the open shift and puts the courier offline
+func (c *courierRepo) EndShift(ctx context.Context, courierId string) (entities.CourierShift, error) {
+	var shift entities.CourierShift
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Raw("UPDATE courier_shifts SET ended_at = ? WHERE courier_id = ? AND ended_at IS NULL RETURNING *",
+			time.Now(), courierId).Scan(&shift)
+		if res.Error != nil {
+			return fmt.Errorf("failed to end shift: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrCourierOffline
+		}
+
+		err := tx.Table("couriers").
+			Where("user_id = ?", courierId).
+			Updates(map[string]interface{}{"is_online": false, "updated_at": time.Now()}).Error
+		if err != nil {
+			return fmt.Errorf("failed to put courier offline: %w", err)
+		}
+		return nil
+	})
+	return shift, err
+}
+
+// closeShift ends the open shift of the courier if there is one
+func closeShift(tx *gorm.DB, courierId string) error {
+	err := tx.Table("courier_shifts").
+		Where("courier_id = ? AND ended_at IS NULL", courierId).
+		Update("ended_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to close courier shift: %w", err)
+	}
+	return nil
+}
+
+func (c *courierRepo) GetCourierShifts(ctx context.Context, courierId string, limit, offset int) ([]entities.CourierShift, error) {
+	var shifts []entities.CourierShift
+	err := c.db.WithContext(ctx).Table("courier_shifts").
+		Where("courier_id = ?", courierId).
+		Order("started_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&shifts).Error
+	if err != nil {
+		return []entities.CourierShift{}, fmt.Errorf("error in GetCourierShifts: %w", err)
+	}
+	return shifts, nil
+}
+
+// GetCourierOrders returns the orders of the courier without their items
+func (c *courierRepo) GetCourierOrders(ctx context.Context, courierId string, statuses []string) ([]entities.Order, error) {
+	var orders []entities.Order
+	err := c.db.WithContext(ctx).Table("orders").
+		Where("courier_id = ? AND status IN ?", courierId, statuses).
+		Find(&orders).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetCourierOrders: %w", err)
+	}
+	return orders, nil
+}
+
+func (c *courierRepo) InsertTrackPoints(ctx context.Context, points []entities.OrderTrackPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	err := c.db.WithContext(ctx).Table("order_tracks").CreateInBatches(points, 100).Error
+	if err != nil {
+		return fmt.Errorf("error in InsertTrackPoints: %w", err)
+	}
+	return nil
+}
+
+// GetDispatchCandidates returns online active couriers among courierIds with their rating and current load
+func (c *courierRepo) GetDispatchCandidates(ctx context.Context, courierIds []string, activeStatuses []string) ([]entities.DispatchCandidate, error) {
+	var candidates []entities.DispatchCandidate
+	if len(courierIds) == 0 {
+		return candidates, nil
+	}
+	err := c.db.WithContext(ctx).Table("couriers c").
+		Select("c.user_id AS courier_id, c.rating, c.cash_balance, "+
+			"(SELECT COUNT(*) FROM orders o WHERE o.courier_id = c.user_id AND o.status IN ?) AS load", activeStatuses).
+		Where("c.user_id IN ? AND c.is_online AND c.status = ? AND c.state = ?",
+			courierIds, constants.CourierStatusActive, constants.Active).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetDispatchCandidates: %w", err)
+	}
+	return candidates, nil
+}
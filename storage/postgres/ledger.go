@@ -0,0 +1,296 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepo struct {
+	db *gorm.DB
+}
+
+func NewLedger(db *gorm.DB) *ledgerRepo {
+	return &ledgerRepo{db: db}
+}
+
+// PostTransactions saves the transactions with their entries at once,
+// nothing is saved if one of them is not balanced
+func (l *ledgerRepo) PostTransactions(ctx context.Context, transactions []entities.LedgerTransaction) error {
+	return l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, transaction := range transactions {
+			if err := postTransaction(tx, transaction); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func postTransaction(tx *gorm.DB, transaction entities.LedgerTransaction) error {
+	if !transaction.Balanced() {
+		return e.ErrUnbalancedTransaction
+	}
+	if err := tx.Table("ledger_transactions").Create(&transaction).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+			return e.ErrEarningAlreadyPosted
+		}
+		return fmt.Errorf("failed to create ledger transaction: %w", err)
+	}
+	for i := range transaction.Entries {
+		transaction.Entries[i].TransactionID = transaction.ID
+		transaction.Entries[i].CreatedAt = transaction.CreatedAt
+	}
+	if err := tx.Table("ledger_entries").Create(&transaction.Entries).Error; err != nil {
+		return fmt.Errorf("failed to create ledger entries: %w", err)
+	}
+	return nil
+}
+
+func (l *ledgerRepo) GetCourierTransactions(ctx context.Context, courierId string, from, to time.Time) ([]entities.LedgerTransaction, error) {
+	var transactions []entities.LedgerTransaction
+	err := l.db.WithContext(ctx).Table("ledger_transactions").
+		Where("courier_id = ? AND created_at >= ? AND created_at < ?", courierId, from, to).
+		Order("created_at").
+		Find(&transactions).Error
+	if err != nil {
+		return []entities.LedgerTransaction{}, fmt.Errorf("error in GetCourierTransactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// GetCourierBalance returns what the platform owes the courier
+func (l *ledgerRepo) GetCourierBalance(ctx context.Context, courierId string) (int64, error) {
+	var balance int64
+	err := l.db.WithContext(ctx).Table("ledger_entries").
+		Where("account = ?", constants.LedgerAccountCourierPayablePrefix+courierId).
+		Select("COALESCE(-SUM(amount), 0)").
+		Scan(&balance).Error
+	if err != nil {
+		return 0, fmt.Errorf("error in GetCourierBalance: %w", err)
+	}
+	return balance, nil
+}
+
+// GetOrderIDsWithoutEarning returns orders delivered after the time by a courier that have no delivery earning posted
+func (l *ledgerRepo) GetOrderIDsWithoutEarning(ctx context.Context, after time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := l.db.WithContext(ctx).Table("orders o").
+		Where("o.status = ? AND o.courier_id IS NOT NULL AND o.delivered_at > ?", constants.OrderStatusDelivered, after).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_transactions t WHERE t.order_id = o.id AND t.kind = ?)", constants.LedgerKindDeliveryBase).
+		Order("o.delivered_at").
+		Limit(limit).
+		Pluck("o.id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetOrderIDsWithoutEarning: %w", err)
+	}
+	return ids, nil
+}
+
+// CreatePayoutBatch pays every courier the sum of their unpaid transactions made before
+// the period end. Couriers whose penalties exceed their earnings are left for the next batch.
+func (l *ledgerRepo) CreatePayoutBatch(ctx context.Context, batch entities.PayoutBatch) (entities.PayoutBatch, error) {
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// locks the unpaid transactions so that two batches never pay the same earning
+		var locked []string
+		err := tx.Table("ledger_transactions").
+			Where("payout_id IS NULL AND kind <> ? AND created_at < ?", constants.LedgerKindPayout, batch.PeriodEnd).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("id", &locked).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock unpaid transactions: %w", err)
+		}
+		if len(locked) == 0 {
+			return e.ErrNothingToPay
+		}
+
+		var payouts []entities.Payout
+		err = tx.Table("ledger_transactions t").
+			Joins("JOIN couriers c ON c.user_id = t.courier_id").
+			Where("t.id IN ?", locked).
+			Group("t.courier_id, c.payout_account").
+			Having("SUM(t.amount) > 0").
+			Select("t.courier_id, c.payout_account, SUM(t.amount) AS amount").
+			Find(&payouts).Error
+		if err != nil {
+			return fmt.Errorf("failed to sum unpaid earnings: %w", err)
+		}
+		if len(payouts) == 0 {
+			return e.ErrNothingToPay
+		}
+
+		batch.Total = 0
+		for _, payout := range payouts {
+			batch.Total += payout.Amount
+		}
+		if err := tx.Table("payout_batches").Create(&batch).Error; err != nil {
+			return fmt.Errorf("failed to create payout batch: %w", err)
+		}
+
+		for i := range payouts {
+			payout := &payouts[i]
+			payout.ID = uuid.NewString()
+			payout.BatchID = batch.ID
+			payout.CreatedAt = batch.CreatedAt
+			if err := tx.Table("payouts").Create(payout).Error; err != nil {
+				return fmt.Errorf("failed to create payout: %w", err)
+			}
+
+			err := tx.Table("ledger_transactions").
+				Where("id IN ? AND courier_id = ?", locked, payout.CourierID).
+				Update("payout_id", payout.ID).Error
+			if err != nil {
+				return fmt.Errorf("failed to mark earnings as paid: %w", err)
+			}
+
+			err = postTransaction(tx, entities.LedgerTransaction{
+				ID:        uuid.NewString(),
+				Kind:      constants.LedgerKindPayout,
+				CourierID: payout.CourierID,
+				PayoutID:  &payout.ID,
+				Amount:    -payout.Amount,
+				Note:      "payout batch " + batch.ID,
+				CreatedBy: batch.CreatedBy,
+				CreatedAt: batch.CreatedAt,
+				Entries: []entities.LedgerEntry{
+					{Account: constants.LedgerAccountCourierPayablePrefix + payout.CourierID, Amount: payout.Amount},
+					{Account: constants.LedgerAccountBank, Amount: -payout.Amount},
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		batch.Payouts = payouts
+		return nil
+	})
+	if err != nil {
+		return entities.PayoutBatch{}, err
+	}
+	return batch, nil
+}
+
+func (l *ledgerRepo) GetPayoutBatches(ctx context.Context, limit, offset int) ([]entities.PayoutBatch, error) {
+	var batches []entities.PayoutBatch
+	err := l.db.WithContext(ctx).Table("payout_batches").
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&batches).Error
+	if err != nil {
+		return []entities.PayoutBatch{}, fmt.Errorf("error in GetPayoutBatches: %w", err)
+	}
+	return batches, nil
+}
+
+func (l *ledgerRepo) GetPayoutBatch(ctx context.Context, id string) (entities.PayoutBatch, error) {
+	var batch entities.PayoutBatch
+	err := l.db.WithContext(ctx).Table("payout_batches").Where("id = ?", id).Take(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.PayoutBatch{}, e.ErrPayoutBatchNotFound
+		}
+		return entities.PayoutBatch{}, fmt.Errorf("error in GetPayoutBatch: %w", err)
+	}
+
+	err = l.db.WithContext(ctx).Table("payouts p").
+		Joins("JOIN users u ON u.id = p.courier_id").
+		Where("p.batch_id = ?", id).
+		Select("p.*, u.firstname, u.surname, u.phone_number").
+		Order("u.firstname, u.surname").
+		Find(&batch.Payouts).Error
+	if err != nil {
+		return entities.PayoutBatch{}, fmt.Errorf("error in GetPayoutBatch: %w", err)
+	}
+	return batch, nil
+}
+
+// RecordCashCollection adds the cash of a delivered order to what the courier holds,
+// an order is collected only once
+func (l *ledgerRepo) RecordCashCollection(ctx context.Context, collection entities.CashCollection) error {
+	return l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("cash_collections").Clauses(clause.OnConflict{DoNothing: true}).Create(&collection)
+		if res.Error != nil {
+			return fmt.Errorf("failed to create cash collection: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		err := tx.Table("couriers").
+			Where("user_id = ?", collection.CourierID).
+			Update("cash_balance", gorm.Expr("cash_balance + ?", collection.Amount)).Error
+		if err != nil {
+			return fmt.Errorf("failed to update courier cash: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetUncollectedCashOrderIDs returns cash orders delivered after the time by a courier whose cash is not collected
+func (l *ledgerRepo) GetUncollectedCashOrderIDs(ctx context.Context, after time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := l.db.WithContext(ctx).Table("orders o").
+		Where("o.status = ? AND o.payment_method = ? AND o.courier_id IS NOT NULL AND o.total > 0 AND o.delivered_at > ?",
+			constants.OrderStatusDelivered, constants.PaymentMethodCash, after).
+		Where("NOT EXISTS (SELECT 1 FROM cash_collections cc WHERE cc.order_id = o.id)").
+		Order("o.delivered_at").
+		Limit(limit).
+		Pluck("o.id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetUncollectedCashOrderIDs: %w", err)
+	}
+	return ids, nil
+}
+
+// CreateCashHandover takes the handed over cash from the courier's balance,
+// the courier can not hand over more than they hold
+func (l *ledgerRepo) CreateCashHandover(ctx context.Context, handover entities.CashHandover) error {
+	return l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("couriers").
+			Where("user_id = ? AND cash_balance >= ?", handover.CourierID, handover.Amount).
+			Update("cash_balance", gorm.Expr("cash_balance - ?", handover.Amount))
+		if res.Error != nil {
+			return fmt.Errorf("failed to update courier cash: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrHandoverExceedsCash
+		}
+		if err := tx.Table("cash_handovers").Create(&handover).Error; err != nil {
+			return fmt.Errorf("failed to create cash handover: %w", err)
+		}
+		return nil
+	})
+}
+
+func (l *ledgerRepo) GetCashCollections(ctx context.Context, courierId string, from, to time.Time) ([]entities.CashCollection, error) {
+	var collections []entities.CashCollection
+	err := l.db.WithContext(ctx).Table("cash_collections").
+		Where("courier_id = ? AND created_at >= ? AND created_at < ?", courierId, from, to).
+		Order("created_at").
+		Find(&collections).Error
+	if err != nil {
+		return []entities.CashCollection{}, fmt.Errorf("error in GetCashCollections: %w", err)
+	}
+	return collections, nil
+}
+
+func (l *ledgerRepo) GetCashHandovers(ctx context.Context, courierId string, from, to time.Time) ([]entities.CashHandover, error) {
+	var handovers []entities.CashHandover
+	err := l.db.WithContext(ctx).Table("cash_handovers").
+		Where("courier_id = ? AND created_at >= ? AND created_at < ?", courierId, from, to).
+		Order("created_at").
+		Find(&handovers).Error
+	if err != nil {
+		return []entities.CashHandover{}, fmt.Errorf("error in GetCashHandovers: %w", err)
+	}
+	return handovers, nil
+}
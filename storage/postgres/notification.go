@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationRepo struct {
+	db *gorm.DB
+}
+
+func NewNotification(db *gorm.DB) *notificationRepo {
+	return &notificationRepo{db: db}
+}
+
+func (n *notificationRepo) CreateNotification(ctx context.Context, req entities.Notification) error {
+	res := n.db.WithContext(ctx).Table("notifications").Create(&req)
+	if res.Error != nil {
+		return fmt.Errorf("error in CreateNotification: %w", res.Error)
+	}
+	return nil
+}
+
+func (n *notificationRepo) GetUserNotifications(ctx context.Context, userId string, limit, offset int) ([]entities.Notification, error) {
+	var notifications []entities.Notification
+	err := n.db.WithContext(ctx).Table("notifications").
+		Where("user_id = ?", userId).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&notifications).Error
+	if err != nil {
+		return []entities.Notification{}, fmt.Errorf("error in GetUserNotifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// GetFcmToken returns the device token of the user, it is empty when the user has none
+func (n *notificationRepo) GetFcmToken(ctx context.Context, userId string) (string, error) {
+	var token sql.NullString
+	err := n.db.WithContext(ctx).Table("users").
+		Select("fcm_token").
+		Where("id = ?", userId).
+		Scan(&token).Error
+	if err != nil {
+		return "", fmt.Errorf("error in GetFcmToken: %w", err)
+	}
+	return token.String, nil
+}
+
+// UpdateFcmToken saves the device token of the user and takes it away from the account
+// that used the device before, so that its notifications do not reach the new user
+func (n *notificationRepo) UpdateFcmToken(ctx context.Context, userId, token string) error {
+	err := n.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table("users").
+			Where("fcm_token = ? AND id <> ?", token, userId).
+			Update("fcm_token", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Table("users").Where("id = ?", userId).Update("fcm_token", token).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error in UpdateFcmToken: %w", err)
+	}
+	return nil
+}
+
+// ClearFcmToken forgets the token rejected by the push provider, unless the user
+// has registered another device meanwhile
+func (n *notificationRepo) ClearFcmToken(ctx context.Context, userId, token string) error {
+	err := n.db.WithContext(ctx).Table("users").
+		Where("id = ? AND fcm_token = ?", userId, token).
+		Update("fcm_token", nil).Error
+	if err != nil {
+		return fmt.Errorf("error in ClearFcmToken: %w", err)
+	}
+	return nil
+}
+
+// GetUserLanguage returns the language the user gets notifications in
+func (n *notificationRepo) GetUserLanguage(ctx context.Context, userId string) (string, error) {
+	var language sql.NullString
+	err := n.db.WithContext(ctx).Table("users").
+		Select("preferred_language").
+		Where("id = ?", userId).
+		Scan(&language).Error
+	if err != nil {
+		return "", fmt.Errorf("error in GetUserLanguage: %w", err)
+	}
+	return language.String, nil
+}
+
+// GetTemplates returns the templates edited by admins
+func (n *notificationRepo) GetTemplates(ctx context.Context) ([]entities.NotificationTemplate, error) {
+	var templates []entities.NotificationTemplate
+	err := n.db.WithContext(ctx).Table("notification_templates").Find(&templates).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetTemplates: %w", err)
+	}
+	return templates, nil
+}
+
+func (n *notificationRepo) GetTemplate(ctx context.Context, key, language string) (entities.NotificationTemplate, error) {
+	var template entities.NotificationTemplate
+	err := n.db.WithContext(ctx).Table("notification_templates").
+		Where("key = ? AND language = ?", key, language).
+		First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.NotificationTemplate{}, e.ErrTemplateNotFound
+		}
+		return entities.NotificationTemplate{}, fmt.Errorf("error in GetTemplate: %w", err)
+	}
+	return template, nil
+}
+
+// SaveTemplate saves the text edited by an admin instead of the previous one
+func (n *notificationRepo) SaveTemplate(ctx context.Context, req entities.NotificationTemplate) error {
+	err := n.db.WithContext(ctx).Table("notification_templates").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}, {Name: "language"}},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "body", "updated_by", "updated_at"}),
+		}).
+		Create(&req).Error
+	if err != nil {
+		return fmt.Errorf("error in SaveTemplate: %w", err)
+	}
+	return nil
+}
+
+// DeleteTemplate brings back the built-in text
+func (n *notificationRepo) DeleteTemplate(ctx context.Context, key, language string) error {
+	err := n.db.WithContext(ctx).Table("notification_templates").
+		Where("key = ? AND language = ?", key, language).
+		Delete(&entities.NotificationTemplate{}).Error
+	if err != nil {
+		return fmt.Errorf("error in DeleteTemplate: %w", err)
+	}
+	return nil
+}
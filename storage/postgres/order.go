@@ -0,0 +1,739 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+type orderRepo struct {
+	db *gorm.DB
+}
+
+func NewOrder(db *gorm.DB) *orderRepo {
+	return &orderRepo{db: db}
+}
+
+func (o *orderRepo) CreateSlot(ctx context.Context, req entities.DeliverySlot) error {
+	res := o.db.WithContext(ctx).Table("delivery_slots").Create(&req)
+	if res.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(res.Error, &pgErr) && pgErr.Code == constants.PGForeignKeyViolationCode {
+			return e.ErrXozmakNotFound
+		}
+		return fmt.Errorf("error in CreateSlot: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("error in CreateSlot: %w", constants.ErrRowsAffectedIsZero)
+	}
+	return nil
+}
+
+func (o *orderRepo) GetSlots(ctx context.Context, xozmakId string, from, to time.Time) ([]entities.DeliverySlot, error) {
+	var slots []entities.DeliverySlot
+	err := o.db.WithContext(ctx).Table("delivery_slots").
+		Where("xozmak_id = ? AND state = ? AND starts_at >= ? AND starts_at < ?", xozmakId, constants.Active, from, to).
+		Order("starts_at").
+		Find(&slots).Error
+	if err != nil {
+		return []entities.DeliverySlot{}, fmt.Errorf("error in GetSlots: %w", err)
+	}
+	return slots, nil
+}
+
+func (o *orderRepo) UpdateSlot(ctx context.Context, req entities.DeliverySlot) error {
+	res := o.db.WithContext(ctx).Table("delivery_slots").
+		Where("id = ? AND state = ?", req.ID, constants.Active).
+		Select("starts_at", "ends_at", "capacity", "updated_at").
+		Updates(req)
+	if res.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(res.Error, &pgErr) && pgErr.Code == constants.PGCheckViolationCode {
+			// capacity can not go below the already reserved orders
+			return e.ErrInvalidInput
+		}
+		return fmt.Errorf("failed to update delivery slot: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrSlotNotFound
+	}
+	return nil
+}
+
+func (o *orderRepo) DeleteSlot(ctx context.Context, id string) error {
+	res := o.db.WithContext(ctx).Table("delivery_slots").Where("id = ?", id).Update("state", constants.InActive)
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete delivery slot: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrSlotNotFound
+	}
+	return nil
+}
+
+func (o *orderRepo) GetSlot(ctx context.Context, id string) (entities.DeliverySlot, error) {
+	var slot entities.DeliverySlot
+	err := o.db.WithContext(ctx).Table("delivery_slots").Where("id = ? AND state = ?", id, constants.Active).First(&slot).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.DeliverySlot{}, e.ErrSlotNotFound
+		}
+		return entities.DeliverySlot{}, fmt.Errorf("error in GetSlot: %w", err)
+	}
+	return slot, nil
+}
+
+func (o *orderRepo) GetXozmakByID(ctx context.Context, id string) (entities.Xozmak, error) {
+	var xozmak entities.Xozmak
+	err := o.db.WithContext(ctx).Table("xozmaks").Where("id = ? AND state = ?", id, constants.Active).First(&xozmak).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Xozmak{}, e.ErrXozmakNotFound
+		}
+		return entities.Xozmak{}, fmt.Errorf("error in GetXozmakByID: %w", err)
+	}
+	return xozmak, nil
+}
+
+func (o *orderRepo) GetUserLocationByID(ctx context.Context, userId, id string) (entities.UserLocation, error) {
+	var location entities.UserLocation
+	err := o.db.WithContext(ctx).Table("users_locations").Where("id = ? AND user_id = ?", id, userId).First(&location).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.UserLocation{}, e.ErrLocationNotFound
+		}
+		return entities.UserLocation{}, fmt.Errorf("error in GetUserLocationByID: %w", err)
+	}
+	return location, nil
+}
+
+func (o *orderRepo) GetProductsByIDs(ctx context.Context, ids []string) ([]entities.Product, error) {
+	var products []entities.Product
+	err := o.db.WithContext(ctx).Table("products").Where("id IN ? AND state = ?", ids, constants.Active).Find(&products).Error
+	if err != nil {
+		return []entities.Product{}, fmt.Errorf("error in GetProductsByIDs: %w", err)
+	}
+	return products, nil
+}
+
+// CreateOrder reserves a place in the slot and the stock of every item, then saves the order.
+// The slot must start after slotStartsAfter, otherwise it is considered closed.
+func (o *orderRepo) CreateOrder(ctx context.Context, order entities.Order, slotStartsAfter time.Time) (entities.Order, error) {
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if order.SlotID != nil {
+			// single conditional update keeps the capacity check atomic
+			res := tx.Table("delivery_slots").
+				Where("id = ? AND xozmak_id = ? AND state = ? AND reserved < capacity AND starts_at > ?",
+					*order.SlotID, order.XozmakID, constants.Active, slotStartsAfter).
+				Update("reserved", gorm.Expr("reserved + 1"))
+			if res.Error != nil {
+				return fmt.Errorf("failed to reserve delivery slot: %w", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return e.ErrSlotUnavailable
+			}
+		}
+
+		for _, item := range order.Items {
+			res := tx.Table("products").
+				Where("id = ? AND stock >= ?", item.ProductID, item.Quantity).
+				Update("stock", gorm.Expr("stock - ?", item.Quantity))
+			if res.Error != nil {
+				return fmt.Errorf("failed to reserve product stock: %w", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return e.ErrProductOutOfStock
+			}
+		}
+
+		if err := tx.Table("orders").Create(&order).Error; err != nil {
+			return fmt.Errorf("failed to create order: %w", err)
+		}
+		if err := tx.Table("order_items").Create(&order.Items).Error; err != nil {
+			return fmt.Errorf("failed to create order items: %w", err)
+		}
+		history := entities.OrderHistory{
+			OrderID:   order.ID,
+			Action:    constants.OrderActionPlaced,
+			ToStatus:  order.Status,
+			ActorRole: constants.UserRole,
+			ActorID:   &order.UserID,
+		}
+		if err := tx.Table("order_history").Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return tx.Raw("SELECT number FROM orders WHERE id = ?", order.ID).Scan(&order.Number).Error
+	})
+	if err != nil {
+		return entities.Order{}, err
+	}
+	return order, nil
+}
+
+func (o *orderRepo) GetUserOrders(ctx context.Context, userId string, limit, offset int) ([]entities.Order, int64, error) {
+	var (
+		orders []entities.Order
+		count  int64
+	)
+	query := o.db.WithContext(ctx).Table("orders").Where("user_id = ?", userId)
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetUserOrders: %w", err)
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetUserOrders: %w", err)
+	}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return []entities.Order{}, 0, err
+	}
+	return orders, count, nil
+}
+
+func (o *orderRepo) GetOrder(ctx context.Context, id string) (entities.Order, error) {
+	var order entities.Order
+	err := o.db.WithContext(ctx).Table("orders").Where("id = ?", id).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Order{}, e.ErrOrderNotFound
+		}
+		return entities.Order{}, fmt.Errorf("error in GetOrder: %w", err)
+	}
+	orders := []entities.Order{order}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return entities.Order{}, err
+	}
+	return orders[0], nil
+}
+
+// attachItems loads items of all given orders with a single query
+func (o *orderRepo) attachItems(ctx context.Context, orders []entities.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+
+	var items []entities.OrderItem
+	err := o.db.WithContext(ctx).Table("order_items").Where("order_id IN ?", ids).Order("name").Find(&items).Error
+	if err != nil {
+		return fmt.Errorf("error in attachItems: %w", err)
+	}
+
+	byOrder := make(map[string][]entities.OrderItem, len(orders))
+	for _, item := range items {
+		byOrder[item.OrderID] = append(byOrder[item.OrderID], item)
+	}
+	for i := range orders {
+		orders[i].Items = byOrder[orders[i].ID]
+	}
+	return nil
+}
+
+func (o *orderRepo) GetUserXozmakID(ctx context.Context, userId string) (string, error) {
+	var user struct {
+		XozmakID *string `gorm:"column:xozmak_id"`
+	}
+	err := o.db.WithContext(ctx).Table("users").Select("xozmak_id").Where("id = ?", userId).Take(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("error in GetUserXozmakID: %w", err)
+	}
+	if user.XozmakID == nil {
+		return "", e.ErrXozmakNotFound
+	}
+	return *user.XozmakID, nil
+}
+
+// CancelOrder moves the order to a final status, gives its reservations back and records the decision.
+// It fails with ErrOrderStatusChanged if the order status changed since it was read.
+func (o *orderRepo) CancelOrder(ctx context.Context, req entities.OrderCancellation) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("orders").
+			Where("id = ? AND status = ?", req.Order.ID, req.Order.Status).
+			Updates(map[string]interface{}{
+				"status":     req.ToStatus,
+				"cancel_fee": req.History.Fee,
+				"updated_at": time.Now(),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to cancel order: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderStatusChanged
+		}
+
+		if err := releaseReservations(tx, req.Order); err != nil {
+			return err
+		}
+		if err := tx.Table("order_history").Create(&req.History).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		if req.Refund != nil {
+			if err := tx.Table("refunds").Create(req.Refund).Error; err != nil {
+				return fmt.Errorf("failed to create refund: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// releaseReservations returns the reserved stock and slot place of the order
+func releaseReservations(tx *gorm.DB, order entities.Order) error {
+	for _, item := range order.Items {
+		err := tx.Table("products").
+			Where("id = ?", item.ProductID).
+			Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error
+		if err != nil {
+			return fmt.Errorf("failed to release product stock: %w", err)
+		}
+	}
+	if order.SlotID != nil {
+		err := tx.Table("delivery_slots").
+			Where("id = ? AND reserved > 0", *order.SlotID).
+			Update("reserved", gorm.Expr("reserved - 1")).Error
+		if err != nil {
+			return fmt.Errorf("failed to release delivery slot: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *orderRepo) InsertOrderHistory(ctx context.Context, req entities.OrderHistory) error {
+	res := o.db.WithContext(ctx).Table("order_history").Create(&req)
+	if res.Error != nil {
+		return fmt.Errorf("error in InsertOrderHistory: %w", res.Error)
+	}
+	return nil
+}
+
+func (o *orderRepo) GetOrderHistory(ctx context.Context, orderId string) ([]entities.OrderHistory, error) {
+	var history []entities.OrderHistory
+	err := o.db.WithContext(ctx).Table("order_history").Where("order_id = ?", orderId).Order("created_at").Find(&history).Error
+	if err != nil {
+		return []entities.OrderHistory{}, fmt.Errorf("error in GetOrderHistory: %w", err)
+	}
+	return history, nil
+}
+
+func (o *orderRepo) GetXozmakOrders(ctx context.Context, xozmakId string, statuses []string, limit, offset int) ([]entities.Order, int64, error) {
+	var (
+		orders []entities.Order
+		count  int64
+	)
+	query := o.db.WithContext(ctx).Table("orders").Where("xozmak_id = ? AND status IN ?", xozmakId, statuses)
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetXozmakOrders: %w", err)
+	}
+	err := query.Order("created_at").Limit(limit).Offset(offset).Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetXozmakOrders: %w", err)
+	}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return []entities.Order{}, 0, err
+	}
+	return orders, count, nil
+}
+
+// GetOrdersWithAction returns orders in the status whose history has the action, the oldest first
+func (o *orderRepo) GetOrdersWithAction(ctx context.Context, status, action string, limit, offset int) ([]entities.Order, int64, error) {
+	var (
+		orders []entities.Order
+		count  int64
+	)
+	query := o.db.WithContext(ctx).Table("orders o").
+		Where("o.status = ?", status).
+		Where("EXISTS (SELECT 1 FROM order_history h WHERE h.order_id = o.id AND h.action = ?)", action)
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetOrdersWithAction: %w", err)
+	}
+	err := query.Select("o.*").Order("o.created_at").Limit(limit).Offset(offset).Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, 0, fmt.Errorf("error in GetOrdersWithAction: %w", err)
+	}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return []entities.Order{}, 0, err
+	}
+	return orders, count, nil
+}
+
+// UpdateOrderStatus changes the status only if it is still FromStatus and records the change
+func (o *orderRepo) UpdateOrderStatus(ctx context.Context, req entities.OrderStatusChange) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fields := map[string]interface{}{
+			"status":     req.ToStatus,
+			"updated_at": time.Now(),
+		}
+		for key, value := range req.Fields {
+			fields[key] = value
+		}
+
+		res := tx.Table("orders").Where("id = ? AND status = ?", req.OrderID, req.FromStatus).Updates(fields)
+		if res.Error != nil {
+			return fmt.Errorf("failed to update order status: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderStatusChanged
+		}
+
+		if err := tx.Table("order_history").Create(&req.History).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		if req.Proof != nil {
+			if err := tx.Table("delivery_proofs").Create(req.Proof).Error; err != nil {
+				return fmt.Errorf("failed to create delivery proof: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (o *orderRepo) GetDeliveryProof(ctx context.Context, orderId string) (entities.DeliveryProof, error) {
+	var proof entities.DeliveryProof
+	err := o.db.WithContext(ctx).Table("delivery_proofs").Where("order_id = ?", orderId).Take(&proof).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.DeliveryProof{}, e.ErrProofNotFound
+		}
+		return entities.DeliveryProof{}, fmt.Errorf("error in GetDeliveryProof: %w", err)
+	}
+	return proof, nil
+}
+
+// GetExpiredNewOrders returns new orders placed before createdBefore, a card order counts from its payment
+func (o *orderRepo) GetExpiredNewOrders(ctx context.Context, createdBefore time.Time, limit int) ([]entities.Order, error) {
+	var orders []entities.Order
+	err := o.db.WithContext(ctx).Table("orders").
+		Where("status = ? AND COALESCE(paid_at, created_at) < ?", constants.OrderStatusNew, createdBefore).
+		Order("created_at").
+		Limit(limit).
+		Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, fmt.Errorf("error in GetExpiredNewOrders: %w", err)
+	}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return []entities.Order{}, err
+	}
+	return orders, nil
+}
+
+// GetUnpaidOrders returns orders placed before createdBefore that are still waiting for the payment
+func (o *orderRepo) GetUnpaidOrders(ctx context.Context, createdBefore time.Time, limit int) ([]entities.Order, error) {
+	var orders []entities.Order
+	err := o.db.WithContext(ctx).Table("orders").
+		Where("status = ? AND created_at < ?", constants.OrderStatusAwaitingPayment, createdBefore).
+		Order("created_at").
+		Limit(limit).
+		Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, fmt.Errorf("error in GetUnpaidOrders: %w", err)
+	}
+	if err := o.attachItems(ctx, orders); err != nil {
+		return []entities.Order{}, err
+	}
+	return orders, nil
+}
+
+func (o *orderRepo) GetOrderTrack(ctx context.Context, orderId string) ([]entities.OrderTrackPoint, error) {
+	var points []entities.OrderTrackPoint
+	err := o.db.WithContext(ctx).Table("order_tracks").
+		Where("order_id = ?", orderId).
+		Order("recorded_at").
+		Find(&points).Error
+	if err != nil {
+		return []entities.OrderTrackPoint{}, fmt.Errorf("error in GetOrderTrack: %w", err)
+	}
+	return points, nil
+}
+
+// AssignCourier sets the courier only if the order is still in one of Statuses and
+// its courier has not been changed by somebody else
+func (o *orderRepo) AssignCourier(ctx context.Context, req entities.CourierAssignment) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("orders").Where("id = ? AND status IN ?", req.OrderID, req.Statuses)
+		if req.FromCourierID == nil {
+			query = query.Where("courier_id IS NULL")
+		} else {
+			query = query.Where("courier_id = ?", *req.FromCourierID)
+		}
+
+		// an order handed over to another courier leaves its batch
+		res := query.Updates(map[string]interface{}{
+			"courier_id": req.CourierID,
+			"batch_id":   nil,
+			"batch_seq":  nil,
+			"updated_at": time.Now(),
+		})
+		if res.Error != nil {
+			return fmt.Errorf("failed to assign courier: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderStatusChanged
+		}
+
+		if err := tx.Table("order_history").Create(&req.History).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return nil
+	})
+}
+
+// AssignBatch gives the orders to the courier as one batch. Orders that were cancelled or
+// taken by somebody else in the meantime are left out, their ids are not returned.
+func (o *orderRepo) AssignBatch(ctx context.Context, req entities.BatchAssignment) ([]string, error) {
+	var assigned []string
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		batch := map[string]interface{}{"id": req.BatchID, "courier_id": req.CourierID, "created_at": now}
+		if err := tx.Table("order_batches").Create(batch).Error; err != nil {
+			return fmt.Errorf("failed to create order batch: %w", err)
+		}
+
+		for _, assignment := range req.Orders {
+			res := tx.Table("orders").
+				Where("id = ? AND status IN ? AND courier_id IS NULL", assignment.OrderID, assignment.Statuses).
+				Updates(map[string]interface{}{
+					"courier_id": req.CourierID,
+					"batch_id":   req.BatchID,
+					"batch_seq":  len(assigned) + 1,
+					"updated_at": now,
+				})
+			if res.Error != nil {
+				return fmt.Errorf("failed to assign courier: %w", res.Error)
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			if err := tx.Table("order_history").Create(&assignment.History).Error; err != nil {
+				return fmt.Errorf("failed to create order history: %w", err)
+			}
+			assigned = append(assigned, assignment.OrderID)
+		}
+		if len(assigned) == 0 {
+			return e.ErrOrderStatusChanged
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+// GetWaitingOrders returns orders in one of statuses that have no courier yet, the oldest first
+func (o *orderRepo) GetWaitingOrders(ctx context.Context, statuses []string, limit int) ([]entities.WaitingOrder, error) {
+	var orders []entities.WaitingOrder
+	err := o.db.WithContext(ctx).Table("orders o").
+		Joins("JOIN xozmaks x ON x.id = o.xozmak_id").
+		Select("o.*, x.location AS pickup").
+		Where("o.status IN ? AND o.courier_id IS NULL", statuses).
+		Order("o.created_at").
+		Limit(limit).
+		Find(&orders).Error
+	if err != nil {
+		return []entities.WaitingOrder{}, fmt.Errorf("error in GetWaitingOrders: %w", err)
+	}
+	return orders, nil
+}
+
+// GetBatchOrders returns the orders of the batch in delivery order
+func (o *orderRepo) GetBatchOrders(ctx context.Context, batchId string) ([]entities.Order, error) {
+	var orders []entities.Order
+	err := o.db.WithContext(ctx).Table("orders").
+		Where("batch_id = ?", batchId).
+		Order("batch_seq").
+		Find(&orders).Error
+	if err != nil {
+		return []entities.Order{}, fmt.Errorf("error in GetBatchOrders: %w", err)
+	}
+	return orders, nil
+}
+
+func (o *orderRepo) CreateZone(ctx context.Context, req entities.DeliveryZone) error {
+	res := o.db.WithContext(ctx).Table("delivery_zones").Create(&req)
+	if res.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(res.Error, &pgErr) && pgErr.Code == constants.PGForeignKeyViolationCode {
+			return e.ErrXozmakNotFound
+		}
+		return fmt.Errorf("error in CreateZone: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("error in CreateZone: %w", constants.ErrRowsAffectedIsZero)
+	}
+	return nil
+}
+
+// GetZones returns active zones of active xozmaks, all of them when xozmakId is empty
+func (o *orderRepo) GetZones(ctx context.Context, xozmakId string) ([]entities.DeliveryZone, error) {
+	var zones []entities.DeliveryZone
+	query := o.db.WithContext(ctx).Table("delivery_zones z").
+		Joins("JOIN xozmaks x ON x.id = z.xozmak_id AND x.state = ?", constants.Active).
+		Where("z.state = ?", constants.Active)
+	if xozmakId != "" {
+		query = query.Where("z.xozmak_id = ?", xozmakId)
+	}
+	err := query.Select("z.*").Order("z.created_at").Find(&zones).Error
+	if err != nil {
+		return []entities.DeliveryZone{}, fmt.Errorf("error in GetZones: %w", err)
+	}
+	return zones, nil
+}
+
+// GetZonesAround returns active zones of active xozmaks whose bounding box has the point,
+// the polygons themselves are checked by the caller
+func (o *orderRepo) GetZonesAround(ctx context.Context, lat, long float64) ([]entities.DeliveryZone, error) {
+	var zones []entities.DeliveryZone
+	err := o.db.WithContext(ctx).Table("delivery_zones z").
+		Joins("JOIN xozmaks x ON x.id = z.xozmak_id AND x.state = ?", constants.Active).
+		Where("z.state = ?", constants.Active).
+		Where("z.min_lat <= ? AND z.max_lat >= ? AND z.min_long <= ? AND z.max_long >= ?", lat, lat, long, long).
+		Select("z.*").Order("z.created_at").Find(&zones).Error
+	if err != nil {
+		return []entities.DeliveryZone{}, fmt.Errorf("error in GetZonesAround: %w", err)
+	}
+	return zones, nil
+}
+
+// HasZones tells whether any active xozmak has an active delivery zone
+func (o *orderRepo) HasZones(ctx context.Context) (bool, error) {
+	var exists bool
+	err := o.db.WithContext(ctx).Raw(`SELECT EXISTS (
+		SELECT 1 FROM delivery_zones z JOIN xozmaks x ON x.id = z.xozmak_id AND x.state = ?
+		WHERE z.state = ?)`, constants.Active, constants.Active).Scan(&exists).Error
+	if err != nil {
+		return false, fmt.Errorf("error in HasZones: %w", err)
+	}
+	return exists, nil
+}
+
+func (o *orderRepo) UpdateZone(ctx context.Context, req entities.DeliveryZone) error {
+	res := o.db.WithContext(ctx).Table("delivery_zones").
+		Where("id = ? AND state = ?", req.ID, constants.Active).
+		Select("name", "area", "delivery_fee", "min_order", "min_lat", "max_lat", "min_long", "max_long", "updated_at").
+		Updates(req)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update delivery zone: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrZoneNotFound
+	}
+	return nil
+}
+
+func (o *orderRepo) DeleteZone(ctx context.Context, id string) error {
+	res := o.db.WithContext(ctx).Table("delivery_zones").
+		Where("id = ? AND state = ?", id, constants.Active).
+		Update("state", constants.InActive)
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete delivery zone: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrZoneNotFound
+	}
+	return nil
+}
+
+// GetWorkingHours returns the weekly hours of the xozmaks
+func (o *orderRepo) GetWorkingHours(ctx context.Context, xozmakIds []string) ([]entities.WorkingHours, error) {
+	var hours []entities.WorkingHours
+	err := o.db.WithContext(ctx).Table("working_hours").
+		Where("xozmak_id IN ?", xozmakIds).
+		Order("weekday, opens_at").
+		Find(&hours).Error
+	if err != nil {
+		return []entities.WorkingHours{}, fmt.Errorf("error in GetWorkingHours: %w", err)
+	}
+	return hours, nil
+}
+
+// ReplaceWorkingHours sets the new weekly hours of the xozmak instead of the old ones
+func (o *orderRepo) ReplaceWorkingHours(ctx context.Context, xozmakId string, hours []entities.WorkingHours) error {
+	return o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("working_hours").Where("xozmak_id = ?", xozmakId).Delete(nil).Error; err != nil {
+			return fmt.Errorf("failed to delete working hours: %w", err)
+		}
+		if len(hours) == 0 {
+			return nil
+		}
+		if err := tx.Table("working_hours").Create(&hours).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == constants.PGForeignKeyViolationCode {
+				return e.ErrXozmakNotFound
+			}
+			return fmt.Errorf("failed to create working hours: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetHoursExceptions returns exceptions of the xozmaks and holidays of all xozmaks
+// dated from from up to to, dates are YYYY-MM-DD
+func (o *orderRepo) GetHoursExceptions(ctx context.Context, xozmakIds []string, from, to string) ([]entities.HoursException, error) {
+	var exceptions []entities.HoursException
+	err := o.db.WithContext(ctx).Table("hours_exceptions").
+		Select("id, xozmak_id, to_char(date, 'YYYY-MM-DD') AS date, closed, opens_at, closes_at, note, created_at").
+		Where("(xozmak_id IN ? OR xozmak_id IS NULL) AND date >= ? AND date < ?", xozmakIds, from, to).
+		Order("date").
+		Find(&exceptions).Error
+	if err != nil {
+		return []entities.HoursException{}, fmt.Errorf("error in GetHoursExceptions: %w", err)
+	}
+	return exceptions, nil
+}
+
+func (o *orderRepo) CreateHoursException(ctx context.Context, req entities.HoursException) error {
+	err := o.db.WithContext(ctx).Table("hours_exceptions").Create(&req).Error
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case constants.PGUniqueKeyViolationCode:
+				return e.ErrHoursExceptionExists
+			case constants.PGForeignKeyViolationCode:
+				return e.ErrXozmakNotFound
+			}
+		}
+		return fmt.Errorf("error in CreateHoursException: %w", err)
+	}
+	return nil
+}
+
+func (o *orderRepo) DeleteHoursException(ctx context.Context, id string) error {
+	res := o.db.WithContext(ctx).Table("hours_exceptions").Where("id = ?", id).Delete(nil)
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete hours exception: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrHoursExceptionNotFound
+	}
+	return nil
+}
+
+// UpdateXozmakOrdering changes the given ordering switches of the xozmak
+func (o *orderRepo) UpdateXozmakOrdering(ctx context.Context, req entities.XozmakOrderingReq) error {
+	fields := map[string]interface{}{"updated_at": time.Now()}
+	if req.OrdersPaused != nil {
+		fields["orders_paused"] = *req.OrdersPaused
+	}
+	if req.AllowPreorders != nil {
+		fields["allow_preorders"] = *req.AllowPreorders
+	}
+	res := o.db.WithContext(ctx).Table("xozmaks").
+		Where("id = ? AND state = ?", req.XozmakID, constants.Active).
+		Updates(fields)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update xozmak ordering: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrXozmakNotFound
+	}
+	return nil
+}
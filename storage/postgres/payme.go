@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+func (p *paymentRepo) GetPaymeTransaction(ctx context.Context, id string) (entities.PaymeTransaction, error) {
+	var transaction entities.PaymeTransaction
+	err := p.db.WithContext(ctx).Table("payme_transactions").Where("id = ?", id).Take(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.PaymeTransaction{}, e.ErrPaymeTransactionNotFound
+		}
+		return entities.PaymeTransaction{}, fmt.Errorf("error in GetPaymeTransaction: %w", err)
+	}
+	return transaction, nil
+}
+
+// GetActivePaymeTransaction returns the created, not yet performed transaction of the order
+func (p *paymentRepo) GetActivePaymeTransaction(ctx context.Context, orderId string) (entities.PaymeTransaction, error) {
+	var transaction entities.PaymeTransaction
+	err := p.db.WithContext(ctx).Table("payme_transactions").
+		Where("order_id = ? AND state = ?", orderId, constants.PaymeStateCreated).
+		Take(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.PaymeTransaction{}, e.ErrPaymeTransactionNotFound
+		}
+		return entities.PaymeTransaction{}, fmt.Errorf("error in GetActivePaymeTransaction: %w", err)
+	}
+	return transaction, nil
+}
+
+// GetPaymeTransactions returns the transactions created in [from, to] in Unix milliseconds
+func (p *paymentRepo) GetPaymeTransactions(ctx context.Context, from, to int64) ([]entities.PaymeTransaction, error) {
+	var transactions []entities.PaymeTransaction
+	err := p.db.WithContext(ctx).Table("payme_transactions").
+		Where("create_time BETWEEN ? AND ?", from, to).
+		Order("create_time").
+		Find(&transactions).Error
+	if err != nil {
+		return []entities.PaymeTransaction{}, fmt.Errorf("error in GetPaymeTransactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// CreatePaymeTransaction binds the transaction to the pending payment, the payment is
+// created when the customer did not ask for an invoice in the app before paying
+func (p *paymentRepo) CreatePaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, payment entities.Payment) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("payments").
+			Where("id = ? AND status = ? AND provider_payment_id IS NULL", payment.ID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"provider_payment_id": transaction.ID,
+				"updated_at":          time.Now(),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to bind payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			payment.ProviderPaymentID = &transaction.ID
+			if err := tx.Table("payments").Create(&payment).Error; err != nil {
+				return fmt.Errorf("failed to create payment: %w", err)
+			}
+		}
+
+		if err := tx.Table("payme_transactions").Create(&transaction).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+				return e.ErrPaymeTransactionChanged
+			}
+			return fmt.Errorf("failed to create payme transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// PerformPaymeTransaction marks the transaction and its payment as paid and passes the
+// order on to the seller. Nothing is changed when the order is not waiting for the payment.
+func (p *paymentRepo) PerformPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, history entities.OrderHistory) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("payme_transactions").
+			Where("id = ? AND state = ?", transaction.ID, constants.PaymeStateCreated).
+			Updates(map[string]interface{}{
+				"state":        constants.PaymeStatePerformed,
+				"perform_time": transaction.PerformTime,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to perform payme transaction: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymeTransactionChanged
+		}
+
+		now := time.Now()
+		res = tx.Table("payments").
+			Where("id = ? AND status = ?", transaction.PaymentID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status":     constants.PaymentStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to confirm payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymentStatusChanged
+		}
+
+		res = tx.Table("orders").
+			Where("id = ? AND status = ? AND paid_at IS NULL", transaction.OrderID, constants.OrderStatusAwaitingPayment).
+			Updates(map[string]interface{}{
+				"status":     constants.OrderStatusNew,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to mark order as paid: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderNotAwaitingPayment
+		}
+
+		if err := tx.Table("order_history").Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return nil
+	})
+}
+
+// CancelPaymeTransaction moves the transaction to a cancelled state. The payment of a created
+// transaction is cancelled; a performed one has been returned to the customer by Payme, so
+// the refunds of its order are completed and the payment is refunded.
+func (p *paymentRepo) CancelPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, fromState int) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Table("payme_transactions").
+			Where("id = ? AND state = ?", transaction.ID, fromState).
+			Updates(map[string]interface{}{
+				"state":       transaction.State,
+				"reason":      transaction.Reason,
+				"cancel_time": transaction.CancelTime,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to cancel payme transaction: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymeTransactionChanged
+		}
+
+		now := time.Now()
+		if fromState == constants.PaymeStateCreated {
+			err := tx.Table("payments").
+				Where("id = ? AND status = ?", transaction.PaymentID, constants.PaymentStatusPending).
+				Updates(map[string]interface{}{
+					"status":     constants.PaymentStatusCancelled,
+					"error":      fmt.Sprintf("cancelled by payme, reason %d", *transaction.Reason),
+					"updated_at": now,
+				}).Error
+			if err != nil {
+				return fmt.Errorf("failed to cancel payment: %w", err)
+			}
+			return nil
+		}
+
+		err := tx.Table("refunds").
+			Where("order_id = ? AND status IN ?", transaction.OrderID,
+				[]string{constants.RefundStatusPending, constants.RefundStatusManual}).
+			Updates(map[string]interface{}{
+				"status":     constants.RefundStatusCompleted,
+				"payment_id": transaction.PaymentID,
+				"error":      "",
+				"updated_at": now,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to complete refunds: %w", err)
+		}
+		err = tx.Table("payments").
+			Where("id = ?", transaction.PaymentID).
+			Updates(map[string]interface{}{
+				"status":          constants.PaymentStatusRefunded,
+				"refunded_amount": gorm.Expr("amount"),
+				"updated_at":      now,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+		return nil
+	})
+}
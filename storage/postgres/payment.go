@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type paymentRepo struct {
+	db *gorm.DB
+}
+
+func NewPayment(db *gorm.DB) *paymentRepo {
+	return &paymentRepo{db: db}
+}
+
+func (p *paymentRepo) CreatePayment(ctx context.Context, payment entities.Payment) error {
+	err := p.db.WithContext(ctx).Table("payments").Create(&payment).Error
+	if err != nil {
+		return fmt.Errorf("error in CreatePayment: %w", err)
+	}
+	return nil
+}
+
+func (p *paymentRepo) GetPayment(ctx context.Context, id string) (entities.Payment, error) {
+	var payment entities.Payment
+	err := p.db.WithContext(ctx).Table("payments").Where("id = ?", id).Take(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Payment{}, e.ErrPaymentNotFound
+		}
+		return entities.Payment{}, fmt.Errorf("error in GetPayment: %w", err)
+	}
+	return payment, nil
+}
+
+func (p *paymentRepo) GetOrderPayments(ctx context.Context, orderId string) ([]entities.Payment, error) {
+	var payments []entities.Payment
+	err := p.db.WithContext(ctx).Table("payments").
+		Where("order_id = ?", orderId).
+		Order("created_at").
+		Find(&payments).Error
+	if err != nil {
+		return []entities.Payment{}, fmt.Errorf("error in GetOrderPayments: %w", err)
+	}
+	return payments, nil
+}
+
+// GetPaidPayment returns the payment the order was paid with
+func (p *paymentRepo) GetPaidPayment(ctx context.Context, orderId string) (entities.Payment, error) {
+	var payment entities.Payment
+	err := p.db.WithContext(ctx).Table("payments").
+		Where("order_id = ? AND purpose = ? AND status IN ?", orderId, constants.PaymentPurposeOrder,
+			[]string{constants.PaymentStatusPaid, constants.PaymentStatusRefunded}).
+		Order("paid_at").
+		Take(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Payment{}, e.ErrPaymentNotFound
+		}
+		return entities.Payment{}, fmt.Errorf("error in GetPaidPayment: %w", err)
+	}
+	return payment, nil
+}
+
+// GetTipPayment returns the paid tip given after the order was delivered
+func (p *paymentRepo) GetTipPayment(ctx context.Context, orderId string) (entities.Payment, error) {
+	var payment entities.Payment
+	err := p.db.WithContext(ctx).Table("payments").
+		Where("order_id = ? AND purpose = ? AND status = ?", orderId, constants.PaymentPurposeTip, constants.PaymentStatusPaid).
+		Order("paid_at").
+		Take(&payment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Payment{}, e.ErrPaymentNotFound
+		}
+		return entities.Payment{}, fmt.Errorf("error in GetTipPayment: %w", err)
+	}
+	return payment, nil
+}
+
+func (p *paymentRepo) GetPendingPayments(ctx context.Context, limit int) ([]entities.Payment, error) {
+	var payments []entities.Payment
+	err := p.db.WithContext(ctx).Table("payments").
+		Where("status = ?", constants.PaymentStatusPending).
+		Order("created_at").
+		Limit(limit).
+		Find(&payments).Error
+	if err != nil {
+		return []entities.Payment{}, fmt.Errorf("error in GetPendingPayments: %w", err)
+	}
+	return payments, nil
+}
+
+// UpdatePaymentStatus moves the payment from one status to another, fields are updated together with it
+func (p *paymentRepo) UpdatePaymentStatus(ctx context.Context, id, fromStatus, toStatus string, fields map[string]interface{}) error {
+	updates := map[string]interface{}{
+		"status":     toStatus,
+		"updated_at": time.Now(),
+	}
+	for key, value := range fields {
+		updates[key] = value
+	}
+	res := p.db.WithContext(ctx).Table("payments").Where("id = ? AND status = ?", id, fromStatus).Updates(updates)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update payment status: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrPaymentStatusChanged
+	}
+	return nil
+}
+
+// ConfirmPayment marks the payment as paid and passes the order on to the seller. When the
+// order is not waiting for the payment anymore, e.g. it was cancelled meanwhile, the money is
+// refunded instead and true is returned.
+func (p *paymentRepo) ConfirmPayment(ctx context.Context, payment entities.Payment, history entities.OrderHistory) (bool, error) {
+	refunded := false
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("payments").
+			Where("id = ? AND status = ?", payment.ID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status":     constants.PaymentStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to confirm payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymentStatusChanged
+		}
+
+		res = tx.Table("orders").
+			Where("id = ? AND status = ? AND paid_at IS NULL", payment.OrderID, constants.OrderStatusAwaitingPayment).
+			Updates(map[string]interface{}{
+				"status":     constants.OrderStatusNew,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to mark order as paid: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			refunded = true
+			refund := entities.Refund{
+				ID:            uuid.NewString(),
+				OrderID:       payment.OrderID,
+				Amount:        payment.Amount,
+				PaymentMethod: constants.PaymentMethodCard,
+				Status:        constants.RefundStatusPending,
+				PaymentID:     &payment.ID,
+			}
+			if err := tx.Table("refunds").Create(&refund).Error; err != nil {
+				return fmt.Errorf("failed to create refund: %w", err)
+			}
+			return nil
+		}
+
+		if err := tx.Table("order_history").Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return nil
+	})
+	return refunded, err
+}
+
+// ConfirmTipPayment marks the tip payment as paid and gives the tip to the courier. When the
+// courier was tipped meanwhile, e.g. by another invoice, the money is refunded instead and
+// true is returned.
+func (p *paymentRepo) ConfirmTipPayment(ctx context.Context, payment entities.Payment, tip entities.LedgerTransaction) (bool, error) {
+	refunded := false
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("payments").
+			Where("id = ? AND status = ?", payment.ID, constants.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status":     constants.PaymentStatusPaid,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to confirm payment: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrPaymentStatusChanged
+		}
+
+		// the savepoint keeps the transaction usable when the tip is already posted
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return postTransaction(tx, tip)
+		})
+		if !errors.Is(err, e.ErrEarningAlreadyPosted) {
+			return err
+		}
+		refunded = true
+		refund := entities.Refund{
+			ID:            uuid.NewString(),
+			OrderID:       payment.OrderID,
+			Amount:        payment.Amount,
+			PaymentMethod: constants.PaymentMethodCard,
+			Status:        constants.RefundStatusPending,
+			PaymentID:     &payment.ID,
+		}
+		if err := tx.Table("refunds").Create(&refund).Error; err != nil {
+			return fmt.Errorf("failed to create refund: %w", err)
+		}
+		return nil
+	})
+	return refunded, err
+}
+
+func (p *paymentRepo) GetPendingRefunds(ctx context.Context, limit int) ([]entities.Refund, error) {
+	var refunds []entities.Refund
+	err := p.db.WithContext(ctx).Table("refunds").
+		Where("status = ?", constants.RefundStatusPending).
+		Order("created_at").
+		Limit(limit).
+		Find(&refunds).Error
+	if err != nil {
+		return []entities.Refund{}, fmt.Errorf("error in GetPendingRefunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// CompleteRefund marks the refund as done and adds it to the refunded amount of the payment
+func (p *paymentRepo) CompleteRefund(ctx context.Context, refund entities.Refund, payment entities.Payment) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := completeRefund(tx, refund, payment)
+		return err
+	})
+}
+
+// completeRefund tells whether the refund was still pending and is completed now
+func completeRefund(tx *gorm.DB, refund entities.Refund, payment entities.Payment) (bool, error) {
+	now := time.Now()
+	res := tx.Table("refunds").
+		Where("id = ? AND status = ?", refund.ID, constants.RefundStatusPending).
+		Updates(map[string]interface{}{
+			"status":     constants.RefundStatusCompleted,
+			"payment_id": payment.ID,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"error":      "",
+			"updated_at": now,
+		})
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to complete refund: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	err := tx.Table("payments").
+		Where("id = ?", payment.ID).
+		Updates(map[string]interface{}{
+			"refunded_amount": gorm.Expr("refunded_amount + ?", refund.Amount),
+			"status": gorm.Expr("CASE WHEN refunded_amount + ? >= amount THEN ? ELSE status END",
+				refund.Amount, constants.PaymentStatusRefunded),
+			"updated_at": now,
+		}).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to update refunded amount: %w", err)
+	}
+	return true, nil
+}
+
+// FailRefundAttempt records why the refund did not go through, it is given up after MaxRefundAttempts
+func (p *paymentRepo) FailRefundAttempt(ctx context.Context, refund entities.Refund, reason string) error {
+	status := constants.RefundStatusPending
+	if refund.Attempts+1 >= constants.MaxRefundAttempts {
+		status = constants.RefundStatusFailed
+	}
+	err := p.db.WithContext(ctx).Table("refunds").
+		Where("id = ?", refund.ID).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"error":      reason,
+			"updated_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("error in FailRefundAttempt: %w", err)
+	}
+	return nil
+}
+
+// UpdateRefundStatus moves the refund from one status to another, e.g. to manual when the provider can not refund it
+func (p *paymentRepo) UpdateRefundStatus(ctx context.Context, id, fromStatus, toStatus, reason string) error {
+	err := p.db.WithContext(ctx).Table("refunds").
+		Where("id = ? AND status = ?", id, fromStatus).
+		Updates(map[string]interface{}{
+			"status":     toStatus,
+			"error":      reason,
+			"updated_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("error in UpdateRefundStatus: %w", err)
+	}
+	return nil
+}
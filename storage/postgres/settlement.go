@@ -0,0 +1,312 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type settlementRepo struct {
+	db *gorm.DB
+}
+
+func NewSettlement(db *gorm.DB) *settlementRepo {
+	return &settlementRepo{db: db}
+}
+
+func (s *settlementRepo) CreateCommissionRule(ctx context.Context, rule entities.CommissionRule) error {
+	err := s.db.WithContext(ctx).Table("commission_rules").Create(&rule).Error
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+			return e.ErrCommissionRuleExists
+		}
+		return fmt.Errorf("error in CreateCommissionRule: %w", err)
+	}
+	return nil
+}
+
+func (s *settlementRepo) GetCommissionRules(ctx context.Context) ([]entities.CommissionRule, error) {
+	var rules []entities.CommissionRule
+	err := s.db.WithContext(ctx).Table("commission_rules").
+		Where("state = ?", constants.Active).
+		Order("created_at").
+		Find(&rules).Error
+	if err != nil {
+		return []entities.CommissionRule{}, fmt.Errorf("error in GetCommissionRules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *settlementRepo) UpdateCommissionRule(ctx context.Context, rule entities.CommissionRule) error {
+	res := s.db.WithContext(ctx).Table("commission_rules").
+		Where("id = ? AND state = ?", rule.ID, constants.Active).
+		Updates(map[string]interface{}{
+			"xozmak_id":    rule.XozmakID,
+			"category_id":  rule.CategoryID,
+			"type":         rule.Type,
+			"percent":      rule.Percent,
+			"fixed_amount": rule.FixedAmount,
+			"tiers":        rule.Tiers,
+			"updated_at":   time.Now(),
+		})
+	if res.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(res.Error, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+			return e.ErrCommissionRuleExists
+		}
+		return fmt.Errorf("error in UpdateCommissionRule: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrCommissionRuleNotFound
+	}
+	return nil
+}
+
+func (s *settlementRepo) DeleteCommissionRule(ctx context.Context, id string) error {
+	res := s.db.WithContext(ctx).Table("commission_rules").
+		Where("id = ? AND state = ?", id, constants.Active).
+		Updates(map[string]interface{}{
+			"state":      constants.InActive,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return fmt.Errorf("error in DeleteCommissionRule: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return e.ErrCommissionRuleNotFound
+	}
+	return nil
+}
+
+// GetProductCategories returns the category of every product that has one
+func (s *settlementRepo) GetProductCategories(ctx context.Context, productIds []string) (map[string]string, error) {
+	categories := make(map[string]string, len(productIds))
+	if len(productIds) == 0 {
+		return categories, nil
+	}
+	var rows []struct {
+		ProductID  string `gorm:"column:product_id"`
+		CategoryID string `gorm:"column:category_id"`
+	}
+	err := s.db.WithContext(ctx).Table("products p").
+		Joins("JOIN sub_category sc ON sc.id = p.sub_category_id").
+		Where("p.id IN ? AND sc.category_id IS NOT NULL", productIds).
+		Select("p.id AS product_id, sc.category_id").
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetProductCategories: %w", err)
+	}
+	for _, row := range rows {
+		categories[row.ProductID] = row.CategoryID
+	}
+	return categories, nil
+}
+
+// RecordOrderCommission saves the commission of a delivered order, an order is recorded only once
+func (s *settlementRepo) RecordOrderCommission(ctx context.Context, commission entities.OrderCommission) error {
+	err := s.db.WithContext(ctx).Table("order_commissions").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&commission).Error
+	if err != nil {
+		return fmt.Errorf("error in RecordOrderCommission: %w", err)
+	}
+	return nil
+}
+
+// GetUncommissionedOrderIDs returns orders delivered before the time that have no commission recorded
+func (s *settlementRepo) GetUncommissionedOrderIDs(ctx context.Context, before time.Time) ([]string, error) {
+	var ids []string
+	err := s.db.WithContext(ctx).Table("orders o").
+		Where("o.status = ? AND o.delivered_at < ?", constants.OrderStatusDelivered, before).
+		Where("NOT EXISTS (SELECT 1 FROM order_commissions oc WHERE oc.order_id = o.id)").
+		Order("o.delivered_at").
+		Pluck("o.id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetUncommissionedOrderIDs: %w", err)
+	}
+	return ids, nil
+}
+
+// unsettledRefunds are refunds of the xozmak's delivered orders made before the period end
+// that no settlement has deducted yet: completed refunds and refunds to the wallet given by admins.
+// Refunds of tips are not the xozmak's money.
+const unsettledRefunds = `
+SELECT r.id AS source_id, r.order_id, r.amount FROM refunds r
+JOIN order_commissions oc ON oc.order_id = r.order_id
+WHERE oc.xozmak_id = @xozmak AND r.status = @completed AND r.updated_at < @end
+AND NOT EXISTS (SELECT 1 FROM settlement_refunds sr WHERE sr.source_id = r.id)
+AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.id = r.payment_id AND p.purpose = @tip)
+UNION ALL
+SELECT w.id AS source_id, w.order_id, -w.amount AS amount FROM wallet_transactions w
+JOIN order_commissions oc ON oc.order_id = w.order_id
+WHERE oc.xozmak_id = @xozmak AND w.kind = @refund AND w.refund_id IS NULL AND w.created_at < @end
+AND NOT EXISTS (SELECT 1 FROM settlement_refunds sr WHERE sr.source_id = w.id)`
+
+// CreateSettlements settles the commissions of all orders delivered before the period end,
+// one settlement per xozmak with the refunds made meanwhile
+func (s *settlementRepo) CreateSettlements(ctx context.Context, periodEnd time.Time, createdBy *string) ([]entities.Settlement, error) {
+	var settlements []entities.Settlement
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// locks the unsettled orders so that two runs never settle the same order
+		var locked []string
+		err := tx.Table("order_commissions").
+			Where("settlement_id IS NULL AND created_at < ?", periodEnd).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("order_id", &locked).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock unsettled orders: %w", err)
+		}
+		if len(locked) == 0 {
+			return e.ErrNothingToSettle
+		}
+
+		err = tx.Table("order_commissions").
+			Where("order_id IN ?", locked).
+			Group("xozmak_id").
+			Select("xozmak_id, COUNT(*) AS orders, SUM(gross) AS gross, SUM(commission) AS commission").
+			Find(&settlements).Error
+		if err != nil {
+			return fmt.Errorf("failed to sum unsettled orders: %w", err)
+		}
+
+		now := time.Now()
+		for i := range settlements {
+			settlement := &settlements[i]
+			var refunds []entities.SettlementRefund
+			err := tx.Raw(unsettledRefunds, map[string]interface{}{
+				"xozmak":    settlement.XozmakID,
+				"completed": constants.RefundStatusCompleted,
+				"refund":    constants.WalletKindRefund,
+				"tip":       constants.PaymentPurposeTip,
+				"end":       periodEnd,
+			}).Scan(&refunds).Error
+			if err != nil {
+				return fmt.Errorf("failed to find unsettled refunds: %w", err)
+			}
+
+			settlement.ID = uuid.NewString()
+			settlement.PeriodEnd = periodEnd
+			settlement.Status = constants.SettlementStatusPending
+			settlement.CreatedBy = createdBy
+			settlement.CreatedAt = now
+			for _, refund := range refunds {
+				settlement.Refunds += refund.Amount
+			}
+			settlement.NetPayable = settlement.Gross - settlement.Commission - settlement.Refunds
+			if err := tx.Table("settlements").Create(settlement).Error; err != nil {
+				return fmt.Errorf("failed to create settlement: %w", err)
+			}
+
+			err = tx.Table("order_commissions").
+				Where("order_id IN ? AND xozmak_id = ?", locked, settlement.XozmakID).
+				Update("settlement_id", settlement.ID).Error
+			if err != nil {
+				return fmt.Errorf("failed to mark orders as settled: %w", err)
+			}
+			for j := range refunds {
+				refunds[j].SettlementID = settlement.ID
+				refunds[j].CreatedAt = now
+			}
+			if len(refunds) > 0 {
+				if err := tx.Table("settlement_refunds").Create(&refunds).Error; err != nil {
+					return fmt.Errorf("failed to create settlement refunds: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []entities.Settlement{}, err
+	}
+	return settlements, nil
+}
+
+func (s *settlementRepo) GetSettlements(ctx context.Context, filter entities.SettlementFilter) ([]entities.Settlement, int64, error) {
+	var (
+		settlements []entities.Settlement
+		count       int64
+	)
+	query := s.db.WithContext(ctx).Table("settlements s").
+		Joins("JOIN xozmaks x ON x.id = s.xozmak_id")
+	if filter.XozmakID != "" {
+		query = query.Where("s.xozmak_id = ?", filter.XozmakID)
+	}
+	if filter.Status != "" {
+		query = query.Where("s.status = ?", filter.Status)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.Settlement{}, 0, fmt.Errorf("error in GetSettlements: %w", err)
+	}
+	err := query.Select("s.*, x.name AS xozmak_name").
+		Order("s.created_at DESC").
+		Limit(filter.Limit).Offset(filter.Offset).
+		Find(&settlements).Error
+	if err != nil {
+		return []entities.Settlement{}, 0, fmt.Errorf("error in GetSettlements: %w", err)
+	}
+	return settlements, count, nil
+}
+
+func (s *settlementRepo) GetSettlement(ctx context.Context, id string) (entities.Settlement, error) {
+	var settlement entities.Settlement
+	err := s.db.WithContext(ctx).Table("settlements s").
+		Joins("JOIN xozmaks x ON x.id = s.xozmak_id").
+		Where("s.id = ?", id).
+		Select("s.*, x.name AS xozmak_name").
+		Take(&settlement).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.Settlement{}, e.ErrSettlementNotFound
+		}
+		return entities.Settlement{}, fmt.Errorf("error in GetSettlement: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Table("order_commissions oc").
+		Joins("JOIN orders o ON o.id = oc.order_id").
+		Where("oc.settlement_id = ?", id).
+		Select("oc.*, o.number").
+		Order("oc.created_at").
+		Find(&settlement.OrderLines).Error
+	if err != nil {
+		return entities.Settlement{}, fmt.Errorf("error in GetSettlement: %w", err)
+	}
+	err = s.db.WithContext(ctx).Table("settlement_refunds").
+		Where("settlement_id = ?", id).
+		Order("created_at").
+		Find(&settlement.RefundLines).Error
+	if err != nil {
+		return entities.Settlement{}, fmt.Errorf("error in GetSettlement: %w", err)
+	}
+	return settlement, nil
+}
+
+// MarkSettlementSettled records that the xozmak was paid the net payable of the settlement
+func (s *settlementRepo) MarkSettlementSettled(ctx context.Context, id, settledBy string) error {
+	res := s.db.WithContext(ctx).Table("settlements").
+		Where("id = ? AND status = ?", id, constants.SettlementStatusPending).
+		Updates(map[string]interface{}{
+			"status":     constants.SettlementStatusSettled,
+			"settled_by": settledBy,
+			"settled_at": time.Now(),
+		})
+	if res.Error != nil {
+		return fmt.Errorf("error in MarkSettlementSettled: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		if _, err := s.GetSettlement(ctx, id); err != nil {
+			return err
+		}
+		return e.ErrSettlementAlreadySettled
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+type walletRepo struct {
+	db *gorm.DB
+}
+
+func NewWallet(db *gorm.DB) *walletRepo {
+	return &walletRepo{db: db}
+}
+
+func (w *walletRepo) PostWalletTransaction(ctx context.Context, transaction entities.WalletTransaction) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return postWalletTransaction(tx, transaction)
+	})
+}
+
+// postWalletTransaction saves the transaction with its entries and moves the balances of
+// the wallets in it. The balance is changed with a guarded update, so concurrent debits
+// wait for each other and the one that would take the wallet below zero fails.
+func postWalletTransaction(tx *gorm.DB, transaction entities.WalletTransaction) error {
+	if !transaction.Balanced() {
+		return e.ErrUnbalancedTransaction
+	}
+	if !entities.ValidTransactionType(transaction.Type) {
+		return e.ErrInvalidTransactionType
+	}
+	if err := tx.Table("wallet_transactions").Create(&transaction).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == constants.PGUniqueKeyViolationCode {
+			return e.ErrWalletTransactionExists
+		}
+		return fmt.Errorf("failed to create wallet transaction: %w", err)
+	}
+	for i := range transaction.Entries {
+		transaction.Entries[i].TransactionID = transaction.ID
+		transaction.Entries[i].CreatedAt = transaction.CreatedAt
+	}
+	if err := tx.Table("wallet_entries").Create(&transaction.Entries).Error; err != nil {
+		return fmt.Errorf("failed to create wallet entries: %w", err)
+	}
+
+	for _, entry := range transaction.Entries {
+		userId, ok := strings.CutPrefix(entry.Account, constants.LedgerAccountWalletPrefix)
+		if !ok {
+			continue
+		}
+		err := tx.Exec("INSERT INTO wallets (user_id) VALUES (?) ON CONFLICT DO NOTHING", userId).Error
+		if err != nil {
+			return fmt.Errorf("failed to create wallet: %w", err)
+		}
+		// a credit of the wallet account raises the balance
+		res := tx.Table("wallets").
+			Where("user_id = ? AND balance - ? >= 0", userId, entry.Amount).
+			Updates(map[string]interface{}{
+				"balance":    gorm.Expr("balance - ?", entry.Amount),
+				"updated_at": time.Now(),
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrInsufficientFunds
+		}
+	}
+	return nil
+}
+
+// GetWalletBalance returns what the platform owes the customer, zero when there is no wallet yet
+func (w *walletRepo) GetWalletBalance(ctx context.Context, userId string) (int64, error) {
+	var balance int64
+	err := w.db.WithContext(ctx).Table("wallets").
+		Where("user_id = ?", userId).
+		Select("COALESCE(SUM(balance), 0)").
+		Scan(&balance).Error
+	if err != nil {
+		return 0, fmt.Errorf("error in GetWalletBalance: %w", err)
+	}
+	return balance, nil
+}
+
+// GetWalletTransactions lists the customer's transactions from the latest one, of one type when txType is set
+func (w *walletRepo) GetWalletTransactions(ctx context.Context, userId string, txType *int, limit, offset int) ([]entities.WalletTransaction, int64, error) {
+	var (
+		transactions []entities.WalletTransaction
+		count        int64
+	)
+	query := w.db.WithContext(ctx).Table("wallet_transactions").Where("user_id = ?", userId)
+	if txType != nil {
+		query = query.Where("type = ?", *txType)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return []entities.WalletTransaction{}, 0, fmt.Errorf("error in GetWalletTransactions: %w", err)
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&transactions).Error
+	if err != nil {
+		return []entities.WalletTransaction{}, 0, fmt.Errorf("error in GetWalletTransactions: %w", err)
+	}
+	return transactions, count, nil
+}
+
+func (w *walletRepo) GetOrderWalletTransaction(ctx context.Context, orderId, kind string) (entities.WalletTransaction, error) {
+	var transaction entities.WalletTransaction
+	err := w.db.WithContext(ctx).Table("wallet_transactions").
+		Where("order_id = ? AND kind = ?", orderId, kind).
+		Take(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entities.WalletTransaction{}, e.ErrWalletTransactionNotFound
+		}
+		return entities.WalletTransaction{}, fmt.Errorf("error in GetOrderWalletTransaction: %w", err)
+	}
+	return transaction, nil
+}
+
+// GetOrderIDsWithoutCashback returns orders delivered after the time with at least minItemsTotal
+// worth of items that got no cashback
+func (w *walletRepo) GetOrderIDsWithoutCashback(ctx context.Context, after time.Time, minItemsTotal int64, limit int) ([]string, error) {
+	var ids []string
+	err := w.db.WithContext(ctx).Table("orders o").
+		Where("o.status = ? AND o.items_total >= ? AND o.delivered_at > ?", constants.OrderStatusDelivered, minItemsTotal, after).
+		Where("NOT EXISTS (SELECT 1 FROM wallet_transactions t WHERE t.order_id = o.id AND t.kind = ?)", constants.WalletKindCashback).
+		Order("o.delivered_at").
+		Limit(limit).
+		Pluck("o.id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("error in GetOrderIDsWithoutCashback: %w", err)
+	}
+	return ids, nil
+}
+
+// PayOrderFromWallet spends the money from the wallet and passes the order on to the seller
+// at once. Nothing is spent when the order is not waiting for the payment.
+func (w *walletRepo) PayOrderFromWallet(ctx context.Context, transaction entities.WalletTransaction, payment entities.Payment, history entities.OrderHistory) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Table("orders").
+			Where("id = ? AND status = ? AND paid_at IS NULL", payment.OrderID, constants.OrderStatusAwaitingPayment).
+			Updates(map[string]interface{}{
+				"status":     constants.OrderStatusNew,
+				"paid_at":    now,
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to mark order as paid: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return e.ErrOrderNotAwaitingPayment
+		}
+
+		payment.Status = constants.PaymentStatusPaid
+		payment.PaidAt = &now
+		if err := tx.Table("payments").Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		if err := postWalletTransaction(tx, transaction); err != nil {
+			return err
+		}
+		if err := tx.Table("order_history").Create(&history).Error; err != nil {
+			return fmt.Errorf("failed to create order history: %w", err)
+		}
+		return nil
+	})
+}
+
+// PayTipFromWallet spends the tip from the wallet and gives it to the courier at once
+func (w *walletRepo) PayTipFromWallet(ctx context.Context, transaction entities.WalletTransaction, payment entities.Payment, tip entities.LedgerTransaction) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("payments").Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		if err := postWalletTransaction(tx, transaction); err != nil {
+			if errors.Is(err, e.ErrWalletTransactionExists) {
+				return e.ErrOrderAlreadyTipped
+			}
+			return err
+		}
+		if err := postTransaction(tx, tip); err != nil {
+			if errors.Is(err, e.ErrEarningAlreadyPosted) {
+				return e.ErrOrderAlreadyTipped
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// RefundToWallet puts the refund of an order paid from the wallet back into it
+func (w *walletRepo) RefundToWallet(ctx context.Context, transaction entities.WalletTransaction, refund entities.Refund, payment entities.Payment) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		completed, err := completeRefund(tx, refund, payment)
+		if err != nil || !completed {
+			return err
+		}
+		return postWalletTransaction(tx, transaction)
+	})
+}
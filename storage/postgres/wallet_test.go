@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"delivery/constants"
+	"delivery/entities"
+	e "delivery/errors"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the migrated database at TEST_POSTGRES_DSN, the ledger rows can not be
+// deleted so every test works with its own users and orders
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	m, err := migrate.New("file://../../db/migrations", dsn)
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("migrate: %v", err)
+	}
+	m.Close()
+
+	db, err := gorm.Open(gormpostgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB) string {
+	t.Helper()
+
+	id := uuid.NewString()
+	err := db.Exec("INSERT INTO users (id, phone_number) VALUES (?, ?)", id, fmt.Sprintf("+998%09d", rand.Intn(1e9))).Error
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return id
+}
+
+func createOrder(t *testing.T, db *gorm.DB, userId string, total int64) string {
+	t.Helper()
+
+	xozmakId := uuid.NewString()
+	if err := db.Exec("INSERT INTO xozmaks (id, name) VALUES (?, 'test')", xozmakId).Error; err != nil {
+		t.Fatalf("create xozmak: %v", err)
+	}
+	id := uuid.NewString()
+	err := db.Exec(`INSERT INTO orders (id, user_id, xozmak_id, address_location, items_total, total, payment_method)
+		VALUES (?, ?, ?, '{"lat": 41.3, "long": 69.2}', ?, ?, ?)`, id, userId, xozmakId, total, total, constants.PaymentMethodWallet).Error
+	if err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return id
+}
+
+func TestConcurrentDebitsKeepBalance(t *testing.T) {
+	db := testDB(t)
+	repo := NewWallet(db)
+	ctx := context.Background()
+	userId := createUser(t, db)
+
+	err := repo.PostWalletTransaction(ctx, entities.WalletCredit(uuid.NewString(), constants.WalletKindTopUp, userId, nil,
+		10000, constants.LedgerAccountWalletTopUp, "top up"))
+	if err != nil {
+		t.Fatalf("credit: %v", err)
+	}
+
+	// twenty debits of 1000 race for a balance of 10000
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		spent, short int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.PostWalletTransaction(ctx, entities.WalletDebit(uuid.NewString(), constants.WalletKindTip, userId, nil,
+				1000, constants.LedgerAccountTipsHeld, "tip"))
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				spent++
+			case errors.Is(err, e.ErrInsufficientFunds):
+				short++
+			default:
+				t.Errorf("debit: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if spent != 10 || short != 10 {
+		t.Fatalf("spent %d times and refused %d times, want 10 and 10", spent, short)
+	}
+	balance, err := repo.GetWalletBalance(ctx, userId)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance != 0 {
+		t.Fatalf("balance is %d, want 0", balance)
+	}
+
+	var entries int64
+	err = db.Table("wallet_entries").Where("account = ?", constants.LedgerAccountWalletPrefix+userId).
+		Select("COALESCE(-SUM(amount), 0)").Scan(&entries).Error
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if entries != balance {
+		t.Fatalf("the entries sum to %d but the balance is %d", entries, balance)
+	}
+}
+
+func TestRefundToWalletOncePerRefund(t *testing.T) {
+	db := testDB(t)
+	repo := NewWallet(db)
+	ctx := context.Background()
+	userId := createUser(t, db)
+	orderId := createOrder(t, db, userId, 5000)
+
+	now := time.Now()
+	pay := entities.Payment{
+		ID:        uuid.NewString(),
+		OrderID:   orderId,
+		UserID:    userId,
+		Purpose:   constants.PaymentPurposeOrder,
+		Provider:  constants.PaymentProviderWallet,
+		Amount:    5000,
+		Status:    constants.PaymentStatusPaid,
+		CreatedAt: now,
+		UpdatedAt: now,
+		PaidAt:    &now,
+	}
+	pay.ProviderPaymentID = &pay.ID
+	if err := db.Table("payments").Create(&pay).Error; err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	refund := entities.Refund{
+		ID:            uuid.NewString(),
+		OrderID:       orderId,
+		Amount:        3000,
+		PaymentMethod: constants.PaymentMethodWallet,
+		Status:        constants.RefundStatusPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := db.Table("refunds").Create(&refund).Error; err != nil {
+		t.Fatalf("create refund: %v", err)
+	}
+
+	credit := func() entities.WalletTransaction {
+		transaction := entities.WalletCredit(uuid.NewString(), constants.WalletKindRefund, userId, &orderId,
+			refund.Amount, constants.LedgerAccountOrderRevenue, "order refund")
+		transaction.RefundID = &refund.ID
+		return transaction
+	}
+
+	// the refund worker may pick the same refund again after a restart
+	for i := 0; i < 3; i++ {
+		if err := repo.RefundToWallet(ctx, credit(), refund, pay); err != nil {
+			t.Fatalf("refund %d: %v", i, err)
+		}
+	}
+	// a credit with the refund id made in any other way is refused
+	if err := repo.PostWalletTransaction(ctx, credit()); !errors.Is(err, e.ErrWalletTransactionExists) {
+		t.Fatalf("second credit of the refund: %v, want ErrWalletTransactionExists", err)
+	}
+
+	balance, err := repo.GetWalletBalance(ctx, userId)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance != refund.Amount {
+		t.Fatalf("balance is %d, want %d", balance, refund.Amount)
+	}
+	var refunded int64
+	if err := db.Table("payments").Where("id = ?", pay.ID).Pluck("refunded_amount", &refunded).Error; err != nil {
+		t.Fatalf("payment: %v", err)
+	}
+	if refunded != refund.Amount {
+		t.Fatalf("payment refunded amount is %d, want %d", refunded, refund.Amount)
+	}
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"delivery/entities"
+	"time"
 )
 
 // IAdminStorage account storage interface
@@ -24,4 +25,152 @@ type IAdminStorage interface {
 	GetSubCategory(ctx context.Context)([]entities.SubCategory, error)
 	UpdateSubCategory(ctx context.Context, req entities.SubCategory) error
 	DeleteSubCategory(ctx context.Context, sub_categoryId string) error
+	CreateProduct(ctx context.Context, req entities.Product) error
+	GetProducts(ctx context.Context, xozmakId string) ([]entities.Product, error)
+	UpdateProduct(ctx context.Context, req entities.Product) error
+	DeleteProduct(ctx context.Context, id string) error
+	GetUserByPhone(ctx context.Context, phoneNumber string) (entities.UserAccount, error)
+}
+
+// IOrderStorage order storage interface
+type IOrderStorage interface {
+	CreateSlot(ctx context.Context, req entities.DeliverySlot) error
+	GetSlots(ctx context.Context, xozmakId string, from, to time.Time) ([]entities.DeliverySlot, error)
+	UpdateSlot(ctx context.Context, req entities.DeliverySlot) error
+	DeleteSlot(ctx context.Context, id string) error
+	GetSlot(ctx context.Context, id string) (entities.DeliverySlot, error)
+	GetXozmakByID(ctx context.Context, id string) (entities.Xozmak, error)
+	GetUserLocationByID(ctx context.Context, userId, id string) (entities.UserLocation, error)
+	GetProductsByIDs(ctx context.Context, ids []string) ([]entities.Product, error)
+	CreateOrder(ctx context.Context, order entities.Order, slotStartsAfter time.Time) (entities.Order, error)
+	GetUserOrders(ctx context.Context, userId string, limit, offset int) ([]entities.Order, int64, error)
+	GetOrder(ctx context.Context, id string) (entities.Order, error)
+	GetUserXozmakID(ctx context.Context, userId string) (string, error)
+	CancelOrder(ctx context.Context, req entities.OrderCancellation) error
+	InsertOrderHistory(ctx context.Context, req entities.OrderHistory) error
+	GetOrderHistory(ctx context.Context, orderId string) ([]entities.OrderHistory, error)
+	GetXozmakOrders(ctx context.Context, xozmakId string, statuses []string, limit, offset int) ([]entities.Order, int64, error)
+	GetOrdersWithAction(ctx context.Context, status, action string, limit, offset int) ([]entities.Order, int64, error)
+	UpdateOrderStatus(ctx context.Context, req entities.OrderStatusChange) error
+	GetExpiredNewOrders(ctx context.Context, createdBefore time.Time, limit int) ([]entities.Order, error)
+	GetUnpaidOrders(ctx context.Context, createdBefore time.Time, limit int) ([]entities.Order, error)
+	GetOrderTrack(ctx context.Context, orderId string) ([]entities.OrderTrackPoint, error)
+	AssignCourier(ctx context.Context, req entities.CourierAssignment) error
+	AssignBatch(ctx context.Context, req entities.BatchAssignment) ([]string, error)
+	GetWaitingOrders(ctx context.Context, statuses []string, limit int) ([]entities.WaitingOrder, error)
+	GetBatchOrders(ctx context.Context, batchId string) ([]entities.Order, error)
+	CreateZone(ctx context.Context, req entities.DeliveryZone) error
+	GetZones(ctx context.Context, xozmakId string) ([]entities.DeliveryZone, error)
+	GetZonesAround(ctx context.Context, lat, long float64) ([]entities.DeliveryZone, error)
+	HasZones(ctx context.Context) (bool, error)
+	UpdateZone(ctx context.Context, req entities.DeliveryZone) error
+	DeleteZone(ctx context.Context, id string) error
+	GetDeliveryProof(ctx context.Context, orderId string) (entities.DeliveryProof, error)
+	GetWorkingHours(ctx context.Context, xozmakIds []string) ([]entities.WorkingHours, error)
+	ReplaceWorkingHours(ctx context.Context, xozmakId string, hours []entities.WorkingHours) error
+	GetHoursExceptions(ctx context.Context, xozmakIds []string, from, to string) ([]entities.HoursException, error)
+	CreateHoursException(ctx context.Context, req entities.HoursException) error
+	DeleteHoursException(ctx context.Context, id string) error
+	UpdateXozmakOrdering(ctx context.Context, req entities.XozmakOrderingReq) error
+}
+
+// INotificationStorage notification storage interface
+type INotificationStorage interface {
+	CreateNotification(ctx context.Context, req entities.Notification) error
+	GetUserNotifications(ctx context.Context, userId string, limit, offset int) ([]entities.Notification, error)
+	GetFcmToken(ctx context.Context, userId string) (string, error)
+	UpdateFcmToken(ctx context.Context, userId, token string) error
+	ClearFcmToken(ctx context.Context, userId, token string) error
+	GetUserLanguage(ctx context.Context, userId string) (string, error)
+	GetTemplates(ctx context.Context) ([]entities.NotificationTemplate, error)
+	GetTemplate(ctx context.Context, key, language string) (entities.NotificationTemplate, error)
+	SaveTemplate(ctx context.Context, req entities.NotificationTemplate) error
+	DeleteTemplate(ctx context.Context, key, language string) error
+}
+
+// ICourierStorage courier storage interface
+type ICourierStorage interface {
+	CreateCourier(ctx context.Context, req entities.CourierReq) error
+	GetCouriers(ctx context.Context, status string, limit, offset int) ([]entities.Courier, int64, error)
+	GetCourier(ctx context.Context, id string) (entities.Courier, error)
+	UpdateCourier(ctx context.Context, req entities.CourierReq) error
+	DeleteCourier(ctx context.Context, id string) error
+	SetCourierStatus(ctx context.Context, id, status, reason string) error
+	StartShift(ctx context.Context, courierId string) (entities.CourierShift, error)
+	EndShift(ctx context.Context, courierId string) (entities.CourierShift, error)
+	GetCourierShifts(ctx context.Context, courierId string, limit, offset int) ([]entities.CourierShift, error)
+	GetCourierOrders(ctx context.Context, courierId string, statuses []string) ([]entities.Order, error)
+	InsertTrackPoints(ctx context.Context, points []entities.OrderTrackPoint) error
+	GetDispatchCandidates(ctx context.Context, courierIds []string, activeStatuses []string) ([]entities.DispatchCandidate, error)
+}
+
+// ILedgerStorage courier ledger storage interface
+type ILedgerStorage interface {
+	PostTransactions(ctx context.Context, transactions []entities.LedgerTransaction) error
+	GetCourierTransactions(ctx context.Context, courierId string, from, to time.Time) ([]entities.LedgerTransaction, error)
+	GetCourierBalance(ctx context.Context, courierId string) (int64, error)
+	GetOrderIDsWithoutEarning(ctx context.Context, after time.Time, limit int) ([]string, error)
+	CreatePayoutBatch(ctx context.Context, batch entities.PayoutBatch) (entities.PayoutBatch, error)
+	GetPayoutBatches(ctx context.Context, limit, offset int) ([]entities.PayoutBatch, error)
+	GetPayoutBatch(ctx context.Context, id string) (entities.PayoutBatch, error)
+	RecordCashCollection(ctx context.Context, collection entities.CashCollection) error
+	GetUncollectedCashOrderIDs(ctx context.Context, after time.Time, limit int) ([]string, error)
+	CreateCashHandover(ctx context.Context, handover entities.CashHandover) error
+	GetCashCollections(ctx context.Context, courierId string, from, to time.Time) ([]entities.CashCollection, error)
+	GetCashHandovers(ctx context.Context, courierId string, from, to time.Time) ([]entities.CashHandover, error)
+}
+
+// IPaymentStorage online payment storage interface
+type IPaymentStorage interface {
+	CreatePayment(ctx context.Context, payment entities.Payment) error
+	GetPayment(ctx context.Context, id string) (entities.Payment, error)
+	GetOrderPayments(ctx context.Context, orderId string) ([]entities.Payment, error)
+	GetPaidPayment(ctx context.Context, orderId string) (entities.Payment, error)
+	GetTipPayment(ctx context.Context, orderId string) (entities.Payment, error)
+	GetPendingPayments(ctx context.Context, limit int) ([]entities.Payment, error)
+	UpdatePaymentStatus(ctx context.Context, id, fromStatus, toStatus string, fields map[string]interface{}) error
+	ConfirmPayment(ctx context.Context, payment entities.Payment, history entities.OrderHistory) (bool, error)
+	ConfirmTipPayment(ctx context.Context, payment entities.Payment, tip entities.LedgerTransaction) (bool, error)
+	GetPendingRefunds(ctx context.Context, limit int) ([]entities.Refund, error)
+	CompleteRefund(ctx context.Context, refund entities.Refund, payment entities.Payment) error
+	FailRefundAttempt(ctx context.Context, refund entities.Refund, reason string) error
+	UpdateRefundStatus(ctx context.Context, id, fromStatus, toStatus, reason string) error
+	GetPaymeTransaction(ctx context.Context, id string) (entities.PaymeTransaction, error)
+	GetActivePaymeTransaction(ctx context.Context, orderId string) (entities.PaymeTransaction, error)
+	GetPaymeTransactions(ctx context.Context, from, to int64) ([]entities.PaymeTransaction, error)
+	CreatePaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, payment entities.Payment) error
+	PerformPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, history entities.OrderHistory) error
+	CancelPaymeTransaction(ctx context.Context, transaction entities.PaymeTransaction, fromState int) error
+	GetClickTransaction(ctx context.Context, id int64) (entities.ClickTransaction, error)
+	GetClickTransactionByClickID(ctx context.Context, clickTransId int64) (entities.ClickTransaction, error)
+	CreateClickTransaction(ctx context.Context, transaction entities.ClickTransaction, payment entities.Payment) (entities.ClickTransaction, error)
+	CompleteClickTransaction(ctx context.Context, transaction entities.ClickTransaction, history entities.OrderHistory) error
+	CancelClickTransaction(ctx context.Context, transaction entities.ClickTransaction, reason string) error
+}
+
+// IWalletStorage customer wallet storage interface
+type IWalletStorage interface {
+	PostWalletTransaction(ctx context.Context, transaction entities.WalletTransaction) error
+	GetWalletBalance(ctx context.Context, userId string) (int64, error)
+	GetWalletTransactions(ctx context.Context, userId string, txType *int, limit, offset int) ([]entities.WalletTransaction, int64, error)
+	GetOrderWalletTransaction(ctx context.Context, orderId, kind string) (entities.WalletTransaction, error)
+	GetOrderIDsWithoutCashback(ctx context.Context, after time.Time, minItemsTotal int64, limit int) ([]string, error)
+	PayOrderFromWallet(ctx context.Context, transaction entities.WalletTransaction, payment entities.Payment, history entities.OrderHistory) error
+	PayTipFromWallet(ctx context.Context, transaction entities.WalletTransaction, payment entities.Payment, tip entities.LedgerTransaction) error
+	RefundToWallet(ctx context.Context, transaction entities.WalletTransaction, refund entities.Refund, payment entities.Payment) error
+}
+
+// ISettlementStorage commission and xozmak settlement storage interface
+type ISettlementStorage interface {
+	CreateCommissionRule(ctx context.Context, rule entities.CommissionRule) error
+	GetCommissionRules(ctx context.Context) ([]entities.CommissionRule, error)
+	UpdateCommissionRule(ctx context.Context, rule entities.CommissionRule) error
+	DeleteCommissionRule(ctx context.Context, id string) error
+	GetProductCategories(ctx context.Context, productIds []string) (map[string]string, error)
+	RecordOrderCommission(ctx context.Context, commission entities.OrderCommission) error
+	GetUncommissionedOrderIDs(ctx context.Context, before time.Time) ([]string, error)
+	CreateSettlements(ctx context.Context, periodEnd time.Time, createdBy *string) ([]entities.Settlement, error)
+	GetSettlements(ctx context.Context, filter entities.SettlementFilter) ([]entities.Settlement, int64, error)
+	GetSettlement(ctx context.Context, id string) (entities.Settlement, error)
+	MarkSettlementSettled(ctx context.Context, id, settledBy string) error
 }
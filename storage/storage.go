@@ -10,10 +10,24 @@ import (
 
 type Storage interface {
 	Admin() repo.IAdminStorage
+	Order() repo.IOrderStorage
+	Notification() repo.INotificationStorage
+	Courier() repo.ICourierStorage
+	Ledger() repo.ILedgerStorage
+	Payment() repo.IPaymentStorage
+	Wallet() repo.IWalletStorage
+	Settlement() repo.ISettlementStorage
 }
 
 type storage struct {
-	adminRepo repo.IAdminStorage
+	adminRepo        repo.IAdminStorage
+	orderRepo        repo.IOrderStorage
+	notificationRepo repo.INotificationStorage
+	courierRepo      repo.ICourierStorage
+	ledgerRepo       repo.ILedgerStorage
+	paymentRepo      repo.IPaymentStorage
+	walletRepo       repo.IWalletStorage
+	settlementRepo   repo.ISettlementStorage
 }
 
 // New
@@ -23,7 +37,14 @@ func New(cfg *configs.Configuration) *storage {
 		log.Fatalf("error connecting to postgres database: %v", err)
 	}
 	return &storage{
-		adminRepo: postgres.NewAdmin(postgresDB),
+		adminRepo:        postgres.NewAdmin(postgresDB),
+		orderRepo:        postgres.NewOrder(postgresDB),
+		notificationRepo: postgres.NewNotification(postgresDB),
+		courierRepo:      postgres.NewCourier(postgresDB),
+		ledgerRepo:       postgres.NewLedger(postgresDB),
+		paymentRepo:      postgres.NewPayment(postgresDB),
+		walletRepo:       postgres.NewWallet(postgresDB),
+		settlementRepo:   postgres.NewSettlement(postgresDB),
 	}
 }
 
@@ -31,3 +52,38 @@ func New(cfg *configs.Configuration) *storage {
 func (s storage) Admin() repo.IAdminStorage {
 	return s.adminRepo
 }
+
+// Order returns order repository
+func (s storage) Order() repo.IOrderStorage {
+	return s.orderRepo
+}
+
+// Notification returns notification repository
+func (s storage) Notification() repo.INotificationStorage {
+	return s.notificationRepo
+}
+
+// Courier returns courier repository
+func (s storage) Courier() repo.ICourierStorage {
+	return s.courierRepo
+}
+
+// Ledger returns courier ledger repository
+func (s storage) Ledger() repo.ILedgerStorage {
+	return s.ledgerRepo
+}
+
+// Payment returns online payment repository
+func (s storage) Payment() repo.IPaymentStorage {
+	return s.paymentRepo
+}
+
+// Wallet returns customer wallet repository
+func (s storage) Wallet() repo.IWalletStorage {
+	return s.walletRepo
+}
+
+// Settlement returns commission and xozmak settlement repository
+func (s storage) Settlement() repo.ISettlementStorage {
+	return s.settlementRepo
+}